@@ -0,0 +1,121 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	maxAttemptRecords = 32
+)
+
+// AttemptHistoryHeaders 记录到AttemptRecord中的响应头,敏感的头会被隐藏
+var AttemptHistoryHeaders = []string{"Retry-After", "X-Request-Id", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
+
+// AttemptHistoryBodyBytes 每次尝试最多记录的响应体字节数,默认不记录
+var AttemptHistoryBodyBytes = 0
+
+// AttemptRecord 一次尝试的结果
+type AttemptRecord struct {
+	Attempt    int
+	Start      time.Time
+	Duration   time.Duration
+	URL        string
+	Host       string
+	Outcome    Outcome
+	StatusCode int
+	Err        string
+	Header     http.Header
+	Body       []byte
+}
+
+// attemptObservation 由最内层的transport填充本次尝试收到的响应
+type attemptObservation struct {
+	sync.Mutex
+	url        string
+	host       string
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+type attemptObservationKey struct{}
+
+func attemptObservationFromContext(ctx context.Context) *attemptObservation {
+	observation, _ := ctx.Value(attemptObservationKey{}).(*attemptObservation)
+	return observation
+}
+
+// attemptHistoryTransport 在状态码检查之前记录响应
+func attemptHistoryTransport(next http.RoundTripper) http.RoundTripper {
+	return newNamedTransport(WrapperInfo{Name: "attempt_history"}, next, TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+		observation := attemptObservationFromContext(httpReq.Context())
+		if observation == nil {
+			return next.RoundTrip(httpReq)
+		}
+		observation.Lock()
+		observation.url = httpReq.URL.Redacted()
+		observation.host = httpReq.URL.Host
+		observation.Unlock()
+		httpResp, err := next.RoundTrip(httpReq)
+		if err != nil {
+			return nil, err
+		}
+		var body []byte
+		if AttemptHistoryBodyBytes > 0 && httpResp.Body != nil {
+			body, err = io.ReadAll(io.LimitReader(httpResp.Body, int64(AttemptHistoryBodyBytes)))
+			if err != nil {
+				httpResp.Body.Close()
+				return nil, err
+			}
+			httpResp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), httpResp.Body), Closer: httpResp.Body}
+		}
+		header := make(http.Header)
+		for _, key := range AttemptHistoryHeaders {
+			if values := httpResp.Header.Values(key); len(values) != 0 {
+				header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+			}
+		}
+		observation.Lock()
+		observation.statusCode = httpResp.StatusCode
+		observation.header = redactHeader(header)
+		observation.body = body
+		observation.Unlock()
+		return httpResp, nil
+	}))
+}
+
+// doAttempt 执行一次尝试,开启WithAttemptHistory时追加记录
+func (b *builder) doAttempt(ctx context.Context, client *http.Client, attempt int) error {
+	if b.history == nil {
+		return b.DoWithClient(ctx, client)
+	}
+	observation := &attemptObservation{}
+	start := time.Now()
+	err := b.DoWithClient(context.WithValue(ctx, attemptObservationKey{}, observation), client)
+	observation.Lock()
+	defer observation.Unlock()
+	record := AttemptRecord{
+		Attempt:    attempt,
+		Start:      start,
+		Duration:   time.Since(start),
+		URL:        observation.url,
+		Host:       observation.host,
+		Outcome:    OutcomeReceived,
+		StatusCode: observation.statusCode,
+		Header:     observation.header,
+		Body:       observation.body,
+	}
+	if err != nil {
+		record.Outcome = OutcomeFromError(err)
+		record.Err = err.Error()
+	}
+	if len(*b.history) < maxAttemptRecords {
+		*b.history = append(*b.history, record)
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestAttemptHistory(t *testing.T) {
+	var calls int32
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req")
+		w.Header().Set("Set-Cookie", "session=a")
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("busy"))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	AttemptHistoryHeaders = append(AttemptHistoryHeaders, "Set-Cookie")
+	defer func() { AttemptHistoryHeaders = AttemptHistoryHeaders[:len(AttemptHistoryHeaders)-1] }()
+
+	var history []AttemptRecord
+	b := Get(server.URL + "/items").
+		ResiliencePolicy(ResiliencePolicy{Retries: 3, RetryBackoff: PolicyDuration(time.Millisecond)}).
+		WithAttemptHistory(&history)
+	if err := b.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected records:3,got:%d", len(history))
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	for i, record := range history {
+		if record.Attempt != i || record.Host != host || record.URL != server.URL+"/items" || record.Start.IsZero() || record.Duration <= 0 {
+			t.Fatalf("unexpected record %d:%+v", i, record)
+		}
+		if record.Header.Get("X-Request-Id") != "req" || record.Header.Get("Set-Cookie") != redactedValue || record.Body != nil {
+			t.Fatalf("unexpected record %d header:%v,body:%q", i, record.Header, record.Body)
+		}
+	}
+	for _, record := range history[:2] {
+		if record.StatusCode != http.StatusServiceUnavailable || record.Outcome != OutcomeReceived ||
+			record.Header.Get("Retry-After") != "0" || !strings.Contains(record.Err, "got:503") {
+			t.Fatalf("unexpected failed record:%+v", record)
+		}
+	}
+	if last := history[2]; last.StatusCode != http.StatusOK || last.Err != "" || last.Header.Get("Retry-After") != "" {
+		t.Fatalf("unexpected final record:%+v", last)
+	}
+
+	// 再次Do时重置
+	if err := b.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("expected records:1,got:%d", len(history))
+	}
+}
+
+func TestAttemptHistoryBody(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"bad request"}`))
+	}))
+	AttemptHistoryBodyBytes = 6
+	defer func() { AttemptHistoryBodyBytes = 0 }()
+
+	var history []AttemptRecord
+	if err := Get(server.URL).WithAttemptHistory(&history).Do(context.Background()); err == nil {
+		t.Fatal("expected status error")
+	}
+	if len(history) != 1 || string(history[0].Body) != `{"erro` || history[0].StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected history:%+v", history)
+	}
+
+	result, _ := DoAllDetailed(context.Background(), []Builder{Get(server.URL)}, DoAllOptions{})
+	if len(result[0].Attempts) != 1 || result[0].Attempts[0].StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected attempts in result,got:%+v", result[0].Attempts)
+	}
+}
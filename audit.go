@@ -0,0 +1,244 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxAuditFields      = 16
+	maxAuditValueLength = 256
+	auditFieldPrefix    = "audit_"
+	redactedValue       = "***"
+)
+
+// sensitiveAuditKeys key中包含这些词时值会被隐藏
+var sensitiveAuditKeys = []string{"password", "secret", "token", "authorization", "cookie"}
+
+// AuditExtractor 从解码后的请求、响应以及错误中提取审计字段
+type AuditExtractor func(req any, resp any, err error) map[string]string
+
+// AuditRecord 一次变更请求的审计记录
+type AuditRecord struct {
+	Method     string
+	Route      string
+	StatusCode int
+	Fields     map[string]string
+	At         time.Time
+}
+
+// AuditSink 接收审计记录
+type AuditSink func(ctx context.Context, record AuditRecord)
+
+// HandlerOption Handler的可选配置
+type HandlerOption func(*handlerOptions)
+
+type handlerOptions struct {
+	auditExtractor AuditExtractor
+	errorMapper    ErrorMapper
+	envelope       *statusEnvelope
+}
+
+// WithAuditExtractor 为POST/PUT/PATCH/DELETE请求提取审计字段,写入AuditHandler的记录以及访问日志
+func WithAuditExtractor(extractor AuditExtractor) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.auditExtractor = extractor
+	}
+}
+
+// auditFields 在handler链中共享的审计字段,由外层的AuditHandler或LoggingHandler创建
+type auditFields struct {
+	sync.Mutex
+	fields map[string]string
+}
+
+type auditFieldsKey struct{}
+
+func withAuditFields(ctx context.Context) (context.Context, *auditFields) {
+	if fields := auditFieldsFromContext(ctx); fields != nil {
+		return ctx, fields
+	}
+	fields := &auditFields{fields: make(map[string]string)}
+	return context.WithValue(ctx, auditFieldsKey{}, fields), fields
+}
+
+func auditFieldsFromContext(ctx context.Context) *auditFields {
+	fields, _ := ctx.Value(auditFieldsKey{}).(*auditFields)
+	return fields
+}
+
+// merge 合并字段,超过maxAuditFields的部分丢弃,过长的值被截断,敏感的值被隐藏
+func (f *auditFields) merge(fields map[string]string) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	f.Lock()
+	defer f.Unlock()
+	for _, key := range keys {
+		if _, exist := f.fields[key]; !exist && len(f.fields) >= maxAuditFields {
+			continue
+		}
+		f.fields[key] = redactAuditValue(key, fields[key])
+	}
+}
+
+func (f *auditFields) snapshot() map[string]string {
+	f.Lock()
+	defer f.Unlock()
+	snapshot := make(map[string]string, len(f.fields))
+	for key, value := range f.fields {
+		snapshot[key] = value
+	}
+	return snapshot
+}
+
+func isSensitiveKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, sensitive := range sensitiveAuditKeys {
+		if strings.Contains(lower, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactAuditValue(key, value string) string {
+	if isSensitiveKey(key) {
+		return redactedValue
+	}
+	if len(value) > maxAuditValueLength {
+		value = value[:maxAuditValueLength]
+		for !utf8.ValidString(value) {
+			value = value[:len(value)-1]
+		}
+	}
+	return value
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// extractAudit 执行extractor,panic不影响请求处理
+func extractAudit(ctx context.Context, extractor AuditExtractor, req, resp any, err error) {
+	fields := auditFieldsFromContext(ctx)
+	if fields == nil {
+		return
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logWarn(ctx, "audit extractor panic", FieldErr, fmt.Sprint(recovered))
+		}
+	}()
+	fields.merge(extractor(req, resp, err))
+}
+
+// AuditHandler 为POST/PUT/PATCH/DELETE请求输出审计记录,sink为nil时输出到日志
+func AuditHandler(sink AuditSink) HandlerWrapper {
+	if sink == nil {
+		sink = logAuditRecord
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+			if !isMutating(httpReq.Method) {
+				next.ServeHTTP(w, httpReq)
+				return
+			}
+			ctx, fields := withAuditFields(httpReq.Context())
+			httpReq = httpReq.WithContext(ctx)
+			recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(recorder, httpReq)
+			sink(ctx, AuditRecord{
+				Method:     httpReq.Method,
+				Route:      httpReq.URL.Path,
+				StatusCode: recorder.statusCode,
+				Fields:     fields.snapshot(),
+				At:         time.Now(),
+			})
+		})
+	}
+}
+
+func logAuditRecord(ctx context.Context, record AuditRecord) {
+	kvs := []interface{}{
+		FieldHTTPMethod, record.Method,
+		FieldHTTPRoute, record.Route,
+		FieldStatusCode, record.StatusCode,
+	}
+	logInfo(ctx, "audit", append(kvs, auditKVs(record.Fields)...)...)
+}
+
+// auditKVs 按key排序,key带上audit_前缀
+func auditKVs(fields map[string]string) []interface{} {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	kvs := make([]interface{}, 0, len(keys)*2)
+	for _, key := range keys {
+		kvs = append(kvs, auditFieldPrefix+key, fields[key])
+	}
+	return kvs
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *statusRecorder) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// AuditTagExtractor 提取请求与响应结构体中带有 audit:"name" tag的字段,响应中的同名字段优先
+func AuditTagExtractor(req any, resp any, err error) map[string]string {
+	fields := make(map[string]string)
+	for _, obj := range []any{req, resp} {
+		collectAuditTags(reflect.ValueOf(obj), fields)
+	}
+	return fields
+}
+
+func collectAuditTags(value reflect.Value, fields map[string]string) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return
+	}
+	for _, field := range reflect.VisibleFields(value.Type()) {
+		name := field.Tag.Get("audit")
+		if name == "" || !field.IsExported() {
+			continue
+		}
+		fieldValue, err := value.FieldByIndexErr(field.Index)
+		if err != nil {
+			continue
+		}
+		fields[name] = fmt.Sprint(fieldValue.Interface())
+	}
+}
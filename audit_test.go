@@ -0,0 +1,77 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/wwq-2020/httpx/httpxtest"
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+type createOrderReq struct {
+	Customer string `json:"customer" audit:"customer_id"`
+	Token    string `json:"token" audit:"token"`
+}
+
+type createOrderResp struct {
+	ID string `json:"id" audit:"resource_id"`
+}
+
+func TestAuditExtractor(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	var records []AuditRecord
+	sink := func(ctx context.Context, record AuditRecord) {
+		records = append(records, record)
+	}
+	handler := WrapHandler(JsonHandler(func(ctx context.Context, req createOrderReq) (*createOrderResp, error) {
+		return &createOrderResp{ID: "o-1"}, nil
+	}, WithAuditExtractor(AuditTagExtractor)), AuditHandler(sink), LoggingHandler(false, false))
+
+	httpxtest.Call[createOrderResp](t, handler, httpxtest.Req{
+		Method: http.MethodPost,
+		Path:   "/orders",
+		JSON:   &createOrderReq{Customer: "c-1", Token: "t"},
+		Expect: createOrderResp{ID: "o-1"},
+	})
+	if len(records) != 1 {
+		t.Fatalf("expected 1 audit record,got:%d", len(records))
+	}
+	record := records[0]
+	expected := map[string]string{"customer_id": "c-1", "resource_id": "o-1", "token": redactedValue}
+	if record.Route != "/orders" || record.StatusCode != http.StatusOK || len(record.Fields) != len(expected) {
+		t.Fatalf("unexpected audit record:%+v", record)
+	}
+	for key, value := range expected {
+		if record.Fields[key] != value {
+			t.Fatalf("expected audit field %s:%s,got:%s", key, value, record.Fields[key])
+		}
+	}
+	logs.AssertField(t, "serve http req", auditFieldPrefix+"resource_id", "o-1")
+
+	httpxtest.Call[createOrderResp](t, handler, httpxtest.Req{
+		Method: http.MethodGet,
+		JSON:   &createOrderReq{},
+		Expect: createOrderResp{ID: "o-1"},
+	})
+	if len(records) != 1 {
+		t.Fatalf("expected no audit record for GET,got:%d", len(records))
+	}
+}
+
+func TestAuditExtractorPanic(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	handler := WrapHandler(JsonHandler(func(ctx context.Context, req createOrderReq) (*createOrderResp, error) {
+		return &createOrderResp{ID: "o-1"}, nil
+	}, WithAuditExtractor(func(req any, resp any, err error) map[string]string {
+		panic("boom")
+	})), AuditHandler(nil))
+
+	httpxtest.Call[createOrderResp](t, handler, httpxtest.Req{
+		Method: http.MethodPost,
+		JSON:   &createOrderReq{},
+		Expect: createOrderResp{ID: "o-1"},
+	})
+	logs.AssertField(t, "audit extractor panic", FieldErr, "boom")
+	logs.AssertField(t, "audit", FieldStatusCode, http.StatusOK)
+}
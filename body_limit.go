@@ -0,0 +1,54 @@
+package httpx
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrResponseTooLarge 响应体超过MaxResponseBytes
+type ErrResponseTooLarge struct {
+	Limit int64
+	Read  int64
+}
+
+func (e *ErrResponseTooLarge) Error() string {
+	return fmt.Sprintf("response body too large,limit:%d,read:%d", e.Limit, e.Read)
+}
+
+// limitedBody 最多读取limit字节,多读到的数据返回ErrResponseTooLarge
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.read > b.limit {
+		return 0, &ErrResponseTooLarge{Limit: b.limit, Read: b.read}
+	}
+	// 多读1字节用来判断是否超过limit
+	if remaining := b.limit - b.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return n - int(b.read-b.limit), &ErrResponseTooLarge{Limit: b.limit, Read: b.read}
+	}
+	return n, err
+}
+
+// BodyLimitTransport 限制响应体最多n字节,放在日志之内时同样限制日志读入内存的响应体;n<=0时不限制
+func BodyLimitTransport(n int64) TransportWrapper {
+	return NamedWrapper("body_limit", fmt.Sprint(n), func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			httpResp, err := next.RoundTrip(httpReq)
+			if err != nil || n <= 0 || httpResp.StatusCode == http.StatusSwitchingProtocols {
+				return httpResp, err
+			}
+			httpResp.Body = &limitedBody{ReadCloser: httpResp.Body, limit: n}
+			return httpResp, nil
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestMaxResponseBytes(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		w.Write([]byte(`{"data":"` + strings.Repeat("a", size) + `"}`))
+	}))
+	tests := []struct {
+		name    string
+		size    int
+		logging bool
+		wantErr bool
+	}{
+		{name: "within limit", size: 10, logging: true},
+		{name: "logging", size: 1 << 20, logging: true, wantErr: true},
+		{name: "decoding", size: 1 << 20, logging: false, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := map[string]string{}
+			err := Get(server.URL).
+				WithQueryString("size", strconv.Itoa(tt.size)).
+				Logging(false, tt.logging).
+				MaxResponseBytes(1024).
+				WithResp(&resp).
+				Do(context.Background())
+			if !tt.wantErr {
+				if err != nil || len(resp["data"]) != tt.size {
+					t.Fatalf("expected data of len %d,got:%v", tt.size, err)
+				}
+				return
+			}
+			tooLarge := &ErrResponseTooLarge{}
+			if !errors.As(err, &tooLarge) || tooLarge.Limit != 1024 || tooLarge.Read != 1025 {
+				t.Fatalf("expected response too large,got:%v", err)
+			}
+		})
+	}
+}
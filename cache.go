@@ -0,0 +1,361 @@
+package httpx
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CacheControlKey    = "Cache-Control"
+	ETagKey            = "ETag"
+	LastModifiedKey    = "Last-Modified"
+	IfNoneMatchKey     = "If-None-Match"
+	IfModifiedSinceKey = "If-Modified-Since"
+
+	defaultCacheStoreSize = 1024
+	// maxCacheableBodyBytes 超过这个长度的响应体不缓存
+	maxCacheableBodyBytes = 1 << 20
+)
+
+// CachedResponse 缓存的响应,StoredAt+MaxAge之前无需向服务端确认
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	StoredAt   time.Time
+	MaxAge     time.Duration
+}
+
+func (c *CachedResponse) fresh(now time.Time) bool {
+	return now.Before(c.StoredAt.Add(c.MaxAge))
+}
+
+// revalidatable 带有ETag或Last-Modified,过期后可以发送条件请求
+func (c *CachedResponse) revalidatable() bool {
+	return c.Header.Get(ETagKey) != "" || c.Header.Get(LastModifiedKey) != ""
+}
+
+// ttl 不能重新确认的响应过期后就没有用了
+func (c *CachedResponse) ttl() time.Duration {
+	if c.revalidatable() {
+		return 0
+	}
+	return c.MaxAge
+}
+
+// response 构造返回给调用方的响应,header与body都是副本
+func (c *CachedResponse) response(httpReq *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(c.StatusCode) + " " + http.StatusText(c.StatusCode),
+		StatusCode:    c.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       httpReq,
+	}
+}
+
+// CacheStore 保存CacheTransport的响应,ttl<=0时只会被容量淘汰
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse, ttl time.Duration)
+	Delete(key string)
+}
+
+type memoryCacheEntry struct {
+	key      string
+	resp     *CachedResponse
+	expireAt time.Time
+}
+
+type memoryCacheStore struct {
+	sync.Mutex
+	max     int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// NewMemoryCacheStore 内存中的LRU CacheStore,最多保存maxEntries个响应,<=0时使用默认值
+func NewMemoryCacheStore(maxEntries int) CacheStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheStoreSize
+	}
+	return &memoryCacheStore{
+		max:     maxEntries,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (s *memoryCacheStore) Get(key string) (*CachedResponse, bool) {
+	s.Lock()
+	defer s.Unlock()
+	elem, exist := s.entries[key]
+	if !exist {
+		return nil, false
+	}
+	entry := elem.Value.(*memoryCacheEntry)
+	if !entry.expireAt.IsZero() && time.Now().After(entry.expireAt) {
+		s.lru.Remove(elem)
+		delete(s.entries, key)
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	return entry.resp, true
+}
+
+func (s *memoryCacheStore) Set(key string, resp *CachedResponse, ttl time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	entry := &memoryCacheEntry{key: key, resp: resp}
+	if ttl > 0 {
+		entry.expireAt = time.Now().Add(ttl)
+	}
+	if elem, exist := s.entries[key]; exist {
+		elem.Value = entry
+		s.lru.MoveToFront(elem)
+		return
+	}
+	s.entries[key] = s.lru.PushFront(entry)
+	for s.lru.Len() > s.max {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+}
+
+func (s *memoryCacheStore) Delete(key string) {
+	s.Lock()
+	defer s.Unlock()
+	if elem, exist := s.entries[key]; exist {
+		s.lru.Remove(elem)
+		delete(s.entries, key)
+	}
+}
+
+// cacheDirectives Cache-Control中用到的部分
+type cacheDirectives struct {
+	noStore    bool
+	noCache    bool
+	private    bool
+	public     bool
+	maxAge     time.Duration
+	hasMaxAge  bool
+	sMaxAge    time.Duration
+	hasSMaxAge bool
+}
+
+// shared 响应允许共享缓存用于带Authorization的请求(RFC 9111 3.5)
+func (d cacheDirectives) shared() bool {
+	return !d.private && (d.public || d.hasSMaxAge)
+}
+
+func parseCacheControl(header http.Header) cacheDirectives {
+	var directives cacheDirectives
+	for _, value := range header.Values(CacheControlKey) {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-store":
+				directives.noStore = true
+			case "no-cache":
+				directives.noCache = true
+			case "private":
+				directives.private = true
+			case "public":
+				directives.public = true
+			case "max-age":
+				if seconds, err := strconv.Atoi(strings.Trim(arg, `"`)); err == nil && seconds >= 0 {
+					directives.maxAge, directives.hasMaxAge = time.Duration(seconds)*time.Second, true
+				}
+			case "s-maxage":
+				if seconds, err := strconv.Atoi(strings.Trim(arg, `"`)); err == nil && seconds >= 0 {
+					directives.sMaxAge, directives.hasSMaxAge = time.Duration(seconds)*time.Second, true
+				}
+			}
+		}
+	}
+	return directives
+}
+
+// freshnessLifetime 依次使用s-maxage、max-age与Expires,减去Age头表示的已缓存时间
+func freshnessLifetime(header http.Header, now time.Time) time.Duration {
+	directives := parseCacheControl(header)
+	if directives.noCache {
+		return 0
+	}
+	lifetime := directives.maxAge
+	if directives.hasSMaxAge {
+		lifetime = directives.sMaxAge
+	} else if !directives.hasMaxAge {
+		expires, err := http.ParseTime(header.Get("Expires"))
+		if err != nil {
+			return 0
+		}
+		lifetime = expires.Sub(now)
+	}
+	if age, err := strconv.Atoi(header.Get("Age")); err == nil {
+		lifetime -= time.Duration(age) * time.Second
+	}
+	if lifetime < 0 {
+		return 0
+	}
+	return lifetime
+}
+
+// cacheKey 按url区分,Vary只支持Accept与Accept-Encoding,因此总是把这两个头放入key
+func cacheKey(httpReq *http.Request) string {
+	return httpReq.URL.String() + "\n" + httpReq.Header.Get(AcceptKey) + "\n" + httpReq.Header.Get("Accept-Encoding")
+}
+
+// varyCacheable Vary中只有Accept与Accept-Encoding时才能缓存
+func varyCacheable(header http.Header) bool {
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			switch http.CanonicalHeaderKey(strings.TrimSpace(name)) {
+			case "", AcceptKey, "Accept-Encoding":
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cacheableRequest GET请求,并且调用方没有自己发送条件请求或要求不使用缓存
+func cacheableRequest(httpReq *http.Request) bool {
+	if httpReq.Method != http.MethodGet || httpReq.Header.Get("Range") != "" {
+		return false
+	}
+	if httpReq.Header.Get(IfNoneMatchKey) != "" || httpReq.Header.Get(IfModifiedSinceKey) != "" {
+		return false
+	}
+	return !parseCacheControl(httpReq.Header).noStore
+}
+
+// CacheTransport 实现RFC 7234的常用部分:缓存200的GET响应,遵守Cache-Control的max-age、no-cache与no-store,
+// 过期后带If-None-Match/If-Modified-Since重新确认,304时返回缓存的响应;
+// store可能被多个调用方共享,private的响应不缓存,带Authorization的请求只缓存与使用public或s-maxage的响应;
+// 放在状态码检查之外时,内层返回的304错误同样被识别
+func CacheTransport(store CacheStore) TransportWrapper {
+	return NamedWrapper("cache", "", func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			if !cacheableRequest(httpReq) {
+				return next.RoundTrip(httpReq)
+			}
+			key := cacheKey(httpReq)
+			authorized := httpReq.Header.Get(AuthorizationKey) != ""
+			cached, hit := store.Get(key)
+			// 其他用户的响应可能不同,带Authorization时只使用可以共享的响应
+			if hit && authorized && !parseCacheControl(cached.Header).shared() {
+				hit = false
+				cached = nil
+			}
+			if hit && cached.fresh(time.Now()) {
+				return cached.response(httpReq), nil
+			}
+			sendReq := httpReq
+			if hit && cached.revalidatable() {
+				sendReq = httpReq.Clone(httpReq.Context())
+				if etag := cached.Header.Get(ETagKey); etag != "" {
+					sendReq.Header.Set(IfNoneMatchKey, etag)
+				}
+				if lastModified := cached.Header.Get(LastModifiedKey); lastModified != "" {
+					sendReq.Header.Set(IfModifiedSinceKey, lastModified)
+				}
+			} else {
+				hit = false
+			}
+			httpResp, err := next.RoundTrip(sendReq)
+			if hit {
+				if header, notModified := notModifiedHeader(httpResp, err); notModified {
+					if httpResp != nil {
+						io.Copy(io.Discard, io.LimitReader(httpResp.Body, maxErrorBodyBytes))
+						httpResp.Body.Close()
+					}
+					return storeRevalidated(store, key, cached, header).response(httpReq), nil
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+			return storeResponse(store, key, httpResp, authorized)
+		})
+	})
+}
+
+// notModifiedHeader 响应304,或者内层的状态码检查返回了304
+func notModifiedHeader(httpResp *http.Response, err error) (http.Header, bool) {
+	if err != nil {
+		var statusErr *ErrUnexpectedStatusCode
+		if errors.As(err, &statusErr) && statusErr.Got == http.StatusNotModified {
+			return statusErr.Header, true
+		}
+		return nil, false
+	}
+	return httpResp.Header, httpResp.StatusCode == http.StatusNotModified
+}
+
+// storeRevalidated 用304的header更新缓存,body保持不变
+func storeRevalidated(store CacheStore, key string, cached *CachedResponse, header http.Header) *CachedResponse {
+	now := time.Now()
+	updated := &CachedResponse{StatusCode: cached.StatusCode, Header: cached.Header.Clone(), Body: cached.Body, StoredAt: now}
+	for name, values := range header {
+		switch name {
+		case "Content-Length", "Transfer-Encoding", "Connection":
+			continue
+		}
+		updated.Header[name] = append([]string(nil), values...)
+	}
+	updated.MaxAge = freshnessLifetime(updated.Header, now)
+	store.Set(key, updated, updated.ttl())
+	return updated
+}
+
+// storeResponse 可以缓存的响应读入内存后保存,否则原样返回;authorized时只保存可以共享的响应
+func storeResponse(store CacheStore, key string, httpResp *http.Response, authorized bool) (*http.Response, error) {
+	directives := parseCacheControl(httpResp.Header)
+	if directives.noStore || directives.private {
+		store.Delete(key)
+		return httpResp, nil
+	}
+	if authorized && !directives.shared() {
+		return httpResp, nil
+	}
+	if httpResp.StatusCode != http.StatusOK || !varyCacheable(httpResp.Header) || httpResp.ContentLength > maxCacheableBodyBytes {
+		return httpResp, nil
+	}
+	now := time.Now()
+	resp := &CachedResponse{StatusCode: httpResp.StatusCode, Header: httpResp.Header.Clone(), StoredAt: now}
+	resp.MaxAge = freshnessLifetime(resp.Header, now)
+	if resp.MaxAge <= 0 && !resp.revalidatable() {
+		return httpResp, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, maxCacheableBodyBytes+1))
+	if err != nil {
+		httpResp.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxCacheableBodyBytes {
+		// 长度未知且超过限制,拼接已读部分继续返回
+		httpResp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), httpResp.Body), httpResp.Body}
+		return httpResp, nil
+	}
+	httpResp.Body.Close()
+	resp.Body = body
+	store.Set(key, resp, resp.ttl())
+	return resp.response(httpResp.Request), nil
+}
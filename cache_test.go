@@ -0,0 +1,146 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestCacheTransport(t *testing.T) {
+	var calls, notModified int32
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set(CacheControlKey, "max-age=60")
+		case "/etag":
+			w.Header().Set(CacheControlKey, "no-cache")
+			w.Header().Set(ETagKey, `"v1"`)
+			if r.Header.Get(IfNoneMatchKey) == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/nostore":
+			w.Header().Set(CacheControlKey, "no-store")
+		}
+		w.Write([]byte(`{"data":"` + r.URL.Path + `"}`))
+	}))
+	tests := []struct {
+		name        string
+		path        string
+		builder     func(store CacheStore) Builder
+		calls       int32
+		notModified int32
+	}{
+		{name: "hit", path: "/fresh", calls: 1},
+		{name: "revalidate", path: "/etag", calls: 3, notModified: 2},
+		{name: "revalidate outside status check", path: "/etag", calls: 3, notModified: 2, builder: func(store CacheStore) Builder {
+			return Get("").WithTransportWrapper(CacheTransport(store))
+		}},
+		{name: "no-store response", path: "/nostore", calls: 3},
+		{name: "no-store request", path: "/fresh", calls: 3, builder: func(store CacheStore) Builder {
+			return WithCache(store).WithHeader(CacheControlKey, "no-store")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			atomic.StoreInt32(&notModified, 0)
+			store := NewMemoryCacheStore(0)
+			builder := WithCache(store)
+			if tt.builder != nil {
+				builder = tt.builder(store)
+			}
+			for i := 0; i < 3; i++ {
+				resp := map[string]string{}
+				if err := builder.Get(server.URL + tt.path).WithResp(&resp).Do(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				if resp["data"] != tt.path {
+					t.Fatalf("expected data:%s,got:%v", tt.path, resp)
+				}
+			}
+			if calls != tt.calls || notModified != tt.notModified {
+				t.Fatalf("expected calls:%d,304s:%d,got:%d,%d", tt.calls, tt.notModified, calls, notModified)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheStore(t *testing.T) {
+	store := NewMemoryCacheStore(2)
+	for _, key := range []string{"a", "b"} {
+		store.Set(key, &CachedResponse{StatusCode: http.StatusOK}, 0)
+	}
+	store.Get("a")
+	store.Set("c", &CachedResponse{StatusCode: http.StatusOK}, 0)
+	if _, exist := store.Get("b"); exist {
+		t.Fatal("expected least recently used entry to be evicted")
+	}
+	if _, exist := store.Get("a"); !exist {
+		t.Fatal("expected recently used entry to be kept")
+	}
+	store.Set("d", &CachedResponse{StatusCode: http.StatusOK}, time.Millisecond)
+	time.Sleep(time.Millisecond * 5)
+	if _, exist := store.Get("d"); exist {
+		t.Fatal("expected expired entry to be removed")
+	}
+	store.Delete("a")
+	if _, exist := store.Get("a"); exist {
+		t.Fatal("expected deleted entry to be removed")
+	}
+}
+
+func TestCacheTransportAuthorization(t *testing.T) {
+	var calls int32
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/user":
+			w.Header().Set(CacheControlKey, "max-age=60")
+		case "/private":
+			w.Header().Set(CacheControlKey, "private, max-age=60")
+		case "/public":
+			w.Header().Set(CacheControlKey, "public, max-age=60")
+		}
+		w.Write([]byte(`{"data":"` + r.Header.Get(AuthorizationKey) + `"}`))
+	}))
+	tests := []struct {
+		name   string
+		path   string
+		tokens []string
+		data   []string
+		calls  int32
+	}{
+		{name: "different tokens", path: "/user", tokens: []string{"a", "b", "a"}, data: []string{"Bearer a", "Bearer b", "Bearer a"}, calls: 3},
+		{name: "private", path: "/private", tokens: []string{"", ""}, data: []string{"", ""}, calls: 2},
+		{name: "public", path: "/public", tokens: []string{"a", "b"}, data: []string{"Bearer a", "Bearer a"}, calls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			store := NewMemoryCacheStore(0)
+			for i, token := range tt.tokens {
+				builder := WithCache(store).Get(server.URL + tt.path)
+				if token != "" {
+					builder = builder.WithBearerToken(token)
+				}
+				resp := map[string]string{}
+				if err := builder.WithResp(&resp).Do(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				if resp["data"] != tt.data[i] {
+					t.Fatalf("request %d:expected data:%s,got:%s", i, tt.data[i], resp["data"])
+				}
+			}
+			if calls != tt.calls {
+				t.Fatalf("expected calls:%d,got:%d", tt.calls, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package httpx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// CassetteMode RecordReplayTransport的模式
+type CassetteMode int
+
+const (
+	// CassetteRecord 发出真实请求,并把请求与响应追加到cassette
+	CassetteRecord CassetteMode = iota
+	// CassetteReplay 只从cassette返回响应,不访问网络
+	CassetteReplay
+)
+
+func (m CassetteMode) String() string {
+	if m == CassetteReplay {
+		return "replay"
+	}
+	return "record"
+}
+
+// CassetteBodyBase64 body不是合法的utf8时按base64保存
+const CassetteBodyBase64 = "base64"
+
+// CassetteRequest cassette中的请求
+type CassetteRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	// BodyEncoding 为空时Body是原文,为base64时Body是base64编码的原始字节
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// RawBody 返回解码后的请求体,BodyEncoding无效时返回nil
+func (r CassetteRequest) RawBody() []byte {
+	data, _ := decodeCassetteBody(r.Body, r.BodyEncoding)
+	return data
+}
+
+// CassetteResponse cassette中的响应
+type CassetteResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	// BodyEncoding 与CassetteRequest.BodyEncoding相同
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// RawBody 返回解码后的响应体,BodyEncoding无效时返回nil
+func (r CassetteResponse) RawBody() []byte {
+	data, _ := decodeCassetteBody(r.Body, r.BodyEncoding)
+	return data
+}
+
+// encodeCassetteBody 文本body原样保存便于阅读,二进制body按base64保存,避免json替换非法utf8
+func encodeCassetteBody(data []byte) (string, string) {
+	if utf8.Valid(data) {
+		return string(data), ""
+	}
+	return base64.StdEncoding.EncodeToString(data), CassetteBodyBase64
+}
+
+func decodeCassetteBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case CassetteBodyBase64:
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return nil, fmt.Errorf("unknown body encoding %s", encoding)
+}
+
+// Interaction 一次请求与对应的响应,文本body按字符串保存,二进制body按base64保存
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteMatcher 判断请求是否与录制的请求匹配,body为请求体,没有时为nil
+type CassetteMatcher func(httpReq *http.Request, body []byte, recorded CassetteRequest) bool
+
+// CassetteOptions RecordReplayTransport的配置
+type CassetteOptions struct {
+	// Matcher 默认比较method、url以及body的sha256
+	Matcher CassetteMatcher
+	// Scrub 写入文件前修改录制的内容,默认隐藏Authorization、Cookie等敏感header
+	Scrub func(*Interaction)
+}
+
+// DefaultCassetteMatcher 比较method、url以及body的sha256
+func DefaultCassetteMatcher(httpReq *http.Request, body []byte, recorded CassetteRequest) bool {
+	return httpReq.Method == recorded.Method &&
+		httpReq.URL.String() == recorded.URL &&
+		bodyHash(body) == bodyHash(recorded.RawBody())
+}
+
+func bodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// ScrubSensitiveHeaders 隐藏请求与响应中的敏感header,值替换为***
+func ScrubSensitiveHeaders(interaction *Interaction) {
+	interaction.Request.Header = redactHeader(interaction.Request.Header)
+	interaction.Response.Header = redactHeader(interaction.Response.Header)
+}
+
+// ErrCassetteMiss replay时cassette中没有匹配的请求,Diff为与最接近的录制请求的差异
+type ErrCassetteMiss struct {
+	Method string
+	URL    string
+	Diff   string
+}
+
+func (e *ErrCassetteMiss) Error() string {
+	if e.Diff == "" {
+		return fmt.Sprintf("no recorded interaction for %s %s:cassette is empty", e.Method, e.URL)
+	}
+	return fmt.Sprintf("no recorded interaction for %s %s,closest(-recorded +got):\n%s", e.Method, e.URL, e.Diff)
+}
+
+type cassette struct {
+	sync.Mutex
+	mode         CassetteMode
+	path         string
+	opts         CassetteOptions
+	loaded       bool
+	interactions []Interaction
+	used         []bool
+}
+
+// load 第一次使用时读取文件,record时文件不存在则从空的cassette开始
+func (c *cassette) load() error {
+	if c.loaded {
+		return nil
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if c.mode == CassetteRecord && errors.Is(err, os.ErrNotExist) {
+			c.loaded = true
+			return nil
+		}
+		return err
+	}
+	var file struct {
+		Interactions []Interaction `json:"interactions"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid cassette %s:%w", c.path, err)
+	}
+	for idx, interaction := range file.Interactions {
+		if _, err := decodeCassetteBody(interaction.Request.Body, interaction.Request.BodyEncoding); err != nil {
+			return fmt.Errorf("invalid cassette %s interaction %d request:%w", c.path, idx, err)
+		}
+		if _, err := decodeCassetteBody(interaction.Response.Body, interaction.Response.BodyEncoding); err != nil {
+			return fmt.Errorf("invalid cassette %s interaction %d response:%w", c.path, idx, err)
+		}
+	}
+	c.interactions = file.Interactions
+	c.used = make([]bool, len(c.interactions))
+	c.loaded = true
+	return nil
+}
+
+// save 先写入临时文件再rename,失败时不会留下不完整的cassette
+func (c *cassette) save() error {
+	data, err := json.MarshalIndent(map[string]interface{}{"interactions": c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+func (c *cassette) record(interaction Interaction) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+	c.opts.Scrub(&interaction)
+	c.interactions = append(c.interactions, interaction)
+	c.used = append(c.used, true)
+	return c.save()
+}
+
+// match 优先返回未使用过的匹配,都用过时重复返回最后一个匹配
+func (c *cassette) match(httpReq *http.Request, body []byte) (Interaction, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.load(); err != nil {
+		return Interaction{}, err
+	}
+	last := -1
+	for idx, interaction := range c.interactions {
+		if !c.opts.Matcher(httpReq, body, interaction.Request) {
+			continue
+		}
+		if !c.used[idx] {
+			c.used[idx] = true
+			return interaction, nil
+		}
+		last = idx
+	}
+	if last >= 0 {
+		return c.interactions[last], nil
+	}
+	return Interaction{}, &ErrCassetteMiss{Method: httpReq.Method, URL: httpReq.URL.String(), Diff: c.closestDiff(httpReq, body)}
+}
+
+// closestDiff 与相同字段最多的录制请求逐项比较
+func (c *cassette) closestDiff(httpReq *http.Request, body []byte) string {
+	got := []string{"method: " + httpReq.Method, "url: " + httpReq.URL.String(), "body sha256: " + bodyHash(body)}
+	var best []string
+	bestSame := -1
+	for _, interaction := range c.interactions {
+		recorded := []string{
+			"method: " + interaction.Request.Method,
+			"url: " + interaction.Request.URL,
+			"body sha256: " + bodyHash(interaction.Request.RawBody()),
+		}
+		same := 0
+		for i := range recorded {
+			if recorded[i] == got[i] {
+				same++
+			}
+		}
+		if same > bestSame {
+			best, bestSame = recorded, same
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for i := range best {
+		if best[i] == got[i] {
+			fmt.Fprintf(&sb, "  %s\n", got[i])
+			continue
+		}
+		fmt.Fprintf(&sb, "- %s\n+ %s\n", best[i], got[i])
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// RecordReplayTransport 在record模式下发出真实请求并追加到path的json cassette,
+// replay模式下按method、url与body匹配cassette返回录制的响应,不访问网络
+func RecordReplayTransport(mode CassetteMode, path string) TransportWrapper {
+	return RecordReplayTransportWithOptions(mode, path, CassetteOptions{})
+}
+
+// RecordReplayTransportWithOptions 可以指定匹配方式与写入前的过滤
+func RecordReplayTransportWithOptions(mode CassetteMode, path string, opts CassetteOptions) TransportWrapper {
+	if opts.Matcher == nil {
+		opts.Matcher = DefaultCassetteMatcher
+	}
+	if opts.Scrub == nil {
+		opts.Scrub = ScrubSensitiveHeaders
+	}
+	c := &cassette{mode: mode, path: path, opts: opts}
+	return NamedWrapper("record_replay", mode.String(), func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			var body []byte
+			if httpReq.Body != nil && httpReq.Body != http.NoBody {
+				var err error
+				if body, httpReq.Body, err = DrainBody(httpReq.Body); err != nil {
+					return nil, withOutcome(err, OutcomeNotSent)
+				}
+			}
+			if mode == CassetteReplay {
+				interaction, err := c.match(httpReq, body)
+				if err != nil {
+					return nil, withOutcome(err, OutcomeNotSent)
+				}
+				return interaction.Response.response(httpReq), nil
+			}
+			recordedReq := CassetteRequest{Method: httpReq.Method, URL: httpReq.URL.String(), Header: httpReq.Header.Clone()}
+			recordedReq.Body, recordedReq.BodyEncoding = encodeCassetteBody(body)
+			httpResp, err := next.RoundTrip(httpReq)
+			if err != nil {
+				return nil, err
+			}
+			respBody, restored, err := DrainBody(httpResp.Body)
+			if err != nil {
+				return nil, err
+			}
+			httpResp.Body = restored
+			recordedResp := CassetteResponse{StatusCode: httpResp.StatusCode, Header: httpResp.Header.Clone()}
+			recordedResp.Body, recordedResp.BodyEncoding = encodeCassetteBody(respBody)
+			interaction := Interaction{Request: recordedReq, Response: recordedResp}
+			if err := c.record(interaction); err != nil {
+				httpResp.Body.Close()
+				return nil, err
+			}
+			return httpResp, nil
+		})
+	})
+}
+
+func (r CassetteResponse) response(httpReq *http.Request) *http.Response {
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	body := r.RawBody()
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       httpReq,
+	}
+}
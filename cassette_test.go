@@ -0,0 +1,112 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestRecordReplayTransport(t *testing.T) {
+	server := testkit.NewServer(t, testkit.Echo())
+	path := filepath.Join(t.TempDir(), "cassettes", "echo.json")
+
+	recorder := RecordReplayTransport(CassetteRecord, path)
+	for _, data := range []string{"a", "b"} {
+		resp := map[string]string{}
+		if err := Post(server.URL + "/echo").WithBearerToken("secret-token").WithTransportWrapper(recorder).
+			WithReq(map[string]string{"data": data}).WithResp(&resp).Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if resp["data"] != data {
+			t.Fatalf("expected recorded resp data:%s,got:%v", data, resp)
+		}
+	}
+	cassetteData, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(cassetteData), "secret-token") || !strings.Contains(string(cassetteData), `\"data\":\"b\"`) {
+		t.Fatalf("expected scrubbed cassette with both interactions,got:%s", cassetteData)
+	}
+	server.Close()
+
+	// 服务端已关闭,replay不访问网络
+	replayer := RecordReplayTransport(CassetteReplay, path)
+	for _, data := range []string{"b", "a", "a"} {
+		resp := map[string]string{}
+		if err := Post(server.URL + "/echo").WithTransportWrapper(replayer).
+			WithReq(map[string]string{"data": data}).WithResp(&resp).Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if resp["data"] != data {
+			t.Fatalf("expected replayed resp data:%s,got:%v", data, resp)
+		}
+	}
+
+	err = Post(server.URL + "/echo").WithTransportWrapper(replayer).WithReq(map[string]string{"data": "c"}).Do(context.Background())
+	miss := &ErrCassetteMiss{}
+	if !errors.As(err, &miss) || OutcomeFromError(err) != OutcomeNotSent {
+		t.Fatalf("expected cassette miss,got:%v", err)
+	}
+	if !strings.Contains(miss.Diff, "  method: POST") || !strings.Contains(miss.Diff, "- body sha256:") || !strings.Contains(miss.Diff, "+ body sha256:") {
+		t.Fatalf("expected body diff,got:\n%s", miss.Diff)
+	}
+
+	// 自定义matcher忽略body
+	lenient := RecordReplayTransportWithOptions(CassetteReplay, path, CassetteOptions{
+		Matcher: func(httpReq *http.Request, body []byte, recorded CassetteRequest) bool {
+			return httpReq.Method == recorded.Method && httpReq.URL.String() == recorded.URL
+		},
+	})
+	if err := Post(server.URL + "/echo").WithTransportWrapper(lenient).WithReq(map[string]string{"data": "c"}).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordReplayTransportBinaryBody(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0xff, 0x00, 0xfe}
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.Header().Set(ContentTypeKey, "application/octet-stream")
+		w.Write(append(data, binary...))
+	}))
+	path := filepath.Join(t.TempDir(), "binary.json")
+
+	roundTrip := func(wrapper TransportWrapper) []byte {
+		client := &http.Client{Transport: WrapTransport(http.DefaultTransport, wrapper)}
+		httpResp, err := client.Post(server.URL, "application/octet-stream", bytes.NewReader(binary))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer httpResp.Body.Close()
+		data, err := io.ReadAll(httpResp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	expected := append(append([]byte(nil), binary...), binary...)
+	if got := roundTrip(RecordReplayTransport(CassetteRecord, path)); !bytes.Equal(got, expected) {
+		t.Fatalf("expected recorded body:%x,got:%x", expected, got)
+	}
+	cassetteData, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(cassetteData), `"body_encoding": "base64"`) != 2 {
+		t.Fatalf("expected base64 request and response body,got:%s", cassetteData)
+	}
+	server.Close()
+
+	if got := roundTrip(RecordReplayTransport(CassetteReplay, path)); !bytes.Equal(got, expected) {
+		t.Fatalf("expected replayed body:%x,got:%x", expected, got)
+	}
+}
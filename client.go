@@ -2,15 +2,25 @@ package httpx
 
 import (
 	"net/http"
+	"net/http/cookiejar"
 	"sync"
 )
 
+// NewCookieJar 创建内存中的cookie jar,用于WithCookieJar
+func NewCookieJar() http.CookieJar {
+	// options为nil时不会返回错误
+	jar, _ := cookiejar.New(nil)
+	return jar
+}
+
+// BuildClient 每次都创建新的client,tws只作用于这个client
 func BuildClient(tws ...TransportWrapper) *http.Client {
 	return &http.Client{
 		Transport: BuildTransport(tws...),
 	}
 }
 
+// BuildInsecureClient 每次都创建新的不校验证书的client,tws只作用于这个client
 func BuildInsecureClient(tws ...TransportWrapper) *http.Client {
 	return &http.Client{
 		Transport: BuildInsecureTransport(tws...),
@@ -29,43 +39,30 @@ func BuildWrappedInsecureClient() *http.Client {
 	}
 }
 
+// 共享的client与共享的transport一样在第一次使用时创建,需要自定义TransportWrapper时使用BuildClient等构造函数
 var (
-	clientOnce                sync.Once
-	client                    *http.Client
-	insecureClientOnce        sync.Once
-	insecureClient            *http.Client
-	wrappedClientOnce         sync.Once
-	wrappedClient             *http.Client
-	wrappedInsecureClientOnce sync.Once
-	wrappedInsecureClient     *http.Client
+	sharedClient                = sync.OnceValue(func() *http.Client { return BuildClient() })
+	sharedInsecureClient        = sync.OnceValue(func() *http.Client { return BuildInsecureClient() })
+	sharedWrappedClient         = sync.OnceValue(BuildtWrappedClient)
+	sharedWrappedInsecureClient = sync.OnceValue(BuildWrappedInsecureClient)
 )
 
-func Client(tws ...TransportWrapper) *http.Client {
-	clientOnce.Do(func() {
-		client = BuildClient()
-	})
-	return client
+// Client 共享的client
+func Client() *http.Client {
+	return sharedClient()
 }
 
-func InsecureClient(tws ...TransportWrapper) *http.Client {
-	insecureClientOnce.Do(func() {
-		insecureClient = BuildInsecureClient()
-	})
-	return insecureClient
+// InsecureClient 共享的不校验证书的client
+func InsecureClient() *http.Client {
+	return sharedInsecureClient()
 }
 
+// WrappedClient 共享的带默认TransportWrapper的client
 func WrappedClient() *http.Client {
-	wrappedClientOnce.Do(func() {
-		wrappedClient = BuildtWrappedClient()
-	})
-	return wrappedClient
-
+	return sharedWrappedClient()
 }
 
+// WrappedInsecureClient 共享的带默认TransportWrapper且不校验证书的client
 func WrappedInsecureClient() *http.Client {
-	wrappedInsecureClientOnce.Do(func() {
-		wrappedInsecureClient = BuildWrappedInsecureClient()
-	})
-	return wrappedInsecureClient
-
+	return sharedWrappedInsecureClient()
 }
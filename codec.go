@@ -2,8 +2,12 @@ package httpx
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"reflect"
+	"strconv"
+	"strings"
 )
 
 type Codec interface {
@@ -11,6 +15,17 @@ type Codec interface {
 	Encode(interface{}) ([]byte, error)
 }
 
+// StreamEncoder 实现了该接口的Codec在发送请求时边编码边发送,不再缓冲整个请求体;
+// 此时请求不带Content-Length且没有GetBody,重定向与连接重试不会重放请求体
+type StreamEncoder interface {
+	EncodeTo(io.Writer, interface{}) error
+}
+
+// ContentTyper 实现了该接口的Codec在Builder未指定ContentType时决定Content-Type
+type ContentTyper interface {
+	ContentType() string
+}
+
 type codec struct {
 	encoder func(interface{}) ([]byte, error)
 	decoder func(io.Reader, interface{}) error
@@ -32,18 +47,59 @@ func (c *codec) Decode(r io.Reader, obj interface{}) error {
 	return c.decoder(r, obj)
 }
 
+// JsonCodec json编解码,零值与encoding/json的默认行为一致,需要严格解码时使用NewJsonCodec
 type JsonCodec struct {
+	opts JsonOptions
+}
+
+// JsonOptions NewJsonCodec的解码选项
+type JsonOptions struct {
+	// DisallowUnknownFields 响应中有目标类型没有的字段时返回ErrUnknownField
+	DisallowUnknownFields bool
+	// UseNumber 解码到interface{}的数字使用json.Number,避免int64经过float64丢失精度
+	UseNumber bool
+}
+
+// ErrUnknownField DisallowUnknownFields时响应中出现了目标类型没有的字段
+type ErrUnknownField struct {
+	Field string
+	Type  reflect.Type
+}
+
+func (e *ErrUnknownField) Error() string {
+	return fmt.Sprintf("unknown field %q for type %s", e.Field, e.Type)
 }
 
 var defaultCodec = &JsonCodec{}
 
+// NewJsonCodec 按opts解码的JsonCodec,通过WithCodec使用
+func NewJsonCodec(opts JsonOptions) *JsonCodec {
+	return &JsonCodec{opts: opts}
+}
+
+// strict 设置了解码选项,不能走不支持这些选项的快速解码
+func (c *JsonCodec) strict() bool {
+	return c.opts != JsonOptions{}
+}
+
 func (c *JsonCodec) Decode(r io.Reader, obj interface{}) error {
-	if err := json.NewDecoder(r).Decode(obj); err != nil {
-		return err
+	if err := c.newDecoder(r).Decode(obj); err != nil {
+		return unknownFieldErr(err, obj)
 	}
 	return nil
 }
 
+func (c *JsonCodec) newDecoder(r io.Reader) *json.Decoder {
+	decoder := json.NewDecoder(r)
+	if c.opts.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if c.opts.UseNumber {
+		decoder.UseNumber()
+	}
+	return decoder
+}
+
 func (c *JsonCodec) Encode(obj interface{}) ([]byte, error) {
 	data, err := json.Marshal(obj)
 	if err != nil {
@@ -52,6 +108,50 @@ func (c *JsonCodec) Encode(obj interface{}) ([]byte, error) {
 	return data, nil
 }
 
+// unknownFieldErr encoding/json的未知字段错误只有文本,转换为ErrUnknownField
+func unknownFieldErr(err error, obj interface{}) error {
+	const prefix = "json: unknown field "
+	msg := err.Error()
+	if !strings.HasPrefix(msg, prefix) {
+		return err
+	}
+	field, unquoteErr := strconv.Unquote(strings.TrimPrefix(msg, prefix))
+	if unquoteErr != nil {
+		return err
+	}
+	typ := reflect.TypeOf(obj)
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return &ErrUnknownField{Field: field, Type: typ}
+}
+
+// StreamJsonCodec 流式编码请求的JsonCodec,适用于很大的请求体
+type StreamJsonCodec struct {
+	JsonCodec
+}
+
+func (c *StreamJsonCodec) EncodeTo(w io.Writer, obj interface{}) error {
+	return json.NewEncoder(w).Encode(obj)
+}
+
+// XmlCodec xml编解码
+type XmlCodec struct{}
+
+func (c *XmlCodec) Decode(r io.Reader, obj interface{}) error {
+	return xml.NewDecoder(r).Decode(obj)
+}
+
+func (c *XmlCodec) Encode(obj interface{}) ([]byte, error) {
+	return xml.Marshal(obj)
+}
+
+func (c *XmlCodec) ContentType() string {
+	return ContentTypeXml
+}
+
+// StatusJsonCodec 解码{code, msg, data}格式的响应,code为0或缺失时成功,否则返回ErrEnvelope;
+// 其他格式使用NewStatusJsonCodec
 type StatusJsonCodec struct{}
 
 type statusResp struct {
@@ -60,17 +160,10 @@ type statusResp struct {
 	Data interface{} `json:"data"`
 }
 
+var defaultStatusEnvelope = newStatusEnvelope(StatusEnvelopeOptions{MissingCodeOK: true})
+
 func (c *StatusJsonCodec) Decode(r io.Reader, obj interface{}) error {
-	resp := &statusResp{
-		Data: obj,
-	}
-	if err := json.NewDecoder(r).Decode(resp); err != nil {
-		return err
-	}
-	if resp.Code != 0 {
-		return fmt.Errorf("unexpected code:%d,msg:%s", resp.Code, resp.Msg)
-	}
-	return nil
+	return defaultStatusEnvelope.Decode(r, obj)
 }
 
 func (c *StatusJsonCodec) Encode(obj interface{}) ([]byte, error) {
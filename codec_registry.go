@@ -0,0 +1,74 @@
+package httpx
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	ContentTypeXml     = "application/xml"
+	ContentTypeTextXml = "text/xml"
+)
+
+// CodecRegistry 按media type查找解码响应的Codec
+type CodecRegistry struct {
+	sync.RWMutex
+	codecs map[string]Codec
+}
+
+func NewCodecRegistry() *CodecRegistry {
+	return &CodecRegistry{codecs: make(map[string]Codec)}
+}
+
+// Register contentType中的charset等参数会被忽略
+func (r *CodecRegistry) Register(contentType string, codec Codec) {
+	r.Lock()
+	defer r.Unlock()
+	r.codecs[mediaType(contentType)] = codec
+}
+
+// Lookup 忽略charset等参数查找contentType对应的Codec
+func (r *CodecRegistry) Lookup(contentType string) (Codec, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	codec, exist := r.codecs[mediaType(contentType)]
+	return codec, exist
+}
+
+// DefaultCodecRegistry NegotiateResponse使用的registry,默认注册了json与xml
+var DefaultCodecRegistry = func() *CodecRegistry {
+	r := NewCodecRegistry()
+	r.Register(ContentTypeJson, defaultCodec)
+	r.Register(ContentTypeXml, &XmlCodec{})
+	r.Register(ContentTypeTextXml, &XmlCodec{})
+	return r
+}()
+
+// RegisterCodec 向DefaultCodecRegistry注册Codec
+func RegisterCodec(contentType string, codec Codec) {
+	DefaultCodecRegistry.Register(contentType, codec)
+}
+
+// ErrUnsupportedContentType NegotiateResponse时响应的Content-Type没有对应的Codec
+type ErrUnsupportedContentType struct {
+	ContentType string
+	Body        []byte
+}
+
+func (e *ErrUnsupportedContentType) Error() string {
+	return fmt.Sprintf("unsupported response content type:%s,body:%q", e.ContentType, e.Body)
+}
+
+// respCodec 选择解码响应的Codec:没有Content-Type或与Builder的Content-Type一致时使用Builder的Codec,
+// 否则从DefaultCodecRegistry中查找
+func (b *builder) respCodec(contentType string, body io.Reader) (Codec, error) {
+	if !b.negotiateResponse || contentType == "" || mediaType(contentType) == mediaType(b.effectiveContentType()) {
+		return b.codec, nil
+	}
+	if codec, exist := DefaultCodecRegistry.Lookup(contentType); exist {
+		return codec, nil
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(body, errorBodySnippetBytes))
+	return nil, &ErrUnsupportedContentType{ContentType: contentType, Body: snippet}
+}
@@ -0,0 +1,82 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+type negotiated struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestNegotiateResponse(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.URL.Query().Get("type")
+		w.Header().Set(ContentTypeKey, contentType)
+		switch mediaType(contentType) {
+		case ContentTypeJson:
+			w.Write([]byte(`{"name":"json"}`))
+		case ContentTypeXml, ContentTypeTextXml:
+			w.Write([]byte(`<negotiated><name>xml</name></negotiated>`))
+		default:
+			w.Write([]byte("plain " + strings.Repeat("x", 1024)))
+		}
+	}))
+	tests := []struct {
+		contentType string
+		expected    string
+	}{
+		{contentType: "application/json; charset=utf-8", expected: "json"},
+		{contentType: "application/xml", expected: "xml"},
+		{contentType: "text/xml; charset=utf-8", expected: "xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			resp := &negotiated{}
+			if err := Get(server.URL).WithQueryString("type", tt.contentType).NegotiateResponse(true).WithResp(resp).Do(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Name != tt.expected {
+				t.Fatalf("expected name:%s,got:%s", tt.expected, resp.Name)
+			}
+		})
+	}
+
+	err := Get(server.URL).WithQueryString("type", "text/plain").NegotiateResponse(true).WithResp(&negotiated{}).Do(context.Background())
+	unsupported := &ErrUnsupportedContentType{}
+	if !errors.As(err, &unsupported) || unsupported.ContentType != "text/plain" || !strings.HasPrefix(string(unsupported.Body), "plain xxx") || len(unsupported.Body) != errorBodySnippetBytes {
+		t.Fatalf("expected unsupported content type,got:%v", err)
+	}
+
+	// 没有开启时仍然使用Builder的Codec
+	if err := Get(server.URL).WithQueryString("type", ContentTypeXml).WithResp(&negotiated{}).Do(context.Background()); err == nil {
+		t.Fatal("expected json decode error")
+	}
+}
+
+func TestCodecRegistry(t *testing.T) {
+	registry := NewCodecRegistry()
+	plain := NewCodec(func(obj interface{}) ([]byte, error) {
+		return []byte(*obj.(*string)), nil
+	}, func(r io.Reader, obj interface{}) error {
+		data, err := io.ReadAll(r)
+		*obj.(*string) = string(data)
+		return err
+	})
+	registry.Register("Text/Plain; charset=utf-8", plain)
+	if codec, exist := registry.Lookup("text/plain"); !exist || codec != plain {
+		t.Fatal("expected codec registered by media type")
+	}
+	if _, exist := registry.Lookup("text/html"); exist {
+		t.Fatal("expected no codec for text/html")
+	}
+	if codec, exist := DefaultCodecRegistry.Lookup("application/json;charset=utf-8"); !exist || codec != defaultCodec {
+		t.Fatal("expected json registered by default")
+	}
+}
@@ -0,0 +1,150 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestStreamJsonCodec(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &pooledPayload{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":               req.ID,
+			"size":             len(req.Data),
+			"contentLength":    r.ContentLength,
+			"transferEncoding": strings.Join(r.TransferEncoding, ","),
+		})
+	}))
+	req := &pooledPayload{ID: "stream", Data: strings.Repeat("a", 1<<20)}
+	b := Post(server.URL).WithCodec(&StreamJsonCodec{}).WithReq(req)
+	httpReq, err := b.BuildHTTPReq(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpReq.Body.Close()
+	if httpReq.GetBody != nil || httpReq.ContentLength != 0 {
+		t.Fatalf("expected no GetBody and unknown length,got:%d", httpReq.ContentLength)
+	}
+
+	resp := map[string]interface{}{}
+	if err := b.WithResp(&resp).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if resp["id"] != "stream" || resp["size"] != float64(1<<20) || resp["contentLength"] != float64(-1) || resp["transferEncoding"] != "chunked" {
+		t.Fatalf("unexpected resp:%v", resp)
+	}
+
+	err = Post(server.URL).WithCodec(&StreamJsonCodec{}).WithReq(map[string]interface{}{"c": make(chan int)}).Do(context.Background())
+	var typeErr *json.UnsupportedTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("expected encode error,got:%v", err)
+	}
+}
+
+func BenchmarkEncodeLargeReq(b *testing.B) {
+	req := &pooledPayload{ID: "bench", Data: strings.Repeat("a", 50<<20)}
+	for name, codec := range map[string]Codec{"buffered": &JsonCodec{}, "stream": &StreamJsonCodec{}} {
+		b.Run(name, func(b *testing.B) {
+			reqBuilder := Post("http://127.0.0.1").WithCodec(codec).WithReq(req)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				httpReq, err := reqBuilder.BuildHTTPReq(context.Background())
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.Copy(io.Discard, httpReq.Body); err != nil {
+					b.Fatal(err)
+				}
+				httpReq.Body.Close()
+			}
+		})
+	}
+}
+
+func TestStatusJsonCodecOptions(t *testing.T) {
+	vendor := StatusEnvelopeOptions{CodeKey: "errcode", MsgKey: "errmsg", DataKey: "result", SuccessCodes: []string{"200", "OK"}}
+	tests := []struct {
+		name     string
+		codec    Codec
+		body     string
+		expected string
+		err      *ErrEnvelope
+	}{
+		{name: "default", codec: &StatusJsonCodec{}, body: `{"code":0,"data":{"id":"a"}}`, expected: "a"},
+		{name: "default missing code", codec: &StatusJsonCodec{}, body: `{"data":{"id":"a"}}`, expected: "a"},
+		{name: "default failure", codec: &StatusJsonCodec{}, body: `{"code":1001,"msg":"denied"}`, err: &ErrEnvelope{Code: "1001", Msg: "denied"}},
+		{name: "vendor numeric", codec: NewStatusJsonCodec(vendor), body: `{"errcode":200,"result":{"id":"b"}}`, expected: "b"},
+		{name: "vendor string", codec: NewStatusJsonCodec(vendor), body: `{"errcode":"OK","result":{"id":"c"}}`, expected: "c"},
+		{name: "vendor failure", codec: NewStatusJsonCodec(vendor), body: `{"errcode":"E42","errmsg":"quota"}`, err: &ErrEnvelope{Code: "E42", Msg: "quota"}},
+		{name: "missing code", codec: NewStatusJsonCodec(vendor), body: `{"errmsg":"gone"}`, err: &ErrEnvelope{Msg: "gone"}},
+		{name: "missing code ok", codec: NewStatusJsonCodec(StatusEnvelopeOptions{MissingCodeOK: true}), body: `{"data":{"id":"d"}}`, expected: "d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &pooledPayload{}
+			err := tt.codec.Decode(strings.NewReader(tt.body), resp)
+			if tt.err != nil {
+				var envelopeErr *ErrEnvelope
+				if !errors.As(err, &envelopeErr) || *envelopeErr != *tt.err {
+					t.Fatalf("expected err:%v,got:%v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.ID != tt.expected {
+				t.Fatalf("expected id:%s,got:%s", tt.expected, resp.ID)
+			}
+		})
+	}
+}
+
+func TestJsonCodecOptions(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ContentTypeKey, ContentTypeJson)
+		io.WriteString(w, `{"id":"a","user_id":9007199254740993}`)
+	}))
+
+	var resp pooledPayload
+	if err := Get(server.URL).WithResp(&resp).Do(context.Background()); err != nil {
+		t.Fatalf("expected default codec to ignore unknown fields,got:%v", err)
+	}
+	err := Get(server.URL).WithCodec(NewJsonCodec(JsonOptions{DisallowUnknownFields: true})).WithResp(&resp).Do(context.Background())
+	var fieldErr *ErrUnknownField
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "user_id" || fieldErr.Type.Name() != "pooledPayload" {
+		t.Fatalf("expected unknown field user_id for pooledPayload,got:%v", err)
+	}
+
+	tests := []struct {
+		name     string
+		codec    Codec
+		expected string
+	}{
+		{name: "default", codec: &JsonCodec{}, expected: "9.007199254740992e+15"},
+		{name: "use number", codec: NewJsonCodec(JsonOptions{UseNumber: true}), expected: "9007199254740993"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := map[string]interface{}{}
+			if err := Get(server.URL).WithCodec(tt.codec).WithResp(&resp).Do(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(resp["user_id"]); got != tt.expected {
+				t.Fatalf("expected user_id:%s,got:%s", tt.expected, got)
+			}
+		})
+	}
+}
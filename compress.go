@@ -0,0 +1,73 @@
+package httpx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	ContentEncodingKey  = "Content-Encoding"
+	ContentEncodingGzip = "gzip"
+)
+
+// compressRequestEncodings CompressRequest支持的编码
+var compressRequestEncodings = map[string]func(io.Writer) io.WriteCloser{
+	ContentEncodingGzip: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+}
+
+func checkRequestEncoding(encoding string) error {
+	if _, exist := compressRequestEncodings[encoding]; !exist {
+		return fmt.Errorf("unsupported request encoding:%s", encoding)
+	}
+	return nil
+}
+
+// CompressRequestTransport 压缩请求体并设置Content-Encoding,
+// 放在日志之内时日志记录的是压缩前的请求体;已设置Content-Encoding的请求不做处理
+func CompressRequestTransport(encoding string) TransportWrapper {
+	newWriter := compressRequestEncodings[encoding]
+	return NamedWrapper("compress_request", encoding, func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			if newWriter == nil || httpReq.Body == nil || httpReq.Body == http.NoBody || httpReq.Header.Get(ContentEncodingKey) != "" {
+				return next.RoundTrip(httpReq)
+			}
+			// 不修改client持有的请求,重定向时GetBody仍然返回未压缩的请求体
+			compressed := httpReq.Clone(httpReq.Context())
+			compressed.Header.Set(ContentEncodingKey, encoding)
+			if httpReq.ContentLength <= 0 {
+				pr, pw := io.Pipe()
+				go func() {
+					pw.CloseWithError(compress(newWriter(pw), httpReq.Body))
+				}()
+				compressed.Body = pr
+				compressed.ContentLength = -1
+				compressed.GetBody = nil
+				return next.RoundTrip(compressed)
+			}
+			buf := &bytes.Buffer{}
+			if err := compress(newWriter(buf), httpReq.Body); err != nil {
+				return nil, err
+			}
+			data := buf.Bytes()
+			compressed.Body = io.NopCloser(bytes.NewReader(data))
+			compressed.ContentLength = int64(len(data))
+			compressed.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(data)), nil
+			}
+			return next.RoundTrip(compressed)
+		})
+	})
+}
+
+// compress 将body写入w并关闭body
+func compress(w io.WriteCloser, body io.ReadCloser) error {
+	defer body.Close()
+	if _, err := io.Copy(w, body); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
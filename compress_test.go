@@ -0,0 +1,77 @@
+package httpx
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestCompressRequest(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	server := testkit.NewServer(t, testkit.RedirectChain(1, http.StatusTemporaryRedirect, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(ContentEncodingKey) != ContentEncodingGzip {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		req := map[string]string{}
+		if err := json.NewDecoder(zr).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": req["data"], "contentLength": r.ContentLength})
+	})))
+	data := strings.Repeat("a", 4096)
+	b := Post(server.URL).
+		CompressRequest(ContentEncodingGzip).
+		Logging(true, false).
+		ExpectedStatusCodes(http.StatusOK, http.StatusTemporaryRedirect)
+
+	tests := []struct {
+		name    string
+		builder Builder
+	}{
+		{name: "buffered", builder: b.WithReq(map[string]string{"data": data})},
+		// 流式的请求体无法重放,不经过重定向
+		{name: "stream", builder: b.Post(server.URL + "?hop=1").WithCodec(&StreamJsonCodec{}).WithReq(map[string]string{"data": data})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := map[string]interface{}{}
+			if err := tt.builder.WithResp(&resp).Do(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if resp["data"] != data {
+				t.Fatalf("expected data of len %d,got:%v", len(data), resp["data"])
+			}
+			length, _ := resp["contentLength"].(float64)
+			if tt.name == "buffered" && (length <= 0 || length >= float64(len(data))) {
+				t.Fatalf("expected compressed content length,got:%v", length)
+			}
+			if tt.name == "stream" && length != -1 {
+				t.Fatalf("expected chunked body,got:%v", length)
+			}
+		})
+	}
+	// 日志记录压缩前的请求体,超过DefaultMaxLogBodyBytes的部分被截断
+	logs.AssertField(t, "send http req", FieldReqData, (`{"data":"` + data)[:DefaultMaxLogBodyBytes])
+	logs.AssertField(t, "send http req", FieldReqTruncated, true)
+
+	if err := Post(server.URL).CompressRequest("br").Do(context.Background()); err == nil || err.Error() != "unsupported request encoding:br" {
+		t.Fatalf("expected unsupported encoding error,got:%v", err)
+	}
+	httpReq, _ := b.WithReq(map[string]string{"data": data}).BuildHTTPReq(context.Background())
+	if body, _ := io.ReadAll(httpReq.Body); len(body) != len(data)+len(`{"data":""}`) {
+		t.Fatalf("expected BuildHTTPReq to return the uncompressed body,got:%d", len(body))
+	}
+}
@@ -0,0 +1,263 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPriorityAging    = time.Second
+	defaultSlowWaitDuration = time.Millisecond * 100
+)
+
+// Priority 请求在并发限制排队时的优先级
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority 在context中记录请求优先级
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFromContext 获取请求优先级,默认PriorityNormal
+func PriorityFromContext(ctx context.Context) Priority {
+	priority, ok := ctx.Value(priorityKey{}).(Priority)
+	if !ok {
+		return PriorityNormal
+	}
+	return priority
+}
+
+// ConcurrencyLimiter 按host限制并发,超出限制的请求按优先级排队
+type ConcurrencyLimiter struct {
+	sync.Mutex
+	limit    int
+	aging    time.Duration
+	slowWait time.Duration
+	hosts    map[string]*hostConcurrency
+}
+
+type hostConcurrency struct {
+	inflight int
+	waiters  []*concurrencyWaiter
+}
+
+type concurrencyWaiter struct {
+	priority Priority
+	enqueued time.Time
+	ready    chan struct{}
+	granted  bool
+}
+
+// HostConcurrencyStats 单个host的并发情况
+type HostConcurrencyStats struct {
+	Inflight int
+	Queued   map[Priority]int
+}
+
+// NewConcurrencyLimiter 每个host最多limit个并发请求,排队每超过aging优先级提升一级,等待超过slowWait时打印日志
+func NewConcurrencyLimiter(limit int, aging, slowWait time.Duration) *ConcurrencyLimiter {
+	if limit <= 0 {
+		limit = 1
+	}
+	if aging <= 0 {
+		aging = defaultPriorityAging
+	}
+	if slowWait <= 0 {
+		slowWait = defaultSlowWaitDuration
+	}
+	return &ConcurrencyLimiter{
+		limit:    limit,
+		aging:    aging,
+		slowWait: slowWait,
+		hosts:    make(map[string]*hostConcurrency),
+	}
+}
+
+// Stats 返回各host当前的并发与排队快照
+func (l *ConcurrencyLimiter) Stats() map[string]HostConcurrencyStats {
+	l.Lock()
+	defer l.Unlock()
+	stats := make(map[string]HostConcurrencyStats, len(l.hosts))
+	for host, hc := range l.hosts {
+		queued := make(map[Priority]int)
+		for _, waiter := range hc.waiters {
+			queued[waiter.priority]++
+		}
+		stats[host] = HostConcurrencyStats{
+			Inflight: hc.inflight,
+			Queued:   queued,
+		}
+	}
+	return stats
+}
+
+func (l *ConcurrencyLimiter) acquire(ctx context.Context, host string, priority Priority) error {
+	l.Lock()
+	hc, exist := l.hosts[host]
+	if !exist {
+		hc = &hostConcurrency{}
+		l.hosts[host] = hc
+	}
+	if hc.inflight < l.limit {
+		hc.inflight++
+		l.Unlock()
+		return nil
+	}
+	waiter := &concurrencyWaiter{
+		priority: priority,
+		enqueued: time.Now(),
+		ready:    make(chan struct{}),
+	}
+	hc.waiters = append(hc.waiters, waiter)
+	l.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.Lock()
+	if waiter.granted {
+		l.Unlock()
+		// 取消的同时拿到了名额,转交给下一个
+		l.release(host)
+		return ctx.Err()
+	}
+	for idx, each := range hc.waiters {
+		if each == waiter {
+			hc.waiters = append(hc.waiters[:idx], hc.waiters[idx+1:]...)
+			break
+		}
+	}
+	l.Unlock()
+	return ctx.Err()
+}
+
+func (l *ConcurrencyLimiter) release(host string) {
+	l.Lock()
+	defer l.Unlock()
+	hc, exist := l.hosts[host]
+	if !exist {
+		return
+	}
+	if len(hc.waiters) == 0 {
+		hc.inflight--
+		if hc.inflight <= 0 {
+			delete(l.hosts, host)
+		}
+		return
+	}
+	now := time.Now()
+	best := 0
+	bestPriority := l.effectivePriority(hc.waiters[0], now)
+	for idx, waiter := range hc.waiters[1:] {
+		if priority := l.effectivePriority(waiter, now); priority > bestPriority {
+			best, bestPriority = idx+1, priority
+		}
+	}
+	waiter := hc.waiters[best]
+	hc.waiters = append(hc.waiters[:best], hc.waiters[best+1:]...)
+	waiter.granted = true
+	close(waiter.ready)
+}
+
+// effectivePriority 排队越久优先级越高,防止低优先级饿死
+func (l *ConcurrencyLimiter) effectivePriority(waiter *concurrencyWaiter, now time.Time) Priority {
+	return waiter.priority + Priority(now.Sub(waiter.enqueued)/l.aging)
+}
+
+// ConcurrencyLimitTransport 按host限制并发
+func ConcurrencyLimitTransport(limiter *ConcurrencyLimiter) TransportWrapper {
+	return NamedWrapper("concurrency_limit", fmt.Sprintf("limit=%d", limiter.limit), func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			host := httpReq.URL.Host
+			priority := PriorityFromContext(httpReq.Context())
+			start := time.Now()
+			if err := limiter.acquire(httpReq.Context(), host, priority); err != nil {
+				return nil, withOutcome(err, OutcomeNotSent)
+			}
+			if waited := time.Since(start); waited > limiter.slowWait {
+				logInfo(httpReq.Context(), "wait for concurrency slot",
+					FieldHTTPMethod, httpReq.Method,
+					FieldHTTPURL, httpReq.URL.String(),
+					FieldPriority, priority.String(),
+					FieldConcurrencyWait, waited,
+				)
+			}
+			httpResp, err := next.RoundTrip(httpReq)
+			if err != nil {
+				limiter.release(host)
+				return nil, err
+			}
+			httpResp.Body = &releaseOnCloseBody{
+				ReadCloser: httpResp.Body,
+				release: func() {
+					limiter.release(host)
+				},
+			}
+			return httpResp, nil
+		})
+	})
+}
+
+// ConcurrencyLimitHandler 最多同时处理limit个请求,超出的请求不排队,直接响应429,
+// 并通过RetryAdvice带上Retry-After与RateLimit-*头;retryAfter<=0时为1秒
+func ConcurrencyLimitHandler(limit int, retryAfter time.Duration) HandlerWrapper {
+	if limit <= 0 {
+		limit = 1
+	}
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+	slots := make(chan struct{}, limit)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+			select {
+			case slots <- struct{}{}:
+			default:
+				writeTooManyRequests(w, RetryAdvice{Delay: retryAfter, Limit: int64(limit), Remaining: 0})
+				return
+			}
+			defer func() {
+				<-slots
+			}()
+			next.ServeHTTP(w, httpReq)
+		})
+	}
+}
+
+type releaseOnCloseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
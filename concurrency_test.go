@@ -0,0 +1,155 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestConcurrencyLimiterPriority(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, time.Hour, 0)
+	host := "example.com"
+	if err := limiter.acquire(context.Background(), host, PriorityLow); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan Priority, 3)
+	waitFor := func(priority Priority) {
+		go func() {
+			if err := limiter.acquire(context.Background(), host, priority); err != nil {
+				t.Error(err)
+				return
+			}
+			acquired <- priority
+		}()
+	}
+	queued := func(expected int) {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			stats := limiter.Stats()[host]
+			total := 0
+			for _, n := range stats.Queued {
+				total += n
+			}
+			if total == expected {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("expected %d waiters", expected)
+	}
+
+	waitFor(PriorityLow)
+	queued(1)
+	waitFor(PriorityLow)
+	queued(2)
+	waitFor(PriorityHigh)
+	queued(3)
+	if got := limiter.Stats()[host].Queued[PriorityLow]; got != 2 {
+		t.Fatalf("expected 2 low waiters,got:%d", got)
+	}
+
+	limiter.release(host)
+	if got := <-acquired; got != PriorityHigh {
+		t.Fatalf("expected priority:%s,got:%s", PriorityHigh, got)
+	}
+	limiter.release(host)
+	limiter.release(host)
+	for i := 0; i < 2; i++ {
+		if got := <-acquired; got != PriorityLow {
+			t.Fatalf("expected priority:%s,got:%s", PriorityLow, got)
+		}
+	}
+}
+
+func TestConcurrencyLimiterCancel(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, 0, 0)
+	host := "example.com"
+	if err := limiter.acquire(context.Background(), host, PriorityNormal); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if err := limiter.acquire(ctx, host, PriorityHigh); err == nil {
+		t.Fatal("expected context error")
+	}
+	if got := limiter.Stats()[host].Queued[PriorityHigh]; got != 0 {
+		t.Fatalf("expected canceled waiter to be removed,got:%d", got)
+	}
+}
+
+func TestConcurrencyLimitHandlerShedding(t *testing.T) {
+	release := make(chan struct{})
+	var requests, calls int64
+	limited := WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			<-release
+		}
+		w.Write([]byte(`{}`))
+	}), ConcurrencyLimitHandler(1, time.Second))
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		limited.ServeHTTP(w, r)
+	}))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Get(server.URL).Do(context.Background())
+	}()
+	for atomic.LoadInt64(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	httpResp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	advice, ok := RetryAdviceFromResponse(httpResp)
+	if httpResp.StatusCode != http.StatusTooManyRequests || !ok || advice.Delay != time.Second || advice.Limit != 1 {
+		t.Fatalf("expected 429 with retry advice,got:%d,%+v", httpResp.StatusCode, advice)
+	}
+
+	// client按Retry-After等待后重试,此时第一个请求已经结束
+	time.AfterFunc(time.Millisecond*100, func() {
+		close(release)
+	})
+	start := time.Now()
+	if err := Get(server.URL).Retry(3, ConstantBackoff(time.Millisecond)).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Fatalf("expected client to wait for Retry-After,waited:%s", waited)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt64(&requests); got != 4 {
+		t.Fatalf("expected requests:4,got:%d", got)
+	}
+	if got := atomic.LoadInt64(&calls); got != 2 {
+		t.Fatalf("expected handler calls:2,got:%d", got)
+	}
+}
+
+func TestPriorityFromContextOnly(t *testing.T) {
+	ctx := WithPriority(context.Background(), PriorityHigh)
+	httpReq, err := Get("http://example.com").BuildHTTPReq(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := PriorityFromContext(httpReq.Context()); got != PriorityHigh {
+		t.Fatalf("expected priority from ctx:%s,got:%s", PriorityHigh, got)
+	}
+	httpReq, err = Get("http://example.com").Priority(PriorityLow).BuildHTTPReq(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := PriorityFromContext(httpReq.Context()); got != PriorityLow {
+		t.Fatalf("expected explicit priority:%s,got:%s", PriorityLow, got)
+	}
+}
@@ -0,0 +1,258 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	connEventQueueSize = 1024
+)
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// ConnEventHooks 连接级别的回调,在后台goroutine中依次执行,不会阻塞请求,panic会被恢复
+type ConnEventHooks struct {
+	OnDial         func(addr string, err error, duration time.Duration)
+	OnTLSHandshake func(state tls.ConnectionState, err error, duration time.Duration)
+	OnConnClose    func(addr string, reused bool, age time.Duration)
+}
+
+// ConnEventStats DefaultConnEventHooks统计的连接信息
+type ConnEventStats struct {
+	Dials         int64
+	DialErrors    int64
+	TLSHandshakes int64
+	TLSErrors     int64
+	Closes        int64
+	ReusedCloses  int64
+	// LegacyTLSHandshakes 协商结果低于TLS 1.2的握手次数
+	LegacyTLSHandshakes int64
+	// NegotiatedTLS 按"版本/套件"统计的成功握手次数
+	NegotiatedTLS    map[string]int64
+	DroppedEvents    int64
+	TotalConnAge     time.Duration
+	TotalDialLatency time.Duration
+}
+
+var connEventStats struct {
+	dials, dialErrors, tlsHandshakes, tlsErrors, closes, reusedCloses int64
+	connAge, dialLatency                                              int64
+	legacyTLSHandshakes                                               int64
+	negotiatedTLS                                                     sync.Map
+}
+
+// DefaultConnEventHooks 将连接事件汇总到GetConnEventStats
+var DefaultConnEventHooks = &ConnEventHooks{
+	OnDial: func(addr string, err error, duration time.Duration) {
+		atomic.AddInt64(&connEventStats.dials, 1)
+		atomic.AddInt64(&connEventStats.dialLatency, int64(duration))
+		if err != nil {
+			atomic.AddInt64(&connEventStats.dialErrors, 1)
+		}
+	},
+	OnTLSHandshake: func(state tls.ConnectionState, err error, duration time.Duration) {
+		atomic.AddInt64(&connEventStats.tlsHandshakes, 1)
+		if err != nil {
+			atomic.AddInt64(&connEventStats.tlsErrors, 1)
+			return
+		}
+		negotiated := tls.VersionName(state.Version) + "/" + tls.CipherSuiteName(state.CipherSuite)
+		counter, _ := connEventStats.negotiatedTLS.LoadOrStore(negotiated, new(int64))
+		atomic.AddInt64(counter.(*int64), 1)
+		if state.Version < tls.VersionTLS12 {
+			atomic.AddInt64(&connEventStats.legacyTLSHandshakes, 1)
+		}
+		observeNegotiatedTLS(state)
+	},
+	OnConnClose: func(addr string, reused bool, age time.Duration) {
+		atomic.AddInt64(&connEventStats.closes, 1)
+		atomic.AddInt64(&connEventStats.connAge, int64(age))
+		if reused {
+			atomic.AddInt64(&connEventStats.reusedCloses, 1)
+		}
+	},
+}
+
+// GetConnEventStats 返回DefaultConnEventHooks统计的连接信息
+func GetConnEventStats() ConnEventStats {
+	negotiatedTLS := make(map[string]int64)
+	connEventStats.negotiatedTLS.Range(func(key, value interface{}) bool {
+		negotiatedTLS[key.(string)] = atomic.LoadInt64(value.(*int64))
+		return true
+	})
+	return ConnEventStats{
+		Dials:               atomic.LoadInt64(&connEventStats.dials),
+		DialErrors:          atomic.LoadInt64(&connEventStats.dialErrors),
+		TLSHandshakes:       atomic.LoadInt64(&connEventStats.tlsHandshakes),
+		TLSErrors:           atomic.LoadInt64(&connEventStats.tlsErrors),
+		Closes:              atomic.LoadInt64(&connEventStats.closes),
+		ReusedCloses:        atomic.LoadInt64(&connEventStats.reusedCloses),
+		LegacyTLSHandshakes: atomic.LoadInt64(&connEventStats.legacyTLSHandshakes),
+		NegotiatedTLS:       negotiatedTLS,
+		DroppedEvents:       atomic.LoadInt64(&connEventDropped),
+		TotalConnAge:        time.Duration(atomic.LoadInt64(&connEventStats.connAge)),
+		TotalDialLatency:    time.Duration(atomic.LoadInt64(&connEventStats.dialLatency)),
+	}
+}
+
+var (
+	connEventQueue   = make(chan func(), connEventQueueSize)
+	connEventDropped int64
+	connEventWorker  struct {
+		sync.Mutex
+		stop chan struct{}
+		done chan struct{}
+	}
+)
+
+// runConnEvents stop关闭后执行完队列中剩余的事件再退出
+func runConnEvents(stop, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case event := <-connEventQueue:
+			runConnEvent(event)
+		case <-stop:
+			for {
+				select {
+				case event := <-connEventQueue:
+					runConnEvent(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// startConnEventWorker 第一次分发事件或Close之后再次分发时启动worker
+func startConnEventWorker() {
+	connEventWorker.Lock()
+	defer connEventWorker.Unlock()
+	if connEventWorker.stop != nil {
+		return
+	}
+	connEventWorker.stop = make(chan struct{})
+	connEventWorker.done = make(chan struct{})
+	go runConnEvents(connEventWorker.stop, connEventWorker.done)
+	defaultLifecycle.register("conn_events", closeStageWorkers, stopConnEventWorker)
+}
+
+func stopConnEventWorker(ctx context.Context) error {
+	connEventWorker.Lock()
+	stop, done := connEventWorker.stop, connEventWorker.done
+	connEventWorker.stop, connEventWorker.done = nil, nil
+	connEventWorker.Unlock()
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dispatchConnEvent 队列满时丢弃事件,保证不阻塞连接
+func dispatchConnEvent(event func()) {
+	startConnEventWorker()
+	select {
+	case connEventQueue <- event:
+	default:
+		atomic.AddInt64(&connEventDropped, 1)
+	}
+}
+
+func runConnEvent(event func()) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logWarn(context.Background(), "conn event hook panic", FieldErr, fmt.Sprint(recovered))
+		}
+	}()
+	event()
+}
+
+func (h *ConnEventHooks) wrapDial(dial dialFunc) dialFunc {
+	if h == nil {
+		return dial
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := dial(ctx, network, addr)
+		duration := time.Since(start)
+		if h.OnDial != nil {
+			dispatchConnEvent(func() { h.OnDial(addr, err, duration) })
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &trackedConn{Conn: conn, hooks: h, created: time.Now()}, nil
+	}
+}
+
+func (h *ConnEventHooks) tlsHandshake(state tls.ConnectionState, err error, duration time.Duration) {
+	if h == nil || h.OnTLSHandshake == nil {
+		return
+	}
+	dispatchConnEvent(func() { h.OnTLSHandshake(state, err, duration) })
+}
+
+// trackedConn 记录连接的存活时间以及是否被复用,复用由connReuseTransport标记
+type trackedConn struct {
+	net.Conn
+	hooks   *ConnEventHooks
+	created time.Time
+	reused  int32
+	once    sync.Once
+}
+
+// trackedConnOf 取出TLS连接之下的trackedConn
+func trackedConnOf(conn net.Conn) *trackedConn {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	tracked, _ := conn.(*trackedConn)
+	return tracked
+}
+
+// connReuseTransport 通过httptrace的GotConnInfo.Reused标记被复用的连接,
+// 握手以及HTTP/2的控制帧都不会被误认为复用
+func connReuseTransport(next http.RoundTripper) http.RoundTripper {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused {
+				return
+			}
+			if conn := trackedConnOf(info.Conn); conn != nil {
+				atomic.StoreInt32(&conn.reused, 1)
+			}
+		},
+	}
+	return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+		return next.RoundTrip(httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), trace)))
+	})
+}
+
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		if c.hooks.OnConnClose == nil {
+			return
+		}
+		addr := c.RemoteAddr().String()
+		reused := atomic.LoadInt32(&c.reused) == 1
+		age := time.Since(c.created)
+		dispatchConnEvent(func() { c.hooks.OnConnClose(addr, reused, age) })
+	})
+	return err
+}
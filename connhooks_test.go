@@ -0,0 +1,111 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+type connEvent struct {
+	kind   string
+	addr   string
+	err    error
+	state  tls.ConnectionState
+	reused bool
+}
+
+func recordingHooks() (*ConnEventHooks, chan connEvent) {
+	events := make(chan connEvent, 16)
+	return &ConnEventHooks{
+		OnDial: func(addr string, err error, duration time.Duration) {
+			events <- connEvent{kind: "dial", addr: addr, err: err}
+		},
+		OnTLSHandshake: func(state tls.ConnectionState, err error, duration time.Duration) {
+			events <- connEvent{kind: "tls", state: state, err: err}
+		},
+		OnConnClose: func(addr string, reused bool, age time.Duration) {
+			events <- connEvent{kind: "close", addr: addr, reused: reused}
+		},
+	}, events
+}
+
+func nextConnEvent(t *testing.T, events chan connEvent) connEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("expected conn event")
+	}
+	return connEvent{}
+}
+
+func TestConnEventHooksTLS(t *testing.T) {
+	server := testkit.NewTLSServer(t, testkit.Echo())
+	hooks, events := recordingHooks()
+	b := Get(server.URL).Insecure(true).ConnEventHooks(hooks)
+	for i := 0; i < 2; i++ {
+		if err := b.Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addr := strings.TrimPrefix(server.URL, "https://")
+	if event := nextConnEvent(t, events); event.kind != "dial" || event.addr != addr || event.err != nil {
+		t.Fatalf("unexpected dial event:%+v", event)
+	}
+	event := nextConnEvent(t, events)
+	if event.kind != "tls" || event.err != nil || event.state.NegotiatedProtocol != "http/1.1" || len(event.state.PeerCertificates) == 0 {
+		t.Fatalf("unexpected tls event:%+v", event)
+	}
+	server.CloseClientConnections()
+	server.Close()
+	defaultTransportCache.get(transportConfig{insecure: true, connHooks: hooks}).CloseIdleConnections()
+	if event := nextConnEvent(t, events); event.kind != "close" || event.addr != addr || !event.reused {
+		t.Fatalf("unexpected close event:%+v", event)
+	}
+}
+
+func TestConnEventHooksPlaintext(t *testing.T) {
+	server := testkit.NewServer(t, testkit.Echo())
+	hooks, events := recordingHooks()
+	if err := Get(server.URL).ConnEventHooks(hooks).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextConnEvent(t, events); event.kind != "dial" || event.err != nil {
+		t.Fatalf("unexpected dial event:%+v", event)
+	}
+	defaultTransportCache.get(transportConfig{connHooks: hooks}).CloseIdleConnections()
+	if event := nextConnEvent(t, events); event.kind != "close" || event.reused {
+		t.Fatalf("unexpected close event:%+v", event)
+	}
+}
+
+func TestConnEventHooksDialFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	panicking := &ConnEventHooks{
+		OnDial: func(string, error, time.Duration) {
+			panic("isolated")
+		},
+	}
+	if err := Get("http://" + addr).ConnEventHooks(panicking).Do(context.Background()); err == nil {
+		t.Fatal("expected dial error")
+	}
+	// worker在hook panic后仍然继续处理事件
+	hooks, events := recordingHooks()
+	if err := Get("http://" + addr).ConnEventHooks(hooks).Do(context.Background()); err == nil {
+		t.Fatal("expected dial error")
+	}
+	if event := nextConnEvent(t, events); event.kind != "dial" || event.addr != addr || event.err == nil {
+		t.Fatalf("unexpected dial event:%+v", event)
+	}
+}
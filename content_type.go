@@ -0,0 +1,41 @@
+package httpx
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	AcceptKey = "Accept"
+)
+
+// ErrUnexpectedContentType 响应的Content-Type与ExpectContentType不一致
+type ErrUnexpectedContentType struct {
+	Expected   string
+	Got        string
+	StatusCode int
+}
+
+func (e *ErrUnexpectedContentType) Error() string {
+	return fmt.Sprintf("expected content type:%s,got:%s,statuscode:%d", e.Expected, e.Got, e.StatusCode)
+}
+
+// mediaType 去掉charset等参数,解析失败时返回小写的原值
+func mediaType(contentType string) string {
+	parsed, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return parsed
+}
+
+// checkContentType 在解码之前检查响应的Content-Type
+func checkContentType(expected string, httpResp *http.Response) error {
+	got := httpResp.Header.Get(ContentTypeKey)
+	if got != "" && mediaType(got) == mediaType(expected) {
+		return nil
+	}
+	return withOutcome(&ErrUnexpectedContentType{Expected: expected, Got: got, StatusCode: httpResp.StatusCode}, OutcomeReceived)
+}
@@ -0,0 +1,183 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	defaultCORSHeaders = []string{"Accept", ContentTypeKey, RequestIDKey}
+)
+
+// CORSOptions CORSHandler的配置
+type CORSOptions struct {
+	// AllowedOrigins 允许的Origin,支持精确匹配、"*"以及"https://*.example.com"形式的子域名通配
+	AllowedOrigins []string
+	// AllowedMethods 允许的方法,默认GET、HEAD、POST
+	AllowedMethods []string
+	// AllowedHeaders 允许的请求头,默认Accept、Content-Type与X-Request-Id;包含"*"时允许预检请求的所有header
+	AllowedHeaders []string
+	// ExposedHeaders 浏览器中脚本可以读取的响应头
+	ExposedHeaders []string
+	// AllowCredentials 允许携带cookie等凭证,此时Access-Control-Allow-Origin回显请求的Origin,
+	// AllowedOrigins中的"*"被忽略,只有明确列出或匹配子域名通配的Origin可以携带凭证
+	AllowCredentials bool
+	// MaxAge 预检结果的缓存时间,0时不设置
+	MaxAge time.Duration
+}
+
+type corsPolicy struct {
+	opts       CORSOptions
+	anyOrigin  bool
+	origins    map[string]struct{}
+	wildcards  [][2]string
+	methods    map[string]struct{}
+	anyHeader  bool
+	headers    map[string]struct{}
+	allowedHdr string
+}
+
+func newCORSPolicy(opts CORSOptions) *corsPolicy {
+	if len(opts.AllowedMethods) == 0 {
+		opts.AllowedMethods = defaultCORSMethods
+	}
+	if len(opts.AllowedHeaders) == 0 {
+		opts.AllowedHeaders = defaultCORSHeaders
+	}
+	p := &corsPolicy{
+		opts:    opts,
+		origins: make(map[string]struct{}),
+		methods: make(map[string]struct{}),
+		headers: make(map[string]struct{}),
+	}
+	for _, origin := range opts.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*" && opts.AllowCredentials:
+			// 浏览器禁止"*"与凭证同时使用,回显任意Origin等于允许任何网站读取带凭证的响应
+			logWarn(context.Background(), "CORS wildcard origin ignored with credentials")
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "*"):
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			p.wildcards = append(p.wildcards, [2]string{prefix, suffix})
+		default:
+			p.origins[origin] = struct{}{}
+		}
+	}
+	for _, method := range opts.AllowedMethods {
+		p.methods[strings.ToUpper(method)] = struct{}{}
+	}
+	var headers []string
+	for _, header := range opts.AllowedHeaders {
+		if header == "*" {
+			p.anyHeader = true
+			continue
+		}
+		header = http.CanonicalHeaderKey(header)
+		p.headers[header] = struct{}{}
+		headers = append(headers, header)
+	}
+	p.allowedHdr = strings.Join(headers, ", ")
+	return p
+}
+
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if _, exist := p.origins[origin]; exist {
+		return true
+	}
+	for _, wildcard := range p.wildcards {
+		prefix, suffix := wildcard[0], wildcard[1]
+		if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders 返回预检响应的Access-Control-Allow-Headers,有不允许的header时ok为false
+func (p *corsPolicy) allowHeaders(requested string) (string, bool) {
+	if p.anyHeader {
+		return requested, true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if _, exist := p.headers[http.CanonicalHeaderKey(header)]; !exist {
+			return "", false
+		}
+	}
+	return p.allowedHdr, true
+}
+
+// setOrigin 允许任意Origin时使用"*"(此时不允许凭证),否则回显Origin
+func (p *corsPolicy) setOrigin(header http.Header, origin string) {
+	if p.anyOrigin {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.opts.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// CORSHandler 处理跨域请求,应放在WrapHandler的最后成为最外层,
+// 预检请求直接响应204,不经过超时、日志等wrapper与handler;
+// 不允许的Origin不带任何CORS头,由浏览器拒绝,请求本身照常处理
+func CORSHandler(opts CORSOptions) HandlerWrapper {
+	policy := newCORSPolicy(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+			origin := httpReq.Header.Get("Origin")
+			header := w.Header()
+			// "*"的响应与Origin无关,其他情况下缓存需要按Origin区分
+			if !policy.anyOrigin {
+				header.Add("Vary", "Origin")
+			}
+			preflight := httpReq.Method == http.MethodOptions && httpReq.Header.Get("Access-Control-Request-Method") != ""
+			if !preflight {
+				if origin != "" && policy.allowOrigin(origin) {
+					policy.setOrigin(header, origin)
+					if len(policy.opts.ExposedHeaders) != 0 {
+						header.Set("Access-Control-Expose-Headers", strings.Join(policy.opts.ExposedHeaders, ", "))
+					}
+				}
+				next.ServeHTTP(w, httpReq)
+				return
+			}
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			defer w.WriteHeader(http.StatusNoContent)
+			if origin == "" || !policy.allowOrigin(origin) {
+				return
+			}
+			method := strings.ToUpper(httpReq.Header.Get("Access-Control-Request-Method"))
+			if _, exist := policy.methods[method]; !exist {
+				return
+			}
+			allowHeaders, ok := policy.allowHeaders(httpReq.Header.Get("Access-Control-Request-Headers"))
+			if !ok {
+				return
+			}
+			policy.setOrigin(header, origin)
+			header.Set("Access-Control-Allow-Methods", strings.Join(policy.opts.AllowedMethods, ", "))
+			if allowHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+			if policy.opts.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.opts.MaxAge/time.Second)))
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestCORSHandler(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	var served int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	})
+	cors := CORSHandler(CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPut},
+		AllowedHeaders: []string{"Content-Type", "X-Token"},
+		ExposedHeaders: []string{RequestIDKey},
+		MaxAge:         10 * time.Minute,
+	})
+	handler := WrapHandler(next, LoggingHandler(false, false), cors)
+
+	tests := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		statusCode int
+		served     bool
+		expected   map[string]string
+	}{
+		{
+			name:       "preflight",
+			method:     http.MethodOptions,
+			headers:    map[string]string{"Origin": "https://api.example.org", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "x-token, content-type"},
+			statusCode: http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "https://api.example.org",
+				"Access-Control-Allow-Methods": "GET, PUT",
+				"Access-Control-Allow-Headers": "Content-Type, X-Token",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:       "preflight disallowed method",
+			method:     http.MethodOptions,
+			headers:    map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"},
+			statusCode: http.StatusNoContent,
+			expected:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "preflight disallowed header",
+			method:     http.MethodOptions,
+			headers:    map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Other"},
+			statusCode: http.StatusNoContent,
+			expected:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "simple",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://app.example.com"},
+			statusCode: http.StatusOK,
+			served:     true,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Expose-Headers":    RequestIDKey,
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name:       "disallowed origin",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://example.org"},
+			statusCode: http.StatusOK,
+			served:     true,
+			expected:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "no origin",
+			method:     http.MethodOptions,
+			statusCode: http.StatusOK,
+			served:     true,
+			expected:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+	}
+	for _, tt := range tests {
+		served = 0
+		records := len(logs.Records())
+		req := httptest.NewRequest(tt.method, "/", nil)
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.statusCode {
+			t.Fatalf("%s:expected status:%d,got:%d", tt.name, tt.statusCode, rec.Code)
+		}
+		if (served == 1) != tt.served {
+			t.Fatalf("%s:expected served:%t,got:%d", tt.name, tt.served, served)
+		}
+		if logged := len(logs.Records()) > records; logged != tt.served {
+			t.Fatalf("%s:expected logged:%t,got:%t", tt.name, tt.served, logged)
+		}
+		for key, value := range tt.expected {
+			if got := rec.Header().Get(key); got != value {
+				t.Fatalf("%s:expected %s:%s,got:%s", tt.name, key, value, got)
+			}
+		}
+		if vary := strings.Join(rec.Header().Values("Vary"), ","); !strings.Contains(vary, "Origin") {
+			t.Fatalf("%s:expected Vary Origin,got:%s", tt.name, vary)
+		}
+	}
+}
+
+func TestCORSHandlerCredentials(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	handler := CORSHandler(CORSOptions{
+		AllowedOrigins:   []string{"*", "https://*.example.com"},
+		AllowedHeaders:   []string{"*"},
+		AllowCredentials: true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if len(logs.Find("CORS wildcard origin ignored with credentials")) != 1 {
+		t.Fatal("expected warning for wildcard origin with credentials")
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "https://a.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "X-Anything")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://a.example.com" {
+		t.Fatalf("expected Access-Control-Allow-Origin:https://a.example.com,got:%s", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Fatalf("expected Access-Control-Allow-Credentials:true,got:%s", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "X-Anything" {
+		t.Fatalf("expected Access-Control-Allow-Headers:X-Anything,got:%s", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "https://b.example.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://b.example.com" {
+		t.Fatalf("expected Access-Control-Allow-Origin:https://b.example.com,got:%s", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Fatalf("expected Vary:Origin,got:%s", got)
+	}
+
+	// "*"不会让任意网站携带凭证读取响应
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "https://evil.test")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected no Access-Control-Allow-Origin,got:%s", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Fatalf("expected no Access-Control-Allow-Credentials,got:%s", got)
+	}
+
+	anyOrigin := CORSHandler(CORSOptions{AllowedOrigins: []string{"*"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec = httptest.NewRecorder()
+	anyOrigin.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("expected Access-Control-Allow-Origin:*,got:%s", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "" {
+		t.Fatalf("expected no Vary,got:%s", got)
+	}
+}
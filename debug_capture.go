@@ -0,0 +1,353 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultCaptureMaxBodyBytes = 64 << 10
+	defaultCaptureMaxCaptures  = 10
+	defaultCaptureInterval     = time.Second
+)
+
+// Capture 一次被捕获的请求与响应,敏感的header与json字段已被隐藏
+type Capture struct {
+	At            time.Time   `json:"at"`
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	ReqHeader     http.Header `json:"req_header"`
+	ReqBody       string      `json:"req_body"`
+	ReqTruncated  bool        `json:"req_truncated,omitempty"`
+	StatusCode    int         `json:"status_code"`
+	RespHeader    http.Header `json:"resp_header"`
+	RespBody      string      `json:"resp_body"`
+	RespTruncated bool        `json:"resp_truncated,omitempty"`
+	// ReqBodyOmitted与RespBodyOmitted 不捕获body的原因,超过MaxBodyBytes时无法隐藏敏感字段,为redaction
+	ReqBodyOmitted  string `json:"req_body_omitted,omitempty"`
+	RespBodyOmitted string `json:"resp_body_omitted,omitempty"`
+}
+
+// CaptureSink 接收捕获结果
+type CaptureSink func(ctx context.Context, capture Capture)
+
+// DebugCaptureOptions DebugCaptureHandler的配置
+type DebugCaptureOptions struct {
+	// MaxBodyBytes 请求体与响应体各自最多捕获的字节数,默认64KB,超过时不捕获body
+	MaxBodyBytes int
+	// MaxCaptures 捕获次数达到后自动关闭,默认10
+	MaxCaptures int
+	// MinInterval 两次捕获之间的最小间隔,间隔内匹配的请求不捕获,默认1s
+	MinInterval time.Duration
+}
+
+// DebugCaptureHandler trigger匹配的请求连同响应完整写入sink,用于排查单个调用方的问题;
+// 未匹配的请求只多一次trigger调用
+func DebugCaptureHandler(trigger func(*http.Request) bool, sink CaptureSink, opts DebugCaptureOptions) HandlerWrapper {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = defaultCaptureMaxBodyBytes
+	}
+	if opts.MaxCaptures <= 0 {
+		opts.MaxCaptures = defaultCaptureMaxCaptures
+	}
+	if opts.MinInterval <= 0 {
+		opts.MinInterval = defaultCaptureInterval
+	}
+	limiter := &captureLimiter{max: int64(opts.MaxCaptures), interval: int64(opts.MinInterval)}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+			if limiter.disabled() {
+				next.ServeHTTP(w, httpReq)
+				return
+			}
+			gate := &captureGate{limiter: limiter, ctx: httpReq.Context()}
+			if !trigger(httpReq.WithContext(context.WithValue(httpReq.Context(), captureGateKey{}, gate))) || !gate.allow() {
+				next.ServeHTTP(w, httpReq)
+				return
+			}
+
+			capture := Capture{
+				At:        time.Now(),
+				Method:    httpReq.Method,
+				URL:       httpReq.URL.String(),
+				ReqHeader: redactHeader(httpReq.Header),
+			}
+			if httpReq.Body != nil && httpReq.Body != http.NoBody {
+				data, err := io.ReadAll(io.LimitReader(httpReq.Body, int64(opts.MaxBodyBytes)+1))
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				// handler仍然读到完整的请求体
+				httpReq.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(data), httpReq.Body), Closer: httpReq.Body}
+				capture.ReqBody, capture.ReqTruncated, capture.ReqBodyOmitted = capBody(data, opts.MaxBodyBytes)
+			}
+			recorder := &captureRecorder{ResponseWriter: w, statusCode: http.StatusOK, max: opts.MaxBodyBytes}
+			next.ServeHTTP(recorder, httpReq)
+			capture.StatusCode = recorder.statusCode
+			capture.RespHeader = redactHeader(w.Header())
+			capture.RespBody, capture.RespTruncated, capture.RespBodyOmitted = capBody(recorder.buf.Bytes(), opts.MaxBodyBytes)
+			if recorder.truncated && !capture.RespTruncated {
+				capture.RespBody, capture.RespTruncated, capture.RespBodyOmitted = "", true, bodyOmittedRedaction
+			}
+			sink(httpReq.Context(), capture)
+		})
+	}
+}
+
+// captureLimiter 限制捕获频率,达到max次后关闭
+type captureLimiter struct {
+	max      int64
+	interval int64
+	captured int64
+	last     int64
+}
+
+func (l *captureLimiter) disabled() bool {
+	return atomic.LoadInt64(&l.captured) >= l.max
+}
+
+func (l *captureLimiter) allow(ctx context.Context) bool {
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&l.last)
+	if last != 0 && now-last < l.interval {
+		return false
+	}
+	if !atomic.CompareAndSwapInt64(&l.last, last, now) {
+		return false
+	}
+	captured := atomic.AddInt64(&l.captured, 1)
+	if captured == l.max {
+		logInfo(ctx, "debug capture disabled")
+	}
+	return captured <= l.max
+}
+
+type captureGateKey struct{}
+
+// captureGate 在trigger中询问limiter,trigger与handler看到同一个结果
+type captureGate struct {
+	limiter *captureLimiter
+	ctx     context.Context
+	decided bool
+	allowed bool
+}
+
+func (g *captureGate) allow() bool {
+	if !g.decided {
+		g.decided = true
+		g.allowed = g.limiter.allow(g.ctx)
+	}
+	return g.allowed
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// capBody 隐藏json中的敏感字段;超过max时截断的body无法解析,与LoggingTransport一样不捕获
+func capBody(data []byte, max int) (string, bool, string) {
+	if len(data) > max {
+		return "", true, bodyOmittedRedaction
+	}
+	return redactJSONBody(data), false, ""
+}
+
+func redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for key, values := range redacted {
+		if !isSensitiveKey(key) {
+			continue
+		}
+		for i := range values {
+			values[i] = redactedValue
+		}
+	}
+	return redacted
+}
+
+// redactJSONBody 不是json时原样返回
+func redactJSONBody(data []byte) string {
+	var obj interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return string(data)
+	}
+	if !redactJSONValue(obj) {
+		return string(data)
+	}
+	redacted, err := json.Marshal(obj)
+	if err != nil {
+		return string(data)
+	}
+	return string(redacted)
+}
+
+func redactJSONValue(obj interface{}) bool {
+	redacted := false
+	switch value := obj.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if isSensitiveKey(key) {
+				value[key] = redactedValue
+				redacted = true
+				continue
+			}
+			redacted = redactJSONValue(child) || redacted
+		}
+	case []interface{}:
+		for _, child := range value {
+			redacted = redactJSONValue(child) || redacted
+		}
+	}
+	return redacted
+}
+
+type captureRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	max        int
+	buf        bytes.Buffer
+	truncated  bool
+}
+
+func (w *captureRecorder) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *captureRecorder) Write(data []byte) (int, error) {
+	if remain := w.max - w.buf.Len(); remain < len(data) {
+		w.buf.Write(data[:max(remain, 0)])
+		w.truncated = true
+	} else {
+		w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// HeaderTrigger 请求头header的值等于token时触发
+func HeaderTrigger(header, token string) func(*http.Request) bool {
+	return func(httpReq *http.Request) bool {
+		value := httpReq.Header.Get(header)
+		return value != "" && subtle.ConstantTimeCompare([]byte(value), []byte(token)) == 1
+	}
+}
+
+// OneShotTrigger 通过Arm或管理接口开启,之后的第一个请求触发捕获
+type OneShotTrigger struct {
+	armed int32
+}
+
+// Arm 开启一次捕获
+func (t *OneShotTrigger) Arm() {
+	atomic.StoreInt32(&t.armed, 1)
+}
+
+// Match 作为DebugCaptureHandler的trigger使用,捕获被频率限制拒绝时保持开启,留给之后的请求
+func (t *OneShotTrigger) Match(httpReq *http.Request) bool {
+	if atomic.LoadInt32(&t.armed) == 0 {
+		return false
+	}
+	if gate, ok := httpReq.Context().Value(captureGateKey{}).(*captureGate); ok && !gate.allow() {
+		return false
+	}
+	return atomic.CompareAndSwapInt32(&t.armed, 1, 0)
+}
+
+// Handler 管理接口,POST开启一次捕获
+func (t *OneShotTrigger) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+		if httpReq.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		t.Arm()
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// CaptureRing 保留最近size次捕获的内存sink
+type CaptureRing struct {
+	sync.Mutex
+	captures []Capture
+	next     int
+	full     bool
+}
+
+// NewCaptureRing 创建CaptureRing,size<=0时为10
+func NewCaptureRing(size int) *CaptureRing {
+	if size <= 0 {
+		size = defaultCaptureMaxCaptures
+	}
+	return &CaptureRing{captures: make([]Capture, size)}
+}
+
+// Sink 写入ring的CaptureSink
+func (r *CaptureRing) Sink() CaptureSink {
+	return func(ctx context.Context, capture Capture) {
+		r.Lock()
+		defer r.Unlock()
+		r.captures[r.next] = capture
+		r.next = (r.next + 1) % len(r.captures)
+		if r.next == 0 {
+			r.full = true
+		}
+	}
+}
+
+// Captures 按时间从旧到新返回
+func (r *CaptureRing) Captures() []Capture {
+	r.Lock()
+	defer r.Unlock()
+	if !r.full {
+		return append([]Capture(nil), r.captures[:r.next]...)
+	}
+	return append(append([]Capture(nil), r.captures[r.next:]...), r.captures[:r.next]...)
+}
+
+// Handler 以json返回Captures
+func (r *CaptureRing) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+		w.Header().Set(ContentTypeKey, ContentTypeJson)
+		json.NewEncoder(w).Encode(r.Captures())
+	})
+}
+
+var captureFileMu sync.Mutex
+
+// FileCaptureSink 以json lines追加写入path,文件权限为0600
+func FileCaptureSink(path string) CaptureSink {
+	return func(ctx context.Context, capture Capture) {
+		data, err := json.Marshal(capture)
+		if err != nil {
+			logWarn(ctx, "marshal capture failed", FieldErr, err)
+			return
+		}
+		captureFileMu.Lock()
+		defer captureFileMu.Unlock()
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			logWarn(ctx, "open capture file failed", FieldErr, err)
+			return
+		}
+		defer file.Close()
+		if _, err := file.Write(append(data, '\n')); err != nil {
+			logWarn(ctx, "write capture file failed", FieldErr, err)
+		}
+	}
+}
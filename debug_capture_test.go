@@ -0,0 +1,153 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func captureEcho(ring *CaptureRing, trigger func(*http.Request) bool, opts DebugCaptureOptions) http.Handler {
+	return WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Write(data)
+	}), DebugCaptureHandler(trigger, ring.Sink(), opts))
+}
+
+func serveCapture(handler http.Handler, body string, header http.Header) string {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder.Body.String()
+}
+
+func TestDebugCaptureHeaderTrigger(t *testing.T) {
+	ring := NewCaptureRing(4)
+	handler := captureEcho(ring, HeaderTrigger("X-Debug-Capture", "t1"), DebugCaptureOptions{MaxBodyBytes: 64, MinInterval: time.Nanosecond})
+
+	serveCapture(handler, `{"a":1}`, nil)
+	serveCapture(handler, `{"a":2}`, http.Header{"X-Debug-Capture": {"other"}})
+	if captures := ring.Captures(); len(captures) != 0 {
+		t.Fatalf("expected no captures,got:%d", len(captures))
+	}
+
+	body := `{"user":"a","password":"p","items":[{"token":"x"}]}`
+	if got := serveCapture(handler, body, http.Header{"X-Debug-Capture": {"t1"}, "Authorization": {"Bearer x"}}); got != body {
+		t.Fatalf("expected handler to get full body:%s,got:%s", body, got)
+	}
+	captures := ring.Captures()
+	if len(captures) != 1 {
+		t.Fatalf("expected captures:1,got:%d", len(captures))
+	}
+	capture := captures[0]
+	expected := `{"items":[{"token":"***"}],"password":"***","user":"a"}`
+	if capture.ReqBody != expected || capture.RespBody != expected {
+		t.Fatalf("expected redacted body:%s,got:%s,%s", expected, capture.ReqBody, capture.RespBody)
+	}
+	if capture.ReqHeader.Get("Authorization") != redactedValue || capture.RespHeader.Get("Set-Cookie") != redactedValue {
+		t.Fatalf("expected redacted headers,got:%v,%v", capture.ReqHeader, capture.RespHeader)
+	}
+
+	// 截断的json无法隐藏敏感字段,不捕获body
+	long := `{"password":"secret","data":"` + strings.Repeat("a", 100) + `"}`
+	if got := serveCapture(handler, long, http.Header{"X-Debug-Capture": {"t1"}}); got != long {
+		t.Fatalf("expected handler to get full body,got:%d bytes", len(got))
+	}
+	capture = ring.Captures()[1]
+	if capture.ReqBody != "" || !capture.ReqTruncated || capture.ReqBodyOmitted != bodyOmittedRedaction ||
+		capture.RespBody != "" || !capture.RespTruncated || capture.RespBodyOmitted != bodyOmittedRedaction {
+		t.Fatalf("expected omitted capture,got:%+v", capture)
+	}
+}
+
+func TestDebugCaptureOneShot(t *testing.T) {
+	ring := NewCaptureRing(4)
+	trigger := &OneShotTrigger{}
+	mux := http.NewServeMux()
+	mux.Handle("/admin/capture", trigger.Handler())
+	mux.Handle("/admin/captures", ring.Handler())
+	mux.Handle("/", captureEcho(ring, trigger.Match, DebugCaptureOptions{MinInterval: time.Nanosecond}))
+	server := testkit.NewServer(t, mux)
+
+	post := func(path, body string) {
+		resp, err := http.Post(server.URL+path, ContentTypeJson, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	post("/orders", `{"id":1}`)
+	post("/admin/capture", "")
+	post("/orders", `{"id":2}`)
+	post("/orders", `{"id":3}`)
+
+	var captures []Capture
+	if err := Get(server.URL + "/admin/captures").WithResp(&captures).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(captures) != 1 || captures[0].ReqBody != `{"id":2}` || captures[0].URL != "/orders" {
+		data, _ := json.Marshal(captures)
+		t.Fatalf("expected the request after arming,got:%s", data)
+	}
+}
+
+func TestDebugCaptureOneShotRateLimited(t *testing.T) {
+	ring := NewCaptureRing(4)
+	trigger := &OneShotTrigger{}
+	handler := captureEcho(ring, trigger.Match, DebugCaptureOptions{MinInterval: time.Hour})
+	trigger.Arm()
+	serveCapture(handler, `{"id":1}`, nil)
+	// 间隔内被限制的请求不消耗trigger
+	trigger.Arm()
+	serveCapture(handler, `{"id":2}`, nil)
+	if captures := ring.Captures(); len(captures) != 1 {
+		t.Fatalf("expected captures:1,got:%d", len(captures))
+	}
+	if !trigger.Match(httptest.NewRequest(http.MethodGet, "/", nil)) {
+		t.Fatal("expected trigger to stay armed")
+	}
+}
+
+func TestDebugCaptureAutoDisable(t *testing.T) {
+	ring := NewCaptureRing(8)
+	always := func(*http.Request) bool { return true }
+	handler := captureEcho(ring, always, DebugCaptureOptions{MaxCaptures: 3, MinInterval: time.Nanosecond})
+	for i := 0; i < 5; i++ {
+		serveCapture(handler, "x", nil)
+		time.Sleep(time.Millisecond)
+	}
+	if captures := ring.Captures(); len(captures) != 3 {
+		t.Fatalf("expected captures:3,got:%d", len(captures))
+	}
+
+	// 间隔内匹配的请求不计数
+	ring = NewCaptureRing(8)
+	handler = captureEcho(ring, always, DebugCaptureOptions{MaxCaptures: 3, MinInterval: time.Hour})
+	for i := 0; i < 5; i++ {
+		serveCapture(handler, "x", nil)
+	}
+	if captures := ring.Captures(); len(captures) != 1 {
+		t.Fatalf("expected captures:1,got:%d", len(captures))
+	}
+}
+
+func TestCaptureRingWraps(t *testing.T) {
+	ring := NewCaptureRing(2)
+	for _, method := range []string{"A", "B", "C"} {
+		ring.Sink()(context.Background(), Capture{Method: method})
+	}
+	captures := ring.Captures()
+	if len(captures) != 2 || captures[0].Method != "B" || captures[1].Method != "C" {
+		t.Fatalf("expected captures:[B C],got:%+v", captures)
+	}
+}
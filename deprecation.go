@@ -0,0 +1,238 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DeprecationKey = "Deprecation"
+	SunsetKey      = "Sunset"
+	LinkKey        = "Link"
+)
+
+// maxDeprecationObservations 最多记录的host+path数,超过时淘汰最久没有出现的记录
+const maxDeprecationObservations = 1024
+
+// DeprecationObservation 某个host+path最近一次收到的弃用信息
+type DeprecationObservation struct {
+	Host string
+	// Path 使用WithPathParam时为路由模板,如/users/{id}
+	Path        string
+	Deprecation time.Time
+	Sunset      time.Time
+	Link        string
+	Count       uint64
+	LastSeen    time.Time
+}
+
+// ErrSunset 接口的Sunset时间已过
+type ErrSunset struct {
+	Observation DeprecationObservation
+}
+
+func (e *ErrSunset) Error() string {
+	return fmt.Sprintf("%s%s sunset at:%s", e.Observation.Host, e.Observation.Path, e.Observation.Sunset.Format(time.RFC3339))
+}
+
+// DeprecationWatchOptions DeprecationWatchTransport的配置
+type DeprecationWatchOptions struct {
+	// FailAfterSunset 为true时,Sunset时间已过的响应返回*ErrSunset,用于CI冒烟测试
+	FailAfterSunset bool
+}
+
+// DeprecationStats DeprecationWatchTransport的统计
+type DeprecationStats struct {
+	// Responses 带有弃用信息的响应数
+	Responses int64
+	// SunsetErrors FailAfterSunset时返回ErrSunset的次数
+	SunsetErrors int64
+	// Evicted 超过容量被淘汰的记录数
+	Evicted int64
+}
+
+type deprecationWatcher struct {
+	sync.Mutex
+	observations map[string]*DeprecationObservation
+	now          func() time.Time
+	responses    int64
+	sunsetErrors int64
+	evicted      int64
+}
+
+var defaultDeprecationWatcher = newDeprecationWatcher()
+
+func newDeprecationWatcher() *deprecationWatcher {
+	return &deprecationWatcher{
+		observations: make(map[string]*DeprecationObservation),
+		now:          time.Now,
+	}
+}
+
+// GetDeprecationObservations 返回收到过弃用信息的接口,按host、path排序
+func GetDeprecationObservations() []DeprecationObservation {
+	return defaultDeprecationWatcher.snapshot()
+}
+
+// GetDeprecationStats 返回DeprecationWatchTransport的统计
+func GetDeprecationStats() DeprecationStats {
+	w := defaultDeprecationWatcher
+	return DeprecationStats{
+		Responses:    atomic.LoadInt64(&w.responses),
+		SunsetErrors: atomic.LoadInt64(&w.sunsetErrors),
+		Evicted:      atomic.LoadInt64(&w.evicted),
+	}
+}
+
+func (w *deprecationWatcher) snapshot() []DeprecationObservation {
+	w.Lock()
+	defer w.Unlock()
+	observations := make([]DeprecationObservation, 0, len(w.observations))
+	for _, observation := range w.observations {
+		observations = append(observations, *observation)
+	}
+	sort.Slice(observations, func(i, j int) bool {
+		if observations[i].Host != observations[j].Host {
+			return observations[i].Host < observations[j].Host
+		}
+		return observations[i].Path < observations[j].Path
+	})
+	return observations
+}
+
+// observe 记录弃用信息,返回记录后的快照以及是否为第一次出现
+func (w *deprecationWatcher) observe(host, path string, deprecation, sunset time.Time, link string) (DeprecationObservation, bool) {
+	atomic.AddInt64(&w.responses, 1)
+	w.Lock()
+	defer w.Unlock()
+	key := host + path
+	observation, exist := w.observations[key]
+	if !exist {
+		if len(w.observations) >= maxDeprecationObservations {
+			w.evictOldest()
+		}
+		observation = &DeprecationObservation{Host: host, Path: path}
+		w.observations[key] = observation
+	}
+	observation.Deprecation = deprecation
+	observation.Sunset = sunset
+	observation.Link = link
+	observation.Count++
+	observation.LastSeen = w.now()
+	return *observation, !exist
+}
+
+func (w *deprecationWatcher) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for key, observation := range w.observations {
+		if oldestKey == "" || observation.LastSeen.Before(oldest) {
+			oldestKey, oldest = key, observation.LastSeen
+		}
+	}
+	delete(w.observations, oldestKey)
+	atomic.AddInt64(&w.evicted, 1)
+}
+
+// DeprecationWatchTransport 解析响应中的Deprecation、Sunset以及rel="deprecation"的Link,
+// 每个host+path只打印一次Warn日志,path优先使用WithPathParam的路由模板,格式错误的值忽略
+func DeprecationWatchTransport(opts DeprecationWatchOptions) TransportWrapper {
+	return defaultDeprecationWatcher.transport(opts)
+}
+
+func (w *deprecationWatcher) transport(opts DeprecationWatchOptions) TransportWrapper {
+	return NamedWrapper("deprecation_watch", fmt.Sprintf("fail_after_sunset=%t", opts.FailAfterSunset), func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			httpResp, err := next.RoundTrip(httpReq)
+			if err != nil {
+				return nil, err
+			}
+			deprecation, deprecated := parseDeprecation(httpResp.Header.Get(DeprecationKey))
+			sunset, _ := parseHTTPDate(httpResp.Header.Get(SunsetKey))
+			link := deprecationLink(httpResp.Header.Values(LinkKey))
+			if !deprecated && sunset.IsZero() && link == "" {
+				return httpResp, nil
+			}
+			path := routeTemplateFromContext(httpReq.Context())
+			if path == "" {
+				path = httpReq.URL.Path
+			}
+			observation, first := w.observe(httpReq.URL.Host, path, deprecation, sunset, link)
+			if first {
+				logWarn(httpReq.Context(), "deprecated http api",
+					FieldHTTPMethod, httpReq.Method,
+					FieldHTTPURL, httpReq.URL.String(),
+					FieldDeprecation, deprecation,
+					FieldSunset, sunset,
+					FieldDeprecationLink, link,
+				)
+			}
+			if opts.FailAfterSunset && !sunset.IsZero() && !w.now().Before(sunset) {
+				atomic.AddInt64(&w.sunsetErrors, 1)
+				httpResp.Body.Close()
+				return nil, withOutcome(&ErrSunset{Observation: observation}, OutcomeReceived)
+			}
+			return httpResp, nil
+		})
+	})
+}
+
+// parseDeprecation 支持 @unix秒(RFC 9745)、HTTP-date以及早期草案中的true
+func parseDeprecation(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if strings.EqualFold(value, "true") {
+		return time.Time{}, true
+	}
+	if strings.HasPrefix(value, "@") {
+		seconds, err := strconv.ParseInt(value[1:], 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(seconds, 0).UTC(), true
+	}
+	t, err := parseHTTPDate(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func parseHTTPDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t, nil
+}
+
+// deprecationLink 返回rel="deprecation"的链接
+func deprecationLink(values []string) string {
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			target, params, found := strings.Cut(link, ";")
+			if !found {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(key, "rel") && strings.EqualFold(strings.Trim(val, `"`), "deprecation") {
+					return strings.Trim(strings.TrimSpace(target), "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
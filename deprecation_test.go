@@ -0,0 +1,105 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func resetDeprecationWatcher(t *testing.T, now time.Time) {
+	prev := defaultDeprecationWatcher
+	defaultDeprecationWatcher = newDeprecationWatcher()
+	defaultDeprecationWatcher.now = func() time.Time { return now }
+	t.Cleanup(func() {
+		defaultDeprecationWatcher = prev
+	})
+}
+
+func deprecatedServer(t *testing.T, deprecation, sunset, link string) string {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(DeprecationKey, deprecation)
+		w.Header().Set(SunsetKey, sunset)
+		w.Header().Set(LinkKey, link)
+		w.Write([]byte(`{}`))
+	}))
+	return server.URL
+}
+
+func TestDeprecationWatch(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	resetDeprecationWatcher(t, now)
+	logs := testkit.CaptureLogs(t)
+	url := deprecatedServer(t, "@1688169599", "Sat, 01 Jun 2024 00:00:00 GMT",
+		`<https://example.com/next>; rel="successor-version", <https://example.com/deprecation>; rel="deprecation"`)
+
+	// 按路由模板记录,不同的id只提示一次
+	for i := 0; i < 3; i++ {
+		if err := Get(url+"/v1/users/{id}").WithPathParam("id", strconv.Itoa(i)).Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(logs.Find("deprecated http api")); got != 1 {
+		t.Fatalf("expected 1 warn,got:%d", got)
+	}
+	logs.AssertField(t, "deprecated http api", FieldDeprecationLink, "https://example.com/deprecation")
+
+	observations := GetDeprecationObservations()
+	if len(observations) != 1 {
+		t.Fatalf("expected 1 observation,got:%v", observations)
+	}
+	observation := observations[0]
+	if observation.Path != "/v1/users/{id}" || observation.Count != 3 ||
+		!observation.Deprecation.Equal(time.Unix(1688169599, 0)) ||
+		!observation.Sunset.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected observation:%+v", observation)
+	}
+	if stats := GetDeprecationStats(); stats.Responses != 3 {
+		t.Fatalf("expected responses:3,got:%+v", stats)
+	}
+
+	// 没有模板的path同样按原始path记录,记录数有上限
+	for i := 0; i < maxDeprecationObservations+10; i++ {
+		defaultDeprecationWatcher.observe("example.com", "/v1/raw/"+strconv.Itoa(i), time.Time{}, time.Time{}, "")
+	}
+	if got := len(GetDeprecationObservations()); got != maxDeprecationObservations {
+		t.Fatalf("expected observations:%d,got:%d", maxDeprecationObservations, got)
+	}
+	if stats := GetDeprecationStats(); stats.Evicted != 11 {
+		t.Fatalf("expected evicted:11,got:%+v", stats)
+	}
+}
+
+func TestDeprecationWatchMalformed(t *testing.T) {
+	resetDeprecationWatcher(t, time.Now())
+	url := deprecatedServer(t, "@soon", "next tuesday", `<https://example.com>; rel="next"`)
+	if err := Get(url).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if observations := GetDeprecationObservations(); len(observations) != 0 {
+		t.Fatalf("expected malformed headers to be ignored,got:%v", observations)
+	}
+}
+
+func TestDeprecationWatchFailAfterSunset(t *testing.T) {
+	resetDeprecationWatcher(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	url := deprecatedServer(t, "true", "Sat, 01 Jun 2024 00:00:00 GMT", "")
+	client := &http.Client{
+		Transport: WrapTransport(http.DefaultTransport, DeprecationWatchTransport(DeprecationWatchOptions{FailAfterSunset: true})),
+	}
+	err := Get(url).DoWithClient(context.Background(), client)
+	var sunsetErr *ErrSunset
+	if !errors.As(err, &sunsetErr) || OutcomeFromError(err) != OutcomeReceived {
+		t.Fatalf("expected ErrSunset,got:%v", err)
+	}
+	if stats := GetDeprecationStats(); stats.SunsetErrors != 1 {
+		t.Fatalf("expected sunset errors:1,got:%+v", stats)
+	}
+	if err := Get(url).Do(context.Background()); err != nil {
+		t.Fatalf("expected default watch to only warn,got:%v", err)
+	}
+}
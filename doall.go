@@ -0,0 +1,135 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DoAllOptions DoAllDetailed的配置
+type DoAllOptions struct {
+	// FailFast 任一请求失败后取消其余请求
+	FailFast bool
+	// Concurrency 最大并发数,<=0时不限制
+	Concurrency int
+}
+
+// RequestResult 单个请求的执行结果
+type RequestResult struct {
+	// Summary Builder.Describe()的结果
+	Summary    string
+	URL        string
+	Duration   time.Duration
+	StatusCode int
+	Resp       interface{}
+	Err        error
+	// Attempts 每次尝试的记录,与WithAttemptHistory得到的相同
+	Attempts []AttemptRecord
+}
+
+// MultiError 多个请求的错误,支持errors.Is/As
+type MultiError struct {
+	Results []RequestResult
+}
+
+func (e *MultiError) Error() string {
+	var msgs []string
+	for _, result := range e.Results {
+		if result.Err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s:%s", result.Summary, result.Err))
+		}
+	}
+	return fmt.Sprintf("%d requests failed: %s", len(msgs), strings.Join(msgs, "; "))
+}
+
+func (e *MultiError) Unwrap() []error {
+	var errs []error
+	for _, result := range e.Results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	return errs
+}
+
+// DoAll 并发执行builders,有失败时返回*MultiError
+func DoAll(ctx context.Context, builders ...Builder) error {
+	_, err := DoAllDetailed(ctx, builders, DoAllOptions{})
+	return err
+}
+
+// DoAllDetailed 并发执行builders,返回与builders一一对应的结果,有失败时返回*MultiError
+func DoAllDetailed(ctx context.Context, builders []Builder, opts DoAllOptions) ([]RequestResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]RequestResult, len(builders))
+	var sem chan struct{}
+	if opts.Concurrency > 0 {
+		sem = make(chan struct{}, opts.Concurrency)
+	}
+	var wg sync.WaitGroup
+	for idx, b := range builders {
+		wg.Add(1)
+		go func(idx int, b Builder) {
+			defer wg.Done()
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					results[idx] = RequestResult{Summary: b.Describe(), Err: ctx.Err()}
+					return
+				}
+			}
+			results[idx] = doDetailed(ctx, b)
+			if results[idx].Err != nil && opts.FailFast {
+				cancel()
+			}
+		}(idx, b)
+	}
+	wg.Wait()
+	for _, result := range results {
+		if result.Err != nil {
+			return results, &MultiError{Results: results}
+		}
+	}
+	return results, nil
+}
+
+func doDetailed(ctx context.Context, b Builder) RequestResult {
+	result := RequestResult{
+		Summary: b.Describe(),
+	}
+	raw, ok := b.(*builder)
+	if !ok {
+		start := time.Now()
+		result.Err = b.Do(ctx)
+		result.Duration = time.Since(start)
+		return result
+	}
+	result.Resp = raw.resp
+	// 状态码在状态码检查之前记录,检查失败时也能拿到
+	statusCode := raw.respStatusCode
+	newBuilder := Builder(raw)
+	if statusCode == nil {
+		statusCode = new(int)
+		newBuilder = newBuilder.WithRespStatusCode(statusCode)
+	}
+	history := raw.history
+	if history == nil {
+		history = &[]AttemptRecord{}
+		newBuilder = newBuilder.WithAttemptHistory(history)
+	}
+	start := time.Now()
+	result.Err = newBuilder.Do(ctx)
+	result.Duration = time.Since(start)
+	result.StatusCode = *statusCode
+	result.Attempts = *history
+	// 使用实际发出的请求的url
+	if len(result.Attempts) != 0 {
+		result.URL = result.Attempts[len(result.Attempts)-1].URL
+	}
+	return result
+}
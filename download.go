@@ -0,0 +1,54 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// progressWriter 每次写入后回调已写入与总字节数,总字节数未知时为-1
+type progressWriter struct {
+	w       io.Writer
+	fn      func(written, total int64)
+	written int64
+	total   int64
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	n, err := p.w.Write(data)
+	p.written += int64(n)
+	p.fn(p.written, p.total)
+	return n, err
+}
+
+// DownloadFile 将响应体写入同目录下的临时文件,成功后rename为path,
+// 失败或ctx取消时删除临时文件,已有的文件保持不变;path的父目录不存在时会被创建
+func (b *builder) DownloadFile(ctx context.Context, path string) error {
+	if b.err != nil {
+		return b.err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	newBuilder := b.cloneTransportIf(b.respWriter == nil)
+	newBuilder.respWriter = tmp
+	err = newBuilder.Do(ctx)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
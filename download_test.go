@@ -0,0 +1,82 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestDownloadFile(t *testing.T) {
+	const size = 3 << 20
+	content := bytes.Repeat([]byte("0123456789abcdef"), size/16)
+	checksum := sha256.Sum256(content)
+	block := make(chan struct{})
+	defer close(block)
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case "/stuck":
+			w.Header().Set("Content-Length", strconv.Itoa(size))
+			w.Write(content[:1<<16])
+			w.(http.Flusher).Flush()
+			select {
+			case <-block:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		w.Write(content)
+	}))
+
+	path := filepath.Join(t.TempDir(), "a", "b", "artifact.bin")
+	var lastWritten, lastTotal int64
+	err := Get(server.URL+"/artifact").
+		WithProgress(func(written, total int64) {
+			if written < lastWritten {
+				t.Errorf("expected progress to grow,got:%d after %d", written, lastWritten)
+			}
+			lastWritten, lastTotal = written, total
+		}).
+		DownloadFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sha256.Sum256(data) != checksum || lastWritten != size || lastTotal != size {
+		t.Fatalf("unexpected download:len=%d,progress=%d/%d", len(data), lastWritten, lastTotal)
+	}
+
+	// 失败时已有的文件保持不变,临时文件被删除
+	statusErr := &ErrUnexpectedStatusCode{}
+	if err := Get(server.URL+"/missing").DownloadFile(context.Background(), path); !errors.As(err, &statusErr) {
+		t.Fatalf("expected statuscode error,got:%v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	err = Get(server.URL+"/stuck").
+		WithProgress(func(written, total int64) { cancel() }).
+		DownloadFile(ctx, path)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled,got:%v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 || entries[0].Name() != "artifact.bin" {
+		t.Fatalf("expected temp files to be removed,got:%v", entries)
+	}
+	if data, _ := os.ReadFile(path); sha256.Sum256(data) != checksum {
+		t.Fatal("expected existing file to be kept")
+	}
+}
@@ -0,0 +1,62 @@
+package httpx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// dumpMu 写入同一个writer的dump不会交错
+	dumpMu  sync.Mutex
+	dumpSeq uint64
+)
+
+// writeDump 一次写入完整的一段dump
+func writeDump(w io.Writer, seq uint64, kind string, data []byte) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "=== #%d %s %s ===\n", seq, kind, time.Now().Format(time.RFC3339Nano))
+	buf.Write(data)
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	dumpMu.Lock()
+	defer dumpMu.Unlock()
+	w.Write(buf.Bytes())
+}
+
+// DumpTransport 使用httputil把线上的请求与响应原样写入w,同一个请求的dump带有相同的序号;
+// dumpBody为true时同时写入body,之后还原body,不影响解码。body会被完整读入内存,只用于调试
+func DumpTransport(w io.Writer, dumpBody bool) TransportWrapper {
+	return NamedWrapper("dump", fmt.Sprintf("body=%t", dumpBody), func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			seq := atomic.AddUint64(&dumpSeq, 1)
+			reqData, err := httputil.DumpRequestOut(httpReq, dumpBody)
+			if err != nil {
+				if httpReq.Body != nil {
+					httpReq.Body.Close()
+				}
+				return nil, withOutcome(err, OutcomeNotSent)
+			}
+			writeDump(w, seq, "request", reqData)
+			httpResp, err := next.RoundTrip(httpReq)
+			if err != nil {
+				writeDump(w, seq, "error", []byte(err.Error()))
+				return nil, err
+			}
+			// 101的body是双向连接,不能读取
+			respData, err := httputil.DumpResponse(httpResp, dumpBody && httpResp.StatusCode != http.StatusSwitchingProtocols)
+			if err != nil {
+				httpResp.Body.Close()
+				return nil, err
+			}
+			writeDump(w, seq, "response", respData)
+			return httpResp, nil
+		})
+	})
+}
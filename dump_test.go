@@ -0,0 +1,69 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestWithDump(t *testing.T) {
+	server := testkit.NewServer(t, testkit.Echo())
+	var buf bytes.Buffer
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data := fmt.Sprintf("req-%d", i)
+			resp := map[string]string{}
+			err := Post(server.URL).Logging(false, false).WithDump(&buf).WithReq(map[string]string{"data": data}).WithResp(&resp).Do(context.Background())
+			if err == nil && resp["data"] != data {
+				err = fmt.Errorf("expected resp data:%s,got:%v", data, resp)
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 每一段都是完整的请求或响应,请求与响应的序号一一对应
+	blocks := regexp.MustCompile(`(?m)^=== #(\d+) (\w+) \S+ ===\n`).FindAllStringSubmatchIndex(buf.String(), -1)
+	if len(blocks) != 20 {
+		t.Fatalf("expected 20 dump blocks,got:%d\n%s", len(blocks), buf.String())
+	}
+	kinds := make(map[string][]string)
+	for i, block := range blocks {
+		end := buf.Len()
+		if i+1 < len(blocks) {
+			end = blocks[i+1][0]
+		}
+		seq, kind, body := buf.String()[block[2]:block[3]], buf.String()[block[4]:block[5]], buf.String()[block[1]:end]
+		kinds[seq] = append(kinds[seq], kind)
+		if !strings.Contains(body, `{"data":"req-`) {
+			t.Fatalf("expected %s dump with body,got:%s", kind, body)
+		}
+		if kind == "request" && !strings.HasPrefix(body, "POST / HTTP/1.1\r\n") {
+			t.Fatalf("expected request line,got:%s", body)
+		}
+		if kind == "response" && !strings.HasPrefix(body, "HTTP/1.1 200 OK\r\n") {
+			t.Fatalf("expected status line,got:%s", body)
+		}
+	}
+	for seq, got := range kinds {
+		if len(got) != 2 || got[0] != "request" || got[1] != "response" {
+			t.Fatalf("expected request and response for #%s,got:%v", seq, got)
+		}
+	}
+}
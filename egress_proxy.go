@@ -0,0 +1,46 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ErrProxyWithTransport WithProxy无法作用于WithTransport指定的transport
+var ErrProxyWithTransport = errors.New("WithProxy can not be combined with WithTransport")
+
+// parseProxyURL 支持http、https与socks5,用户名密码写在url中
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url:%w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy url %q:unsupported scheme %q", u.Redacted(), u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %q:missing host", u.Redacted())
+	}
+	return u, nil
+}
+
+// ProxyTransport 构造通过proxyURL访问的transport,支持http、https与socks5,
+// url中的用户名密码用于代理认证;经过代理的https请求不使用InsecureForHosts与TLS握手hooks
+func ProxyTransport(proxyURL string) (http.RoundTripper, error) {
+	u, err := parseProxyURL(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	return newTransport(transportConfig{proxy: u.String()}), nil
+}
+
+// proxyErr 代理配置在共享的transport上,不能与自定义transport同时使用
+func (b *builder) proxyErr() error {
+	if b.proxy != "" && b.transport != nil {
+		return ErrProxyWithTransport
+	}
+	return nil
+}
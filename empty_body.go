@@ -0,0 +1,74 @@
+package httpx
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+)
+
+// blankPeekBytes 最多查看的前导空白字节数,缓冲区为空时大的Read直接读取原body
+const blankPeekBytes = 64
+
+// emptyResp 没有内容的响应不解码,除非设置了RequireResponseBody;
+// 带业务码的codec要求其他状态码必须有body,空body按解码失败处理;
+// 需要查看body时用bufio包装httpResp.Body,不消耗任何数据
+func (b *builder) emptyResp(httpResp *http.Response) bool {
+	if b.requireRespBody {
+		return false
+	}
+	switch httpResp.StatusCode {
+	case http.StatusNoContent, http.StatusResetContent, http.StatusNotModified:
+		return true
+	}
+	if isEnvelopeCodec(b.codec) {
+		return false
+	}
+	if httpResp.ContentLength == 0 {
+		return true
+	}
+	reader := bufio.NewReaderSize(httpResp.Body, blankPeekBytes)
+	httpResp.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, httpResp.Body}
+	return blankPrefix(reader)
+}
+
+// blankPrefix 在不消耗数据的情况下判断剩余内容是否只有空白,空白超过缓冲区大小时视为有内容
+func blankPrefix(reader *bufio.Reader) bool {
+	for n := 1; n <= reader.Size(); n++ {
+		data, err := reader.Peek(n)
+		if len(data) < n {
+			return err == io.EOF
+		}
+		switch data[n-1] {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+func isEnvelopeCodec(codec Codec) bool {
+	switch codec.(type) {
+	case *StatusJsonCodec, *statusEnvelope:
+		return true
+	}
+	return false
+}
+
+// validateEmptyResp 没有内容的响应不解码,但WithRespValidator仍然执行,raw为剩余的空白内容
+func (b *builder) validateEmptyResp(httpResp *http.Response) error {
+	if b.respValidator == nil {
+		return nil
+	}
+	raw, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if err := b.respValidator(respContext(httpResp), b.resp, raw); err != nil {
+		return &ErrResponseValidation{Raw: raw, Err: err}
+	}
+	return nil
+}
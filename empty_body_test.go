@@ -0,0 +1,87 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestEmptyRespBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		chunked    bool
+	}{
+		{name: "no content", statusCode: http.StatusNoContent},
+		{name: "empty 200", statusCode: http.StatusOK},
+		{name: "empty chunked 200", statusCode: http.StatusOK, chunked: true},
+		{name: "whitespace", statusCode: http.StatusOK, body: " \r\n\t\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				io.WriteString(w, tt.body)
+				if tt.chunked {
+					w.(http.Flusher).Flush()
+				}
+			}))
+			resp := &pooledPayload{ID: "untouched"}
+			if err := Delete(server.URL).Expect2xx().WithResp(resp).Do(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if resp.ID != "untouched" {
+				t.Fatalf("expected resp to be untouched,got:%+v", resp)
+			}
+			err := Delete(server.URL).Expect2xx().WithResp(resp).RequireResponseBody().Do(context.Background())
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("expected EOF with RequireResponseBody,got:%v", err)
+			}
+		})
+	}
+
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "  \n{\"id\":\"a\"}")
+	}))
+	resp := &pooledPayload{}
+	if err := Get(server.URL).WithResp(resp).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "a" {
+		t.Fatalf("expected id:a,got:%s", resp.ID)
+	}
+}
+
+func TestEmptyRespBodyValidator(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	errEmpty := errors.New("empty body")
+	var called bool
+	err := Get(server.URL).WithRespValidator(func(ctx context.Context, decoded interface{}, raw []byte) error {
+		called = true
+		if len(raw) == 0 {
+			return errEmpty
+		}
+		return nil
+	}).Do(context.Background())
+	validationErr := &ErrResponseValidation{}
+	if !called || !errors.As(err, &validationErr) || !errors.Is(err, errEmpty) {
+		t.Fatalf("expected validation error,got:%v", err)
+	}
+
+	// 带业务码的codec不把空body当作成功
+	resp := &pooledPayload{}
+	if err := Get(server.URL).WithCodec(&StatusJsonCodec{}).WithResp(resp).Do(context.Background()); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF with StatusJsonCodec,got:%v", err)
+	}
+	noContent := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	if err := Get(noContent.URL).Expect2xx().WithCodec(&StatusJsonCodec{}).WithResp(resp).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
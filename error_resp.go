@@ -0,0 +1,61 @@
+package httpx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	// errorBodySnippetBytes 错误信息中最多展示的响应体长度
+	errorBodySnippetBytes = 256
+)
+
+// ErrErrorResp 状态码不符合预期时WithErrorResp的解码结果,
+// 解码成功时Resp为WithErrorResp传入的对象,失败时DecodeErr不为nil
+type ErrErrorResp struct {
+	StatusCode int
+	Resp       interface{}
+	Body       []byte
+	DecodeErr  error
+	statusErr  *ErrUnexpectedStatusCode
+}
+
+func (e *ErrErrorResp) Error() string {
+	if e.DecodeErr != nil {
+		return fmt.Sprintf("unexpected statuscode:%d,body:%q,decode error resp:%s", e.StatusCode, bodySnippet(e.Body), e.DecodeErr)
+	}
+	return fmt.Sprintf("unexpected statuscode:%d,error resp:%+v", e.StatusCode, e.Resp)
+}
+
+// Unwrap 返回ErrUnexpectedStatusCode,重试等逻辑仍然可以识别状态码
+func (e *ErrErrorResp) Unwrap() error {
+	return e.statusErr
+}
+
+// bodySnippet 截断错误信息中的响应体
+func bodySnippet(body []byte) []byte {
+	if len(body) > errorBodySnippetBytes {
+		return body[:errorBodySnippetBytes]
+	}
+	return body
+}
+
+// decodeErrorResp err为ErrUnexpectedStatusCode时将响应体解码到errorResp
+func (b *builder) decodeErrorResp(err error) error {
+	var statusErr *ErrUnexpectedStatusCode
+	if b.errorResp == nil || !errors.As(err, &statusErr) {
+		return err
+	}
+	errResp := &ErrErrorResp{StatusCode: statusErr.Got, Body: statusErr.Body, statusErr: statusErr}
+	codec, decodeErr := b.respCodec(statusErr.Header.Get(ContentTypeKey), bytes.NewReader(statusErr.Body))
+	if decodeErr == nil {
+		decodeErr = codec.Decode(bytes.NewReader(statusErr.Body), b.errorResp)
+	}
+	if decodeErr != nil {
+		errResp.DecodeErr = decodeErr
+	} else {
+		errResp.Resp = b.errorResp
+	}
+	return withOutcome(errResp, OutcomeReceived)
+}
@@ -0,0 +1,63 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+type apiError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func TestWithErrorResp(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":{"code":"conflict","message":"already exists"}}`))
+		case "/html":
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>" + strings.Repeat("x", 1024) + "</html>"))
+		default:
+			w.Write([]byte(`{"data":"ok"}`))
+		}
+	}))
+
+	resp := map[string]string{"data": "untouched"}
+	errResp := &apiError{}
+	err := Get(server.URL + "/json").WithResp(&resp).WithErrorResp(errResp).Do(context.Background())
+	target := &ErrErrorResp{}
+	if !errors.As(err, &target) || target.StatusCode != http.StatusConflict || target.DecodeErr != nil || target.Resp != errResp {
+		t.Fatalf("expected error resp,got:%v", err)
+	}
+	if errResp.Error.Code != "conflict" || errResp.Error.Message != "already exists" {
+		t.Fatalf("unexpected error resp:%+v", errResp)
+	}
+	if resp["data"] != "untouched" {
+		t.Fatalf("expected resp untouched,got:%v", resp)
+	}
+	statusErr := &ErrUnexpectedStatusCode{}
+	if !errors.As(err, &statusErr) || statusErr.Got != http.StatusConflict || OutcomeFromError(err) != OutcomeReceived {
+		t.Fatalf("expected wrapped statuscode error,got:%v", err)
+	}
+
+	err = Get(server.URL + "/html").WithErrorResp(&apiError{}).Do(context.Background())
+	if !errors.As(err, &target) || target.StatusCode != http.StatusBadGateway || target.DecodeErr == nil || target.Resp != nil {
+		t.Fatalf("expected decode failure,got:%v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "unexpected statuscode:502") || !strings.Contains(msg, `body:"<html>xxx`) || strings.Contains(msg, "</html>") {
+		t.Fatalf("expected status and body snippet,got:%s", msg)
+	}
+
+	if err := Get(server.URL).WithResp(&resp).WithErrorResp(&apiError{}).Do(context.Background()); err != nil || resp["data"] != "ok" {
+		t.Fatalf("expected success,got:%v,%v", err, resp)
+	}
+}
@@ -6,9 +6,9 @@ require (
 	github.com/google/go-querystring v1.1.0
 	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.44.0
 	go.opentelemetry.io/otel v1.18.0
-	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.18.0
 	go.opentelemetry.io/otel/sdk v1.18.0
 	go.opentelemetry.io/otel/trace v1.18.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,210 @@
+package httpx
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressibleTypes GzipHandler压缩的Content-Type,另外包括text/*以及+json、+xml后缀
+var compressibleTypes = map[string]struct{}{
+	"application/json":       {},
+	"application/javascript": {},
+	"application/xml":        {},
+	ContentTypeNDJSON:        {},
+	"image/svg+xml":          {},
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if _, exist := compressibleTypes[mediaType]; exist {
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// acceptsGzip Accept-Encoding中包含q不为0的gzip
+func acceptsGzip(header http.Header) bool {
+	for _, value := range header.Values("Accept-Encoding") {
+		for _, item := range strings.Split(value, ",") {
+			encoding, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+			if !strings.EqualFold(strings.TrimSpace(encoding), ContentEncodingGzip) {
+				continue
+			}
+			q, exist := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			if !exist {
+				return true
+			}
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+	}
+	return false
+}
+
+// GzipHandler 客户端接受gzip、响应体达到minSize字节且Content-Type可压缩时按level压缩响应,
+// minSize<=0时总是压缩,level为0或无效时使用gzip.DefaultCompression;
+// 应放在WrapHandler中LoggingHandler之前,使日志记录压缩前的响应体;支持Flush
+func GzipHandler(minSize int, level int) HandlerWrapper {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil || level == gzip.NoCompression {
+		level = gzip.DefaultCompression
+	}
+	pool := &sync.Pool{
+		New: func() interface{} {
+			gz, _ := gzip.NewWriterLevel(io.Discard, level)
+			return gz
+		},
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+			if httpReq.Method == http.MethodHead || httpReq.Header.Get("Connection") == "Upgrade" {
+				next.ServeHTTP(w, httpReq)
+				return
+			}
+			gw := &gzipResponseWriter{
+				ResponseWriter: w,
+				out:            w,
+				pool:           pool,
+				minSize:        minSize,
+				accept:         acceptsGzip(httpReq.Header),
+				statusCode:     http.StatusOK,
+			}
+			// 在LoggingHandler之内时压缩后的数据直接写出,日志缓存压缩前的数据
+			if rw, ok := w.(*responseWriterWrapper); ok {
+				gw.capture = rw
+				gw.out = rw.ResponseWriter
+			}
+			defer gw.close()
+			next.ServeHTTP(gw, httpReq)
+		})
+	}
+}
+
+// gzipResponseWriter 缓存不足minSize的响应体,确定是否压缩后再写出响应头
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	out     io.Writer
+	capture *responseWriterWrapper
+	pool    *sync.Pool
+	minSize int
+	accept  bool
+
+	statusCode int
+	decided    bool
+	buf        []byte
+	gz         *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(statusCode int) {
+	if statusCode < http.StatusOK || w.decided {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.statusCode = statusCode
+	// 没有响应体的状态码不需要等待
+	switch statusCode {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		w.decide(false)
+	}
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get(ContentEncodingKey) != "" {
+			w.decide(false)
+		} else {
+			w.buf = append(w.buf, data...)
+			if len(w.buf) < w.minSize {
+				return len(data), nil
+			}
+			w.decide(true)
+			pending := w.buf
+			w.buf = nil
+			if _, err := w.write(pending); err != nil {
+				return 0, err
+			}
+			return len(data), nil
+		}
+	}
+	return w.write(data)
+}
+
+func (w *gzipResponseWriter) write(data []byte) (int, error) {
+	if w.gz == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	if _, err := w.gz.Write(data); err != nil {
+		return 0, err
+	}
+	if w.capture != nil {
+		w.capture.record(data)
+	}
+	return len(data), nil
+}
+
+// decide 写出响应头,sized为true且满足条件时开始压缩
+func (w *gzipResponseWriter) decide(sized bool) {
+	w.decided = true
+	header := w.Header()
+	if header.Get(ContentTypeKey) == "" && len(w.buf) != 0 {
+		header.Set(ContentTypeKey, http.DetectContentType(w.buf))
+	}
+	if sized && header.Get(ContentEncodingKey) == "" && compressible(header.Get(ContentTypeKey)) {
+		header.Add("Vary", "Accept-Encoding")
+		if w.accept {
+			header.Set(ContentEncodingKey, ContentEncodingGzip)
+			header.Del("Content-Length")
+			w.gz = w.pool.Get().(*gzip.Writer)
+			w.gz.Reset(w.out)
+			if w.capture != nil {
+				w.capture.uncompressed = true
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.statusCode)
+}
+
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		pending := w.buf
+		w.decide(len(pending) >= w.minSize)
+		w.buf = nil
+		w.write(pending)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.out.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close handler返回后写出缓存的响应体并结束压缩
+func (w *gzipResponseWriter) close() {
+	if !w.decided {
+		pending := w.buf
+		w.decide(false)
+		w.buf = nil
+		if len(pending) != 0 {
+			w.write(pending)
+		}
+	}
+	if w.gz == nil {
+		return
+	}
+	w.gz.Close()
+	w.gz.Reset(io.Discard)
+	w.pool.Put(w.gz)
+	w.gz = nil
+}
@@ -0,0 +1,163 @@
+package httpx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestGzipHandlerRoundTrip(t *testing.T) {
+	type resp struct {
+		Data string `json:"data"`
+	}
+	data := strings.Repeat("gzip", 256)
+	logs := testkit.CaptureLogs(t)
+	server := testkit.NewServer(t, WrapHandler(JsonHandler(func(ctx context.Context, req struct{}) (resp, error) {
+		return resp{Data: data}, nil
+	}), GzipHandler(512, 0), LoggingHandler(false, true)))
+
+	tests := []struct {
+		name               string
+		disableCompression bool
+		acceptEncoding     string
+		uncompressed       bool
+		contentEncoding    string
+	}{
+		{name: "transparent", uncompressed: true},
+		{name: "disable compression", disableCompression: true},
+		{name: "disable compression with accept encoding", disableCompression: true, acceptEncoding: ContentEncodingGzip, contentEncoding: ContentEncodingGzip},
+	}
+	for _, tt := range tests {
+		b := Get(server.URL).WithTransportOptions(TransportOptions{DisableCompression: tt.disableCompression})
+		if tt.acceptEncoding != "" {
+			b = b.WithHeader("Accept-Encoding", tt.acceptEncoding)
+		}
+		httpResp, err := b.DoRaw(context.Background())
+		if err != nil {
+			t.Fatalf("%s:%v", tt.name, err)
+		}
+		body, err := io.ReadAll(httpResp.Body)
+		httpResp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s:%v", tt.name, err)
+		}
+		if httpResp.Uncompressed != tt.uncompressed {
+			t.Fatalf("%s:expected uncompressed:%t,got:%t", tt.name, tt.uncompressed, httpResp.Uncompressed)
+		}
+		if got := httpResp.Header.Get(ContentEncodingKey); got != tt.contentEncoding {
+			t.Fatalf("%s:expected Content-Encoding:%s,got:%s", tt.name, tt.contentEncoding, got)
+		}
+		if tt.contentEncoding == ContentEncodingGzip {
+			gz, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("%s:%v", tt.name, err)
+			}
+			if body, err = io.ReadAll(gz); err != nil {
+				t.Fatalf("%s:%v", tt.name, err)
+			}
+		}
+		if !strings.Contains(string(body), data) {
+			t.Fatalf("%s:unexpected body:%s", tt.name, body)
+		}
+	}
+
+	var r resp
+	if err := Get(server.URL).WithResp(&r).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.Data != data {
+		t.Fatalf("expected data:%s,got:%s", data, r.Data)
+	}
+	records := logs.Find("serve http req")
+	if len(records) != len(tests)+1 {
+		t.Fatalf("expected %d access logs,got:%d", len(tests)+1, len(records))
+	}
+	for _, record := range records {
+		if _, omitted := record.Attrs[FieldRespBodyOmitted]; omitted || !strings.Contains(fmt.Sprint(record.Attrs[FieldRespData]), data) {
+			t.Fatalf("expected uncompressed body in log,got:%v", record.Attrs)
+		}
+	}
+}
+
+func TestGzipHandler(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentType     string
+		body            string
+		contentEncoding string
+	}{
+		{name: "compressible", contentType: ContentTypeJson, body: strings.Repeat("a", 64), contentEncoding: ContentEncodingGzip},
+		{name: "sniffed", body: strings.Repeat("a", 64), contentEncoding: ContentEncodingGzip},
+		{name: "small", contentType: ContentTypeJson, body: "a"},
+		{name: "binary", contentType: "image/png", body: strings.Repeat("a", 64)},
+	}
+	for _, tt := range tests {
+		handler := GzipHandler(32, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tt.contentType != "" {
+				w.Header().Set(ContentTypeKey, tt.contentType)
+			}
+			w.Header().Set("Content-Length", fmt.Sprint(len(tt.body)))
+			io.WriteString(w, tt.body)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "deflate, gzip;q=0.5")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get(ContentEncodingKey); got != tt.contentEncoding {
+			t.Fatalf("%s:expected Content-Encoding:%s,got:%s", tt.name, tt.contentEncoding, got)
+		}
+		if tt.contentEncoding == "" {
+			if rec.Body.String() != tt.body {
+				t.Fatalf("%s:expected body:%s,got:%s", tt.name, tt.body, rec.Body.String())
+			}
+			continue
+		}
+		if got := rec.Header().Get("Content-Length"); got != "" {
+			t.Fatalf("%s:expected no Content-Length,got:%s", tt.name, got)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Fatalf("%s:expected Vary:Accept-Encoding,got:%s", tt.name, got)
+		}
+		gz, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("%s:%v", tt.name, err)
+		}
+		body, _ := io.ReadAll(gz)
+		if string(body) != tt.body {
+			t.Fatalf("%s:expected body:%s,got:%s", tt.name, tt.body, body)
+		}
+	}
+}
+
+func TestGzipHandlerFlush(t *testing.T) {
+	handler := WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ContentTypeKey, ContentTypeNDJSON)
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "{\"i\":%d}\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}), GzipHandler(0, gzip.BestSpeed), LoggingHandler(false, true))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", ContentEncodingGzip)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if !rec.Flushed {
+		t.Fatal("expected response to be flushed")
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(gz)
+	if expected := "{\"i\":0}\n{\"i\":1}\n{\"i\":2}\n"; string(body) != expected {
+		t.Fatalf("expected body:%s,got:%s", expected, body)
+	}
+}
@@ -0,0 +1,98 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// ErrHTTPStatus handler返回该错误时响应Status,并用Codec编码Code、Message与Details作为响应体
+type ErrHTTPStatus struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code,omitempty"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e *ErrHTTPStatus) Error() string {
+	return fmt.Sprintf("http status:%d,code:%s,message:%s", e.Status, e.Code, e.Message)
+}
+
+// ErrorMapper 将handler返回的领域错误映射为ErrHTTPStatus,返回nil时按未映射的错误处理
+type ErrorMapper func(error) *ErrHTTPStatus
+
+// WithErrorMapper handler的错误不是ErrHTTPStatus时先经过mapper,如sql.ErrNoRows映射为404
+func WithErrorMapper(mapper ErrorMapper) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.errorMapper = mapper
+	}
+}
+
+// WithStatusEnvelope 按opts把响应包装为{code, msg, data},错误也以200响应,见StatusJsonHandler
+func WithStatusEnvelope(opts StatusEnvelopeOptions) HandlerOption {
+	return func(handlerOpts *handlerOptions) {
+		handlerOpts.envelope = newStatusEnvelope(opts)
+	}
+}
+
+// errInternal 未映射的错误统一响应500,不向调用方暴露原始错误
+var errInternal = &ErrHTTPStatus{Status: http.StatusInternalServerError, Code: "internal", Message: "internal server error"}
+
+// httpStatusErr 依次使用err中的ErrHTTPStatus与mapper的结果,都没有时记录原始错误并返回errInternal
+func httpStatusErr(ctx context.Context, mapper ErrorMapper, err error) *ErrHTTPStatus {
+	var statusErr *ErrHTTPStatus
+	if errors.As(err, &statusErr) && statusErr.Status != 0 {
+		return statusErr
+	}
+	if mapper != nil {
+		if statusErr := mapper(err); statusErr != nil && statusErr.Status != 0 {
+			return statusErr
+		}
+	}
+	logAt(ctx, nil, nil, slog.LevelError, "handler error", FieldErr, err)
+	return errInternal
+}
+
+// writeHTTPStatusErr 编码失败时只写状态码
+func writeHTTPStatusErr(w http.ResponseWriter, codec Codec, statusErr *ErrHTTPStatus) {
+	data, err := codec.Encode(statusErr)
+	if err != nil {
+		w.WriteHeader(statusErr.Status)
+		return
+	}
+	if typer, ok := codec.(ContentTyper); ok {
+		w.Header().Set(ContentTypeKey, typer.ContentType())
+	} else if isJsonCodec(codec) {
+		w.Header().Set(ContentTypeKey, ContentTypeJson)
+	}
+	w.WriteHeader(statusErr.Status)
+	w.Write(data)
+}
+
+func (o *handlerOptions) writeErr(w http.ResponseWriter, codec Codec, statusErr *ErrHTTPStatus) {
+	if o.envelope == nil {
+		writeHTTPStatusErr(w, codec, statusErr)
+		return
+	}
+	code := statusErr.Code
+	if code == "" {
+		code = strconv.Itoa(statusErr.Status)
+	}
+	data, err := o.envelope.wrap(code, statusErr.Message, statusErr.Details)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(ContentTypeKey, ContentTypeJson)
+	w.Write(data)
+}
+
+func (o *handlerOptions) encodeResp(codec Codec, respObj interface{}) ([]byte, error) {
+	if o.envelope == nil {
+		return codec.Encode(respObj)
+	}
+	return o.envelope.wrap(o.envelope.opts.SuccessCodes[0], "", respObj)
+}
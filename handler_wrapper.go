@@ -1,38 +1,82 @@
 package httpx
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
-	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	defaultHandlerTimeout = time.Second * 10
 )
 
-func JsonHandler[Req, Resp any](handler func(ctx context.Context, req Req) (Resp, error)) http.Handler {
-	return Handler(defaultCodec, handler)
+func JsonHandler[Req, Resp any](handler func(ctx context.Context, req Req) (Resp, error), opts ...HandlerOption) http.Handler {
+	return Handler(defaultCodec, handler, opts...)
 }
 
-func Handler[Req, Resp any](codec Codec, handler func(ctx context.Context, req Req) (Resp, error)) http.Handler {
+// StatusJsonHandler 与StatusJsonCodec配套,成功时响应{"code":0,"msg":"","data":resp},
+// 错误时HTTP状态码仍为200,code为ErrHTTPStatus的Code(为空时使用Status),msg为Message;
+// 字段名与成功码可以通过WithStatusEnvelope修改
+func StatusJsonHandler[Req, Resp any](handler func(ctx context.Context, req Req) (Resp, error), opts ...HandlerOption) http.Handler {
+	return Handler(defaultCodec, handler, append([]HandlerOption{WithStatusEnvelope(StatusEnvelopeOptions{})}, opts...)...)
+}
+
+func Handler[Req, Resp any](codec Codec, handler func(ctx context.Context, req Req) (Resp, error), opts ...HandlerOption) http.Handler {
+	options := &handlerOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		reqObj := new(Req)
-		if err := codec.Decode(r.Body, reqObj); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+		decode := codec.Decode
+		// ndjson请求体逐行解码到slice类型的Req中
+		if strings.HasPrefix(r.Header.Get(ContentTypeKey), ContentTypeNDJSON) {
+			decode = func(r io.Reader, obj interface{}) error {
+				return decodeNDJSON(codec, r, obj)
+			}
+		}
+		// query参数先绑定到Req,body中的同名字段覆盖它;GET等没有body的请求只使用query
+		if err := bindQuery(r.URL.Query(), reqObj); err != nil {
+			options.writeErr(w, codec, &ErrHTTPStatus{Status: http.StatusBadRequest, Code: "invalid_query", Message: err.Error()})
 			return
 		}
+		body := bufio.NewReaderSize(r.Body, blankPeekBytes)
+		if !blankPrefix(body) || !bindsQuery(reqObj) {
+			if err := decode(body, reqObj); err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					options.writeErr(w, codec, bodyTooLargeErr(maxBytesErr.Limit))
+					return
+				}
+				options.writeErr(w, codec, &ErrHTTPStatus{Status: http.StatusBadRequest, Code: "invalid_request", Message: err.Error()})
+				return
+			}
+		}
 		respObj, err := handler(ctx, *reqObj)
+		if options.auditExtractor != nil && isMutating(r.Method) {
+			extractAudit(ctx, options.auditExtractor, *reqObj, respObj, err)
+		}
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			var tooManyRequests *ErrTooManyRequests
+			if errors.As(err, &tooManyRequests) {
+				// 与其他错误一样按协商的格式输出响应体
+				tooManyRequests.Advice.WriteHeader(w.Header())
+				options.writeErr(w, codec, &ErrHTTPStatus{Status: http.StatusTooManyRequests, Code: "too_many_requests", Message: err.Error()})
+				return
+			}
+			options.writeErr(w, codec, httpStatusErr(ctx, options.errorMapper, err))
 			return
 		}
-		respData, err := codec.Encode(respObj)
+		respData, err := options.encodeResp(codec, respObj)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
@@ -71,45 +115,113 @@ func TracingHandler(serviceName string) HandlerWrapper {
 
 // LoggingHandler 添加日志
 func LoggingHandler(loggingReqBody, loggingRespBody bool) HandlerWrapper {
+	return LoggingHandlerWithOptions(LoggingOptions{ReqBody: loggingReqBody, RespBody: loggingRespBody})
+}
+
+// LoggingHandlerWith 使用logger输出日志,处理请求时的client请求也使用它,opts只使用第一个
+func LoggingHandlerWith(logger *slog.Logger, opts ...LoggingOptions) HandlerWrapper {
+	var opt LoggingOptions
+	if len(opts) != 0 {
+		opt = opts[0]
+	}
+	opt.Logger = logger
+	return LoggingHandlerWithOptions(opt)
+}
+
+// LoggingHandlerWithOptions 添加日志,header与json body中的敏感字段按opts隐藏
+func LoggingHandlerWithOptions(opts LoggingOptions) HandlerWrapper {
+	loggingReqBody, loggingRespBody := opts.ReqBody, opts.RespBody
+	redactor := newLogRedactor(opts)
+	maxBodyBytes := opts.maxBodyBytes()
+	// 不记录响应体时不需要缓存
+	maxRespBytes := maxBodyBytes
+	if !loggingRespBody {
+		maxRespBytes = 0
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
-			spanContext := trace.SpanFromContext(httpReq.Context()).SpanContext()
+			start := time.Now()
+			ctx := httpReq.Context()
+			// RequestIDHandler已经设置时沿用其id
+			if RequestIDFromContext(ctx) == "" {
+				requestID := httpReq.Header.Get(RequestIDKey)
+				if requestID == "" {
+					requestID = newRequestID()
+				}
+				ctx = withRequestID(ctx, requestID)
+			}
+			// 处理请求时发出的client请求也会带上这些属性
+			ctx = AppendLogAttrs(ctx, slog.String(FieldHTTPRoute, httpReq.URL.Path))
+			ctx = ContextWithLogger(ctx, opts.Logger)
+			ctx, audit := withAuditFields(ctx)
+			httpReq = httpReq.WithContext(ctx)
+			spanContext := requestSpanContext(httpReq)
 
 			traceID := spanContext.TraceID().String()
 			spanID := spanContext.SpanID().String()
 			kvs := []interface{}{
-				"http_method", httpReq.Method,
-				"http_url", httpReq.URL.String(),
-				"traceID", traceID,
-				"spanID", spanID,
+				FieldHTTPMethod, httpReq.Method,
+				FieldHTTPURL, httpReq.URL.String(),
+				FieldTraceID, traceID,
+				FieldSpanID, spanID,
+				FieldRemoteAddr, httpReq.RemoteAddr,
+			}
+			if opts.LogHeaders {
+				kvs = append(kvs, FieldReqHeader, redactor.header(httpReq.Header))
 			}
 
 			isUpgrade := httpReq.Header.Get("Connection") == "Upgrade"
 			if !isUpgrade {
-				wWrapped := wrapResponseWriter(w)
+				wWrapped := wrapResponseWriter(w, maxRespBytes)
+				// 请求体超过MaxBodyBytesHandler的上限时不调用next,直接响应413
+				var tooLarge *ErrHTTPStatus
 				if loggingReqBody && httpReq.Body != nil {
-					reqData, reqBody, err := DrainBody(httpReq.Body)
-					if err != nil {
-						return
+					if binaryBody(httpReq.Header) {
+						kvs = append(kvs, FieldReqBodyOmitted, bodyOmittedBinary)
+					} else {
+						reqData, truncated, reqBody, err := peekBody(httpReq.Body, logBodyLimit(ctx, maxBodyBytes))
+						var maxBytesErr *http.MaxBytesError
+						switch {
+						case errors.As(err, &maxBytesErr):
+							tooLarge = bodyTooLargeErr(maxBytesErr.Limit)
+						case err != nil:
+							return
+						default:
+							httpReq.Body = reqBody
+							kvs = redactor.appendBody(kvs, reqLogBodyFields, reqData, truncated, httpReq.ContentLength)
+						}
 					}
-					httpReq.Body = reqBody
-
-					kvs = append(kvs, "req_data", string(reqData))
 				}
 				defer func() {
 					if loggingRespBody {
 						respData := wWrapped.Body()
 						statusCode := wWrapped.StatusCode()
-						kvs = append(kvs, "resp_data", string(respData), "statusCode", statusCode)
+						if respBinary(wWrapped) {
+							kvs = append(kvs, FieldRespBodyOmitted, bodyOmittedBinary)
+						} else {
+							truncated, total := responseTruncated(wWrapped)
+							kvs = redactor.appendBody(kvs, respLogBodyFields, []byte(respData), truncated, total)
+						}
+						kvs = append(kvs, FieldServerStatusCode, statusCode)
+					}
+					if opts.LogHeaders {
+						kvs = append(kvs, FieldRespHeader, redactor.header(wWrapped.Header()))
 					}
-					slog.Info("serve http req", kvs...)
+					kvs = append(kvs, auditKVs(audit.snapshot())...)
+					kvs = append(kvs, FieldDurationMs, durationMs(time.Since(start)))
+					logAt(httpReq.Context(), opts.Logger, opts.FieldMapper, opts.RespLevel, "serve http req", kvs...)
 				}()
+				if tooLarge != nil {
+					writeHTTPStatusErr(wWrapped, defaultCodec, tooLarge)
+					return
+				}
 				next.ServeHTTP(wWrapped, httpReq)
 				return
 			}
 
 			defer func() {
-				slog.Info("serve http req", kvs...)
+				kvs = append(kvs, FieldDurationMs, durationMs(time.Since(start)))
+				logAt(httpReq.Context(), opts.Logger, opts.FieldMapper, opts.RespLevel, "serve http req", kvs...)
 			}()
 
 			next.ServeHTTP(w, httpReq)
@@ -128,6 +240,11 @@ type responseWriterWrapper struct {
 	http.ResponseWriter
 	statusCode int
 	buf        *bytes.Buffer
+	// max 最多缓存的字节数,<0时不限制;written为写入的总字节数
+	max     int64
+	written int64
+	// uncompressed 内层的GzipHandler压缩了响应,buf中是压缩前的响应体
+	uncompressed bool
 }
 
 func (rw *responseWriterWrapper) Flush() {
@@ -152,10 +269,20 @@ func (rw *responseWriterWrapper) Write(data []byte) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	rw.buf.Write(data[:n])
+	rw.record(data[:n])
 	return n, nil
 }
 
+// record 缓存已经写出的data
+func (rw *responseWriterWrapper) record(data []byte) {
+	rw.written += int64(len(data))
+	if keep := rw.max - int64(rw.buf.Len()); rw.max < 0 {
+		rw.buf.Write(data)
+	} else if keep > 0 {
+		rw.buf.Write(data[:min(int64(len(data)), keep)])
+	}
+}
+
 func (rw *responseWriterWrapper) Body() string {
 	body := rw.buf.String()
 	rw.buf.Reset()
@@ -169,7 +296,7 @@ func (rw *responseWriterWrapper) StatusCode() int {
 	return rw.statusCode
 }
 
-func wrapResponseWriter(w http.ResponseWriter) WrappedResponseWriter {
+func wrapResponseWriter(w http.ResponseWriter, max int64) WrappedResponseWriter {
 	raw, ok := w.(WrappedResponseWriter)
 	if ok {
 		return raw
@@ -178,16 +305,38 @@ func wrapResponseWriter(w http.ResponseWriter) WrappedResponseWriter {
 		ResponseWriter: w,
 		buf:            bytes.NewBuffer(nil),
 		statusCode:     http.StatusOK,
+		max:            max,
+	}
+}
+
+// respBinary 响应体是否不记录,内层GzipHandler压缩时按压缩前的响应体判断
+func respBinary(w WrappedResponseWriter) bool {
+	header := w.Header()
+	if rw, ok := w.(*responseWriterWrapper); ok && rw.uncompressed {
+		header = header.Clone()
+		header.Del(ContentEncodingKey)
+	}
+	return binaryBody(header)
+}
+
+// responseTruncated 写入的响应体超过了缓存的长度
+func responseTruncated(w WrappedResponseWriter) (bool, int64) {
+	rw, ok := w.(*responseWriterWrapper)
+	if !ok || rw.max < 0 || rw.written <= rw.max {
+		return false, -1
 	}
+	return true, rw.written
 }
 
 type HandlerWrapper func(http.Handler) http.Handler
 
+// DefaultHandlerWrapper 添加日志、trace与超时,请求体最多DefaultMaxBodyBytes字节
 func DefaultHandlerWrapper(next http.Handler) http.Handler {
 	for _, wrapper := range []HandlerWrapper{
 		LoggingHandler(true, true),
 		TracingHandler(""),
 		TimeoutHandler(defaultHandlerTimeout),
+		MaxBodyBytesHandler(DefaultMaxBodyBytes),
 	} {
 		next = wrapper(next)
 	}
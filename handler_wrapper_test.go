@@ -0,0 +1,203 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/httpxtest"
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestJsonHandler(t *testing.T) {
+	type req struct {
+		Name string `json:"name"`
+	}
+	type resp struct {
+		Greeting string `json:"greeting"`
+	}
+	handler := WrapHandler(JsonHandler(func(ctx context.Context, r req) (*resp, error) {
+		if r.Name == "" {
+			return nil, errors.New("empty name")
+		}
+		return &resp{Greeting: "hello " + r.Name}, nil
+	}), TimeoutHandler(0))
+
+	httpxtest.Call[resp](t, handler, httpxtest.Req{
+		Method: http.MethodPost,
+		JSON:   &req{Name: "a"},
+		Expect: resp{Greeting: "hello a"},
+	})
+	httpxtest.Call[resp](t, handler, httpxtest.Req{
+		Method: http.MethodPost,
+		JSON:   &req{},
+		Status: http.StatusInternalServerError,
+	})
+	// 空body时Req由query构造,这里为空
+	httpxtest.Call[resp](t, handler, httpxtest.Req{
+		Method: http.MethodPost,
+		Status: http.StatusInternalServerError,
+	})
+	httpxtest.Call[resp](t, handler, httpxtest.Req{
+		Method: http.MethodPost,
+		JSON:   "not an object",
+		Status: http.StatusBadRequest,
+	})
+}
+
+func TestJsonHandlerQuery(t *testing.T) {
+	type page struct {
+		Page int `url:"page"`
+	}
+	type req struct {
+		page
+		Name   string   `json:"name" url:"name"`
+		Active bool     `json:"active" form:"active"`
+		Tags   []string `json:"tags" url:"tag"`
+		IDs    []int64  `json:"ids" url:"ids,comma"`
+		Ignore string   `json:"ignore" url:"-"`
+	}
+	handler := JsonHandler(func(ctx context.Context, r req) (req, error) {
+		return r, nil
+	})
+	tests := []struct {
+		name     string
+		req      httpxtest.Req
+		expected req
+	}{
+		{
+			name:     "get",
+			req:      httpxtest.Req{Path: "/?page=2&name=a&active=true&tag=x&tag=y&ids=1,2&Ignore=z"},
+			expected: req{page: page{Page: 2}, Name: "a", Active: true, Tags: []string{"x", "y"}, IDs: []int64{1, 2}},
+		},
+		{
+			name:     "delete",
+			req:      httpxtest.Req{Method: http.MethodDelete, Path: "/?name=b"},
+			expected: req{Name: "b"},
+		},
+		{
+			name:     "body and query",
+			req:      httpxtest.Req{Method: http.MethodPost, Path: "/?page=3&name=query", JSON: map[string]interface{}{"name": "body", "tags": []string{"t"}}},
+			expected: req{page: page{Page: 3}, Name: "body", Tags: []string{"t"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := httpxtest.Call[req](t, handler, tt.req); fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", tt.expected) {
+				t.Fatalf("expected req:%+v,got:%+v", tt.expected, got)
+			}
+		})
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/?page=abc", nil))
+	if body := recorder.Body.String(); recorder.Code != http.StatusBadRequest || !strings.Contains(body, "page") || !strings.Contains(body, "abc") {
+		t.Fatalf("expected 400 naming the field,got:%d,%s", recorder.Code, body)
+	}
+}
+
+func TestHandlerErrorMapping(t *testing.T) {
+	errNotFound := errors.New("not found")
+	handler := JsonHandler(func(ctx context.Context, r struct {
+		Case string `url:"case"`
+	}) (*struct{}, error) {
+		switch r.Case {
+		case "status":
+			return nil, fmt.Errorf("lookup:%w", &ErrHTTPStatus{Status: http.StatusConflict, Code: "conflict", Message: "version mismatch", Details: map[string]int{"version": 2}})
+		case "mapped":
+			return nil, fmt.Errorf("lookup:%w", errNotFound)
+		}
+		return nil, errors.New("db password is hunter2")
+	}, WithErrorMapper(func(err error) *ErrHTTPStatus {
+		if errors.Is(err, errNotFound) {
+			return &ErrHTTPStatus{Status: http.StatusNotFound, Code: "not_found", Message: "no such item"}
+		}
+		return nil
+	}))
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+	}{
+		{name: "status", statusCode: http.StatusConflict, body: `{"code":"conflict","message":"version mismatch","details":{"version":2}}`},
+		{name: "mapped", statusCode: http.StatusNotFound, body: `{"code":"not_found","message":"no such item"}`},
+		{name: "unmapped", statusCode: http.StatusInternalServerError, body: `{"code":"internal","message":"internal server error"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := testkit.CaptureLogs(t)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/?case="+tt.name, nil))
+			if recorder.Code != tt.statusCode {
+				t.Fatalf("expected statuscode:%d,got:%d", tt.statusCode, recorder.Code)
+			}
+			if body := recorder.Body.String(); body != tt.body {
+				t.Fatalf("expected body:%s,got:%s", tt.body, body)
+			}
+			if got := recorder.Header().Get(ContentTypeKey); got != ContentTypeJson {
+				t.Fatalf("expected content type:%s,got:%s", ContentTypeJson, got)
+			}
+			if tt.name == "unmapped" {
+				logs.AssertField(t, "handler error", FieldErr, "db password is hunter2")
+			} else if len(logs.Find("handler error")) != 0 {
+				t.Fatal("expected mapped errors not to be logged")
+			}
+		})
+	}
+}
+
+func TestStatusJsonHandler(t *testing.T) {
+	type req struct {
+		Name string `json:"name"`
+	}
+	type resp struct {
+		Greeting string `json:"greeting"`
+	}
+	handle := func(ctx context.Context, r req) (*resp, error) {
+		switch r.Name {
+		case "taken":
+			return nil, &ErrHTTPStatus{Status: http.StatusConflict, Code: "1001", Message: "name taken"}
+		case "gone":
+			return nil, &ErrHTTPStatus{Status: http.StatusGone, Message: "gone"}
+		case "boom":
+			return nil, errors.New("boom")
+		}
+		return &resp{Greeting: "hello " + r.Name}, nil
+	}
+	vendor := StatusEnvelopeOptions{CodeKey: "errcode", MsgKey: "errmsg", DataKey: "result", SuccessCodes: []string{"OK"}}
+	tests := []struct {
+		name    string
+		handler http.Handler
+		codec   Codec
+	}{
+		{name: "default", handler: StatusJsonHandler(handle), codec: &StatusJsonCodec{}},
+		{name: "vendor", handler: StatusJsonHandler(handle, WithStatusEnvelope(vendor)), codec: NewStatusJsonCodec(vendor)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := testkit.NewServer(t, tt.handler)
+			got := &resp{}
+			if err := Post(server.URL).WithCodec(tt.codec).WithReq(&req{Name: "a"}).WithResp(got).Do(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got.Greeting != "hello a" {
+				t.Fatalf("expected greeting:hello a,got:%s", got.Greeting)
+			}
+			for name, expected := range map[string]ErrEnvelope{
+				"taken": {Code: "1001", Msg: "name taken"},
+				"gone":  {Code: "410", Msg: "gone"},
+				"boom":  {Code: "internal", Msg: "internal server error"},
+			} {
+				err := Post(server.URL).WithCodec(tt.codec).WithReq(&req{Name: name}).WithResp(&resp{}).Do(context.Background())
+				var envelopeErr *ErrEnvelope
+				if !errors.As(err, &envelopeErr) || *envelopeErr != expected {
+					t.Fatalf("expected err:%+v,got:%v", expected, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package httpx
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultMaxHeaderCount       = 100
+	defaultMaxHeaderValueLength = 8 << 10
+)
+
+// forwardedHeaders 只应由可信代理设置的请求头
+var forwardedHeaders = []string{
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+	"X-Real-Ip",
+}
+
+// HeaderPolicy HeaderPolicyHandler的配置
+type HeaderPolicy struct {
+	// MaxHeaderCount 请求头的最大个数(按值计数),默认100
+	MaxHeaderCount int
+	// MaxHeaderValueLength 单个请求头值的最大长度,默认8KB
+	MaxHeaderValueLength int
+	// TrustedProxies 可信代理的ip或CIDR,其他来源的X-Forwarded-*等请求头会被删除
+	TrustedProxies []string
+	// Routes 按path前缀覆盖配置,最长前缀优先
+	Routes map[string]HeaderPolicy
+}
+
+// HeaderPolicyHandler 限制请求头个数与长度(431),删除不可信来源的转发头,
+// 并为handler提供过滤CR/LF的SafeHeader
+func HeaderPolicyHandler(policy HeaderPolicy) HandlerWrapper {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+			p := policy.forPath(httpReq.URL.Path)
+			count := 0
+			for key, values := range httpReq.Header {
+				count += len(values)
+				for _, value := range values {
+					if len(value) > p.MaxHeaderValueLength {
+						logWarn(httpReq.Context(), "request header too large",
+							FieldHTTPMethod, httpReq.Method,
+							FieldHTTPURL, httpReq.URL.String(),
+							FieldHeader, key,
+						)
+						w.WriteHeader(http.StatusRequestHeaderFieldsTooLarge)
+						return
+					}
+				}
+			}
+			if count > p.MaxHeaderCount {
+				logWarn(httpReq.Context(), "too many request headers",
+					FieldHTTPMethod, httpReq.Method,
+					FieldHTTPURL, httpReq.URL.String(),
+				)
+				w.WriteHeader(http.StatusRequestHeaderFieldsTooLarge)
+				return
+			}
+			if !p.trusted(httpReq.RemoteAddr) {
+				for _, key := range forwardedHeaders {
+					if _, exist := httpReq.Header[key]; !exist {
+						continue
+					}
+					logWarn(httpReq.Context(), "strip untrusted request header",
+						FieldHTTPMethod, httpReq.Method,
+						FieldHTTPURL, httpReq.URL.String(),
+						FieldHeader, key,
+					)
+					httpReq.Header.Del(key)
+				}
+			}
+			next.ServeHTTP(&safeHeaderWriter{ResponseWriter: w, httpReq: httpReq}, httpReq)
+		})
+	}
+}
+
+// forPath 返回path对应的配置,未设置的限制使用默认值
+func (p HeaderPolicy) forPath(path string) HeaderPolicy {
+	matched := ""
+	effective := p
+	for prefix, override := range p.Routes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(matched) {
+			matched = prefix
+			effective = override
+			if effective.TrustedProxies == nil {
+				effective.TrustedProxies = p.TrustedProxies
+			}
+		}
+	}
+	if effective.MaxHeaderCount <= 0 {
+		effective.MaxHeaderCount = defaultMaxHeaderCount
+	}
+	if effective.MaxHeaderValueLength <= 0 {
+		effective.MaxHeaderValueLength = defaultMaxHeaderValueLength
+	}
+	return effective
+}
+
+func (p HeaderPolicy) trusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range p.TrustedProxies {
+		if _, ipNet, err := net.ParseCIDR(proxy); err == nil {
+			if ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SafeHeaderSetter 设置响应头前过滤CR/LF,防止回显请求内容时注入响应头
+type SafeHeaderSetter interface {
+	SafeHeader(key, value string)
+}
+
+type safeHeaderWriter struct {
+	http.ResponseWriter
+	httpReq *http.Request
+}
+
+func (w *safeHeaderWriter) SafeHeader(key, value string) {
+	sanitized := sanitizeHeaderValue(value)
+	if sanitized != value {
+		logWarn(w.httpReq.Context(), "strip CR/LF from response header",
+			FieldHTTPMethod, w.httpReq.Method,
+			FieldHTTPURL, w.httpReq.URL.String(),
+			FieldHeader, key,
+		)
+	}
+	w.Header().Set(key, sanitized)
+}
+
+func (w *safeHeaderWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *safeHeaderWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// SafeHeader 通过w设置过滤了CR/LF的响应头,w不是HeaderPolicyHandler包装的writer时同样会过滤
+func SafeHeader(w http.ResponseWriter, key, value string) {
+	if setter, ok := w.(SafeHeaderSetter); ok {
+		setter.SafeHeader(key, value)
+		return
+	}
+	w.Header().Set(key, sanitizeHeaderValue(value))
+}
+
+func sanitizeHeaderValue(value string) string {
+	if !strings.ContainsAny(value, "\r\n") {
+		return value
+	}
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
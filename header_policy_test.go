@@ -0,0 +1,138 @@
+package httpx
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestHeaderPolicyLimits(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	handler := HeaderPolicyHandler(HeaderPolicy{
+		MaxHeaderCount:       4,
+		MaxHeaderValueLength: 16,
+		Routes: map[string]HeaderPolicy{
+			"/upload": {MaxHeaderValueLength: 64},
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name       string
+		path       string
+		headers    map[string]string
+		statusCode int
+	}{
+		{name: "ok", path: "/", headers: map[string]string{"X-A": "a"}, statusCode: http.StatusOK},
+		{name: "long value", path: "/", headers: map[string]string{"X-Secret": strings.Repeat("a", 17)}, statusCode: http.StatusRequestHeaderFieldsTooLarge},
+		{name: "route override", path: "/upload", headers: map[string]string{"X-A": strings.Repeat("a", 17)}, statusCode: http.StatusOK},
+		{name: "too many", path: "/", headers: map[string]string{"X-1": "1", "X-2": "2", "X-3": "3", "X-4": "4", "X-5": "5"}, statusCode: http.StatusRequestHeaderFieldsTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.statusCode {
+			t.Fatalf("%s:expected status:%d,got:%d", tt.name, tt.statusCode, rec.Code)
+		}
+	}
+	logs.AssertField(t, "request header too large", FieldHeader, "X-Secret")
+	for _, record := range logs.Records() {
+		for _, value := range record.Attrs {
+			if strings.Contains(fmt.Sprint(value), "aaaa") {
+				t.Fatalf("header value leaked into log:%v", record.Attrs)
+			}
+		}
+	}
+}
+
+func TestHeaderPolicyForwarded(t *testing.T) {
+	var got string
+	handler := HeaderPolicyHandler(HeaderPolicy{
+		TrustedProxies: []string{"10.0.0.0/8"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Forwarded-For")
+	}))
+	tests := []struct {
+		remoteAddr string
+		expected   string
+	}{
+		{remoteAddr: "10.1.2.3:1234", expected: "1.1.1.1"},
+		{remoteAddr: "192.0.2.1:1234", expected: ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Forwarded-For", "1.1.1.1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.expected {
+			t.Fatalf("expected X-Forwarded-For:%s from %s,got:%s", tt.expected, tt.remoteAddr, got)
+		}
+	}
+}
+
+func TestHeaderPolicySafeHeader(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	handler := HeaderPolicyHandler(HeaderPolicy{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SafeHeader(w, "X-Echo", r.URL.Query().Get("v"))
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?v=a%0d%0aSet-Cookie:%20x=1", nil))
+	if got := rec.Header().Get("X-Echo"); got != "aSet-Cookie: x=1" {
+		t.Fatalf("unexpected X-Echo:%q", got)
+	}
+	if got := rec.Header().Get("Set-Cookie"); got != "" {
+		t.Fatalf("unexpected Set-Cookie:%s", got)
+	}
+	logs.AssertField(t, "strip CR/LF from response header", FieldHeader, "X-Echo")
+}
+
+func TestHeaderPolicyUpgrade(t *testing.T) {
+	handler := HeaderPolicyHandler(HeaderPolicy{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("unexpected hijack err:%v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		rw.Flush()
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		rw.WriteString(line)
+		rw.Flush()
+	}))
+	server := testkit.NewServer(t, handler)
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected err:%v", err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n", server.Listener.Addr())
+	reader := bufio.NewReader(conn)
+	httpResp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("unexpected err:%v", err)
+	}
+	if httpResp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status:%d,got:%d", http.StatusSwitchingProtocols, httpResp.StatusCode)
+	}
+	fmt.Fprint(conn, "ping\n")
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected err:%v", err)
+	}
+	if line != "ping\n" {
+		t.Fatalf("expected echo:%q,got:%q", "ping\n", line)
+	}
+}
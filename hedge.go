@@ -0,0 +1,120 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HedgingTransport 请求在delay内没有返回时再发出一个相同的请求,最多额外发出maxHedges个,
+// 取最先返回的响应并取消其余请求;只作用于幂等且body可以通过GetBody重放的请求。
+// 某次尝试失败时立即发出下一个,全部失败时返回最后一个错误
+func HedgingTransport(delay time.Duration, maxHedges int) TransportWrapper {
+	return NamedWrapper("hedging", fmt.Sprintf("delay=%s,hedges=%d", delay, maxHedges), func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			if !hedgeable(httpReq) || maxHedges < 1 {
+				return next.RoundTrip(httpReq)
+			}
+			return hedge(next, httpReq, delay, maxHedges)
+		})
+	})
+}
+
+// hedgeable 幂等且body可以重放
+func hedgeable(httpReq *http.Request) bool {
+	if !isIdempotent(httpReq.Method) && httpReq.Header.Get(IdempotencyKeyHeader) == "" {
+		return false
+	}
+	return httpReq.Body == nil || httpReq.Body == http.NoBody || httpReq.GetBody != nil
+}
+
+type hedgeResult struct {
+	idx    int
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+func hedge(next http.RoundTripper, httpReq *http.Request, delay time.Duration, maxHedges int) (*http.Response, error) {
+	ctx := httpReq.Context()
+	// 容量足够所有尝试,落选的结果不会阻塞
+	results := make(chan hedgeResult, maxHedges+1)
+	cancels := make([]context.CancelFunc, 0, maxHedges+1)
+	launch := func() error {
+		var body io.ReadCloser
+		if len(cancels) > 0 && httpReq.Body != nil && httpReq.Body != http.NoBody {
+			var err error
+			if body, err = httpReq.GetBody(); err != nil {
+				return err
+			}
+		}
+		attemptCtx, cancel := context.WithCancel(ctx)
+		// 每次尝试使用独立的header,内层wrapper修改header时不会互相影响
+		attemptReq := httpReq.Clone(attemptCtx)
+		if body != nil {
+			attemptReq.Body = body
+		}
+		cancels = append(cancels, cancel)
+		idx := len(cancels) - 1
+		go func() {
+			httpResp, err := next.RoundTrip(attemptReq)
+			results <- hedgeResult{idx: idx, resp: httpResp, err: err, cancel: cancel}
+		}()
+		return nil
+	}
+	if err := launch(); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	inflight := 1
+	var lastErr error
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) <= maxHedges {
+				if err := launch(); err == nil {
+					inflight++
+					timer.Reset(delay)
+				}
+			}
+		case result := <-results:
+			inflight--
+			if result.err == nil {
+				for idx, cancel := range cancels {
+					if idx != result.idx {
+						cancel()
+					}
+				}
+				go closeHedgeLosers(results, inflight)
+				result.resp.Body = &cancelOnClose{ReadCloser: result.resp.Body, cancel: result.cancel}
+				return result.resp, nil
+			}
+			result.cancel()
+			lastErr = result.err
+			if len(cancels) <= maxHedges && ctx.Err() == nil {
+				if err := launch(); err == nil {
+					inflight++
+					timer.Reset(delay)
+					continue
+				}
+			}
+			if inflight == 0 {
+				return nil, lastErr
+			}
+		}
+	}
+}
+
+// closeHedgeLosers 关闭落选请求的body,避免连接泄漏
+func closeHedgeLosers(results chan hedgeResult, inflight int) {
+	for ; inflight > 0; inflight-- {
+		result := <-results
+		if result.resp != nil {
+			result.resp.Body.Close()
+		}
+		result.cancel()
+	}
+}
@@ -0,0 +1,82 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestHedgingTransport(t *testing.T) {
+	var calls int32
+	loserCanceled := make(chan struct{}, 1)
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				loserCanceled <- struct{}{}
+			case <-time.After(time.Second * 2):
+				w.Write([]byte(`{"data":"slow"}`))
+			}
+			return
+		}
+		w.Write([]byte(`{"data":"fast"}`))
+	}))
+	hedged := Get(server.URL).WithTransportWrapper(HedgingTransport(time.Millisecond*20, 1))
+
+	start := time.Now()
+	resp := map[string]string{}
+	if err := hedged.WithResp(&resp).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if resp["data"] != "fast" || time.Since(start) > time.Second {
+		t.Fatalf("expected fast response to win,got:%s after %s", resp["data"], time.Since(start))
+	}
+	select {
+	case <-loserCanceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected slow request to be canceled")
+	}
+
+	// 非幂等请求不会对冲
+	atomic.StoreInt32(&calls, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	if err := Post(server.URL).WithTransportWrapper(HedgingTransport(time.Millisecond*20, 1)).Do(ctx); err == nil {
+		t.Fatal("expected post to wait for slow handler")
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 1 {
+		t.Fatalf("expected post not to be hedged,got calls:%d", calls)
+	}
+	<-loserCanceled
+}
+
+func TestHedgingTransportHeaderIsolation(t *testing.T) {
+	var calls, shared int32
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if len(r.Header.Values("X-A")) != 1 {
+			atomic.AddInt32(&shared, 1)
+		}
+		time.Sleep(time.Millisecond * 100)
+	}))
+	// HeadersTransport在对冲之内,每次尝试都会修改header
+	client := &http.Client{Transport: WrapTransport(http.DefaultTransport,
+		HeadersTransport(http.Header{"X-A": []string{"a"}}),
+		HedgingTransport(time.Millisecond*10, 2),
+	)}
+	httpResp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	if calls := atomic.LoadInt32(&calls); calls != 3 {
+		t.Fatalf("expected 3 attempts,got:%d", calls)
+	}
+	if shared := atomic.LoadInt32(&shared); shared != 0 {
+		t.Fatalf("expected each attempt to send one X-A header,got %d attempts with shared header", shared)
+	}
+}
@@ -3,12 +3,19 @@ package httpx
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	stdurl "net/url"
+	"strings"
 	"time"
 
 	"github.com/google/go-querystring/query"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Builder interface {
@@ -26,24 +33,98 @@ type Builder interface {
 	WithQueryString(key string, value string) Builder
 	WithURLValues(values stdurl.Values) Builder
 	WithQueryStringObj(obj interface{}) Builder
+	ReqAsQuery(asQuery bool) Builder
+	DuplicateQueryPolicy(policy DuplicatePolicy) Builder
 	WithCodec(codec Codec) Builder
+	NegotiateResponse(negotiate bool) Builder
 	WithHeader(key string, value string) Builder
 	WithBasicAuth(username, password string) Builder
+	WithBearerToken(token string) Builder
+	WithUserAgent(ua string) Builder
+	WithAccept(contentType string) Builder
+	WithRespHeaders(h *http.Header) Builder
+	WithErrorResp(obj interface{}) Builder
+	MaxResponseBytes(n int64) Builder
+	WithRespWriter(w io.Writer) Builder
+	WithRespWritten(n *int64) Builder
+	WithProgress(fn func(written, total int64)) Builder
+	WithRespStatusCode(code *int) Builder
+	WithTimings(t *Timings) Builder
+	RequireResponseBody() Builder
+	CompressRequest(encoding string) Builder
+	ExpectContentType(contentType string) Builder
+	WithPathParam(key, value string) Builder
+	WithAttemptHistory(h *[]AttemptRecord) Builder
+	WithHost(host string) Builder
+	WithRequestEditor(fn func(*http.Request) error) Builder
+	WithTransportWrapper(tws ...TransportWrapper) Builder
+	WithIdempotencyKey(key string) Builder
+	WithAutoIdempotencyKey() Builder
+	IdempotencyKey() string
+	WithCookie(c *http.Cookie) Builder
+	WithCookies(cs ...*http.Cookie) Builder
+	WithCookieJar(jar http.CookieJar) Builder
 	WithHeaders(headers http.Header) Builder
 	WithReq(req interface{}) Builder
+	WithReqSlice(items interface{}, lineCodec Codec) Builder
+	WithReqStream(next func() (interface{}, bool)) Builder
+	WithFormReq(values stdurl.Values) Builder
+	WithFormReqObj(obj interface{}) Builder
+	WithMultipartFile(fieldName, fileName string, r io.Reader) Builder
+	WithMultipartField(name, value string) Builder
+	WithBodyReader(r io.Reader) Builder
+	WithBodyBytes(data []byte) Builder
 	WithResp(resp interface{}) Builder
+	WithRespValidator(validator RespValidator) Builder
+	WithRespTransformer(transformer RespTransformer) Builder
 	ExpectedStatusCodes(...int) Builder
+	ExpectedStatusRange(start, end int) Builder
+	Expect2xx() Builder
+	ExpectAnyStatus() Builder
 	Logging(loggingReq, loggingResp bool) Builder
+	WithLogger(logger *slog.Logger) Builder
 	Timeout(timeout time.Duration) Builder
 	Tracing(tracing bool) Builder
+	SpanName(name string) Builder
+	TracingServiceName(serviceName string) Builder
+	WithTracingOptions(opts ...otelhttp.Option) Builder
+	WithSpanAttributes(attrs ...attribute.KeyValue) Builder
+	Propagation(propagation bool) Builder
 	ContentType(contentType string) Builder
 	Insecure(insecure bool) Builder
+	InsecureForHosts(hosts ...string) Builder
+	Priority(priority Priority) Builder
+	SecurityProfile(profile SecurityProfile) Builder
+	ConnEventHooks(hooks *ConnEventHooks) Builder
+	StrictOptions(strict bool) Builder
+	ResiliencePolicy(policy ResiliencePolicy) Builder
+	Retry(maxAttempts int, backoff BackoffPolicy) Builder
+	MaxRetryAfter(d time.Duration) Builder
+	RetryNonIdempotent(retryNonIdempotent bool) Builder
+	RequestID(headerName string) Builder
+	WithTokenProvider(provider TokenProvider) Builder
+	WithSigner(signer Signer) Builder
+	WithCache(store CacheStore) Builder
+	WithDump(w io.Writer) Builder
+	WithProxy(proxyURL string) Builder
+	WithTLSConfig(config *tls.Config) Builder
+	WithTransportOptions(opts TransportOptions) Builder
+	WithClientCert(certPEM, keyPEM []byte) Builder
+	WithRootCAs(pool *x509.CertPool) Builder
+	Describe() string
+	Validate() error
 	BuildHTTPReq(context.Context) (*http.Request, error)
 	BuildTransport(context.Context) (http.RoundTripper, error)
 	Do(context.Context) error
+	DoInto(ctx context.Context, resp interface{}) error
+	MustDo(context.Context)
+	DoWithDeadline(deadline time.Duration) error
+	NoDefaultDeadline() Builder
 	WithTransport(transport http.RoundTripper) Builder
 	DoWithTransport(ctx context.Context, transport http.RoundTripper) error
 	DoWithClient(ctx context.Context, client *http.Client) error
+	DoRaw(ctx context.Context) (*http.Response, error)
+	DownloadFile(ctx context.Context, path string) error
 }
 
 type builder struct {
@@ -52,32 +133,99 @@ type builder struct {
 	baseURL             string
 	codec               Codec
 	resp                interface{}
+	respValidator       RespValidator
+	respTransformers    []RespTransformer
 	req                 interface{}
+	ndjson              *ndjsonBody
+	form                stdurl.Values
+	multipartParts      []multipartPart
+	bodyReader          io.Reader
+	bodyBytes           []byte
 	urlValues           stdurl.Values
+	objValues           stdurl.Values
+	reqAsQuery          bool
+	duplicatePolicy     DuplicatePolicy
 	header              http.Header
+	cookies             []*http.Cookie
+	userAgent           string
+	expectContentType   string
+	respHeaders         *http.Header
+	negotiateResponse   bool
+	errorResp           interface{}
+	maxResponseBytes    int64
+	respWriter          io.Writer
+	respWritten         *int64
+	progress            func(written, total int64)
+	respStatusCode      *int
+	respStream          func(*http.Response) error
+	timings             *Timings
+	requireRespBody     bool
+	requestEncoding     string
+	pathParams          map[string]string
+	history             *[]AttemptRecord
+	host                string
+	requestEditors      []func(*http.Request) error
+	transportWrappers   []TransportWrapper
+	idempotencyKey      *idempotencyKey
+	jar                 http.CookieJar
 	expectedStatusCodes []int
-	loggingReq          bool
-	loggingResp         bool
-	timeout             time.Duration
-	tracing             bool
-	contentType         string
-	insecure            bool
-	transport           http.RoundTripper
-	err                 error
+	// expectedStatusRanges 与expectedStatusCodes取并集,都为空时只允许200
+	expectedStatusRanges []StatusCodeRange
+	anyStatus            bool
+	loggingReq           bool
+	loggingResp          bool
+	logger               *slog.Logger
+	timeout              time.Duration
+	noDefaultDeadline    bool
+	tracing              bool
+	tracingSet           bool
+	spanName             string
+	serviceName          string
+	tracingOptions       []otelhttp.Option
+	spanAttrs            []attribute.KeyValue
+	propagation          bool
+	contentType          string
+	insecure             bool
+	insecureHosts        []string
+	priority             Priority
+	prioritySet          bool
+	proxied              bool
+	profile              SecurityProfile
+	profileSet           bool
+	connHooks            *ConnEventHooks
+	strict               bool
+	policy               *ResiliencePolicy
+	retryAttempts        int
+	retryBackoff         BackoffPolicy
+	retryNonIdempotent   bool
+	requestIDHeader      string
+	tokenTransport       TransportWrapper
+	signer               Signer
+	cacheStore           CacheStore
+	dumpWriter           io.Writer
+	proxy                string
+	tlsConfig            *tls.Config
+	maxRetryAfter        time.Duration
+	maxRetryAfterSet     bool
+	transportOptions     TransportOptions
+	transport            http.RoundTripper
+	built                *builtTransport
+	err                  error
 }
 
 func New() Builder {
 	return &builder{
-		urlValues:           make(stdurl.Values),
-		header:              make(http.Header),
-		codec:               defaultCodec,
-		expectedStatusCodes: []int{http.StatusOK},
-		loggingReq:          true,
-		loggingResp:         true,
-		timeout:             defaultTransprtTimeout,
-		tracing:             true,
-		contentType:         ContentTypeJson,
-		insecure:            false,
+		urlValues:   make(stdurl.Values),
+		objValues:   make(stdurl.Values),
+		header:      make(http.Header),
+		codec:       defaultCodec,
+		loggingReq:  true,
+		loggingResp: true,
+		tracing:     true,
+		insecure:    false,
+		priority:    PriorityNormal,
+		strict:      DefaultStrictOptions,
+		built:       &builtTransport{},
 	}
 }
 
@@ -133,6 +281,14 @@ func WithQueryStringObj(obj interface{}) Builder {
 	return New().WithQueryStringObj(obj)
 }
 
+func ReqAsQuery(asQuery bool) Builder {
+	return New().ReqAsQuery(asQuery)
+}
+
+func DuplicateQueryPolicy(policy DuplicatePolicy) Builder {
+	return New().DuplicateQueryPolicy(policy)
+}
+
 func WithCodec(codec Codec) Builder {
 	return New().WithCodec(codec)
 }
@@ -145,6 +301,108 @@ func WithBasicAuth(username, password string) Builder {
 	return New().WithBasicAuth(username, password)
 }
 
+func WithBearerToken(token string) Builder {
+	return New().WithBearerToken(token)
+}
+
+func WithUserAgent(ua string) Builder {
+	return New().WithUserAgent(ua)
+}
+
+func WithAccept(contentType string) Builder {
+	return New().WithAccept(contentType)
+}
+
+func WithRespHeaders(h *http.Header) Builder {
+	return New().WithRespHeaders(h)
+}
+
+func NegotiateResponse(negotiate bool) Builder {
+	return New().NegotiateResponse(negotiate)
+}
+
+func WithErrorResp(obj interface{}) Builder {
+	return New().WithErrorResp(obj)
+}
+
+func MaxResponseBytes(n int64) Builder {
+	return New().MaxResponseBytes(n)
+}
+
+func WithRespWriter(w io.Writer) Builder {
+	return New().WithRespWriter(w)
+}
+
+func WithRespWritten(n *int64) Builder {
+	return New().WithRespWritten(n)
+}
+
+func WithProgress(fn func(written, total int64)) Builder {
+	return New().WithProgress(fn)
+}
+
+func WithRespStatusCode(code *int) Builder {
+	return New().WithRespStatusCode(code)
+}
+
+// WithTimings 请求结束时将各阶段耗时写入t
+func WithTimings(t *Timings) Builder {
+	return New().WithTimings(t)
+}
+
+// RequireResponseBody 响应没有内容时也解码
+func RequireResponseBody() Builder {
+	return New().RequireResponseBody()
+}
+
+func ExpectContentType(contentType string) Builder {
+	return New().ExpectContentType(contentType)
+}
+
+func CompressRequest(encoding string) Builder {
+	return New().CompressRequest(encoding)
+}
+
+func WithPathParam(key, value string) Builder {
+	return New().WithPathParam(key, value)
+}
+
+func WithAttemptHistory(h *[]AttemptRecord) Builder {
+	return New().WithAttemptHistory(h)
+}
+
+func WithHost(host string) Builder {
+	return New().WithHost(host)
+}
+
+func WithRequestEditor(fn func(*http.Request) error) Builder {
+	return New().WithRequestEditor(fn)
+}
+
+func WithTransportWrapper(tws ...TransportWrapper) Builder {
+	return New().WithTransportWrapper(tws...)
+}
+
+func WithIdempotencyKey(key string) Builder {
+	return New().WithIdempotencyKey(key)
+}
+
+func WithAutoIdempotencyKey() Builder {
+	return New().WithAutoIdempotencyKey()
+}
+
+func WithCookie(c *http.Cookie) Builder {
+	return New().WithCookie(c)
+}
+
+func WithCookies(cs ...*http.Cookie) Builder {
+	return New().WithCookies(cs...)
+}
+
+func WithCookieJar(jar http.CookieJar) Builder {
+	return New().WithCookieJar(jar)
+}
+
 func WithHeaders(headers http.Header) Builder {
 	return New().WithHeaders(headers)
 }
@@ -153,23 +411,174 @@ func WithReq(req interface{}) Builder {
 	return New().WithReq(req)
 }
 
+func WithReqSlice(items interface{}, lineCodec Codec) Builder {
+	return New().WithReqSlice(items, lineCodec)
+}
+
+func WithReqStream(next func() (interface{}, bool)) Builder {
+	return New().WithReqStream(next)
+}
+
+func WithFormReq(values stdurl.Values) Builder {
+	return New().WithFormReq(values)
+}
+
+func WithFormReqObj(obj interface{}) Builder {
+	return New().WithFormReqObj(obj)
+}
+
+func WithMultipartFile(fieldName, fileName string, r io.Reader) Builder {
+	return New().WithMultipartFile(fieldName, fileName, r)
+}
+
+func WithMultipartField(name, value string) Builder {
+	return New().WithMultipartField(name, value)
+}
+
+func WithBodyReader(r io.Reader) Builder {
+	return New().WithBodyReader(r)
+}
+
+func WithBodyBytes(data []byte) Builder {
+	return New().WithBodyBytes(data)
+}
+
 func WithResp(resp interface{}) Builder {
 	return New().WithResp(resp)
 }
 
+func WithRespValidator(validator RespValidator) Builder {
+	return New().WithRespValidator(validator)
+}
+
+func WithRespTransformer(transformer RespTransformer) Builder {
+	return New().WithRespTransformer(transformer)
+}
+
 func ExpectedStatusCodes(expectedStatusCodes ...int) Builder {
 	return New().ExpectedStatusCodes(expectedStatusCodes...)
 }
+
+func ExpectedStatusRange(start, end int) Builder {
+	return New().ExpectedStatusRange(start, end)
+}
+
+func Expect2xx() Builder {
+	return New().Expect2xx()
+}
+
+func ExpectAnyStatus() Builder {
+	return New().ExpectAnyStatus()
+}
+
 func Logging(loggingReq, loggingResp bool) Builder {
 	return New().Logging(loggingReq, loggingResp)
 }
 
+// WithLogger 使用logger输出日志
+func WithLogger(logger *slog.Logger) Builder {
+	return New().WithLogger(logger)
+}
+
+func Retry(maxAttempts int, backoff BackoffPolicy) Builder {
+	return New().Retry(maxAttempts, backoff)
+}
+
+func MaxRetryAfter(d time.Duration) Builder {
+	return New().MaxRetryAfter(d)
+}
+
+// WithTokenProvider 使用provider提供的bearer token
+func WithTokenProvider(provider TokenProvider) Builder {
+	return New().WithTokenProvider(provider)
+}
+
+// WithSigner 对请求签名
+func WithSigner(signer Signer) Builder {
+	return New().WithSigner(signer)
+}
+
+// WithCache 使用store缓存GET响应
+func WithCache(store CacheStore) Builder {
+	return New().WithCache(store)
+}
+
+// WithDump 把线上的请求与响应写入w
+func WithDump(w io.Writer) Builder {
+	return New().WithDump(w)
+}
+
+// WithProxy 通过proxyURL发出请求
+func WithProxy(proxyURL string) Builder {
+	return New().WithProxy(proxyURL)
+}
+
+// WithTLSConfig 使用自定义的TLS配置
+func WithTLSConfig(config *tls.Config) Builder {
+	return New().WithTLSConfig(config)
+}
+
+// WithTransportOptions 使用自定义的连接池与超时配置
+func WithTransportOptions(opts TransportOptions) Builder {
+	return New().WithTransportOptions(opts)
+}
+
+// WithClientCert 使用客户端证书
+func WithClientCert(certPEM, keyPEM []byte) Builder {
+	return New().WithClientCert(certPEM, keyPEM)
+}
+
+// WithRootCAs 使用pool校验服务端证书
+func WithRootCAs(pool *x509.CertPool) Builder {
+	return New().WithRootCAs(pool)
+}
+
+// RequestID 设置并记录request id
+func RequestID(headerName string) Builder {
+	return New().RequestID(headerName)
+}
+
+// RetryNonIdempotent 同时重试非幂等的请求
+func RetryNonIdempotent(retryNonIdempotent bool) Builder {
+	return New().RetryNonIdempotent(retryNonIdempotent)
+}
+
 func Timeout(timeout time.Duration) Builder {
 	return New().Timeout(timeout)
 }
+func NoDefaultDeadline() Builder {
+	return New().NoDefaultDeadline()
+}
+
 func Tracing(tracing bool) Builder {
 	return New().Tracing(tracing)
 }
+
+// SpanName 设置client span的名称
+func SpanName(name string) Builder {
+	return New().SpanName(name)
+}
+
+// TracingServiceName 设置tracing的service name
+func TracingServiceName(serviceName string) Builder {
+	return New().TracingServiceName(serviceName)
+}
+
+// WithTracingOptions 设置otelhttp的配置
+func WithTracingOptions(opts ...otelhttp.Option) Builder {
+	return New().WithTracingOptions(opts...)
+}
+
+// WithSpanAttributes 设置client span的属性
+func WithSpanAttributes(attrs ...attribute.KeyValue) Builder {
+	return New().WithSpanAttributes(attrs...)
+}
+
+// Propagation 不创建span时传播trace context
+func Propagation(propagation bool) Builder {
+	return New().Propagation(propagation)
+}
+
 func ContentType(contentType string) Builder {
 	return New().ContentType(contentType)
 }
@@ -177,6 +586,14 @@ func Insecure(insecure bool) Builder {
 	return New().Insecure(insecure)
 }
 
+func InsecureForHosts(hosts ...string) Builder {
+	return New().InsecureForHosts(hosts...)
+}
+
+func StrictOptions(strict bool) Builder {
+	return New().StrictOptions(strict)
+}
+
 func WithTransport(transport http.RoundTripper) Builder {
 	return New().WithTransport(transport)
 }
@@ -308,12 +725,32 @@ func (b *builder) WithQueryStringObj(obj interface{}) Builder {
 	newBuilder.err = err
 	for key, values := range urlValues {
 		for _, value := range values {
-			newBuilder.urlValues.Add(key, value)
+			newBuilder.objValues.Add(key, value)
 		}
 	}
 	return newBuilder
 }
 
+// ReqAsQuery 为true时WithReq的对象通过query.Values编码到query中,不再产生请求体,
+// 适用于GET/HEAD/DELETE
+func (b *builder) ReqAsQuery(asQuery bool) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.reqAsQuery = asQuery
+	return newBuilder
+}
+
+func (b *builder) DuplicateQueryPolicy(policy DuplicatePolicy) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.duplicatePolicy = policy
+	return newBuilder
+}
+
 func (b *builder) WithURLValues(urlValues stdurl.Values) Builder {
 	newBuilder := b.clone()
 	if newBuilder.err != nil {
@@ -328,7 +765,7 @@ func (b *builder) WithURLValues(urlValues stdurl.Values) Builder {
 }
 
 func (b *builder) WithCodec(codec Codec) Builder {
-	newBuilder := b.clone()
+	newBuilder := b.cloneTransport()
 	if newBuilder.err != nil {
 		return newBuilder
 	}
@@ -354,192 +791,1135 @@ func (b *builder) WithBasicAuth(username, password string) Builder {
 	return newBuilder
 }
 
-func (b *builder) WithHeaders(headers http.Header) Builder {
+func (b *builder) WithBearerToken(token string) Builder {
 	newBuilder := b.clone()
 	if newBuilder.err != nil {
 		return newBuilder
 	}
-	for k, vs := range headers {
-		for _, v := range vs {
-			newBuilder.header.Add(k, v)
-		}
-	}
+	newBuilder.header.Set("Authorization", "Bearer "+token)
 	return newBuilder
 }
 
-func (b *builder) WithReq(req interface{}) Builder {
+// WithUserAgent 设置User-Agent,WithHeader显式设置的User-Agent优先
+func (b *builder) WithUserAgent(ua string) Builder {
 	newBuilder := b.clone()
 	if newBuilder.err != nil {
 		return newBuilder
 	}
-	newBuilder.req = req
+	newBuilder.userAgent = ua
 	return newBuilder
 }
 
-func (b *builder) WithResp(resp interface{}) Builder {
-	newBuilder := b.clone()
+// WithAccept 设置Accept头
+func (b *builder) WithAccept(contentType string) Builder {
+	return b.WithHeader(AcceptKey, contentType)
+}
+
+// WithRespHeaders 请求结束后将最后一个响应的header(包括读完body后的trailer)写入h,
+// 状态码不符合预期或解码失败时同样写入
+func (b *builder) WithRespHeaders(h *http.Header) Builder {
+	newBuilder := b.cloneTransportIf((b.respHeaders == nil) != (h == nil))
 	if newBuilder.err != nil {
 		return newBuilder
 	}
-	newBuilder.resp = resp
+	newBuilder.respHeaders = h
 	return newBuilder
 }
 
-func (b *builder) ExpectedStatusCodes(expectedStatusCodes ...int) Builder {
+// NegotiateResponse 为true时按响应的Content-Type从DefaultCodecRegistry选择解码的Codec,
+// 没有Content-Type或与请求的Content-Type一致时使用WithCodec指定的Codec
+func (b *builder) NegotiateResponse(negotiate bool) Builder {
 	newBuilder := b.clone()
 	if newBuilder.err != nil {
 		return newBuilder
 	}
-	newBuilder.expectedStatusCodes = expectedStatusCodes
+	newBuilder.negotiateResponse = negotiate
 	return newBuilder
 }
 
-func (b *builder) Logging(loggingReq, loggingResp bool) Builder {
+// WithErrorResp 状态码不在ExpectedStatusCodes中时用Codec将响应体解码到obj,
+// 返回ErrErrorResp,WithResp的对象不会被修改
+func (b *builder) WithErrorResp(obj interface{}) Builder {
 	newBuilder := b.clone()
 	if newBuilder.err != nil {
 		return newBuilder
 	}
-	newBuilder.loggingReq = loggingReq
-	newBuilder.loggingResp = loggingResp
+	newBuilder.errorResp = obj
 	return newBuilder
 }
 
-func (b *builder) Timeout(timeout time.Duration) Builder {
-	newBuilder := b.clone()
+// MaxResponseBytes 响应体超过n字节时返回ErrResponseTooLarge,同样限制日志读入内存的响应体;
+// 默认不限制
+func (b *builder) MaxResponseBytes(n int64) Builder {
+	newBuilder := b.cloneTransport()
 	if newBuilder.err != nil {
 		return newBuilder
 	}
-	newBuilder.timeout = timeout
+	newBuilder.maxResponseBytes = n
 	return newBuilder
 }
-func (b *builder) Tracing(tracing bool) Builder {
-	newBuilder := b.clone()
+
+// WithRespWriter 将响应体直接io.Copy到w,不经过Codec解码,同时不再记录响应体日志;
+// 不使用DefaultDeadline与默认的超时,设置了Timeout时它同样覆盖读取响应体
+func (b *builder) WithRespWriter(w io.Writer) Builder {
+	newBuilder := b.cloneTransportIf((b.respWriter == nil) != (w == nil))
 	if newBuilder.err != nil {
 		return newBuilder
 	}
-	newBuilder.tracing = tracing
+	newBuilder.respWriter = w
 	return newBuilder
 }
-func (b *builder) ContentType(contentType string) Builder {
+
+// WithRespWritten 配合WithRespWriter,请求结束后n为写入w的字节数
+func (b *builder) WithRespWritten(n *int64) Builder {
 	newBuilder := b.clone()
 	if newBuilder.err != nil {
 		return newBuilder
 	}
-	newBuilder.contentType = contentType
+	newBuilder.respWritten = n
 	return newBuilder
 }
 
-func (b *builder) Insecure(insecure bool) Builder {
+// WithProgress 配合WithRespWriter与DownloadFile,每次写入后回调,
+// total来自Content-Length,未知时为-1
+func (b *builder) WithProgress(fn func(written, total int64)) Builder {
 	newBuilder := b.clone()
 	if newBuilder.err != nil {
 		return newBuilder
 	}
-	newBuilder.insecure = insecure
+	newBuilder.progress = fn
 	return newBuilder
 }
 
-func (b *builder) BuildHTTPReq(ctx context.Context) (*http.Request, error) {
-	if b.err != nil {
-		return nil, b.err
-	}
-	url := b.baseURL + b.path
-	urlValues := make(stdurl.Values)
-
-	{
-		urlObj, err := stdurl.Parse(url)
-		if err != nil {
-			return nil, err
-		}
-		for key, values := range b.urlValues {
-			for _, value := range values {
-				urlValues.Add(key, value)
-			}
-		}
-		for key, values := range urlObj.Query() {
-			for _, value := range values {
-				urlValues.Add(key, value)
-			}
-		}
-		if len(urlValues) != 0 {
-			urlObj.RawQuery = urlValues.Encode()
-		}
-		url = urlObj.String()
+// WithRespStatusCode 将最后一个响应的状态码写入code,在状态码检查之前记录,
+// 重试时为最后一次尝试的状态码
+func (b *builder) WithRespStatusCode(code *int) Builder {
+	newBuilder := b.cloneTransportIf((b.respStatusCode == nil) != (code == nil))
+	if newBuilder.err != nil {
+		return newBuilder
 	}
+	newBuilder.respStatusCode = code
+	return newBuilder
+}
 
-	method := b.method
-	if b.method == "" {
-		method = http.MethodGet
+// WithTimings Do返回前将DNS、建连、TLS握手、首字节与总耗时写入t,出错时包含已经完成的阶段;
+// DoRaw的Total只到收到响应头
+func (b *builder) WithTimings(t *Timings) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
 	}
+	newBuilder.timings = t
+	return newBuilder
+}
 
-	var body io.Reader
-	var data []byte
-	if b.req != nil {
-		var err error
-		data, err = b.codec.Encode(b.req)
-		if err != nil {
-			return nil, err
-		}
-		body = bytes.NewReader(data)
-	}
-	httpReq, err := http.NewRequestWithContext(ctx, method, url, body)
-	if err != nil {
-		return nil, err
-	}
-	headers := make(http.Header)
-	for key, values := range b.header {
-		for _, value := range values {
-			headers.Set(key, value)
-		}
-	}
-	contentType := b.contentType
-	if contentType == "" {
-		contentType = ContentTypeJson
-	}
-	if headers.Get(ContentTypeKey) == "" {
-		headers.Set(ContentTypeKey, contentType)
+// RequireResponseBody 默认204、205、304以及空的或只有空白的响应体不解码,resp保持不变;
+// 设置后这些响应也交给Codec解码,通常会返回EOF错误
+func (b *builder) RequireResponseBody() Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
 	}
-	httpReq.Header = headers
-	return httpReq, nil
+	newBuilder.requireRespBody = true
+	return newBuilder
 }
 
-func (b *builder) BuildTransport(ctx context.Context) (http.RoundTripper, error) {
-	if b.err != nil {
-		return nil, b.err
+// ExpectContentType 响应的Content-Type(忽略charset等参数)不一致时在解码前返回ErrUnexpectedContentType
+func (b *builder) ExpectContentType(contentType string) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
 	}
-	transport := Transport()
+	newBuilder.expectContentType = contentType
+	return newBuilder
+}
 
-	if b.insecure {
-		transport = InsecureTransport()
-	}
-	if b.transport != nil {
-		transport = b.transport
+// CompressRequest 按encoding(目前支持gzip)压缩请求体,日志中记录的是压缩前的请求体
+func (b *builder) CompressRequest(encoding string) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
 	}
-	expectedStatusCodes := []int{http.StatusOK}
+	newBuilder.requestEncoding = encoding
+	newBuilder.err = checkRequestEncoding(encoding)
+	return newBuilder
+}
 
-	if len(b.expectedStatusCodes) != 0 {
-		expectedStatusCodes = b.expectedStatusCodes
-	}
-	tws := []TransportWrapper{
-		StatusCodesTransport(expectedStatusCodes...),
-	}
-	if b.contentType == "" || b.contentType == ContentTypeJson {
-		tws = append(tws, JsonTransport)
-	}
-	tws = append(tws, LoggingTransport(b.loggingReq, b.loggingResp))
-	if b.tracing {
-		tws = append(tws, TracingTransport(""))
+// WithPathParam 替换path中的{key}占位符,value会经过url.PathEscape
+func (b *builder) WithPathParam(key, value string) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
 	}
-	tws = append(tws, TimeoutTransport(b.timeout))
-	transport = WrapTransport(transport, tws...)
-	return transport, nil
+	newBuilder.pathParams[key] = value
+	return newBuilder
 }
 
-func (b *builder) WithTransport(transport http.RoundTripper) Builder {
-	newBuilder := b.clone()
+// WithAttemptHistory Do结束后h中为每次尝试(包括最后成功的一次)的记录,
+// h会在每次Do开始时被重置,不要在并发的请求之间共享
+func (b *builder) WithAttemptHistory(h *[]AttemptRecord) Builder {
+	newBuilder := b.cloneTransportIf((b.history == nil) != (h == nil))
 	if newBuilder.err != nil {
 		return newBuilder
 	}
-	newBuilder.transport = transport
+	newBuilder.history = h
+	return newBuilder
+}
+
+// WithHost 覆盖请求的Host头,连接仍然发往url中的地址
+func (b *builder) WithHost(host string) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.host = host
+	return newBuilder
+}
+
+// WithRequestEditor 在BuildHTTPReq最后按注册顺序执行fn,第一个错误会中止请求;
+// 编码后的请求体可以通过GetBody读取
+func (b *builder) WithRequestEditor(fn func(*http.Request) error) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.requestEditors = append(newBuilder.requestEditors, fn)
+	return newBuilder
+}
+
+// WithTransportWrapper 追加自定义的TransportWrapper,位于状态码检查与json之外、日志之内,
+// 先追加的更靠近底层transport;请求经过tws时已经记录过日志
+func (b *builder) WithTransportWrapper(tws ...TransportWrapper) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.transportWrappers = append(newBuilder.transportWrappers, tws...)
+	return newBuilder
+}
+
+// WithIdempotencyKey 设置Idempotency-Key头,带key的POST/PATCH在重试策略中视为幂等
+func (b *builder) WithIdempotencyKey(key string) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.idempotencyKey = &idempotencyKey{value: key}
+	return newBuilder
+}
+
+// WithAutoIdempotencyKey 每次Do生成UUID v4作为Idempotency-Key,这次Do的重试复用同一个key,
+// 派生的Builder以及再次Do使用新的key
+func (b *builder) WithAutoIdempotencyKey() Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.idempotencyKey = &idempotencyKey{auto: true}
+	return newBuilder
+}
+
+// IdempotencyKey 返回请求使用的Idempotency-Key,自动生成时为该Builder最近一次Do使用的key,未设置或还没有Do时为空
+func (b *builder) IdempotencyKey() string {
+	if b.idempotencyKey == nil {
+		return ""
+	}
+	if !b.idempotencyKey.auto {
+		return b.idempotencyKey.value
+	}
+	if last := b.idempotencyKey.last.Load(); last != nil {
+		return *last
+	}
+	return ""
+}
+
+// WithCookie 添加cookie,同名cookie会同时发送,c为nil时Do返回错误
+func (b *builder) WithCookie(c *http.Cookie) Builder {
+	return b.WithCookies(c)
+}
+
+func (b *builder) WithCookies(cs ...*http.Cookie) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	for idx, c := range cs {
+		if c == nil {
+			newBuilder.err = fmt.Errorf("cookie %d is nil", idx)
+			return newBuilder
+		}
+		copied := *c
+		newBuilder.cookies = append(newBuilder.cookies, &copied)
+	}
+	return newBuilder
+}
+
+// WithCookieJar 请求使用jar,共享同一个jar的Builder之间可以保持会话
+func (b *builder) WithCookieJar(jar http.CookieJar) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.jar = jar
+	return newBuilder
+}
+
+func (b *builder) WithHeaders(headers http.Header) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	for k, vs := range headers {
+		for _, v := range vs {
+			newBuilder.header.Add(k, v)
+		}
+	}
+	return newBuilder
+}
+
+func (b *builder) WithReq(req interface{}) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.req = req
+	return newBuilder
+}
+
+// WithReqSlice 将items的每个元素用lineCodec编码为一行,以ndjson流式发送
+func (b *builder) WithReqSlice(items interface{}, lineCodec Codec) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	if lineCodec == nil {
+		lineCodec = defaultCodec
+	}
+	newBuilder.ndjson, newBuilder.err = newSliceNDJSONBody(items, lineCodec)
+	newBuilder.contentType = ContentTypeNDJSON
+	return newBuilder
+}
+
+// WithReqStream 逐个调用next直到返回false,以ndjson流式发送,无法重放因此不支持重试,
+// Builder只能发送一次,再次Do返回ErrReqStreamConsumed
+func (b *builder) WithReqStream(next func() (interface{}, bool)) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.ndjson = newStreamNDJSONBody(next, defaultCodec)
+	newBuilder.contentType = ContentTypeNDJSON
+	return newBuilder
+}
+
+// WithFormReq 请求体编码为application/x-www-form-urlencoded,不经过codec
+func (b *builder) WithFormReq(values stdurl.Values) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	form := make(stdurl.Values, len(values))
+	for key, vals := range values {
+		form[key] = append([]string(nil), vals...)
+	}
+	newBuilder.form = form
+	newBuilder.contentType = ContentTypeForm
+	return newBuilder
+}
+
+// WithFormReqObj 按go-querystring的url tag将obj编码为form请求体
+func (b *builder) WithFormReqObj(obj interface{}) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	values, err := query.Values(obj)
+	if err != nil {
+		newBuilder.err = err
+		return newBuilder
+	}
+	return newBuilder.WithFormReq(values)
+}
+
+// WithMultipartFile 以multipart/form-data上传r的内容,r只能读取一次因此不支持重试
+func (b *builder) WithMultipartFile(fieldName, fileName string, r io.Reader) Builder {
+	return b.withMultipartPart(multipartPart{name: fieldName, fileName: fileName, reader: r})
+}
+
+// oneShotBody 请求体来自只能读取一次的io.Reader
+func (b *builder) oneShotBody() bool {
+	return b.bodyReader != nil || (b.ndjson != nil && !b.ndjson.replayable) || oneShotMultipart(b.multipartParts)
+}
+
+// WithMultipartField 添加multipart/form-data的普通字段,与文件按添加顺序发送
+func (b *builder) WithMultipartField(name, value string) Builder {
+	return b.withMultipartPart(multipartPart{name: name, value: value})
+}
+
+func (b *builder) withMultipartPart(part multipartPart) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.multipartParts = append(append([]multipartPart(nil), b.multipartParts...), part)
+	newBuilder.contentType = ContentTypeMultipart
+	return newBuilder
+}
+
+// WithBodyReader 原样发送r,不经过codec,r只能读取一次因此ResiliencePolicy不重试
+func (b *builder) WithBodyReader(r io.Reader) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.bodyReader = r
+	return newBuilder
+}
+
+// WithBodyBytes 原样发送data,不经过codec,重定向与重试时可以重放
+func (b *builder) WithBodyBytes(data []byte) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	newBuilder.bodyBytes = data
+	return newBuilder
+}
+
+func (b *builder) WithResp(resp interface{}) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.resp = resp
+	return newBuilder
+}
+
+// WithRespValidator 多次调用时按注册顺序执行,第一个失败的validator返回ErrResponseValidation
+func (b *builder) WithRespValidator(validator RespValidator) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.respValidator = chainRespValidators(b.respValidator, validator)
+	return newBuilder
+}
+
+// WithRespTransformer 解码前转换响应体,多次调用时按顺序执行
+func (b *builder) WithRespTransformer(transformer RespTransformer) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.respTransformers = append(append([]RespTransformer(nil), b.respTransformers...), transformer)
+	return newBuilder
+}
+
+func (b *builder) ExpectedStatusCodes(expectedStatusCodes ...int) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.expectedStatusCodes = expectedStatusCodes
+	newBuilder.anyStatus = false
+	return newBuilder
+}
+
+// ExpectedStatusRange 允许start到end之间(包含两端)的状态码,可以多次调用,与ExpectedStatusCodes取并集;
+// 只设置了范围时不再默认允许200
+func (b *builder) ExpectedStatusRange(start, end int) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	if start > end {
+		newBuilder.err = fmt.Errorf("invalid status range:%d-%d", start, end)
+		return newBuilder
+	}
+	newBuilder.expectedStatusRanges = append(append([]StatusCodeRange(nil), b.expectedStatusRanges...), StatusCodeRange{Start: start, End: end})
+	newBuilder.anyStatus = false
+	return newBuilder
+}
+
+// Expect2xx 允许所有2xx状态码
+func (b *builder) Expect2xx() Builder {
+	return b.ExpectedStatusRange(200, 299)
+}
+
+// ExpectAnyStatus 不检查状态码,调用方通过WithRespStatusCode自行处理;WithErrorResp不再生效
+func (b *builder) ExpectAnyStatus() Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.anyStatus = true
+	return newBuilder
+}
+
+func (b *builder) Logging(loggingReq, loggingResp bool) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.loggingReq = loggingReq
+	newBuilder.loggingResp = loggingResp
+	return newBuilder
+}
+
+// WithLogger 请求经过的所有wrapper都使用logger输出日志,默认使用slog.Default()
+func (b *builder) WithLogger(logger *slog.Logger) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.logger = logger
+	return newBuilder
+}
+
+func (b *builder) Timeout(timeout time.Duration) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.timeout = timeout
+	return newBuilder
+}
+func (b *builder) NoDefaultDeadline() Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.noDefaultDeadline = true
+	return newBuilder
+}
+
+func (b *builder) Tracing(tracing bool) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.tracing = tracing
+	newBuilder.tracingSet = true
+	return newBuilder
+}
+
+// SpanName 设置client span的名称;没有设置时,path中有{key}占位符的请求使用"方法 path模板",例如GET /users/{id}
+func (b *builder) SpanName(name string) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.spanName = name
+	return newBuilder
+}
+
+// TracingServiceName 设置TracingTransport的service name,默认使用os.Args[0]
+func (b *builder) TracingServiceName(serviceName string) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.serviceName = serviceName
+	return newBuilder
+}
+
+// WithTracingOptions 追加otelhttp的配置,在默认配置之后应用,可以多次调用
+func (b *builder) WithTracingOptions(opts ...otelhttp.Option) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.tracingOptions = append(append([]otelhttp.Option(nil), b.tracingOptions...), opts...)
+	return newBuilder
+}
+
+// WithSpanAttributes 在client span上设置attrs,可以多次调用
+func (b *builder) WithSpanAttributes(attrs ...attribute.KeyValue) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.spanAttrs = append(append([]attribute.KeyValue(nil), b.spanAttrs...), attrs...)
+	return newBuilder
+}
+
+// Propagation 与Tracing(false)一起使用时,只通过PropagationTransport注入traceparent头,不创建span
+func (b *builder) Propagation(propagation bool) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.propagation = propagation
+	return newBuilder
+}
+
+// tracingTransport Tracing优先,都没有开启时返回nil
+func (b *builder) tracingTransport() TransportWrapper {
+	if b.tracing {
+		return TracingTransportWithOptions(b.serviceName, b.tracingOptions...)
+	}
+	if b.propagation {
+		return PropagationTransport(nil)
+	}
+	return nil
+}
+
+// effectiveSpanName 没有SpanName时由path模板得到,path没有占位符时返回空,使用otelhttp默认的名称
+func (b *builder) effectiveSpanName() string {
+	if b.spanName != "" {
+		return b.spanName
+	}
+	if route := b.routeTemplate(); route != "" {
+		return b.effectiveMethod() + " " + route
+	}
+	return ""
+}
+
+// routeTemplate WithPathParam占位符替换之前的路由,path没有占位符时返回空
+func (b *builder) routeTemplate() string {
+	if !strings.Contains(b.path, "{") {
+		return ""
+	}
+	return routeTemplate(b.path)
+}
+
+type routeTemplateKey struct{}
+
+func contextWithRouteTemplate(ctx context.Context, route string) context.Context {
+	if route == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, routeTemplateKey{}, route)
+}
+
+// routeTemplateFromContext 请求的路由模板,没有时返回空
+func routeTemplateFromContext(ctx context.Context) string {
+	route, _ := ctx.Value(routeTemplateKey{}).(string)
+	return route
+}
+
+// routeTemplate 去掉path中的scheme、host与query,只保留路由部分
+func routeTemplate(path string) string {
+	if idx := strings.Index(path, "://"); idx >= 0 {
+		path = path[idx+len("://"):]
+		if idx = strings.IndexByte(path, '/'); idx < 0 {
+			path = "/"
+		} else {
+			path = path[idx:]
+		}
+	}
+	if idx := strings.IndexByte(path, '?'); idx >= 0 {
+		path = path[:idx]
+	}
+	return path
+}
+func (b *builder) ContentType(contentType string) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.contentType = contentType
+	return newBuilder
+}
+
+func (b *builder) Insecure(insecure bool) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.insecure = insecure
+	return newBuilder
+}
+
+// InsecureForHosts 只对hosts跳过证书校验,host:port精确匹配,host匹配自身及子域名
+func (b *builder) InsecureForHosts(hosts ...string) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.insecureHosts = append(append([]string(nil), b.insecureHosts...), hosts...)
+	return newBuilder
+}
+
+func (b *builder) Priority(priority Priority) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.priority = priority
+	newBuilder.prioritySet = true
+	return newBuilder
+}
+
+// SecurityProfile 按profile限制TLS版本与套件,覆盖DefaultSecurityProfile
+func (b *builder) SecurityProfile(profile SecurityProfile) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.profile = profile
+	newBuilder.profileSet = true
+	return newBuilder
+}
+
+// ConnEventHooks 使用回调hooks的transport,WithTransport指定的transport不受影响
+func (b *builder) ConnEventHooks(hooks *ConnEventHooks) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.connHooks = hooks
+	return newBuilder
+}
+
+// StrictOptions 为true时会被忽略的option组合在Validate与Do时返回错误,否则只打印Warn日志
+func (b *builder) StrictOptions(strict bool) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.strict = strict
+	return newBuilder
+}
+
+// ResiliencePolicy 为当前Builder指定重试与超时策略,优先于SwapPolicySet设置的按host策略
+func (b *builder) ResiliencePolicy(policy ResiliencePolicy) Builder {
+	newBuilder := b.clone()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.policy = &policy
+	return newBuilder
+}
+
+// MaxRetryAfter 覆盖DefaultMaxRetryAfter,同时作用于Retry与ResiliencePolicy
+func (b *builder) MaxRetryAfter(d time.Duration) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.maxRetryAfter = d
+	newBuilder.maxRetryAfterSet = true
+	return newBuilder
+}
+
+// RetryNonIdempotent 默认只重试GET/HEAD/PUT/DELETE/OPTIONS/TRACE以及带Idempotency-Key的请求,
+// 为true时POST等请求也会重试,调用方需要保证重放是安全的;同时作用于Retry与ResiliencePolicy
+func (b *builder) RetryNonIdempotent(retryNonIdempotent bool) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.retryNonIdempotent = retryNonIdempotent
+	return newBuilder
+}
+
+// WithTokenProvider 每个请求设置provider提供的bearer token,token在Builder及其派生的Builder之间缓存,
+// 响应401时刷新token并重试一次
+func (b *builder) WithTokenProvider(provider TokenProvider) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.tokenTransport = TokenSourceTransport(provider)
+	return newBuilder
+}
+
+// WithSigner 使用signer对请求签名,签名在transport链的最内层进行,覆盖所有wrapper设置的header以及压缩后的请求体;
+// 每次重试都会重新签名
+func (b *builder) WithSigner(signer Signer) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.signer = signer
+	return newBuilder
+}
+
+// WithCache 使用store缓存GET响应,过期后按ETag/Last-Modified重新确认,见CacheTransport
+func (b *builder) WithCache(store CacheStore) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.cacheStore = store
+	return newBuilder
+}
+
+// WithDump 把线上的请求与响应(包括body)写入w,位于底层transport之外,
+// 因此包含所有wrapper设置的header以及每一次重试,见DumpTransport
+func (b *builder) WithDump(w io.Writer) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.dumpWriter = w
+	return newBuilder
+}
+
+// WithProxy 通过proxyURL发出请求,支持http、https与socks5,url中的用户名密码用于代理认证;
+// 相同代理的请求共享缓存的transport,url无效时返回错误,见ProxyTransport
+func (b *builder) WithProxy(proxyURL string) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	u, err := parseProxyURL(proxyURL)
+	if err != nil {
+		newBuilder.err = err
+		return newBuilder
+	}
+	newBuilder.proxy = u.String()
+	return newBuilder
+}
+
+// RequestID 请求没有headerName头时设置request id,优先沿用ctx中的id,日志会记录它;headerName为空时使用RequestIDKey
+func (b *builder) RequestID(headerName string) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	if headerName == "" {
+		headerName = RequestIDKey
+	}
+	newBuilder.requestIDHeader = headerName
+	return newBuilder
+}
+
+// effectiveMaxRetryAfter 未调用MaxRetryAfter时使用DefaultMaxRetryAfter
+func (b *builder) effectiveMaxRetryAfter() time.Duration {
+	if b.maxRetryAfterSet {
+		return b.maxRetryAfter
+	}
+	return DefaultMaxRetryAfter
+}
+
+// Retry 在transport链中按backoff重试,包括第一次在内最多尝试maxAttempts次,Timeout覆盖所有尝试
+func (b *builder) Retry(maxAttempts int, backoff BackoffPolicy) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	if maxAttempts < 1 {
+		newBuilder.err = fmt.Errorf("retry max attempts must be positive,got:%d", maxAttempts)
+		return newBuilder
+	}
+	newBuilder.retryAttempts = maxAttempts
+	newBuilder.retryBackoff = backoff
+	return newBuilder
+}
+
+// Describe 返回 "METHOD URL" 形式的摘要,url中的密码会被隐藏
+func (b *builder) Describe() string {
+	method := b.method
+	if method == "" {
+		method = http.MethodGet
+	}
+	url := b.baseURL + b.path
+	if urlObj, err := stdurl.Parse(url); err == nil {
+		url = urlObj.Redacted()
+	}
+	desc := method + " " + url
+	if source, policy, ok := b.effectivePolicy(); ok {
+		desc += fmt.Sprintf(" policy=%s(%s)", source, policy)
+	}
+	if profile := b.securityProfile(); profile != SecurityProfileNone {
+		desc += " tls=" + profile.String()
+	}
+	return desc
+}
+
+func (b *builder) BuildHTTPReq(ctx context.Context) (*http.Request, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	path, err := expandPathParams(b.path, b.pathParams)
+	if err != nil {
+		return nil, err
+	}
+	url := b.baseURL + path
+
+	{
+		urlObj, err := stdurl.Parse(url)
+		if err != nil {
+			return nil, err
+		}
+		var reqValues stdurl.Values
+		if b.reqAsQuery && b.req != nil {
+			reqValues, err = query.Values(b.req)
+			if err != nil {
+				return nil, err
+			}
+		}
+		urlValues, err := mergeQuery(b.duplicatePolicy,
+			querySource{name: "explicit", values: b.urlValues},
+			querySource{name: "object", values: b.objValues},
+			querySource{name: "req", values: reqValues},
+			querySource{name: "inline", values: urlObj.Query()},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if len(urlValues) != 0 {
+			urlObj.RawQuery = urlValues.Encode()
+		}
+		url = urlObj.String()
+	}
+
+	method := b.method
+	if b.method == "" {
+		method = http.MethodGet
+	}
+
+	if sources := b.bodySources(); len(sources) > 1 {
+		return nil, fmt.Errorf("request body options are mutually exclusive:%s", strings.Join(sources, ","))
+	}
+	var body io.Reader
+	contentType := b.effectiveContentType()
+	switch {
+	case b.req != nil && !b.reqAsQuery:
+		if encoder, ok := b.codec.(StreamEncoder); ok {
+			req := b.req
+			body = &lazyPipeReader{write: func(w io.Writer) error {
+				return encoder.EncodeTo(w, req)
+			}}
+			break
+		}
+		data, err := b.codec.Encode(b.req)
+		if err != nil {
+			return nil, err
+		}
+		// 与WithBodyBytes相同,NewRequest据此设置ContentLength与GetBody
+		body = bytes.NewReader(data)
+	case b.ndjson != nil:
+		ndjsonBody, err := b.ndjson.reader()
+		if err != nil {
+			return nil, err
+		}
+		body = ndjsonBody
+	case b.form != nil:
+		body = strings.NewReader(b.form.Encode())
+	case len(b.multipartParts) != 0:
+		body, contentType = multipartBody(b.multipartParts)
+	case b.bodyBytes != nil:
+		// *bytes.Reader使NewRequest设置ContentLength与GetBody,重定向时可以重放
+		body = bytes.NewReader(b.bodyBytes)
+	case b.bodyReader != nil:
+		body = b.bodyReader
+	}
+	// 只有显式调用Priority时才覆盖调用方在ctx中设置的优先级
+	if b.prioritySet {
+		ctx = WithPriority(ctx, b.priority)
+	}
+	ctx = ContextWithLogger(ctx, b.logger)
+	ctx = ContextWithSpanName(ctx, b.effectiveSpanName())
+	ctx = contextWithRouteTemplate(ctx, b.routeTemplate())
+	ctx = ContextWithSpanAttributes(ctx, b.spanAttrs...)
+	if len(b.respTransformers) != 0 {
+		ctx = withRespTransformed(ctx)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
+		return nil, err
+	}
+	if b.ndjson != nil {
+		httpReq.GetBody = b.ndjson.getBody()
+	}
+	headers := make(http.Header)
+	for key, values := range b.header {
+		for _, value := range values {
+			headers.Set(key, value)
+		}
+	}
+	if headers.Get(ContentTypeKey) == "" {
+		headers.Set(ContentTypeKey, contentType)
+	}
+	if b.userAgent != "" && headers.Get(UserAgentKey) == "" {
+		headers.Set(UserAgentKey, b.userAgent)
+	}
+	if b.idempotencyKey != nil && headers.Get(IdempotencyKeyHeader) == "" {
+		headers.Set(IdempotencyKeyHeader, b.idempotencyKey.get(ctx))
+	}
+	httpReq.Header = headers
+	if b.host != "" {
+		httpReq.Host = b.host
+	}
+	for _, cookie := range b.cookies {
+		httpReq.AddCookie(cookie)
+	}
+	for _, editor := range b.requestEditors {
+		if err := editor(httpReq); err != nil {
+			if httpReq.Body != nil {
+				httpReq.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	return httpReq, nil
+}
+
+func (b *builder) BuildTransport(ctx context.Context) (http.RoundTripper, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err := b.transportOptionsErr(); err != nil {
+		return nil, err
+	}
+	transport, _ := b.builtClient()
+	return transport, nil
+}
+
+// baseTransport WithTransport指定的transport不经过缓存
+func (b *builder) baseTransport() http.RoundTripper {
+	if b.transport != nil {
+		return b.transport
+	}
+	transport := defaultTransportCache.get(b.transportConfig())
+	if b.connHooks != nil {
+		return connReuseTransport(transport)
+	}
+	return transport
+}
+
+// buildTransport raw为true时不检查状态码、不记录body、不限制超时,响应body保持流式
+func (b *builder) buildTransport(raw bool) http.RoundTripper {
+	transport := b.baseTransport()
+	if b.dumpWriter != nil {
+		transport = DumpTransport(b.dumpWriter, true)(transport)
+	}
+	transport = StaleConnRetryTransport(transport)
+	if b.history != nil {
+		transport = attemptHistoryTransport(transport)
+	}
+	if b.respHeaders != nil || b.respStatusCode != nil {
+		transport = respCaptureTransport(transport)
+	}
+	if raw {
+		var tws []TransportWrapper
+		if b.signer != nil {
+			tws = append(tws, SigningTransport(b.signer))
+		}
+		if b.tokenTransport != nil {
+			tws = append(tws, b.tokenTransport)
+		}
+		tws = append(tws, b.transportWrappers...)
+		tws = append(tws, LoggingTransport(false, false))
+		if tw := b.tracingTransport(); tw != nil {
+			tws = append(tws, tw)
+		}
+		if b.requestIDHeader != "" {
+			tws = append(tws, RequestIDTransport(b.requestIDHeader))
+		}
+		return WrapTransportChain(transport, tws...)
+	}
+	expectedStatusCodes := b.expectedStatusCodes
+	if len(expectedStatusCodes) == 0 && len(b.expectedStatusRanges) == 0 {
+		expectedStatusCodes = []int{http.StatusOK}
+	}
+	var tws []TransportWrapper
+	if b.signer != nil {
+		tws = append(tws, SigningTransport(b.signer))
+	}
+	if b.requestEncoding != "" {
+		tws = append(tws, CompressRequestTransport(b.requestEncoding))
+	}
+	if b.maxResponseBytes > 0 {
+		tws = append(tws, BodyLimitTransport(b.maxResponseBytes))
+	}
+	// 在状态码检查之内才能看到304;条件请求头在签名之外添加,在token之内才能看到Authorization
+	if b.cacheStore != nil {
+		tws = append(tws, CacheTransport(b.cacheStore))
+	}
+	// 在状态码检查之内,401时可以刷新token重试
+	if b.tokenTransport != nil {
+		tws = append(tws, b.tokenTransport)
+	}
+	tws = append(tws, DeprecationWatchTransport(DeprecationWatchOptions{}))
+	if !b.anyStatus {
+		tws = append(tws, statusCheckTransport(maxErrorBodyBytes, expectedStatusCodes, b.expectedStatusRanges))
+	}
+	if b.effectiveContentType() == ContentTypeJson && !b.proxied {
+		tws = append(tws, JsonTransport)
+	}
+	if b.retryAttempts > 1 {
+		tws = append(tws, retryTransport(b.retryAttempts, b.retryBackoff, b.effectiveMaxRetryAfter(), b.retryNonIdempotent))
+	}
+	tws = append(tws, b.transportWrappers...)
+	// 写入respWriter的响应体可能很大,流式的响应体没有结尾,都不读入内存记录日志
+	tws = append(tws, LoggingTransport(b.loggingReq, b.loggingResp && b.respWriter == nil && b.respStream == nil))
+	if tw := b.tracingTransport(); tw != nil {
+		tws = append(tws, tw)
+	}
+	// 流式与写入io.Writer的响应可能持续很久,没有设置Timeout时不使用默认的超时
+	if b.timeout > 0 || !b.streamingResp() {
+		tws = append(tws, TimeoutTransport(b.timeout))
+	}
+	// 放在最外层,日志与重试都使用同一个id
+	if b.requestIDHeader != "" {
+		tws = append(tws, RequestIDTransport(b.requestIDHeader))
+	}
+	return WrapTransportChain(transport, tws...)
+}
+
+// effectiveContentType 依次使用ContentType、Codec的ContentTyper以及json
+func (b *builder) effectiveContentType() string {
+	if b.contentType != "" {
+		return b.contentType
+	}
+	if typer, ok := b.codec.(ContentTyper); ok {
+		return typer.ContentType()
+	}
+	return ContentTypeJson
+}
+
+// bodySources 设置了请求体的option,多于一个时无法确定发送哪一个
+func (b *builder) bodySources() []string {
+	var sources []string
+	if b.req != nil && !b.reqAsQuery {
+		sources = append(sources, "WithReq")
+	}
+	if b.ndjson != nil {
+		sources = append(sources, "WithReqSlice/WithReqStream")
+	}
+	if b.form != nil {
+		sources = append(sources, "WithFormReq")
+	}
+	if len(b.multipartParts) != 0 {
+		sources = append(sources, "WithMultipartFile/WithMultipartField")
+	}
+	if b.bodyBytes != nil {
+		sources = append(sources, "WithBodyBytes")
+	}
+	if b.bodyReader != nil {
+		sources = append(sources, "WithBodyReader")
+	}
+	return sources
+}
+
+func (b *builder) transportConfig() transportConfig {
+	return transportConfig{
+		insecure:      b.insecure,
+		insecureHosts: b.insecureHosts,
+		connHooks:     b.connHooks,
+		profile:       b.securityProfile(),
+		proxy:         b.proxy,
+		tlsConfig:     b.tlsConfig,
+		options:       b.transportOptions,
+	}
+}
+
+func (b *builder) WithTransport(transport http.RoundTripper) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.transport = transport
 	return newBuilder
 }
 
@@ -547,11 +1927,73 @@ func (b *builder) Do(ctx context.Context) error {
 	if b.err != nil {
 		return b.err
 	}
-	transport, err := b.BuildTransport(ctx)
-	if err != nil {
+	if b.history != nil {
+		*b.history = nil
+	}
+	ctx = b.idempotencyKey.withKey(ctx)
+	if source, policy, ok := b.effectivePolicy(); ok {
+		return b.doWithPolicy(ctx, source, policy)
+	}
+	if err := b.transportOptionsErr(); err != nil {
 		return err
 	}
-	return b.DoWithTransport(ctx, transport)
+	_, client := b.builtClient()
+	return b.doAttempt(ctx, client, 0)
+}
+
+// DoRaw 经过完整的wrapper链发送请求并返回未读取的响应,状态码检查照常生效,不经过Codec解码;
+// 调用方需要关闭body。开启响应日志时body会先被读入内存再交给调用方,
+// 流式的响应需要配合Logging(req, false);DoRaw不执行ResiliencePolicy
+func (b *builder) DoRaw(ctx context.Context) (*http.Response, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err := b.checkOptions(ctx); err != nil {
+		return nil, err
+	}
+	if err := b.transportOptionsErr(); err != nil {
+		return nil, err
+	}
+	_, client := b.builtClient()
+	ctx = b.idempotencyKey.withKey(ctx)
+	ctx, cancel := b.withDefaultDeadline(ctx)
+	ctx, timings := b.withTimings(ctx)
+	defer b.setTimings(timings)
+	httpReq, err := b.BuildHTTPReq(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	httpReq, tracker := trackOutcome(httpReq)
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		cancel()
+		return nil, tracker.classify(wrapDeadlineCause(ctx, err))
+	}
+	httpResp.Body = &cancelOnClose{ReadCloser: httpResp.Body, cancel: cancel}
+	return httpResp, nil
+}
+
+// DoInto 将响应解码到resp,resp只对本次调用生效,可以在共享的Builder上并发调用
+func (b *builder) DoInto(ctx context.Context, resp interface{}) error {
+	if b.err != nil {
+		return b.err
+	}
+	newBuilder := b.clone()
+	newBuilder.resp = resp
+	return newBuilder.Do(ctx)
+}
+
+func (b *builder) MustDo(ctx context.Context) {
+	if err := b.Do(ctx); err != nil {
+		panic(err)
+	}
+}
+
+func (b *builder) DoWithDeadline(deadline time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+	return b.Do(ctx)
 }
 
 func (b *builder) DoWithTransport(ctx context.Context, transport http.RoundTripper) error {
@@ -560,6 +2002,7 @@ func (b *builder) DoWithTransport(ctx context.Context, transport http.RoundTripp
 	}
 	client := &http.Client{
 		Transport: transport,
+		Jar:       b.jar,
 	}
 	return b.DoWithClient(ctx, client)
 
@@ -569,24 +2012,146 @@ func (b *builder) DoWithClient(ctx context.Context, client *http.Client) error {
 	if b.err != nil {
 		return b.err
 	}
+	if err := b.checkOptions(ctx); err != nil {
+		return err
+	}
+	if b.jar != nil && client.Jar == nil {
+		// 不修改调用方的client
+		withJar := *client
+		withJar.Jar = b.jar
+		client = &withJar
+	}
+	release, err := acquireRespTarget(b.resp)
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx = b.idempotencyKey.withKey(ctx)
+	ctx, cancel := b.withDefaultDeadline(ctx)
+	defer cancel()
+	ctx, shared := withSharedRespBody(ctx)
+	var capture *respCapture
+	if b.respHeaders != nil || b.respStatusCode != nil {
+		ctx, capture = withRespCapture(ctx)
+	}
+	ctx, timings := b.withTimings(ctx)
+	defer b.setTimings(timings)
 	httpReq, err := b.BuildHTTPReq(ctx)
 	if err != nil {
 		return err
 	}
+	httpReq, tracker := trackOutcome(httpReq)
 	httpResp, err := client.Do(httpReq)
 	if err != nil {
-		return err
+		if capture != nil {
+			b.setRespCapture(capture.get())
+		}
+		return b.decodeErrorResp(tracker.classify(wrapDeadlineCause(ctx, err)))
 	}
 	defer httpResp.Body.Close()
+	if capture != nil {
+		defer func() {
+			var header http.Header
+			if b.respHeaders != nil {
+				header = finalRespHeaders(httpResp)
+			}
+			b.setRespCapture(httpResp.StatusCode, header)
+		}()
+	}
+	if b.expectContentType != "" {
+		if err := checkContentType(b.expectContentType, httpResp); err != nil {
+			return err
+		}
+	}
+	if err := b.decodeResp(httpResp, shared); err != nil {
+		return withOutcome(wrapDeadlineCause(ctx, err), OutcomeReceived)
+	}
+	return nil
+}
+
+func (b *builder) decodeResp(httpResp *http.Response, shared *sharedRespBody) error {
+	if b.respStream != nil {
+		return b.respStream(httpResp)
+	}
+	if b.respWriter != nil {
+		w := b.respWriter
+		if b.progress != nil {
+			w = &progressWriter{w: w, fn: b.progress, total: httpResp.ContentLength}
+		}
+		n, err := io.Copy(w, httpResp.Body)
+		if b.respWritten != nil {
+			*b.respWritten = n
+		}
+		return err
+	}
+	if b.resp == nil && b.respValidator == nil {
+		return nil
+	}
+	if b.emptyResp(httpResp) {
+		return b.validateEmptyResp(httpResp)
+	}
+	codec, err := b.respCodec(httpResp.Header.Get(ContentTypeKey), httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if jsonCodec, isJson := codec.(*JsonCodec); isJson && !jsonCodec.strict() && b.respValidator == nil && len(b.respTransformers) == 0 {
+		return decodeSmallJSON(httpResp, shared, b.resp)
+	}
+	body, err := applyRespTransformers(b.respTransformers, httpResp.Header.Get(ContentTypeKey), httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if b.respValidator == nil {
+		return codec.Decode(body, b.resp)
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
 	if b.resp != nil {
-		err := b.codec.Decode(httpResp.Body, b.resp)
-		if err != nil {
+		if err := codec.Decode(bytes.NewReader(raw), b.resp); err != nil {
 			return err
 		}
 	}
+	if err := b.respValidator(respContext(httpResp), b.resp, raw); err != nil {
+		return &ErrResponseValidation{Raw: raw, Err: err}
+	}
 	return nil
 }
 
+// withDefaultDeadline ctx没有deadline且未设置Timeout时,使用DefaultDeadline兜底
+func (b *builder) withDefaultDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.noDefaultDeadline || b.timeout > 0 || DefaultDeadline <= 0 || b.streamingResp() {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	ctx = context.WithValue(ctx, defaultDeadlineKey{}, struct{}{})
+	return context.WithTimeoutCause(ctx, DefaultDeadline, fmt.Errorf("httpx default deadline %s", DefaultDeadline))
+}
+
+type defaultDeadlineKey struct{}
+
+// hasDefaultDeadline ctx的deadline来自DefaultDeadline
+func hasDefaultDeadline(ctx context.Context) bool {
+	return ctx.Value(defaultDeadlineKey{}) != nil
+}
+
+// streamingResp 响应体交给DoStream、WithRespWriter或ProxyHandler逐步处理
+func (b *builder) streamingResp() bool {
+	return b.respStream != nil || b.respWriter != nil || b.proxied
+}
+
+// wrapDeadlineCause 超时时带上ctx的cause,便于区分是否为默认deadline
+func wrapDeadlineCause(ctx context.Context, err error) error {
+	cause := context.Cause(ctx)
+	if cause == nil || cause == ctx.Err() {
+		return err
+	}
+	return fmt.Errorf("%w: %w", err, cause)
+}
+
 func (b *builder) clone() *builder {
 	urlValues := make(stdurl.Values)
 	for key, values := range b.urlValues {
@@ -595,6 +2160,12 @@ func (b *builder) clone() *builder {
 
 		}
 	}
+	objValues := make(stdurl.Values)
+	for key, values := range b.objValues {
+		for _, value := range values {
+			objValues.Add(key, value)
+		}
+	}
 	header := make(http.Header)
 	for key, values := range b.header {
 		for _, value := range values {
@@ -602,23 +2173,99 @@ func (b *builder) clone() *builder {
 
 		}
 	}
+	cookies := make([]*http.Cookie, 0, len(b.cookies))
+	for _, cookie := range b.cookies {
+		copied := *cookie
+		cookies = append(cookies, &copied)
+	}
+	pathParams := make(map[string]string, len(b.pathParams))
+	for key, value := range b.pathParams {
+		pathParams[key] = value
+	}
+	requestEditors := append([]func(*http.Request) error(nil), b.requestEditors...)
+	transportWrappers := append([]TransportWrapper(nil), b.transportWrappers...)
 	return &builder{
-		path:                b.path,
-		method:              b.method,
-		baseURL:             b.baseURL,
-		codec:               b.codec,
-		resp:                b.resp,
-		req:                 b.req,
-		urlValues:           urlValues,
-		header:              header,
-		expectedStatusCodes: b.expectedStatusCodes,
-		loggingReq:          b.loggingReq,
-		loggingResp:         b.loggingResp,
-		timeout:             b.timeout,
-		tracing:             b.tracing,
-		contentType:         b.contentType,
-		insecure:            b.insecure,
-		err:                 b.err,
-		transport:           b.transport,
+		path:                 b.path,
+		method:               b.method,
+		baseURL:              b.baseURL,
+		codec:                b.codec,
+		resp:                 b.resp,
+		respValidator:        b.respValidator,
+		respTransformers:     b.respTransformers,
+		req:                  b.req,
+		ndjson:               b.ndjson,
+		form:                 b.form,
+		multipartParts:       b.multipartParts,
+		bodyReader:           b.bodyReader,
+		bodyBytes:            b.bodyBytes,
+		urlValues:            urlValues,
+		objValues:            objValues,
+		reqAsQuery:           b.reqAsQuery,
+		duplicatePolicy:      b.duplicatePolicy,
+		header:               header,
+		cookies:              cookies,
+		jar:                  b.jar,
+		userAgent:            b.userAgent,
+		expectContentType:    b.expectContentType,
+		respHeaders:          b.respHeaders,
+		negotiateResponse:    b.negotiateResponse,
+		errorResp:            b.errorResp,
+		maxResponseBytes:     b.maxResponseBytes,
+		respWriter:           b.respWriter,
+		respWritten:          b.respWritten,
+		progress:             b.progress,
+		respStatusCode:       b.respStatusCode,
+		respStream:           b.respStream,
+		timings:              b.timings,
+		requireRespBody:      b.requireRespBody,
+		requestEncoding:      b.requestEncoding,
+		pathParams:           pathParams,
+		history:              b.history,
+		host:                 b.host,
+		requestEditors:       requestEditors,
+		transportWrappers:    transportWrappers,
+		idempotencyKey:       b.idempotencyKey.clone(),
+		expectedStatusCodes:  b.expectedStatusCodes,
+		expectedStatusRanges: b.expectedStatusRanges,
+		anyStatus:            b.anyStatus,
+		loggingReq:           b.loggingReq,
+		loggingResp:          b.loggingResp,
+		logger:               b.logger,
+		timeout:              b.timeout,
+		noDefaultDeadline:    b.noDefaultDeadline,
+		tracing:              b.tracing,
+		tracingSet:           b.tracingSet,
+		spanName:             b.spanName,
+		serviceName:          b.serviceName,
+		tracingOptions:       b.tracingOptions,
+		spanAttrs:            b.spanAttrs,
+		propagation:          b.propagation,
+		contentType:          b.contentType,
+		insecure:             b.insecure,
+		insecureHosts:        b.insecureHosts,
+		priority:             b.priority,
+		prioritySet:          b.prioritySet,
+		proxied:              b.proxied,
+		profile:              b.profile,
+		profileSet:           b.profileSet,
+		connHooks:            b.connHooks,
+		strict:               b.strict,
+		policy:               b.policy,
+		retryAttempts:        b.retryAttempts,
+		retryBackoff:         b.retryBackoff,
+		retryNonIdempotent:   b.retryNonIdempotent,
+		requestIDHeader:      b.requestIDHeader,
+		tokenTransport:       b.tokenTransport,
+		signer:               b.signer,
+		cacheStore:           b.cacheStore,
+		dumpWriter:           b.dumpWriter,
+		proxy:                b.proxy,
+		tlsConfig:            b.tlsConfig,
+		maxRetryAfter:        b.maxRetryAfter,
+		maxRetryAfterSet:     b.maxRetryAfterSet,
+		err:                  b.err,
+		transportOptions:     b.transportOptions,
+		transport:            b.transport,
+		built:                b.built,
 	}
 }
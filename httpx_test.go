@@ -1,31 +1,31 @@
 package httpx
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
-	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/trace"
-
-	"go.opentelemetry.io/otel"
+	"github.com/wwq-2020/httpx/internal/testkit"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func TestPost(t *testing.T) {
-	exp, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
-	if err != nil {
-		panic("failed to New")
-	}
-	bsp := trace.NewBatchSpanProcessor(exp)
-	tp := trace.NewTracerProvider(
-		trace.WithSampler(trace.AlwaysSample()),
-		trace.WithSpanProcessor(bsp),
-	)
-	otel.SetTextMapPropagator(propagation.TraceContext{})
-	otel.SetTracerProvider(tp)
+	exporter := testkit.InstallTracer(t)
+	logs := testkit.CaptureLogs(t)
 
 	type req struct {
 		Data string
@@ -33,7 +33,7 @@ func TestPost(t *testing.T) {
 	type resp struct {
 		Data string
 	}
-	server := httptest.NewServer(DefaultHandlerWrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := testkit.NewServer(t, DefaultHandlerWrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		gotReq := &req{}
 		if err := json.NewDecoder(r.Body).Decode(gotReq); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -44,7 +44,6 @@ func TestPost(t *testing.T) {
 			return
 		}
 	})))
-	defer server.Close()
 	givenData := "hello world"
 	givenReq := &req{
 		Data: givenData,
@@ -57,11 +56,84 @@ func TestPost(t *testing.T) {
 		WithReq(givenReq).
 		WithResp(gotResp).
 		Do(context.TODO()); err != nil {
-		panic(err)
+		t.Fatal(err)
 	}
 	if gotResp.Data != givenData {
 		t.Fatalf("expected data:%s,got:%s", givenData, gotResp.Data)
 	}
+	if len(exporter.GetSpans()) == 0 {
+		t.Fatal("expected spans to be exported")
+	}
+	logs.AssertField(t, "serve http req", "http_method", http.MethodPost)
+	logs.AssertField(t, "got http resp", "http_status_code", http.StatusOK)
+}
+
+func TestSpanName(t *testing.T) {
+	exporter := testkit.InstallTracer(t)
+	server := testkit.NewServer(t, testkit.Echo())
+	tests := []struct {
+		name     string
+		builder  Builder
+		expected string
+	}{
+		{name: "default", builder: Get(server.URL + "/users/12345"), expected: "HTTP GET"},
+		{name: "path template", builder: Delete(server.URL+"/users/{id}?force=1").WithPathParam("id", "12345"), expected: "DELETE /users/{id}"},
+		{name: "explicit", builder: Get(server.URL+"/users/{id}").WithPathParam("id", "12345").SpanName("load user"), expected: "load user"},
+		{
+			name: "options",
+			builder: Get(server.URL).WithTracingOptions(otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return "custom " + r.Method
+			})),
+			expected: "custom GET",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			if err := tt.builder.ExpectedStatusCodes(http.StatusOK).Do(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			spans := exporter.GetSpans()
+			if len(spans) != 1 || spans[0].Name != tt.expected {
+				t.Fatalf("expected span:%s,got:%v", tt.expected, spans.Snapshots())
+			}
+		})
+	}
+
+	rt, err := TracingServiceName("orders").BuildTransport(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain := ChainOf(rt); chain[1].Name != "tracing" || chain[1].Config != "orders" {
+		t.Fatalf("expected tracing with service name orders,got:%+v", chain)
+	}
+}
+
+func TestWithSpanAttributes(t *testing.T) {
+	exporter := testkit.InstallTracer(t)
+	server := testkit.NewServer(t, testkit.StatusSequence(http.StatusServiceUnavailable, http.StatusOK))
+	base := Get(server.URL).WithSpanAttributes(attribute.String("tenant.id", "t1"))
+	// clone后追加,不影响base
+	b := base.WithSpanAttributes(attribute.String("operation", "list")).Retry(2, ConstantBackoff(time.Millisecond))
+	if err := b.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := base.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans,got:%d", len(spans))
+	}
+	for i, operation := range []string{"list", ""} {
+		got := map[attribute.Key]string{}
+		for _, attr := range spans[i].Attributes {
+			got[attr.Key] = attr.Value.Emit()
+		}
+		if got["tenant.id"] != "t1" || got["operation"] != operation {
+			t.Fatalf("unexpected span attributes:%v", got)
+		}
+	}
 }
 
 func TestStatusPost(t *testing.T) {
@@ -71,7 +143,7 @@ func TestStatusPost(t *testing.T) {
 	type resp struct {
 		Data string
 	}
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		gotReq := &req{}
 		if err := json.NewDecoder(r.Body).Decode(gotReq); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -82,7 +154,6 @@ func TestStatusPost(t *testing.T) {
 			return
 		}
 	}))
-	defer server.Close()
 	givenData := "hello world"
 	givenReq := &req{
 		Data: givenData,
@@ -95,7 +166,7 @@ func TestStatusPost(t *testing.T) {
 		WithResp(gotResp).
 		WithCodec(&StatusJsonCodec{}).
 		Do(context.TODO()); err != nil {
-		panic(err)
+		t.Fatal(err)
 	}
 	if gotResp.Data != givenData {
 		t.Fatalf("expected data:%s,got:%s", givenData, gotResp.Data)
@@ -103,15 +174,994 @@ func TestStatusPost(t *testing.T) {
 }
 
 func Test_builder_BuildHTTPReq(t *testing.T) {
-	b := Get("https://www.abcd123.top/api/v1/login?q1=a&q2=b").
-		WithQueryString("q3", "c")
+	tests := []struct {
+		name     string
+		builder  Builder
+		expected string
+	}{
+		{
+			name: "inline and builder query",
+			builder: Get("https://www.abcd123.top/api/v1/login?q1=a&q2=b").
+				WithQueryString("q3", "c"),
+			expected: "q1=a&q2=b&q3=c",
+		},
+		{
+			name: "repeated key",
+			builder: Get("https://www.abcd123.top/api/v1/login?q1=a").
+				WithQueryString("q1", "b"),
+			expected: "q1=b&q1=a",
+		},
+		{
+			name:     "no query",
+			builder:  Get("https://www.abcd123.top/api/v1/login"),
+			expected: "",
+		},
+		{
+			name: "query obj",
+			builder: Get("https://www.abcd123.top/api/v1/login").
+				WithQueryStringObj(struct {
+					Name string `url:"name"`
+				}{Name: "a b"}),
+			expected: "name=a+b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpReq, err := tt.builder.BuildHTTPReq(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := httpReq.URL.RawQuery; got != tt.expected {
+				t.Fatalf("expected query:%s,got:%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestInsecure(t *testing.T) {
+	server := testkit.NewTLSServer(t, testkit.Echo())
+
+	if err := Post(server.URL).
+		WithReq(map[string]string{"data": "hello"}).
+		Do(context.Background()); err == nil {
+		t.Fatal("expected certificate error")
+	}
+
+	gotResp := map[string]string{}
+	if err := Post(server.URL).
+		Insecure(true).
+		WithReq(map[string]string{"data": "hello"}).
+		WithResp(&gotResp).
+		Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if gotResp["data"] != "hello" {
+		t.Fatalf("expected data:hello,got:%s", gotResp["data"])
+	}
+}
+
+func TestWithBearerToken(t *testing.T) {
+	var got []string
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Values("Authorization")
+		w.Write([]byte(`{}`))
+	}))
+	if err := WithBearerToken("old").WithBearerToken("token").Get(server.URL).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "Bearer token" {
+		t.Fatalf("expected Authorization:Bearer token,got:%v", got)
+	}
+}
 
-	httpReq, err := b.BuildHTTPReq(context.Background())
+func TestWithUserAgent(t *testing.T) {
+	var got []string
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Values("User-Agent")
+		w.Write([]byte(`{}`))
+	}))
+	if err := WithUserAgent("svc/1.0").Get(server.URL).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "svc/1.0" {
+		t.Fatalf("expected User-Agent:svc/1.0,got:%v", got)
+	}
+	if err := WithHeader("User-Agent", "explicit").WithUserAgent("svc/1.0").Get(server.URL).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "explicit" {
+		t.Fatalf("expected User-Agent:explicit,got:%v", got)
+	}
+}
+
+func TestWithPathParam(t *testing.T) {
+	var got string
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.EscapedPath()
+		w.Write([]byte(`{}`))
+	}))
+	b := Get(server.URL+"/users/{id}/orders/{orderID}").WithPathParam("id", "a/b")
+	shared := b.WithPathParam("orderID", "100%")
+	if err := shared.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/users/a%2Fb/orders/100%25"; got != expected {
+		t.Fatalf("expected path:%s,got:%s", expected, got)
+	}
+	_, err := b.BuildHTTPReq(context.Background())
+	if !errors.Is(err, ErrMissingPathParam) || !strings.Contains(err.Error(), "orderID") {
+		t.Fatalf("expected missing path param orderID,got:%v", err)
+	}
+	if err := b.WithPathParam("orderID", "1").Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/users/a%2Fb/orders/1"; got != expected {
+		t.Fatalf("expected path:%s,got:%s", expected, got)
+	}
+}
+
+func TestWithFormReq(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Header.Get("Content-Type") != ContentTypeForm {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(r.PostForm)
+	}))
+	got := make(url.Values)
+	if err := Post(server.URL).WithFormReq(url.Values{"name": {"a b"}, "tag": {"x", "y"}}).WithResp(&got).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("name") != "a b" || len(got["tag"]) != 2 {
+		t.Fatalf("expected form fields,got:%v", got)
+	}
+
+	type form struct {
+		Name string `url:"name"`
+		Age  int    `url:"age,omitempty"`
+	}
+	got = make(url.Values)
+	if err := Post(server.URL).WithFormReqObj(&form{Name: "b"}).WithResp(&got).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got.Encode() != "name=b" {
+		t.Fatalf("expected form:name=b,got:%s", got.Encode())
+	}
+
+	transport, err := WithFormReq(url.Values{}).BuildTransport(context.Background())
 	if err != nil {
-		t.Error(err)
-		return
+		t.Fatal(err)
+	}
+	for _, info := range ChainOf(transport) {
+		if info.Name == "json" {
+			t.Fatal("expected no json wrapper for form body")
+		}
 	}
-	t.Logf("httpReq: %#v", httpReq)
+}
+
+func TestWithMultipart(t *testing.T) {
+	const size = 8 << 20
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil || r.ContentLength != -1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var parts []string
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			hash := sha256.New()
+			n, _ := io.Copy(hash, part)
+			if part.FileName() == "" {
+				parts = append(parts, fmt.Sprintf("%s=%d", part.FormName(), n))
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s:%s:%d:%x", part.FormName(), part.FileName(), n, hash.Sum(nil)[:4]))
+		}
+		json.NewEncoder(w).Encode(parts)
+	}))
+	content := bytes.Repeat([]byte("0123456789abcdef"), size/16)
+	sum := sha256.Sum256(content)
+	var got []string
+	err := Post(server.URL).
+		WithMultipartField("name", "report").
+		WithMultipartFile("file", "big.bin", bytes.NewReader(content)).
+		WithMultipartField("tag", "x").
+		WithMultipartFile("file", "small.txt", strings.NewReader("abc")).
+		WithResp(&got).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"name=6", fmt.Sprintf("file:big.bin:%d:%x", size, sum[:4]), "tag=1", "file:small.txt:3:ba7816bf"}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected parts:%v,got:%v", expected, got)
+	}
+}
+
+func TestWithHost(t *testing.T) {
+	exporter := testkit.InstallTracer(t)
+	logs := testkit.CaptureLogs(t)
+	var got string
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Host
+		w.Write([]byte(`{}`))
+	}))
+	if err := Get(server.URL).WithHost("canary.example.com").Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got != "canary.example.com" {
+		t.Fatalf("expected host:canary.example.com,got:%s", got)
+	}
+	logs.AssertField(t, "send http req", "http_host", "canary.example.com")
+	logs.AssertField(t, "send http req", "http_url", server.URL)
+	var attrs []string
+	for _, span := range exporter.GetSpans() {
+		for _, attr := range span.Attributes {
+			if attr.Key == "http.host" {
+				attrs = append(attrs, attr.Value.AsString())
+			}
+		}
+	}
+	if len(attrs) != 1 || attrs[0] != "canary.example.com" {
+		t.Fatalf("expected span attribute http.host:canary.example.com,got:%v", attrs)
+	}
+}
+
+func TestWithRequestEditor(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"signature": r.Header.Get("X-Signature"),
+			"query":     r.URL.RawQuery,
+		})
+	}))
+	sign := func(httpReq *http.Request) error {
+		body, err := httpReq.GetBody()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("X-Signature", fmt.Sprintf("%d", len(data)))
+		return nil
+	}
+	debug := func(httpReq *http.Request) error {
+		httpReq.URL.RawQuery = "debug=1"
+		return nil
+	}
+	base := Post(server.URL).WithReq(map[string]string{"a": "b"}).WithRequestEditor(sign)
+	signed := base.WithRequestEditor(debug)
+
+	resp := map[string]string{}
+	if err := signed.WithResp(&resp).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if resp["signature"] != "9" || resp["query"] != "debug=1" {
+		t.Fatalf("expected signature:9,query:debug=1,got:%v", resp)
+	}
+	resp = map[string]string{}
+	if err := base.WithResp(&resp).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if resp["signature"] != "9" || resp["query"] != "" {
+		t.Fatalf("expected editors not shared between clones,got:%v", resp)
+	}
+
+	var called bool
+	err := base.
+		WithRequestEditor(func(*http.Request) error { return errors.New("sign failed") }).
+		WithRequestEditor(func(*http.Request) error { called = true; return nil }).
+		Do(context.Background())
+	if err == nil || err.Error() != "sign failed" || called {
+		t.Fatalf("expected first error to abort,got:%v,called:%t", err, called)
+	}
+}
+
+func TestWithTransportWrapper(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(r.Header.Values("X-Wrapper"))
+	}))
+	var order []string
+	var errs []error
+	wrapper := func(name string) TransportWrapper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				httpReq.Header.Add("X-Wrapper", name)
+				httpResp, err := next.RoundTrip(httpReq)
+				errs = append(errs, err)
+				return httpResp, err
+			})
+		}
+	}
+	b := Get(server.URL).WithTransportWrapper(wrapper("inner")).WithTransportWrapper(wrapper("outer"))
+	var resp []string
+	if err := b.WithResp(&resp).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "outer,inner" || strings.Join(resp, ",") != "outer,inner" {
+		t.Fatalf("expected outer,inner,got order:%v,resp:%v", order, resp)
+	}
+	logs.AssertField(t, "send http req", "http_url", server.URL)
+
+	// 自定义wrapper位于状态码检查之外
+	errs = nil
+	err := b.Get(server.URL + "/fail").Do(context.Background())
+	statusErr := &ErrUnexpectedStatusCode{}
+	if !errors.As(err, &statusErr) || len(errs) != 2 || !errors.As(errs[0], &statusErr) {
+		t.Fatalf("expected wrappers to see status code error,got:%v,%v", err, errs)
+	}
+	order = nil
+	if err := Get(server.URL).Do(context.Background()); err != nil || len(order) != 0 {
+		t.Fatalf("expected wrappers not to leak into new builders,got:%v,%v", err, order)
+	}
+}
+
+func TestWithCookies(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cookies []string
+		for _, cookie := range r.Cookies() {
+			cookies = append(cookies, cookie.Name+"="+cookie.Value)
+		}
+		json.NewEncoder(w).Encode(cookies)
+	}))
+	session := &http.Cookie{Name: "session", Value: "a"}
+	b := WithCookie(session).WithCookies(&http.Cookie{Name: "lang", Value: "zh"}, &http.Cookie{Name: "session", Value: "b"})
+	session.Value = "changed"
+	shared := b.Get(server.URL)
+	var got []string
+	if err := shared.WithCookie(&http.Cookie{Name: "extra", Value: "1"}).WithResp(&got).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "session=a,lang=zh,session=b,extra=1"; strings.Join(got, ",") != expected {
+		t.Fatalf("expected cookies:%s,got:%v", expected, got)
+	}
+	got = nil
+	if err := shared.WithResp(&got).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "session=a,lang=zh,session=b"; strings.Join(got, ",") != expected {
+		t.Fatalf("expected clone to keep cookies unchanged:%s,got:%v", expected, got)
+	}
+}
+
+func TestWithCookieNil(t *testing.T) {
+	for _, b := range []Builder{WithCookie(nil), WithCookies(&http.Cookie{Name: "a", Value: "1"}, nil)} {
+		err := b.Get("http://127.0.0.1").Do(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "is nil") {
+			t.Fatalf("expected nil cookie error,got:%v", err)
+		}
+	}
+}
+
+func TestWithCookieJar(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		case "/me":
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "s1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		w.Write([]byte(`{}`))
+	}))
+	jar := NewCookieJar()
+	if err := Post(server.URL + "/login").WithCookieJar(jar).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := Get(server.URL + "/me").WithCookieJar(jar).Do(context.Background()); err != nil {
+		t.Fatalf("expected session cookie from jar,got:%v", err)
+	}
+	if err := Get(server.URL + "/me").Do(context.Background()); err == nil {
+		t.Fatal("expected unauthorized without jar")
+	}
+}
+
+func TestRedirectWithBody(t *testing.T) {
+	server := testkit.NewServer(t, testkit.RedirectChain(2, http.StatusTemporaryRedirect, testkit.Echo()))
+
+	gotResp := map[string]string{}
+	if err := Post(server.URL+"/echo").
+		ExpectedStatusCodes(http.StatusOK, http.StatusTemporaryRedirect).
+		WithReq(map[string]string{"data": "hello"}).
+		WithResp(&gotResp).
+		Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if gotResp["data"] != "hello" {
+		t.Fatalf("expected data:hello,got:%s", gotResp["data"])
+	}
+}
+
+func TestRawBody(t *testing.T) {
+	server := testkit.NewServer(t, testkit.RedirectChain(1, http.StatusTemporaryRedirect, testkit.Echo()))
+
+	b := Post(server.URL+"/echo").ContentType("application/octet-stream").ExpectedStatusCodes(http.StatusOK, http.StatusTemporaryRedirect)
+	var got map[string]string
+	if err := b.WithBodyBytes([]byte(`{"data":"bytes"}`)).WithResp(&got).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got["data"] != "bytes" {
+		t.Fatalf("expected data:bytes,got:%s", got["data"])
+	}
+
+	reader := io.MultiReader(strings.NewReader(`{"data":`), strings.NewReader(`"reader"}`))
+	if err := Post(server.URL + "/echo?hop=1").WithBodyReader(reader).WithResp(&got).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got["data"] != "reader" {
+		t.Fatalf("expected data:reader,got:%s", got["data"])
+	}
+
+	_, err := b.WithReq(map[string]string{}).WithBodyBytes([]byte("x")).BuildHTTPReq(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "WithReq,WithBodyBytes") {
+		t.Fatalf("expected mutually exclusive error,got:%v", err)
+	}
+}
 
-	t.Logf("builder: %#v", b)
+func TestBufferedBodyReplay(t *testing.T) {
+	server := testkit.NewServer(t, testkit.RedirectChain(1, http.StatusTemporaryRedirect, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength <= 0 {
+			w.WriteHeader(http.StatusLengthRequired)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(map[string]interface{}{"contentLength": r.ContentLength, "body": string(body)})
+	})))
+	tests := []struct {
+		name     string
+		builder  Builder
+		expected string
+	}{
+		{name: "req", builder: Post(server.URL).WithReq(map[string]string{"data": "req"}), expected: `{"data":"req"}`},
+		{name: "form", builder: Post(server.URL).WithFormReq(url.Values{"data": {"form"}}), expected: "data=form"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpReq, err := tt.builder.BuildHTTPReq(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if httpReq.ContentLength != int64(len(tt.expected)) || httpReq.GetBody == nil {
+				t.Fatalf("expected content length:%d and GetBody,got:%d", len(tt.expected), httpReq.ContentLength)
+			}
+			replayed, _ := httpReq.GetBody()
+			if data, _ := io.ReadAll(replayed); string(data) != tt.expected {
+				t.Fatalf("expected replayed body:%s,got:%s", tt.expected, data)
+			}
+
+			// 307重定向后body保持完整
+			resp := map[string]interface{}{}
+			if err := tt.builder.ExpectedStatusCodes(http.StatusOK, http.StatusTemporaryRedirect).WithResp(&resp).Do(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if resp["body"] != tt.expected || resp["contentLength"] != float64(len(tt.expected)) {
+				t.Fatalf("expected body:%s on the wire,got:%v", tt.expected, resp)
+			}
+		})
+	}
+}
+
+func TestExpectContentType(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ContentTypeKey, r.URL.Query().Get("type"))
+		w.Write([]byte(`{"accept":"` + r.Header.Get(AcceptKey) + `"}`))
+	}))
+	b := Get(server.URL).WithAccept(ContentTypeJson).ExpectContentType(ContentTypeJson)
+	got := map[string]string{}
+	if err := b.WithQueryString("type", "application/json; charset=utf-8").WithResp(&got).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got["accept"] != ContentTypeJson {
+		t.Fatalf("expected accept:%s,got:%s", ContentTypeJson, got["accept"])
+	}
+	err := b.WithQueryString("type", "text/html").WithResp(&got).Do(context.Background())
+	contentTypeErr := &ErrUnexpectedContentType{}
+	if !errors.As(err, &contentTypeErr) || contentTypeErr.Got != "text/html" || contentTypeErr.StatusCode != http.StatusOK {
+		t.Fatalf("expected content type error,got:%v", err)
+	}
+	if expected := "expected content type:application/json,got:text/html,statuscode:200"; err.Error() != expected {
+		t.Fatalf("expected:%s,got:%s", expected, err)
+	}
+	if OutcomeFromError(err) != OutcomeReceived {
+		t.Fatalf("expected outcome received,got:%s", OutcomeFromError(err))
+	}
+}
+
+func TestStatusSequence(t *testing.T) {
+	server := testkit.NewServer(t, testkit.StatusSequence(http.StatusInternalServerError, http.StatusOK))
+
+	if err := Get(server.URL).Do(context.Background()); err == nil {
+		t.Fatal("expected statuscode error")
+	}
+	if err := Get(server.URL).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBrokenBody(t *testing.T) {
+	server := testkit.NewServer(t, testkit.BrokenBody(`{"data":`))
+
+	gotResp := map[string]string{}
+	err := Get(server.URL).
+		Logging(false, false).
+		WithResp(&gotResp).
+		Do(context.Background())
+	if err == nil || err == io.EOF {
+		t.Fatalf("expected unexpected eof error,got:%v", err)
+	}
+}
+
+func TestDefaultDeadline(t *testing.T) {
+	prev := DefaultDeadline
+	DefaultDeadline = time.Millisecond * 50
+	t.Cleanup(func() {
+		DefaultDeadline = prev
+	})
+	server := testkit.NewServer(t, testkit.Delay(time.Millisecond*200, testkit.Echo()))
+
+	err := Get(server.URL).Do(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "httpx default deadline 50ms") {
+		t.Fatalf("expected default deadline error,got:%v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := Get(server.URL).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := Get(server.URL).NoDefaultDeadline().Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := Get(server.URL).DoWithDeadline(time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultDeadlineWithTransportTimeout(t *testing.T) {
+	// 与默认值的关系相同,TimeoutTransport(0)的超时短于DefaultDeadline
+	prevTimeout, prevDeadline := defaultTransprtTimeout, DefaultDeadline
+	defaultTransprtTimeout, DefaultDeadline = time.Millisecond*100, time.Millisecond*300
+	t.Cleanup(func() {
+		defaultTransprtTimeout, DefaultDeadline = prevTimeout, prevDeadline
+	})
+	server := testkit.NewServer(t, testkit.Delay(time.Millisecond*200, testkit.Echo()))
+
+	// 200ms的响应在DefaultDeadline之内,不被默认的TimeoutTransport打断
+	if err := Get(server.URL).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	slow := testkit.NewServer(t, testkit.Delay(time.Second, testkit.Echo()))
+	err := Get(slow.URL).Do(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "httpx default deadline 300ms") {
+		t.Fatalf("expected default deadline error,got:%v", err)
+	}
+	// 没有DefaultDeadline时仍然使用默认超时
+	if err := Get(server.URL).NoDefaultDeadline().Do(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected transport timeout,got:%v", err)
+	}
+}
+
+func TestDuplicateQueryPolicy(t *testing.T) {
+	type obj struct {
+		Limit  int    `url:"limit"`
+		Offset int    `url:"offset"`
+		Sort   string `url:"sort,omitempty"`
+	}
+	build := func(policy DuplicatePolicy) Builder {
+		return Get("https://www.abcd123.top/api?limit=10&sort=name&q=x").
+			DuplicateQueryPolicy(policy).
+			WithQueryString("limit", "1").
+			WithQueryStringObj(obj{Limit: 100, Offset: 5, Sort: "id"})
+	}
+	tests := []struct {
+		name     string
+		policy   DuplicatePolicy
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "accumulate",
+			policy:   DuplicateAccumulate,
+			expected: "limit=1&limit=100&limit=10&offset=5&q=x&sort=id&sort=name",
+		},
+		{
+			name:     "first wins",
+			policy:   DuplicateFirstWins,
+			expected: "limit=1&offset=5&q=x&sort=id",
+		},
+		{
+			name:     "last wins",
+			policy:   DuplicateLastWins,
+			expected: "limit=10&offset=5&q=x&sort=name",
+		},
+		{
+			name:    "error",
+			policy:  DuplicateError,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpReq, err := build(tt.policy).BuildHTTPReq(context.Background())
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "duplicate query key:limit,from:explicit,object,inline") {
+					t.Fatalf("expected duplicate error,got:%v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := httpReq.URL.RawQuery; got != tt.expected {
+				t.Fatalf("expected query:%s,got:%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestReqAsQuery(t *testing.T) {
+	type search struct {
+		Query string   `url:"q"`
+		Tags  []string `url:"tag"`
+		Page  int      `url:"page,omitempty"`
+	}
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"query":         r.URL.RawQuery,
+			"body":          string(body),
+			"contentLength": r.ContentLength,
+		})
+	}))
+	resp := map[string]interface{}{}
+	err := Get(server.URL+"?page=3").
+		WithQueryString("q", "explicit").
+		WithReq(search{Query: "go", Tags: []string{"a", "b"}}).
+		ReqAsQuery(true).
+		WithResp(&resp).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "page=3&q=explicit&q=go&tag=a&tag=b"; resp["query"] != expected {
+		t.Fatalf("expected query:%s,got:%v", expected, resp["query"])
+	}
+	if resp["body"] != "" || resp["contentLength"] != float64(0) {
+		t.Fatalf("expected no body on the wire,got:%v", resp)
+	}
+
+	// ReqAsQuery与其他请求体option不冲突
+	httpReq, err := Delete(server.URL).WithReq(search{Query: "go"}).ReqAsQuery(true).WithBodyBytes([]byte("raw")).BuildHTTPReq(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if httpReq.URL.RawQuery != "q=go" || httpReq.ContentLength != 3 {
+		t.Fatalf("unexpected req:%s,%d", httpReq.URL.RawQuery, httpReq.ContentLength)
+	}
+	if _, err := Get(server.URL).WithReq(map[string]string{"q": "go"}).ReqAsQuery(true).BuildHTTPReq(context.Background()); err == nil {
+		t.Fatal("expected error for non struct req")
+	}
+}
+
+func TestSharedRespTarget(t *testing.T) {
+	server := testkit.NewServer(t, testkit.Delay(time.Millisecond*50, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":"` + r.URL.Query().Get("data") + `"}`))
+	})))
+
+	shared := map[string]string{}
+	unsafe := Get(server.URL).WithResp(&shared)
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- unsafe.Do(context.Background())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var shareErrs int
+	for err := range errs {
+		if errors.Is(err, ErrSharedRespTarget) {
+			shareErrs++
+		}
+	}
+	if shareErrs == 0 {
+		t.Fatal("expected ErrSharedRespTarget for concurrent shared target")
+	}
+
+	safe := Get(server.URL)
+	results := make([]map[string]string, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = map[string]string{}
+			if err := safe.WithQueryString("data", strconv.Itoa(i)).DoInto(context.Background(), &results[i]); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, result := range results {
+		if result["data"] != strconv.Itoa(i) {
+			t.Fatalf("expected data:%d,got:%s", i, result["data"])
+		}
+	}
+}
+
+func TestInsecureForHosts(t *testing.T) {
+	allowed := testkit.NewTLSServer(t, testkit.Echo())
+	denied := testkit.NewTLSServer(t, testkit.Echo())
+	allowedAddr := strings.TrimPrefix(allowed.URL, "https://")
+
+	if err := Post(allowed.URL).
+		InsecureForHosts(allowedAddr).
+		WithReq(map[string]string{"data": "hello"}).
+		Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	err := Post(denied.URL).
+		InsecureForHosts(allowedAddr).
+		WithReq(map[string]string{"data": "hello"}).
+		Do(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("expected certificate error,got:%v", err)
+	}
+}
+
+func Test_insecureHostMatcher(t *testing.T) {
+	matcher := insecureHostMatcher{"example.com", "127.0.0.1:8443"}
+	for addr, expected := range map[string]bool{
+		"example.com:443":     true,
+		"api.example.com:443": true,
+		"badexample.com:443":  false,
+		"127.0.0.1:8443":      true,
+		"127.0.0.1:9443":      false,
+	} {
+		if got := matcher.match(addr); got != expected {
+			t.Fatalf("expected match %s:%t,got:%t", addr, expected, got)
+		}
+	}
+}
+
+func TestWithRespHeaders(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("X-Next-Page", "2")
+		w.Header().Set("Link", `</items?page=2>; rel="next"`)
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"data":"a"}`))
+		w.Header().Set("X-Checksum", "abc")
+	}))
+	var header http.Header
+	resp := map[string]string{}
+	if err := Get(server.URL).WithRespHeaders(&header).WithResp(&resp).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("X-Next-Page") != "2" || header.Get("Link") != `</items?page=2>; rel="next"` || header.Get("X-Checksum") != "abc" {
+		t.Fatalf("unexpected headers:%v", header)
+	}
+
+	// 不解码时也会读完body拿到trailer
+	header = nil
+	if err := Get(server.URL).WithRespHeaders(&header).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("X-Checksum") != "abc" {
+		t.Fatalf("expected trailer X-Checksum:abc,got:%v", header)
+	}
+
+	header = nil
+	if err := Get(server.URL).WithQueryString("fail", "1").WithRespHeaders(&header).Do(context.Background()); err == nil {
+		t.Fatal("expected status error")
+	}
+	if header.Get("X-Next-Page") != "2" {
+		t.Fatalf("expected headers on status error,got:%v", header)
+	}
+}
+
+func TestWithRespStatusCode(t *testing.T) {
+	server := testkit.NewServer(t, testkit.StatusSequence(http.StatusAccepted, http.StatusOK, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusBadRequest))
+	var code int
+	b := Get(server.URL).ExpectedStatusCodes(http.StatusOK, http.StatusAccepted).WithRespStatusCode(&code)
+	for _, expected := range []int{http.StatusAccepted, http.StatusOK} {
+		if err := b.Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if code != expected {
+			t.Fatalf("expected statuscode:%d,got:%d", expected, code)
+		}
+	}
+
+	// 重试时为最后一次尝试的状态码
+	policy := ResiliencePolicy{Retries: 2, RetryBackoff: PolicyDuration(time.Millisecond)}
+	err := b.ResiliencePolicy(policy).Do(context.Background())
+	statusErr := &ErrUnexpectedStatusCode{}
+	if !errors.As(err, &statusErr) || statusErr.Got != http.StatusBadRequest {
+		t.Fatalf("expected statuscode error,got:%v", err)
+	}
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected statuscode:%d,got:%d", http.StatusBadRequest, code)
+	}
+}
+
+func TestExpectedStatusRange(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(r.URL.Query().Get("code"))
+		w.WriteHeader(code)
+	}))
+	tests := []struct {
+		name    string
+		builder Builder
+		code    int
+		ok      bool
+	}{
+		{name: "2xx", builder: Expect2xx(), code: http.StatusNoContent, ok: true},
+		{name: "2xx rejects 3xx", builder: Expect2xx(), code: http.StatusNotModified},
+		{name: "range without default 200", builder: ExpectedStatusRange(300, 399), code: http.StatusOK},
+		{name: "union code", builder: ExpectedStatusCodes(http.StatusNotFound).Expect2xx(), code: http.StatusNotFound, ok: true},
+		{name: "union range", builder: ExpectedStatusCodes(http.StatusNotFound).Expect2xx(), code: http.StatusCreated, ok: true},
+		{name: "any", builder: ExpectAnyStatus(), code: http.StatusInternalServerError, ok: true},
+		{name: "codes after any", builder: ExpectAnyStatus().ExpectedStatusCodes(http.StatusOK), code: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var code int
+			err := tt.builder.Get(server.URL).WithQueryString("code", strconv.Itoa(tt.code)).WithRespStatusCode(&code).Do(context.Background())
+			if tt.ok != (err == nil) || code != tt.code {
+				t.Fatalf("expected ok:%t,statuscode:%d,got:%v,%d", tt.ok, tt.code, err, code)
+			}
+		})
+	}
+
+	err := ExpectedStatusCodes(http.StatusNotFound).Expect2xx().Get(server.URL).WithQueryString("code", "500").Do(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "expected statuscodes:[404 200-299],got:500") {
+		t.Fatalf("expected ranges in error,got:%v", err)
+	}
+	if err := ExpectedStatusRange(300, 200).Get(server.URL).Do(context.Background()); err == nil {
+		t.Fatal("expected invalid range error")
+	}
+}
+
+func TestDoRaw(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("X-Event", "push")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "chunk%d\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(time.Millisecond * 10)
+		}
+	}))
+
+	// 响应日志读入内存后body仍然完整交给调用方
+	httpResp, err := Get(server.URL).Logging(true, true).DoRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	if err != nil || string(data) != "chunk0\nchunk1\nchunk2\n" || httpResp.Header.Get("X-Event") != "push" {
+		t.Fatalf("unexpected raw resp:%q,%v", data, err)
+	}
+	logs.AssertField(t, "got http resp", FieldRespData, "chunk0\nchunk1\nchunk2\n")
+
+	// 不记录响应时body在返回之后才开始读取
+	httpResp, err = Get(server.URL).Logging(false, false).DoRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(httpResp.Body)
+	for i := 0; i < 3; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil || line != fmt.Sprintf("chunk%d\n", i) {
+			t.Fatalf("unexpected line %d:%q,%v", i, line, err)
+		}
+	}
+	httpResp.Body.Close()
+
+	_, err = Get(server.URL).WithQueryString("fail", "1").DoRaw(context.Background())
+	statusErr := &ErrUnexpectedStatusCode{}
+	if !errors.As(err, &statusErr) || statusErr.Got != http.StatusBadGateway {
+		t.Fatalf("expected statuscode error,got:%v", err)
+	}
+	httpResp, err = Get(server.URL).WithQueryString("fail", "1").ExpectedStatusCodes(http.StatusBadGateway).DoRaw(context.Background())
+	if err != nil || httpResp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected raw 502,got:%v", err)
+	}
+	httpResp.Body.Close()
+}
+
+func TestWithRespWriter(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	const size = 4 << 20
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		chunk := bytes.Repeat([]byte("a"), 1<<16)
+		for written := 0; written < size; written += len(chunk) {
+			w.Write(chunk)
+		}
+	}))
+	hash := sha256.New()
+	var written int64
+	if err := Get(server.URL).Logging(true, true).WithRespWriter(hash).WithRespWritten(&written).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expected := sha256.Sum256(bytes.Repeat([]byte("a"), size))
+	if written != size || !bytes.Equal(hash.Sum(nil), expected[:]) {
+		t.Fatalf("expected %d bytes written,got:%d", size, written)
+	}
+	for _, record := range logs.Find("got http resp") {
+		if _, exist := record.Attrs[FieldRespData]; exist {
+			t.Fatal("expected response body not to be logged")
+		}
+	}
+
+	err := Get(server.URL).StrictOptions(true).WithRespWriter(io.Discard).WithResp(&map[string]string{}).Do(context.Background())
+	conflict := &ErrOptionConflict{}
+	if !errors.As(err, &conflict) || conflict.Option != "WithRespWriter" {
+		t.Fatalf("expected option conflict,got:%v", err)
+	}
+}
+
+func TestOneShotBodyNoRetry(t *testing.T) {
+	var attempts int32
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	policy := ResiliencePolicy{Retries: 2, RetryBackoff: PolicyDuration(time.Millisecond)}
+	tests := []struct {
+		name    string
+		builder Builder
+	}{
+		{name: "multipart", builder: Put(server.URL).WithMultipartFile("file", "a.txt", strings.NewReader("abc"))},
+		{name: "body reader", builder: Put(server.URL).WithBodyReader(strings.NewReader("abc"))},
+		{name: "ndjson stream", builder: Put(server.URL).WithReqStream(func() (interface{}, bool) { return nil, false })},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&attempts, 0)
+		err := tt.builder.ResiliencePolicy(policy).Do(context.Background())
+		if err == nil || atomic.LoadInt32(&attempts) != 1 {
+			t.Fatalf("%s:expected 1 attempt,got:%d,%v", tt.name, atomic.LoadInt32(&attempts), err)
+		}
+	}
+
+	// 没有发送的请求不读取文件,也不启动编码的goroutine
+	reader := &countingReader{Reader: strings.NewReader("abc")}
+	httpReq, err := Post(server.URL).WithMultipartFile("file", "a.txt", reader).BuildHTTPReq(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpReq.Body.Close()
+	if reader.read != 0 {
+		t.Fatalf("expected file not to be read,got:%d bytes", reader.read)
+	}
 }
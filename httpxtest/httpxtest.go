@@ -0,0 +1,232 @@
+// Package httpxtest 提供测试http.Handler以及基于Builder的client的辅助工具
+package httpxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+// TB testing.TB中Call用到的部分,便于测试失败信息
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+}
+
+// File multipart上传的文件
+type File struct {
+	Field   string
+	Name    string
+	Content []byte
+}
+
+// Req 描述一次handler调用
+type Req struct {
+	Method  string
+	Path    string
+	Headers http.Header
+	// JSON 以json编码作为请求体
+	JSON interface{}
+	// Form 以application/x-www-form-urlencoded编码作为请求体
+	Form url.Values
+	// Fields与Files 以multipart/form-data编码作为请求体
+	Fields map[string]string
+	Files  []File
+	// Status 期望的状态码,默认200
+	Status int
+	// Expect 不为nil时与解码后的响应按json比较
+	Expect interface{}
+	// Recorder 不为nil时使用该recorder,便于调用方检查响应头
+	Recorder *httptest.ResponseRecorder
+}
+
+// Call 调用handler,校验状态码并将响应体解码为Resp
+func Call[Resp any](t TB, handler http.Handler, req Req) Resp {
+	t.Helper()
+	var resp Resp
+	httpReq, err := req.build()
+	if err != nil {
+		t.Fatalf("build request:%s", err)
+		return resp
+	}
+	recorder := req.Recorder
+	if recorder == nil {
+		recorder = httptest.NewRecorder()
+	}
+	handler.ServeHTTP(recorder, httpReq)
+
+	expectedStatus := req.Status
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+	if recorder.Code != expectedStatus {
+		t.Fatalf("%s %s: expected statuscode:%d,got:%d,body:%s", httpReq.Method, httpReq.URL.Path, expectedStatus, recorder.Code, recorder.Body.String())
+		return resp
+	}
+	if recorder.Body.Len() == 0 {
+		return resp
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: decode resp:%s,body:%s", httpReq.Method, httpReq.URL.Path, err, recorder.Body.String())
+		return resp
+	}
+	if req.Expect != nil {
+		if diff := jsonDiff(req.Expect, resp); diff != "" {
+			t.Fatalf("%s %s: resp mismatch (-expected +got):\n%s", httpReq.Method, httpReq.URL.Path, diff)
+		}
+	}
+	return resp
+}
+
+func (r Req) build() (*http.Request, error) {
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := r.Path
+	if path == "" {
+		path = "/"
+	}
+	var body io.Reader
+	var contentType string
+	switch {
+	case r.JSON != nil:
+		data, err := json.Marshal(r.JSON)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	case r.Form != nil:
+		body = strings.NewReader(r.Form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	case r.Fields != nil || r.Files != nil:
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		for key, value := range r.Fields {
+			if err := writer.WriteField(key, value); err != nil {
+				return nil, err
+			}
+		}
+		for _, file := range r.Files {
+			part, err := writer.CreateFormFile(file.Field, file.Name)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := part.Write(file.Content); err != nil {
+				return nil, err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		body = &buf
+		contentType = writer.FormDataContentType()
+	}
+	httpReq := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	for key, values := range r.Headers {
+		httpReq.Header.Del(key)
+		for _, value := range values {
+			httpReq.Header.Add(key, value)
+		}
+	}
+	return httpReq, nil
+}
+
+// jsonDiff 按行比较两个值的json表示,相同时返回空字符串
+func jsonDiff(expected, got interface{}) string {
+	expectedData, err := json.MarshalIndent(expected, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("marshal expected:%s", err)
+	}
+	gotData, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("marshal got:%s", err)
+	}
+	if bytes.Equal(expectedData, gotData) {
+		return ""
+	}
+	expectedLines := strings.Split(string(expectedData), "\n")
+	gotLines := strings.Split(string(gotData), "\n")
+	var sb strings.Builder
+	for _, line := range diffLines(expectedLines, gotLines) {
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// diffLines 基于最长公共子序列的行级diff
+func diffLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+ "+b[j])
+	}
+	return lines
+}
+
+// namedTransport httpx中带名称的wrapper
+type namedTransport interface {
+	WrapperName() string
+	Unwrap() http.RoundTripper
+}
+
+// AssertChain 断言rt从外到内的wrapper名称依次为names,不一致时输出diff
+func AssertChain(t TB, rt http.RoundTripper, names ...string) {
+	t.Helper()
+	var got []string
+	for {
+		named, ok := rt.(namedTransport)
+		if !ok {
+			break
+		}
+		got = append(got, named.WrapperName())
+		rt = named.Unwrap()
+	}
+	if strings.Join(got, "\n") == strings.Join(names, "\n") {
+		return
+	}
+	t.Fatalf("transport chain mismatch (-expected +got):\n%s", strings.Join(diffLines(names, got), "\n"))
+}
@@ -0,0 +1,135 @@
+package httpxtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type fakeTB struct {
+	msg string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	if f.msg == "" {
+		f.msg = fmt.Sprintf(format, args...)
+	}
+}
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+var echoUser = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	u := &user{}
+	if err := json.NewDecoder(r.Body).Decode(u); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(u)
+})
+
+func TestCall(t *testing.T) {
+	got := Call[user](t, echoUser, Req{
+		Method: http.MethodPost,
+		Path:   "/users",
+		JSON:   &user{ID: 1, Name: "a"},
+		Expect: user{ID: 1, Name: "a"},
+	})
+	if got.Name != "a" {
+		t.Fatalf("expected name:a,got:%s", got.Name)
+	}
+}
+
+func TestCallForm(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("avatar")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		file.Close()
+		json.NewEncoder(w).Encode(map[string]string{"name": r.FormValue("name"), "file": header.Filename})
+	})
+	Call[map[string]string](t, handler, Req{
+		Method: http.MethodPost,
+		Fields: map[string]string{"name": "a"},
+		Files:  []File{{Field: "avatar", Name: "a.png", Content: []byte("png")}},
+		Expect: map[string]string{"name": "a", "file": "a.png"},
+	})
+}
+
+func TestCallFailureMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      Req
+		expected string
+	}{
+		{
+			name:     "status",
+			req:      Req{Method: http.MethodPost, Path: "/users"},
+			expected: "POST /users: expected statuscode:200,got:400,body:",
+		},
+		{
+			name: "mismatch",
+			req: Req{
+				Method: http.MethodPost,
+				Path:   "/users",
+				JSON:   &user{ID: 1, Name: "a"},
+				Expect: user{ID: 1, Name: "b"},
+			},
+			expected: "POST /users: resp mismatch (-expected +got):\n" +
+				"  {\n" +
+				"    \"id\": 1,\n" +
+				"-   \"name\": \"b\"\n" +
+				"+   \"name\": \"a\"\n" +
+				"  }\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := &fakeTB{}
+			Call[user](tb, echoUser, tt.req)
+			if tb.msg != tt.expected {
+				t.Fatalf("expected message:\n%s\ngot:\n%s", tt.expected, tb.msg)
+			}
+		})
+	}
+}
+
+type chainLink struct {
+	name string
+	next http.RoundTripper
+}
+
+func (l *chainLink) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func (l *chainLink) WrapperName() string {
+	return l.name
+}
+
+func (l *chainLink) Unwrap() http.RoundTripper {
+	return l.next
+}
+
+func TestAssertChain(t *testing.T) {
+	rt := &chainLink{name: "timeout", next: &chainLink{name: "logging", next: &chainLink{name: "status", next: http.DefaultTransport}}}
+	AssertChain(t, rt, "timeout", "logging", "status")
+
+	tb := &fakeTB{}
+	AssertChain(tb, rt, "logging", "timeout", "status")
+	expected := "transport chain mismatch (-expected +got):\n- logging\n  timeout\n+ logging\n  status"
+	if tb.msg != expected {
+		t.Fatalf("expected message:\n%s\ngot:\n%s", expected, tb.msg)
+	}
+}
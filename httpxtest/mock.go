@@ -0,0 +1,343 @@
+package httpxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MockTransport 按stub返回响应的http.RoundTripper,可以直接用于Builder.WithTransport与DoWithTransport,
+// 不需要启动httptest server;没有匹配stub的请求会使测试失败并输出完整的请求
+type MockTransport struct {
+	t       TB
+	mu      sync.Mutex
+	stubs   []*Stub
+	ordered bool
+	calls   []RecordedCall
+}
+
+// RecordedCall 一次被MockTransport收到的请求,Body为请求体的副本
+type RecordedCall struct {
+	Method string
+	Path   string
+	Header http.Header
+	Query  map[string][]string
+	Body   []byte
+}
+
+// Stub 一个请求匹配条件与对应的响应
+type Stub struct {
+	method    string
+	path      string
+	query     map[string]string
+	header    map[string]string
+	bodyMatch []func(body []byte) bool
+
+	status     int
+	respHeader http.Header
+	respBody   []byte
+	err        error
+
+	// times 最多匹配的次数,0表示不限制;calls为已匹配的次数
+	times int
+	calls int
+}
+
+// NewMockTransport 失败信息通过t报告
+func NewMockTransport(t TB) *MockTransport {
+	return &MockTransport{t: t}
+}
+
+// InOrder 之后的请求必须按stub注册的顺序到达,每个stub默认匹配一次
+func (m *MockTransport) InOrder() *MockTransport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ordered = true
+	return m
+}
+
+// On 注册method与path(不含query)的stub,默认返回200与空的body
+func (m *MockTransport) On(method, path string) *Stub {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stub := &Stub{method: method, path: path, status: http.StatusOK, respHeader: make(http.Header)}
+	m.stubs = append(m.stubs, stub)
+	return stub
+}
+
+// WithQuery 要求query参数key等于value
+func (s *Stub) WithQuery(key, value string) *Stub {
+	if s.query == nil {
+		s.query = make(map[string]string)
+	}
+	s.query[key] = value
+	return s
+}
+
+// WithHeader 要求header key等于value
+func (s *Stub) WithHeader(key, value string) *Stub {
+	if s.header == nil {
+		s.header = make(map[string]string)
+	}
+	s.header[key] = value
+	return s
+}
+
+// WithBody 要求请求体满足match
+func (s *Stub) WithBody(match func(body []byte) bool) *Stub {
+	s.bodyMatch = append(s.bodyMatch, match)
+	return s
+}
+
+// Times 最多匹配n次,超过后请求交给后面的stub
+func (s *Stub) Times(n int) *Stub {
+	s.times = n
+	return s
+}
+
+// Return 返回status与body
+func (s *Stub) Return(status int, body string) *Stub {
+	s.status, s.respBody = status, []byte(body)
+	return s
+}
+
+// ReturnJSON 返回status与json编码的body,编码失败时panic
+func (s *Stub) ReturnJSON(status int, body interface{}) *Stub {
+	data, err := json.Marshal(body)
+	if err != nil {
+		panic(fmt.Sprintf("httpxtest: marshal stub body:%s", err))
+	}
+	s.status, s.respBody = status, data
+	s.respHeader.Set("Content-Type", "application/json")
+	return s
+}
+
+// ReturnHeader 在响应中添加header
+func (s *Stub) ReturnHeader(key, value string) *Stub {
+	s.respHeader.Add(key, value)
+	return s
+}
+
+// ReturnError RoundTrip返回err,用于模拟网络错误
+func (s *Stub) ReturnError(err error) *Stub {
+	s.err = err
+	return s
+}
+
+func (s *Stub) String() string {
+	var conds []string
+	for key, value := range s.query {
+		conds = append(conds, "query "+key+"="+value)
+	}
+	for key, value := range s.header {
+		conds = append(conds, "header "+key+"="+value)
+	}
+	sort.Strings(conds)
+	if len(s.bodyMatch) != 0 {
+		conds = append(conds, "body predicate")
+	}
+	desc := s.method + " " + s.path
+	if len(conds) != 0 {
+		desc += " (" + strings.Join(conds, ",") + ")"
+	}
+	return desc
+}
+
+func (s *Stub) match(httpReq *http.Request, body []byte) bool {
+	if s.method != httpReq.Method || s.path != httpReq.URL.Path {
+		return false
+	}
+	if s.times > 0 && s.calls >= s.times {
+		return false
+	}
+	query := httpReq.URL.Query()
+	for key, value := range s.query {
+		if query.Get(key) != value {
+			return false
+		}
+	}
+	for key, value := range s.header {
+		if httpReq.Header.Get(key) != value {
+			return false
+		}
+	}
+	for _, match := range s.bodyMatch {
+		if !match(body) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Stub) response(httpReq *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(s.status) + " " + http.StatusText(s.status),
+		StatusCode:    s.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        s.respHeader.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(s.respBody)),
+		ContentLength: int64(len(s.respBody)),
+		Request:       httpReq,
+	}
+}
+
+func (m *MockTransport) RoundTrip(httpReq *http.Request) (*http.Response, error) {
+	var body []byte
+	if httpReq.Body != nil {
+		var err error
+		body, err = io.ReadAll(httpReq.Body)
+		httpReq.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.mu.Lock()
+	m.calls = append(m.calls, RecordedCall{
+		Method: httpReq.Method,
+		Path:   httpReq.URL.Path,
+		Header: httpReq.Header.Clone(),
+		Query:  httpReq.URL.Query(),
+		Body:   body,
+	})
+	stub, expected := m.find(httpReq, body)
+	if stub != nil {
+		stub.calls++
+	}
+	m.mu.Unlock()
+	if stub == nil {
+		m.t.Helper()
+		dump := dumpRequest(httpReq, body)
+		m.t.Fatalf("unexpected request, %s\n%s", expected, dump)
+		return nil, fmt.Errorf("httpxtest: unexpected request %s %s", httpReq.Method, httpReq.URL)
+	}
+	if stub.err != nil {
+		return nil, stub.err
+	}
+	return stub.response(httpReq), nil
+}
+
+// find 返回匹配的stub,没有时返回期望的描述;InOrder时只看第一个还未用完的stub
+func (m *MockTransport) find(httpReq *http.Request, body []byte) (*Stub, string) {
+	if m.ordered {
+		for _, stub := range m.stubs {
+			if stub.calls >= stub.orderedTimes() {
+				continue
+			}
+			if stub.match(httpReq, body) {
+				return stub, ""
+			}
+			return nil, "expected next:" + stub.String()
+		}
+		return nil, "all expectations were already met"
+	}
+	for _, stub := range m.stubs {
+		if stub.match(httpReq, body) {
+			return stub, ""
+		}
+	}
+	stubs := make([]string, 0, len(m.stubs))
+	for _, stub := range m.stubs {
+		stubs = append(stubs, stub.String())
+	}
+	return nil, "registered stubs:[" + strings.Join(stubs, "; ") + "]"
+}
+
+func (s *Stub) orderedTimes() int {
+	if s.times > 0 {
+		return s.times
+	}
+	return 1
+}
+
+func dumpRequest(httpReq *http.Request, body []byte) string {
+	dumpReq := httpReq.Clone(httpReq.Context())
+	dumpReq.Body = io.NopCloser(bytes.NewReader(body))
+	dump, err := httputil.DumpRequest(dumpReq, true)
+	if err != nil {
+		return fmt.Sprintf("%s %s (dump failed:%s)", httpReq.Method, httpReq.URL, err)
+	}
+	return string(dump)
+}
+
+// Calls 返回收到的全部请求
+func (m *MockTransport) Calls() []RecordedCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]RecordedCall(nil), m.calls...)
+}
+
+// CallCount 返回method与path的请求次数
+func (m *MockTransport) CallCount(method, path string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, call := range m.calls {
+		if call.Method == method && call.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+// AssertCalled 断言method与path至少被请求过一次
+func (m *MockTransport) AssertCalled(t TB, method, path string) {
+	t.Helper()
+	if m.CallCount(method, path) == 0 {
+		t.Fatalf("expected %s %s to be called,got calls:%s", method, path, m.describeCalls())
+	}
+}
+
+// AssertCalledTimes 断言method与path被请求了times次
+func (m *MockTransport) AssertCalledTimes(t TB, method, path string, times int) {
+	t.Helper()
+	if got := m.CallCount(method, path); got != times {
+		t.Fatalf("expected %s %s to be called %d times,got:%d", method, path, times, got)
+	}
+}
+
+// AssertNotCalled 断言method与path没有被请求
+func (m *MockTransport) AssertNotCalled(t TB, method, path string) {
+	t.Helper()
+	if got := m.CallCount(method, path); got != 0 {
+		t.Fatalf("expected %s %s not to be called,got:%d", method, path, got)
+	}
+}
+
+// AssertExpectations 断言设置了Times的stub都匹配了对应的次数,InOrder时所有stub都已按顺序匹配
+func (m *MockTransport) AssertExpectations(t TB) {
+	t.Helper()
+	m.mu.Lock()
+	var unmet []string
+	for _, stub := range m.stubs {
+		expected := stub.times
+		if m.ordered {
+			expected = stub.orderedTimes()
+		}
+		if expected > 0 && stub.calls != expected {
+			unmet = append(unmet, fmt.Sprintf("%s:expected %d calls,got:%d", stub, expected, stub.calls))
+		}
+	}
+	m.mu.Unlock()
+	if len(unmet) != 0 {
+		t.Fatalf("unmet expectations:\n%s", strings.Join(unmet, "\n"))
+	}
+}
+
+func (m *MockTransport) describeCalls() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := make([]string, 0, len(m.calls))
+	for _, call := range m.calls {
+		calls = append(calls, call.Method+" "+call.Path)
+	}
+	return "[" + strings.Join(calls, ", ") + "]"
+}
@@ -0,0 +1,117 @@
+package httpxtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx"
+	"github.com/wwq-2020/httpx/httpxtest"
+)
+
+type recordingTB struct {
+	msgs []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Fatalf(format string, args ...interface{}) {
+	r.msgs = append(r.msgs, fmt.Sprintf(format, args...))
+}
+
+func TestMockTransport(t *testing.T) {
+	mock := httpxtest.NewMockTransport(t)
+	mock.On(http.MethodPost, "/users").
+		WithHeader("X-Tenant", "t1").
+		WithBody(func(body []byte) bool { return strings.Contains(string(body), `"name":"a"`) }).
+		ReturnJSON(http.StatusCreated, map[string]int{"id": 1})
+	mock.On(http.MethodGet, "/users").WithQuery("id", "1").ReturnJSON(http.StatusOK, map[string]string{"name": "a"})
+	mock.On(http.MethodGet, "/down").ReturnError(errors.New("connection refused"))
+
+	resp := map[string]int{}
+	if err := httpx.Post("http://api.test/users").
+		WithTransport(mock).
+		WithHeader("X-Tenant", "t1").
+		WithReq(map[string]string{"name": "a"}).
+		ExpectedStatusCodes(http.StatusCreated).
+		WithResp(&resp).
+		Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if resp["id"] != 1 {
+		t.Fatalf("expected id:1,got:%v", resp)
+	}
+	user := map[string]string{}
+	if err := httpx.Get("http://api.test/users").WithQueryString("id", "1").WithResp(&user).DoWithTransport(context.Background(), mock); err != nil {
+		t.Fatal(err)
+	}
+	if user["name"] != "a" {
+		t.Fatalf("expected name:a,got:%v", user)
+	}
+	if err := httpx.Get("http://api.test/down").WithTransport(mock).Do(context.Background()); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected stub error,got:%v", err)
+	}
+	mock.AssertCalled(t, http.MethodPost, "/users")
+	mock.AssertCalledTimes(t, http.MethodGet, "/users", 1)
+	mock.AssertNotCalled(t, http.MethodDelete, "/users")
+	if calls := mock.Calls(); len(calls) != 3 || calls[0].Header.Get("X-Tenant") != "t1" {
+		t.Fatalf("expected 3 recorded calls,got:%+v", calls)
+	}
+}
+
+func TestMockTransportUnexpected(t *testing.T) {
+	tb := &recordingTB{}
+	mock := httpxtest.NewMockTransport(tb)
+	mock.On(http.MethodGet, "/users")
+	err := httpx.Post("http://api.test/orders").WithTransport(mock).WithReq(map[string]string{"item": "x"}).Do(context.Background())
+	if err == nil || len(tb.msgs) != 1 {
+		t.Fatalf("expected unexpected request to fail,got:%v,%v", err, tb.msgs)
+	}
+	for _, expected := range []string{"registered stubs:[GET /users]", "POST /orders HTTP/1.1", `{"item":"x"}`} {
+		if !strings.Contains(tb.msgs[0], expected) {
+			t.Fatalf("expected failure to contain %q,got:%s", expected, tb.msgs[0])
+		}
+	}
+
+	tb = &recordingTB{}
+	mock.AssertCalled(tb, http.MethodGet, "/users")
+	if len(tb.msgs) != 1 || !strings.Contains(tb.msgs[0], "got calls:[POST /orders]") {
+		t.Fatalf("expected AssertCalled to fail,got:%v", tb.msgs)
+	}
+}
+
+func TestMockTransportInOrder(t *testing.T) {
+	tb := &recordingTB{}
+	mock := httpxtest.NewMockTransport(tb).InOrder()
+	mock.On(http.MethodPost, "/login").Return(http.StatusOK, "")
+	mock.On(http.MethodGet, "/profile").Times(2).Return(http.StatusOK, "")
+	mock.On(http.MethodPost, "/logout").Return(http.StatusOK, "")
+
+	do := func(method, path string) error {
+		return httpx.Method(method, "http://api.test"+path).WithTransport(mock).Do(context.Background())
+	}
+	if err := do(http.MethodGet, "/profile"); err == nil || !strings.Contains(tb.msgs[0], "expected next:POST /login") {
+		t.Fatalf("expected out of order request to fail,got:%v,%v", err, tb.msgs)
+	}
+	tb.msgs = nil
+	for _, call := range [][2]string{{http.MethodPost, "/login"}, {http.MethodGet, "/profile"}, {http.MethodGet, "/profile"}} {
+		if err := do(call[0], call[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mock.AssertExpectations(tb)
+	if len(tb.msgs) != 1 || !strings.Contains(tb.msgs[0], "POST /logout:expected 1 calls,got:0") {
+		t.Fatalf("expected unmet logout expectation,got:%v", tb.msgs)
+	}
+	if err := do(http.MethodPost, "/logout"); err != nil {
+		t.Fatal(err)
+	}
+	tb.msgs = nil
+	mock.AssertExpectations(tb)
+	if len(tb.msgs) != 0 {
+		t.Fatalf("expected all expectations met,got:%v", tb.msgs)
+	}
+}
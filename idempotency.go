@@ -0,0 +1,63 @@
+package httpx
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync/atomic"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// idempotencyKey 固定的key在clone之间共享;自动生成的key每次Do生成一次,只在这次Do的重试之间复用,
+// clone时重新创建,派生的Builder不会发送相同的key
+type idempotencyKey struct {
+	auto  bool
+	value string
+	// last 该Builder最近一次Do使用的自动生成的key
+	last atomic.Pointer[string]
+}
+
+func (k *idempotencyKey) clone() *idempotencyKey {
+	if k == nil || !k.auto {
+		return k
+	}
+	return &idempotencyKey{auto: true}
+}
+
+// withKey 自动生成的key放入ctx,以k为context key,其他Builder的请求不会使用它
+func (k *idempotencyKey) withKey(ctx context.Context) context.Context {
+	if k == nil || !k.auto {
+		return ctx
+	}
+	if _, ok := ctx.Value(k).(string); ok {
+		return ctx
+	}
+	value := newUUIDv4()
+	k.last.Store(&value)
+	return context.WithValue(ctx, k, value)
+}
+
+// get 返回请求使用的key,ctx中没有时(直接调用BuildHTTPReq)生成新的key
+func (k *idempotencyKey) get(ctx context.Context) string {
+	if !k.auto {
+		return k.value
+	}
+	if value, ok := ctx.Value(k).(string); ok {
+		return value
+	}
+	return newUUIDv4()
+}
+
+// newUUIDv4 随机生成UUID v4
+func newUUIDv4() string {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		panic(err)
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
@@ -0,0 +1,80 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+var uuidv4Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestAutoIdempotencyKey(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	status := testkit.StatusSequence(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK)
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		mu.Unlock()
+		status.ServeHTTP(w, r)
+	}))
+	policy := ResiliencePolicy{Retries: 2, RetryBackoff: PolicyDuration(time.Millisecond), AttemptTimeout: PolicyDuration(time.Second)}
+	template := Post(server.URL).WithAutoIdempotencyKey()
+	derived := template.ResiliencePolicy(policy)
+	if err := derived.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	key := derived.IdempotencyKey()
+	if !uuidv4Pattern.MatchString(key) {
+		t.Fatalf("expected uuidv4,got:%s", key)
+	}
+	if len(keys) != 3 || keys[0] != key || keys[1] != key || keys[2] != key {
+		t.Fatalf("expected key %s on all attempts,got:%v", key, keys)
+	}
+	if template.IdempotencyKey() != "" {
+		t.Fatalf("expected template not to share the derived key,got:%s", template.IdempotencyKey())
+	}
+
+	// 同一个模板派生的不同请求以及同一Builder的再次Do使用不同的key
+	keys = nil
+	first := template.WithReq(map[string]int{"amount": 1})
+	second := template.WithReq(map[string]int{"amount": 2})
+	for _, b := range []Builder{first, second, first} {
+		if err := b.Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(keys) != 3 || keys[0] == keys[1] || keys[0] == keys[2] || keys[1] == keys[2] {
+		t.Fatalf("expected distinct keys for distinct requests,got:%v", keys)
+	}
+	if first.IdempotencyKey() != keys[2] {
+		t.Fatalf("expected last key:%s,got:%s", keys[2], first.IdempotencyKey())
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	var got []string
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(IdempotencyKeyHeader))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	policy := ResiliencePolicy{Retries: 1, RetryBackoff: PolicyDuration(time.Millisecond)}
+	if err := Post(server.URL).ResiliencePolicy(policy).Do(context.Background()); err == nil {
+		t.Fatal("expected status error")
+	}
+	if len(got) != 1 || got[0] != "" {
+		t.Fatalf("expected post without key not to be retried,got:%v", got)
+	}
+	got = nil
+	if err := Post(server.URL).WithIdempotencyKey("order-1").ResiliencePolicy(policy).Do(context.Background()); err == nil {
+		t.Fatal("expected status error")
+	}
+	if len(got) != 2 || got[0] != "order-1" || got[1] != "order-1" {
+		t.Fatalf("expected retried post with key order-1,got:%v", got)
+	}
+}
@@ -0,0 +1,86 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	insecureHostsWarned sync.Map
+)
+
+// insecureHostMatcher 判断连接的目标是否允许跳过证书校验
+type insecureHostMatcher []string
+
+// match entry带端口时精确匹配addr,否则匹配host本身及其子域名
+func (m insecureHostMatcher) match(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	for _, entry := range m {
+		if _, _, err := net.SplitHostPort(entry); err == nil {
+			if strings.EqualFold(entry, addr) {
+				return true
+			}
+			continue
+		}
+		entry = strings.ToLower(strings.TrimPrefix(entry, "."))
+		host = strings.ToLower(host)
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialTLS 自行完成TLS握手,用于按host跳过证书校验以及触发OnTLSHandshake,
+// 只有allow-list中的目标跳过证书校验,config中显式指定了RootCAs时始终校验
+func dialTLS(dial dialFunc, config *tls.Config, hosts []string, hooks *ConnEventHooks) dialFunc {
+	matcher := insecureHostMatcher(hosts)
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		tlsConfig := &tls.Config{}
+		if config != nil {
+			tlsConfig = config.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = host
+		}
+		if len(tlsConfig.NextProtos) == 0 {
+			tlsConfig.NextProtos = []string{"http/1.1"}
+		}
+		if tlsConfig.RootCAs == nil && matcher.match(addr) {
+			tlsConfig.InsecureSkipVerify = true
+			if _, warned := insecureHostsWarned.LoadOrStore(addr, struct{}{}); !warned {
+				logWarn(context.Background(), "skip tls verification", "addr", addr)
+			}
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		start := time.Now()
+		err = tlsConn.HandshakeContext(ctx)
+		hooks.tlsHandshake(tlsConn.ConnectionState(), err, time.Since(start))
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// InsecureForHostsTransport 只对hosts跳过证书校验,相同hosts复用同一个transport
+func InsecureForHostsTransport(hosts ...string) http.RoundTripper {
+	return defaultTransportCache.get(transportConfig{insecureHosts: hosts})
+}
@@ -0,0 +1,96 @@
+// Package testkit 提供httpx自身测试使用的辅助工具
+package testkit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// NewServer 启动http测试服务,测试结束时自动关闭
+func NewServer(tb testing.TB, handler http.Handler) *httptest.Server {
+	tb.Helper()
+	server := httptest.NewServer(handler)
+	tb.Cleanup(server.Close)
+	return server
+}
+
+// NewTLSServer 启动https测试服务,测试结束时自动关闭
+func NewTLSServer(tb testing.TB, handler http.Handler) *httptest.Server {
+	tb.Helper()
+	server := httptest.NewTLSServer(handler)
+	tb.Cleanup(server.Close)
+	return server
+}
+
+// Echo 原样返回请求体
+func Echo() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
+		io.Copy(w, r.Body)
+	})
+}
+
+// Delay 延迟delay后再交给next处理,请求取消时提前返回
+func Delay(delay time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StatusSequence 依次返回给定的状态码,用完后一直返回最后一个
+func StatusSequence(statusCodes ...int) http.Handler {
+	var idx int64
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt64(&idx, 1) - 1)
+		if i >= len(statusCodes) {
+			i = len(statusCodes) - 1
+		}
+		w.WriteHeader(statusCodes[i])
+	})
+}
+
+// RedirectChain 经过hops次statusCode跳转后交给final处理
+func RedirectChain(hops int, statusCode int, final http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var hop int
+		fmt.Sscanf(r.URL.Query().Get("hop"), "%d", &hop)
+		if hop >= hops {
+			final.ServeHTTP(w, r)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("%s?hop=%d", r.URL.Path, hop+1), statusCode)
+	})
+}
+
+// BrokenBody 声明的Content-Length大于实际写出的内容,然后断开连接
+func BrokenBody(partial string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(partial)+1024))
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, partial)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			return
+		}
+		conn, _, err := hijacker.Hijack()
+		if err != nil {
+			return
+		}
+		conn.Close()
+	})
+}
@@ -0,0 +1,113 @@
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+// LogRecord 捕获到的一条日志
+type LogRecord struct {
+	Level   slog.Level
+	Message string
+	Attrs   map[string]interface{}
+}
+
+// LogCapture 捕获slog日志的handler
+type LogCapture struct {
+	mu      *sync.Mutex
+	records *[]LogRecord
+	attrs   []slog.Attr
+}
+
+// NewLogCapture 返回不替换默认logger的LogCapture,通过slog.New(capture)使用
+func NewLogCapture() *LogCapture {
+	return &LogCapture{
+		mu:      &sync.Mutex{},
+		records: &[]LogRecord{},
+	}
+}
+
+// CaptureLogs 将slog默认logger替换为LogCapture,测试结束后恢复
+func CaptureLogs(tb testing.TB) *LogCapture {
+	tb.Helper()
+	capture := NewLogCapture()
+	prev := slog.Default()
+	slog.SetDefault(slog.New(capture))
+	tb.Cleanup(func() {
+		slog.SetDefault(prev)
+	})
+	return capture
+}
+
+func (c *LogCapture) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (c *LogCapture) Handle(_ context.Context, record slog.Record) error {
+	attrs := make(map[string]interface{})
+	for _, attr := range c.attrs {
+		attrs[attr.Key] = attr.Value.Any()
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs[attr.Key] = attr.Value.Any()
+		return true
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.records = append(*c.records, LogRecord{
+		Level:   record.Level,
+		Message: record.Message,
+		Attrs:   attrs,
+	})
+	return nil
+}
+
+func (c *LogCapture) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogCapture{
+		mu:      c.mu,
+		records: c.records,
+		attrs:   append(append([]slog.Attr{}, c.attrs...), attrs...),
+	}
+}
+
+func (c *LogCapture) WithGroup(string) slog.Handler {
+	return c
+}
+
+// Records 返回目前捕获到的所有日志
+func (c *LogCapture) Records() []LogRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]LogRecord(nil), *c.records...)
+}
+
+// Find 返回message匹配的日志
+func (c *LogCapture) Find(message string) []LogRecord {
+	var records []LogRecord
+	for _, record := range c.Records() {
+		if record.Message == message {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// AssertField 断言存在message匹配且key字段等于value的日志
+func (c *LogCapture) AssertField(tb testing.TB, message, key string, value interface{}) {
+	tb.Helper()
+	records := c.Find(message)
+	if len(records) == 0 {
+		tb.Fatalf("no log with message:%s", message)
+	}
+	var got []interface{}
+	for _, record := range records {
+		if fmt.Sprint(record.Attrs[key]) == fmt.Sprint(value) {
+			return
+		}
+		got = append(got, record.Attrs[key])
+	}
+	tb.Fatalf("expected log %s field %s:%v,got:%v", message, key, value, got)
+}
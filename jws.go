@@ -0,0 +1,254 @@
+package httpx
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+const (
+	ContentTypeJOSE = "application/jose"
+)
+
+// JWSAlg JWS签名算法
+type JWSAlg string
+
+const (
+	JWSHS256 JWSAlg = "HS256"
+	JWSRS256 JWSAlg = "RS256"
+	JWSES256 JWSAlg = "ES256"
+)
+
+// JWSKey 带kid的密钥,HS256使用[]byte,RS256使用*rsa.PrivateKey/*rsa.PublicKey,
+// ES256使用*ecdsa.PrivateKey/*ecdsa.PublicKey(P-256)
+type JWSKey struct {
+	ID  string
+	Key interface{}
+}
+
+// KeyProvider 提供签名与验签的密钥,密钥轮换时返回新的密钥即可
+type KeyProvider interface {
+	// SigningKey 当前用于签名的密钥
+	SigningKey() (JWSKey, error)
+	// VerificationKey 按kid查找验签密钥,不存在时返回error
+	VerificationKey(kid string) (JWSKey, error)
+}
+
+type staticKeys struct {
+	signKey    JWSKey
+	verifyKeys map[string]JWSKey
+}
+
+// StaticKeys 固定的KeyProvider
+func StaticKeys(signKey JWSKey, verifyKeys ...JWSKey) KeyProvider {
+	keys := &staticKeys{signKey: signKey, verifyKeys: make(map[string]JWSKey, len(verifyKeys))}
+	for _, key := range verifyKeys {
+		keys.verifyKeys[key.ID] = key
+	}
+	return keys
+}
+
+func (k *staticKeys) SigningKey() (JWSKey, error) {
+	if k.signKey.Key == nil {
+		return JWSKey{}, fmt.Errorf("no jws signing key")
+	}
+	return k.signKey, nil
+}
+
+func (k *staticKeys) VerificationKey(kid string) (JWSKey, error) {
+	key, exist := k.verifyKeys[kid]
+	if !exist {
+		return JWSKey{}, fmt.Errorf("unknown kid:%q", kid)
+	}
+	return key, nil
+}
+
+// ErrJWSVerification 响应的JWS格式错误或验签失败
+type ErrJWSVerification struct {
+	KeyID  string
+	Reason string
+}
+
+func (e *ErrJWSVerification) Error() string {
+	return fmt.Sprintf("jws verification failed,kid:%q,%s", e.KeyID, e.Reason)
+}
+
+type jwsHeader struct {
+	Alg JWSAlg `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+}
+
+type jwsCodec struct {
+	keys KeyProvider
+	alg  JWSAlg
+}
+
+// JWSCodec 请求体编码为compact JWS,响应验签后再按json解码
+func JWSCodec(signKey JWSKey, verifyKeys []JWSKey, alg JWSAlg) Codec {
+	return JWSCodecWithKeys(StaticKeys(signKey, verifyKeys...), alg)
+}
+
+// JWSCodecWithKeys 使用KeyProvider的JWSCodec,用于密钥轮换
+func JWSCodecWithKeys(keys KeyProvider, alg JWSAlg) Codec {
+	return &jwsCodec{keys: keys, alg: alg}
+}
+
+func (c *jwsCodec) ContentType() string {
+	return ContentTypeJOSE
+}
+
+func (c *jwsCodec) Encode(obj interface{}) ([]byte, error) {
+	key, err := c.keys.SigningKey()
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(&jwsHeader{Alg: c.alg, Kid: key.ID})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := jwsSign(c.alg, key.Key, []byte(signingInput))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)), nil
+}
+
+func (c *jwsCodec) Decode(r io.Reader, obj interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	data = bytes.TrimSpace(data)
+	parts := bytes.Split(data, []byte("."))
+	if len(parts) != 3 {
+		return &ErrJWSVerification{Reason: "malformed compact serialization"}
+	}
+	headerData, err := base64.RawURLEncoding.DecodeString(string(parts[0]))
+	if err != nil {
+		return &ErrJWSVerification{Reason: "malformed header"}
+	}
+	header := &jwsHeader{}
+	if err := json.Unmarshal(headerData, header); err != nil {
+		return &ErrJWSVerification{Reason: "malformed header"}
+	}
+	// 只接受配置的算法,避免alg被替换
+	if header.Alg != c.alg {
+		return &ErrJWSVerification{KeyID: header.Kid, Reason: fmt.Sprintf("unexpected alg:%s", header.Alg)}
+	}
+	key, err := c.keys.VerificationKey(header.Kid)
+	if err != nil {
+		return &ErrJWSVerification{KeyID: header.Kid, Reason: err.Error()}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(string(parts[2]))
+	if err != nil {
+		return &ErrJWSVerification{KeyID: header.Kid, Reason: "malformed signature"}
+	}
+	signingInput := data[:len(parts[0])+1+len(parts[1])]
+	if err := jwsVerify(c.alg, key.Key, signingInput, signature); err != nil {
+		return &ErrJWSVerification{KeyID: header.Kid, Reason: err.Error()}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(string(parts[1]))
+	if err != nil {
+		return &ErrJWSVerification{KeyID: header.Kid, Reason: "malformed payload"}
+	}
+	return json.Unmarshal(payload, obj)
+}
+
+func jwsSign(alg JWSAlg, key interface{}, signingInput []byte) ([]byte, error) {
+	hashed := sha256.Sum256(signingInput)
+	switch alg {
+	case JWSHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("HS256 requires []byte key,got:%T", key)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		return mac.Sum(nil), nil
+	case JWSRS256:
+		privateKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("RS256 requires *rsa.PrivateKey,got:%T", key)
+		}
+		return rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed[:])
+	case JWSES256:
+		privateKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok || privateKey.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 requires P-256 *ecdsa.PrivateKey,got:%T", key)
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, privateKey, hashed[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS使用定长的r||s,而不是ASN.1
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	}
+	return nil, fmt.Errorf("unsupported jws alg:%s", alg)
+}
+
+func jwsVerify(alg JWSAlg, key interface{}, signingInput, signature []byte) error {
+	hashed := sha256.Sum256(signingInput)
+	switch alg {
+	case JWSHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("HS256 requires []byte key,got:%T", key)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	case JWSRS256:
+		publicKey, ok := jwsPublicKey(key).(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("RS256 requires rsa key,got:%T", key)
+		}
+		if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signature); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	case JWSES256:
+		publicKey, ok := jwsPublicKey(key).(*ecdsa.PublicKey)
+		if !ok || publicKey.Curve != elliptic.P256() {
+			return fmt.Errorf("ES256 requires P-256 ecdsa key,got:%T", key)
+		}
+		if len(signature) != 64 {
+			return fmt.Errorf("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(publicKey, hashed[:], r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported jws alg:%s", alg)
+}
+
+// jwsPublicKey 验签时也接受私钥
+func jwsPublicKey(key interface{}) interface{} {
+	if signer, ok := key.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return key
+}
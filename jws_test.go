@@ -0,0 +1,213 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+// RFC 7515 附录A.1的示例
+const (
+	rfc7515HS256Key = "AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow"
+	rfc7515HS256JWS = "eyJ0eXAiOiJKV1QiLA0KICJhbGciOiJIUzI1NiJ9" +
+		".eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ" +
+		".dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+type partner struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func loadPEMKey(t *testing.T, name string) interface{} {
+	t.Helper()
+	data, err := os.ReadFile("testdata/jws/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("expected pem block in:%s", name)
+	}
+	if block.Type == "PUBLIC KEY" {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile("testdata/jws/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// testdata/jws中的密钥与签名由openssl生成
+func TestJWSDecodeFixtures(t *testing.T) {
+	hsKey, _ := base64.RawURLEncoding.DecodeString(rfc7515HS256Key)
+	claims := map[string]interface{}{}
+	if err := JWSCodec(JWSKey{}, []JWSKey{{Key: hsKey}}, JWSHS256).Decode(strings.NewReader(rfc7515HS256JWS), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims["iss"] != "joe" || claims["http://example.com/is_root"] != true {
+		t.Fatalf("unexpected claims:%v", claims)
+	}
+
+	tests := []struct {
+		alg     JWSAlg
+		fixture string
+		key     JWSKey
+	}{
+		{alg: JWSRS256, fixture: "rs256.jws", key: JWSKey{ID: "rsa-1", Key: loadPEMKey(t, "rsa.pub.pem")}},
+		{alg: JWSES256, fixture: "es256.jws", key: JWSKey{ID: "ec-1", Key: loadPEMKey(t, "ec.pub.pem")}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.alg), func(t *testing.T) {
+			got := &partner{}
+			if err := JWSCodec(JWSKey{}, []JWSKey{tt.key}, tt.alg).Decode(strings.NewReader(readFixture(t, tt.fixture)), got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != 7 || got.Name != "partner" {
+				t.Fatalf("unexpected payload:%+v", got)
+			}
+		})
+	}
+}
+
+func TestJWSRoundTrip(t *testing.T) {
+	tests := []struct {
+		alg JWSAlg
+		key JWSKey
+	}{
+		{alg: JWSHS256, key: JWSKey{ID: "hs-1", Key: []byte("secret")}},
+		{alg: JWSRS256, key: JWSKey{ID: "rsa-1", Key: loadPEMKey(t, "rsa.pem")}},
+		{alg: JWSES256, key: JWSKey{ID: "ec-1", Key: loadPEMKey(t, "ec.pem")}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.alg), func(t *testing.T) {
+			codec := JWSCodec(tt.key, []JWSKey{tt.key}, tt.alg)
+			server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if contentType := r.Header.Get(ContentTypeKey); contentType != ContentTypeJOSE {
+					w.WriteHeader(http.StatusUnsupportedMediaType)
+					return
+				}
+				req := &partner{}
+				if err := codec.Decode(r.Body, req); err != nil {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				req.Name += "-ok"
+				data, _ := codec.Encode(req)
+				w.Write(data)
+			}))
+			resp := &partner{}
+			if err := Post(server.URL).WithCodec(codec).WithReq(&partner{ID: 1, Name: "a"}).WithResp(resp).Do(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if resp.ID != 1 || resp.Name != "a-ok" {
+				t.Fatalf("unexpected resp:%+v", resp)
+			}
+		})
+	}
+}
+
+func TestJWSVerificationFailures(t *testing.T) {
+	key := JWSKey{ID: "ec-1", Key: loadPEMKey(t, "ec.pub.pem")}
+	fixture := strings.TrimSpace(readFixture(t, "es256.jws"))
+	parts := strings.Split(fixture, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"id":8,"name":"partner"}`)) + "." + parts[2]
+	otherKid := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","kid":"ec-2"}`)) + "." + parts[1] + "." + parts[2]
+	tests := []struct {
+		name   string
+		data   string
+		reason string
+	}{
+		{name: "tampered", data: tampered, reason: "invalid signature"},
+		{name: "unknown kid", data: otherKid, reason: `unknown kid:"ec-2"`},
+		{name: "alg", data: rfc7515HS256JWS, reason: "unexpected alg:HS256"},
+		{name: "malformed", data: "a.b", reason: "malformed compact serialization"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := JWSCodec(JWSKey{}, []JWSKey{key}, JWSES256).Decode(strings.NewReader(tt.data), &partner{})
+			verificationErr := &ErrJWSVerification{}
+			if !errors.As(err, &verificationErr) || verificationErr.Reason != tt.reason {
+				t.Fatalf("expected jws verification error:%s,got:%v", tt.reason, err)
+			}
+		})
+	}
+}
+
+type rotatingKeys struct {
+	sync.Mutex
+	current JWSKey
+	keys    map[string]JWSKey
+}
+
+func (k *rotatingKeys) rotate(key JWSKey) {
+	k.Lock()
+	defer k.Unlock()
+	k.current = key
+	k.keys[key.ID] = key
+}
+
+func (k *rotatingKeys) SigningKey() (JWSKey, error) {
+	k.Lock()
+	defer k.Unlock()
+	return k.current, nil
+}
+
+func (k *rotatingKeys) VerificationKey(kid string) (JWSKey, error) {
+	k.Lock()
+	defer k.Unlock()
+	key, exist := k.keys[kid]
+	if !exist {
+		return JWSKey{}, errors.New("unknown kid")
+	}
+	return key, nil
+}
+
+func TestJWSKeyRotation(t *testing.T) {
+	keys := &rotatingKeys{keys: make(map[string]JWSKey)}
+	keys.rotate(JWSKey{ID: "k1", Key: []byte("one")})
+	codec := JWSCodecWithKeys(keys, JWSHS256)
+	old, err := codec.Encode(&partner{ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.rotate(JWSKey{ID: "k2", Key: []byte("two")})
+	current, err := codec.Encode(&partner{ID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _ := base64.RawURLEncoding.DecodeString(strings.Split(string(current), ".")[0])
+	if !bytes.Contains(header, []byte(`"kid":"k2"`)) {
+		t.Fatalf("expected kid:k2,got:%s", header)
+	}
+	for _, data := range [][]byte{old, current} {
+		if err := codec.Decode(io.NopCloser(bytes.NewReader(data)), &partner{}); err != nil {
+			t.Fatalf("expected both keys to verify,got:%s", err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrClose Close时未能在ctx结束前停止或停止失败的组件
+type ErrClose struct {
+	Failed map[string]error
+}
+
+func (e *ErrClose) Error() string {
+	names := make([]string, 0, len(e.Failed))
+	for name := range e.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s:%s", name, e.Failed[name]))
+	}
+	return "close failed: " + strings.Join(msgs, "; ")
+}
+
+// closeStage closer的执行阶段,前一阶段全部结束后才执行下一阶段
+type closeStage int
+
+const (
+	// closeStageTransports 关闭连接,会产生连接关闭事件
+	closeStageTransports closeStage = iota
+	// closeStageWorkers 处理完剩余事件后停止后台worker
+	closeStageWorkers
+)
+
+type stagedCloser struct {
+	stage  closeStage
+	closer func(ctx context.Context) error
+}
+
+// lifecycle 带有后台资源的组件在创建时注册closer,closer需要可以重复调用
+type lifecycle struct {
+	sync.Mutex
+	closers map[string]stagedCloser
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{closers: make(map[string]stagedCloser)}
+}
+
+var defaultLifecycle = newLifecycle()
+
+// register 同名的closer只保留第一个
+func (l *lifecycle) register(name string, stage closeStage, closer func(ctx context.Context) error) {
+	l.Lock()
+	defer l.Unlock()
+	if _, exist := l.closers[name]; !exist {
+		l.closers[name] = stagedCloser{stage: stage, closer: closer}
+	}
+}
+
+// close 按阶段并发执行closer,等待到ctx结束
+func (l *lifecycle) close(ctx context.Context) error {
+	l.Lock()
+	stages := make(map[closeStage]map[string]func(ctx context.Context) error)
+	for name, staged := range l.closers {
+		if stages[staged.stage] == nil {
+			stages[staged.stage] = make(map[string]func(ctx context.Context) error)
+		}
+		stages[staged.stage][name] = staged.closer
+	}
+	l.Unlock()
+
+	failed := make(map[string]error)
+	for _, stage := range []closeStage{closeStageTransports, closeStageWorkers} {
+		for name, err := range runClosers(ctx, stages[stage]) {
+			failed[name] = err
+		}
+	}
+	if len(failed) != 0 {
+		return &ErrClose{Failed: failed}
+	}
+	return nil
+}
+
+// runClosers 返回失败或超时的closer
+func runClosers(ctx context.Context, closers map[string]func(ctx context.Context) error) map[string]error {
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, len(closers))
+	for name, closer := range closers {
+		go func(name string, closer func(ctx context.Context) error) {
+			results <- result{name: name, err: closer(ctx)}
+		}(name, closer)
+	}
+	failed := make(map[string]error)
+	pending := make(map[string]struct{}, len(closers))
+	for name := range closers {
+		pending[name] = struct{}{}
+	}
+	for len(pending) != 0 {
+		select {
+		case r := <-results:
+			delete(pending, r.name)
+			if r.err != nil {
+				failed[r.name] = r.err
+			}
+		case <-ctx.Done():
+			for name := range pending {
+				failed[name] = ctx.Err()
+			}
+			pending = nil
+		}
+	}
+	return failed
+}
+
+// Close 停止后台goroutine并关闭缓存transport的空闲连接,返回未能在ctx结束前停止的组件;
+// 可以重复调用,Close之后创建的Builder照常工作,需要时会重新启动后台资源
+func Close(ctx context.Context) error {
+	return defaultLifecycle.close(ctx)
+}
+
+// closeIdleConnections 沿着Unwrap找到底层transport并关闭其空闲连接
+func closeIdleConnections(rt http.RoundTripper) {
+	for rt != nil {
+		if closer, ok := rt.(interface{ CloseIdleConnections() }); ok {
+			closer.CloseIdleConnections()
+			return
+		}
+		unwrapper, ok := rt.(interface{ Unwrap() http.RoundTripper })
+		if !ok {
+			return
+		}
+		rt = unwrapper.Unwrap()
+	}
+}
@@ -0,0 +1,89 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func waitGoroutines(t *testing.T, max int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 2)
+	for runtime.NumGoroutine() > max {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("expected goroutines<=%d,got:%d\n%s", max, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestCloseStopsBackgroundGoroutines(t *testing.T) {
+	server := testkit.NewServer(t, testkit.Echo())
+	if err := Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitGoroutines(t, runtime.NumGoroutine())
+	before := runtime.NumGoroutine()
+
+	for round := 0; round < 2; round++ {
+		hooks, events := recordingHooks()
+		for i := 0; i < 3; i++ {
+			if err := Get(server.URL).ConnEventHooks(hooks).Do(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := Get(server.URL).WithTransport(Transport()).Do(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Close之后新的请求会重新启动worker
+		if event := nextConnEvent(t, events); event.kind != "dial" {
+			t.Fatalf("expected dial event in round %d,got:%+v", round, event)
+		}
+		if runtime.NumGoroutine() <= before {
+			t.Fatal("expected background goroutines while requests are pooled")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := Close(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitGoroutines(t, before)
+	}
+	if err := Close(context.Background()); err != nil {
+		t.Fatalf("expected double close to be safe,got:%s", err)
+	}
+	if stats := GetTransportCacheStats(); stats.Size != 0 {
+		t.Fatalf("expected empty transport cache,got:%d", stats.Size)
+	}
+}
+
+func TestCloseReportsStuckComponents(t *testing.T) {
+	l := newLifecycle()
+	block := make(chan struct{})
+	defer close(block)
+	l.register("ok", closeStageTransports, func(ctx context.Context) error { return nil })
+	l.register("failed", closeStageWorkers, func(ctx context.Context) error { return errors.New("boom") })
+	l.register("stuck", closeStageWorkers, func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	err := l.close(ctx)
+	closeErr := &ErrClose{}
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("expected close error,got:%v", err)
+	}
+	if len(closeErr.Failed) != 2 || !errors.Is(closeErr.Failed["stuck"], context.DeadlineExceeded) || closeErr.Failed["failed"] == nil {
+		t.Fatalf("unexpected failed components:%v", closeErr.Failed)
+	}
+	if expected := "close failed: failed:boom; stuck:context deadline exceeded"; err.Error() != expected {
+		t.Fatalf("expected:%s,got:%s", expected, err)
+	}
+}
@@ -0,0 +1,99 @@
+package httpx
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxLogBodyBytes LoggingOptions.MaxBodyBytes为0时日志中最多记录的body字节数
+const DefaultMaxLogBodyBytes = 4 << 10
+
+const (
+	bodyOmittedBinary = "binary"
+	// bodyOmittedRedaction 截断的json无法解析,设置了RedactBodyFields时不记录
+	bodyOmittedRedaction = "redaction"
+)
+
+// logBodyFields 请求与响应body使用的日志字段
+type logBodyFields struct {
+	data      string
+	truncated string
+	total     string
+	omitted   string
+}
+
+var (
+	reqLogBodyFields  = logBodyFields{data: FieldReqData, truncated: FieldReqTruncated, total: FieldReqTotalBytes, omitted: FieldReqBodyOmitted}
+	respLogBodyFields = logBodyFields{data: FieldRespData, truncated: FieldRespTruncated, total: FieldRespTotalBytes, omitted: FieldRespBodyOmitted}
+)
+
+func (o LoggingOptions) maxBodyBytes() int64 {
+	if o.MaxBodyBytes == 0 {
+		return DefaultMaxLogBodyBytes
+	}
+	return o.MaxBodyBytes
+}
+
+// peekBody 读取body的前max字节用于日志,max<0时读取全部;
+// 超过max时剩余部分不读入内存,返回的body依次读出已读部分与剩余部分
+func peekBody(body io.ReadCloser, max int64) ([]byte, bool, io.ReadCloser, error) {
+	if max < 0 {
+		data, restored, err := DrainBody(body)
+		return data, false, restored, err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, max+1))
+	if err != nil {
+		body.Close()
+		return nil, false, nil, err
+	}
+	if int64(len(data)) <= max {
+		body.Close()
+		return data, false, io.NopCloser(bytes.NewReader(data)), nil
+	}
+	restored := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), body), body}
+	return data[:max], true, restored, nil
+}
+
+// binaryBody 二进制或仍然压缩的body不记录
+func binaryBody(header http.Header) bool {
+	if encoding := header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get(ContentTypeKey))
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/octet-stream", "application/gzip", "application/x-gzip", "application/zip", "application/pdf":
+		return true
+	}
+	for _, prefix := range []string{"image/", "audio/", "video/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendBody 记录body,截断时带上truncated与已知的总长度(total<0表示未知)
+func (r *logRedactor) appendBody(kvs []interface{}, fields logBodyFields, data []byte, truncated bool, total int64) []interface{} {
+	if !truncated {
+		return append(kvs, fields.data, r.body(data))
+	}
+	if len(r.bodyFields) != 0 {
+		kvs = append(kvs, fields.omitted, bodyOmittedRedaction)
+	} else {
+		kvs = append(kvs, fields.data, string(data))
+	}
+	kvs = append(kvs, fields.truncated, true)
+	if total >= 0 {
+		kvs = append(kvs, fields.total, total)
+	}
+	return kvs
+}
@@ -0,0 +1,121 @@
+package httpx
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestLoggingTransportBodyLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentType   string
+		contentLength int64
+		opts          LoggingOptions
+		expected      map[string]interface{}
+		maxRead       int
+	}{
+		{
+			name:          "unknown length",
+			contentLength: -1,
+			opts:          LoggingOptions{RespBody: true, MaxBodyBytes: 16},
+			expected:      map[string]interface{}{FieldRespData: strings.Repeat("x", 16), FieldRespTruncated: true},
+			maxRead:       17,
+		},
+		{
+			name:          "known length",
+			contentLength: 1 << 20,
+			opts:          LoggingOptions{RespBody: true, MaxBodyBytes: 16},
+			expected:      map[string]interface{}{FieldRespData: strings.Repeat("x", 16), FieldRespTruncated: true, FieldRespTotalBytes: 1 << 20},
+			maxRead:       17,
+		},
+		{
+			name:          "binary",
+			contentType:   "image/png",
+			contentLength: 1 << 20,
+			opts:          LoggingOptions{RespBody: true},
+			expected:      map[string]interface{}{FieldRespBodyOmitted: "binary"},
+		},
+		{
+			name:          "redacted",
+			contentLength: -1,
+			opts:          LoggingOptions{RespBody: true, MaxBodyBytes: 16, RedactBodyFields: []string{"password"}},
+			expected:      map[string]interface{}{FieldRespBodyOmitted: "redaction", FieldRespTruncated: true},
+			maxRead:       17,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := testkit.CaptureLogs(t)
+			body := &countingReader{Reader: strings.NewReader(strings.Repeat("x", 1<<20))}
+			client := &http.Client{Transport: WrapTransport(TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+				header := http.Header{}
+				if tt.contentType != "" {
+					header.Set(ContentTypeKey, tt.contentType)
+				}
+				return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(body), ContentLength: tt.contentLength, Request: httpReq}, nil
+			}), LoggingTransportWithOptions(tt.opts))}
+			resp, err := client.Get("http://upstream.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if body.read > tt.maxRead {
+				t.Fatalf("expected at most %d bytes buffered,got:%d", tt.maxRead, body.read)
+			}
+			for key, value := range tt.expected {
+				logs.AssertField(t, "got http resp", key, value)
+			}
+			if data, _ := io.ReadAll(resp.Body); len(data) != 1<<20 {
+				t.Fatalf("expected full body for the caller,got:%d", len(data))
+			}
+		})
+	}
+}
+
+func TestLoggingHandlerBodyLimit(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	opts := LoggingOptions{ReqBody: true, RespBody: true, MaxBodyBytes: 8}
+	server := testkit.NewServer(t, WrapHandler(testkit.Echo(), LoggingHandlerWithOptions(opts)))
+	resp, err := http.Post(server.URL, "text/plain", strings.NewReader(strings.Repeat("y", 1000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(data) != 1000 {
+		t.Fatalf("expected echoed body of 1000 bytes,got:%d", len(data))
+	}
+	for key, value := range map[string]interface{}{
+		FieldReqData:        "yyyyyyyy",
+		FieldReqTruncated:   true,
+		FieldReqTotalBytes:  1000,
+		FieldRespData:       "yyyyyyyy",
+		FieldRespTruncated:  true,
+		FieldRespTotalBytes: 1000,
+	} {
+		logs.AssertField(t, "serve http req", key, value)
+	}
+
+	resp, err = http.Post(server.URL, "application/octet-stream", strings.NewReader("binary"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	logs.AssertField(t, "serve http req", FieldReqBodyOmitted, "binary")
+	logs.AssertField(t, "serve http req", FieldRespBodyOmitted, "binary")
+}
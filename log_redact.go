@@ -0,0 +1,94 @@
+package httpx
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// DefaultRedactHeaders 日志中总是隐藏值的header
+var DefaultRedactHeaders = []string{AuthorizationKey, "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// LoggingOptions LoggingTransportWithOptions与LoggingHandlerWithOptions的配置
+type LoggingOptions struct {
+	// Logger 为nil时使用context中的logger,见ContextWithLogger
+	Logger *slog.Logger
+	// ReqLevel 请求日志的级别,默认Info
+	ReqLevel slog.Level
+	// RespLevel 响应日志的级别,默认Info;LoggingHandler只在处理完成后输出一条日志,使用RespLevel
+	RespLevel slog.Level
+	// ReqBody 记录请求体
+	ReqBody bool
+	// RespBody 记录响应体
+	RespBody bool
+	// MaxBodyBytes 最多记录的body字节数,超过时截断且不再读入内存;0时使用DefaultMaxLogBodyBytes,<0时不限制
+	MaxBodyBytes int64
+	// LogTimings 记录连接是否复用、空闲时间以及DNS、建连与TLS握手的耗时
+	LogTimings bool
+	// LogHeaders 记录请求与响应的header,敏感header的值按RedactHeaders隐藏
+	LogHeaders bool
+	// RedactHeaders 在DefaultRedactHeaders之外需要隐藏值的header
+	RedactHeaders []string
+	// RedactBodyFields json body中需要隐藏值的顶层字段,不区分大小写;不是json对象的body原样记录
+	RedactBodyFields []string
+	// FieldMapper 日志字段名的映射,如ECSFieldMapper;nil时使用SetFieldMapper设置的FieldMapper
+	FieldMapper FieldMapper
+}
+
+type logRedactor struct {
+	headers    map[string]struct{}
+	bodyFields []string
+}
+
+func newLogRedactor(opts LoggingOptions) *logRedactor {
+	redactor := &logRedactor{headers: make(map[string]struct{}), bodyFields: opts.RedactBodyFields}
+	for _, headers := range [][]string{DefaultRedactHeaders, opts.RedactHeaders} {
+		for _, key := range headers {
+			redactor.headers[http.CanonicalHeaderKey(key)] = struct{}{}
+		}
+	}
+	return redactor
+}
+
+// header 返回副本,原header不会被修改
+func (r *logRedactor) header(header http.Header) http.Header {
+	redacted := header.Clone()
+	for key, values := range redacted {
+		if _, exist := r.headers[http.CanonicalHeaderKey(key)]; !exist {
+			continue
+		}
+		for i := range values {
+			values[i] = redactedValue
+		}
+	}
+	return redacted
+}
+
+func (r *logRedactor) body(data []byte) string {
+	if len(r.bodyFields) == 0 {
+		return string(data)
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return string(data)
+	}
+	redacted := false
+	for key := range obj {
+		for _, field := range r.bodyFields {
+			if strings.EqualFold(key, field) {
+				obj[key] = json.RawMessage(`"` + redactedValue + `"`)
+				redacted = true
+				break
+			}
+		}
+	}
+	if !redacted {
+		return string(data)
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return redactedValue
+	}
+	return string(data)
+}
@@ -0,0 +1,88 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestLoggingRedaction(t *testing.T) {
+	opts := LoggingOptions{
+		ReqBody:          true,
+		RespBody:         true,
+		LogHeaders:       true,
+		RedactHeaders:    []string{"x-internal-secret"},
+		RedactBodyFields: []string{"Password", "token"},
+	}
+	server := testkit.NewServer(t, WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-secret"})
+		testkit.Echo().ServeHTTP(w, r)
+	}), LoggingHandlerWithOptions(opts)))
+	logs := testkit.CaptureLogs(t)
+	secrets := []string{"bearer-secret", "cookie-secret", "apikey-secret", "header-secret", "hunter2", "token-secret"}
+	req := map[string]interface{}{"user": "bob", "password": "hunter2", "token": "token-secret"}
+	err := Post(server.URL).
+		Tracing(false).
+		Logging(false, false).
+		WithTransportWrapper(LoggingTransportWithOptions(opts)).
+		WithBearerToken("bearer-secret").
+		WithHeader("Cookie", "session=cookie-secret").
+		WithHeader("X-Api-Key", "apikey-secret").
+		WithHeader("X-Internal-Secret", "header-secret").
+		WithReq(req).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"send http req", "got http resp", "serve http req"} {
+		records := logs.Find(message)
+		if len(records) == 0 {
+			t.Fatalf("no log with message:%s", message)
+		}
+		// builder自带的LoggingTransport不记录header与body
+		redacted := false
+		for _, record := range records {
+			attrs := fmt.Sprint(record.Attrs)
+			for _, secret := range secrets {
+				if strings.Contains(attrs, secret) {
+					t.Fatalf("expected %s to be redacted in %s,got:%s", secret, message, attrs)
+				}
+			}
+			redacted = redacted || strings.Contains(attrs, "bob") && strings.Contains(attrs, redactedValue)
+		}
+		if !redacted {
+			t.Fatalf("expected redacted fields in %s,got:%v", message, records)
+		}
+	}
+	for _, record := range logs.Find("got http resp") {
+		if respHeader, ok := record.Attrs[FieldRespHeader].(http.Header); ok && respHeader.Get("Set-Cookie") != redactedValue {
+			t.Fatalf("expected Set-Cookie to be redacted,got:%v", respHeader)
+		}
+	}
+}
+
+func TestLoggingRedactionDefaults(t *testing.T) {
+	redactor := newLogRedactor(LoggingOptions{})
+	header := http.Header{"Authorization": {"Bearer x"}, "Set-Cookie": {"a=1", "b=2"}, "Accept": {"*/*"}}
+	redacted := redactor.header(header)
+	if redacted.Get("Authorization") != redactedValue || redacted.Values("Set-Cookie")[1] != redactedValue || redacted.Get("Accept") != "*/*" {
+		t.Fatalf("unexpected redacted header:%v", redacted)
+	}
+	if header.Get("Authorization") != "Bearer x" {
+		t.Fatalf("expected original header untouched,got:%v", header)
+	}
+	body := `{"password":"hunter2"}`
+	if got := redactor.body([]byte(body)); got != body {
+		t.Fatalf("expected body:%s,got:%s", body, got)
+	}
+	redactor = newLogRedactor(LoggingOptions{RedactBodyFields: []string{"password"}})
+	for _, body := range []string{"not json", `["password"]`, `{"nested":{"password":"x"}}`} {
+		if got := redactor.body([]byte(body)); got != body {
+			t.Fatalf("expected body:%s,got:%s", body, got)
+		}
+	}
+}
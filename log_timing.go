@@ -0,0 +1,127 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// connTimings 通过httptrace记录连接复用情况、DNS、建连与TLS握手的耗时以及首字节时间
+type connTimings struct {
+	sync.Mutex
+	start        time.Time
+	firstByte    time.Duration
+	gotConn      bool
+	reused       bool
+	wasIdle      bool
+	idleTime     time.Duration
+	dnsStart     time.Time
+	dns          time.Duration
+	connectStart time.Time
+	connect      time.Duration
+	tlsStart     time.Time
+	tls          time.Duration
+}
+
+func traceConnTimings(httpReq *http.Request) (*http.Request, *connTimings) {
+	ctx, timings := withConnTimings(httpReq.Context())
+	return httpReq.WithContext(ctx), timings
+}
+
+// withConnTimings 在ctx上追加httptrace回调,ctx中已有的ClientTrace仍然生效
+func withConnTimings(ctx context.Context) (context.Context, *connTimings) {
+	timings := &connTimings{start: time.Now()}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			timings.Lock()
+			defer timings.Unlock()
+			timings.gotConn, timings.reused, timings.wasIdle, timings.idleTime = true, info.Reused, info.WasIdle, info.IdleTime
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			timings.Lock()
+			defer timings.Unlock()
+			timings.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			timings.Lock()
+			defer timings.Unlock()
+			timings.dns = time.Since(timings.dnsStart)
+		},
+		// 同时尝试多个地址时,从第一次开始建连算到最后一次完成
+		ConnectStart: func(string, string) {
+			timings.Lock()
+			defer timings.Unlock()
+			if timings.connectStart.IsZero() {
+				timings.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(string, string, error) {
+			timings.Lock()
+			defer timings.Unlock()
+			timings.connect = time.Since(timings.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			timings.Lock()
+			defer timings.Unlock()
+			timings.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			timings.Lock()
+			defer timings.Unlock()
+			timings.tls = time.Since(timings.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			timings.Lock()
+			defer timings.Unlock()
+			timings.firstByte = time.Since(timings.start)
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace), timings
+}
+
+// kvs 没有拿到连接时只返回空;没有发生的阶段不输出
+func (t *connTimings) kvs() []interface{} {
+	t.Lock()
+	defer t.Unlock()
+	if !t.gotConn {
+		return nil
+	}
+	kvs := []interface{}{FieldConnReused, t.reused}
+	if t.wasIdle {
+		kvs = append(kvs, FieldConnWasIdle, true, FieldConnIdleMs, durationMs(t.idleTime))
+	}
+	if !t.dnsStart.IsZero() {
+		kvs = append(kvs, FieldDNSMs, durationMs(t.dns))
+	}
+	if !t.connectStart.IsZero() {
+		kvs = append(kvs, FieldConnectMs, durationMs(t.connect))
+	}
+	if !t.tlsStart.IsZero() {
+		kvs = append(kvs, FieldTLSMs, durationMs(t.tls))
+	}
+	return kvs
+}
+
+// durationMs 保留到微秒的毫秒数
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// snapshot total为从开始到现在的耗时
+func (t *connTimings) snapshot() Timings {
+	t.Lock()
+	defer t.Unlock()
+	return Timings{
+		DNS:          t.dns,
+		Connect:      t.connect,
+		TLSHandshake: t.tls,
+		TTFB:         t.firstByte,
+		Total:        time.Since(t.start),
+		ConnReused:   t.reused,
+		ConnWasIdle:  t.wasIdle,
+		ConnIdleTime: t.idleTime,
+	}
+}
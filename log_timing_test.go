@@ -0,0 +1,71 @@
+package httpx
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestLoggingTimings(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	server := testkit.NewTLSServer(t, testkit.Echo())
+	base := server.Client().Transport
+	client := &http.Client{Transport: WrapTransport(base, LoggingTransportWithOptions(LoggingOptions{LogTimings: true}))}
+	for i := 0; i < 2; i++ {
+		httpResp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, httpResp.Body)
+		httpResp.Body.Close()
+	}
+	records := logs.Find("got http resp")
+	if len(records) != 2 {
+		t.Fatalf("expected 2 logs,got:%d", len(records))
+	}
+	first, second := records[0].Attrs, records[1].Attrs
+	for _, key := range []string{FieldDurationMs, FieldConnectMs, FieldTLSMs} {
+		if _, ok := first[key].(float64); !ok {
+			t.Fatalf("expected %s on the first request,got:%v", key, first)
+		}
+	}
+	if first[FieldConnReused] != false || second[FieldConnReused] != true || second[FieldConnWasIdle] != true {
+		t.Fatalf("expected the second request to reuse an idle conn,got:%v,%v", first, second)
+	}
+	if _, exist := second[FieldTLSMs]; exist {
+		t.Fatalf("expected no handshake on a reused conn,got:%v", second)
+	}
+
+	// 不开启时只记录耗时
+	logs = testkit.CaptureLogs(t)
+	client = &http.Client{Transport: WrapTransport(base, LoggingTransport(false, false))}
+	httpResp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	attrs := logs.Find("got http resp")[0].Attrs
+	if _, ok := attrs[FieldDurationMs].(float64); !ok {
+		t.Fatalf("expected %s,got:%v", FieldDurationMs, attrs)
+	}
+	if _, exist := attrs[FieldConnReused]; exist {
+		t.Fatalf("expected no timings without LogTimings,got:%v", attrs)
+	}
+}
+
+func TestLoggingHandlerDuration(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	server := testkit.NewServer(t, WrapHandler(testkit.Echo(), LoggingHandler(false, false)))
+	httpResp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	attrs := logs.Find("serve http req")[0].Attrs
+	remoteAddr, _ := attrs[FieldRemoteAddr].(string)
+	if _, ok := attrs[FieldDurationMs].(float64); !ok || remoteAddr == "" {
+		t.Fatalf("expected duration and remote addr,got:%v", attrs)
+	}
+}
@@ -0,0 +1,66 @@
+package httpx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+const (
+	maxLogAttrs  = 32
+	RequestIDKey = "X-Request-Id"
+
+	FieldRequestID = "request_id"
+	FieldHTTPRoute = "http_route"
+)
+
+type logAttrsKey struct{}
+
+// AppendLogAttrs 返回带有attrs的新context,client与server的日志都会带上这些属性,最多保留maxLogAttrs个
+func AppendLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev := LogAttrsFromContext(ctx)
+	if len(prev)+len(attrs) > maxLogAttrs {
+		attrs = attrs[:maxLogAttrs-len(prev)]
+	}
+	if len(attrs) == 0 {
+		return ctx
+	}
+	// 复制一份,不修改父context中的属性
+	merged := make([]slog.Attr, 0, len(prev)+len(attrs))
+	merged = append(merged, prev...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, logAttrsKey{}, merged)
+}
+
+// LogAttrsFromContext 返回context中的日志属性
+func LogAttrsFromContext(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger 返回带有logger的新context,client与server的日志使用它输出
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	if logger == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext 返回context中的logger,没有时返回slog.Default()
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+func newRequestID() string {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf[:])
+}
@@ -0,0 +1,125 @@
+package httpx
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestLogAttrsPropagation(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	upstream := testkit.NewServer(t, testkit.Echo())
+	tenant := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := AppendLogAttrs(r.Context(), slog.String("tenant", r.Header.Get("X-Tenant")))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+	server := testkit.NewServer(t, WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := Post(upstream.URL).
+			Tracing(false).
+			WithReq(map[string]string{"data": "hello"}).
+			Do(r.Context()); err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}), LoggingHandler(false, false), tenant))
+
+	httpReq, err := http.NewRequest(http.MethodGet, server.URL+"/orders", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpReq.Header.Set("X-Tenant", "t1")
+	httpReq.Header.Set(RequestIDKey, "req-1")
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+
+	for _, message := range []string{"serve http req", "got http resp"} {
+		logs.AssertField(t, message, "tenant", "t1")
+		logs.AssertField(t, message, FieldRequestID, "req-1")
+		logs.AssertField(t, message, FieldHTTPRoute, "/orders")
+	}
+}
+
+func TestAppendLogAttrs(t *testing.T) {
+	parent := AppendLogAttrs(context.Background(), slog.String("a", "1"))
+	child := AppendLogAttrs(parent, slog.String("b", "2"))
+	if got := len(LogAttrsFromContext(parent)); got != 1 {
+		t.Fatalf("expected parent to keep 1 attr,got:%d", got)
+	}
+	if got := len(LogAttrsFromContext(child)); got != 2 {
+		t.Fatalf("expected child to have 2 attrs,got:%d", got)
+	}
+	ctx := context.Background()
+	for i := 0; i < maxLogAttrs+10; i++ {
+		ctx = AppendLogAttrs(ctx, slog.Int("i", i))
+	}
+	if got := len(LogAttrsFromContext(ctx)); got != maxLogAttrs {
+		t.Fatalf("expected attrs to be capped at %d,got:%d", maxLogAttrs, got)
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	defaultLogs := testkit.CaptureLogs(t)
+	logs := testkit.NewLogCapture()
+	logger := slog.New(logs)
+	upstream := testkit.NewServer(t, testkit.Echo())
+	server := testkit.NewServer(t, WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 沿用LoggingHandlerWith设置的logger
+		if err := Post(upstream.URL).Tracing(false).WithReq(map[string]string{"data": "hello"}).Do(r.Context()); err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}), LoggingHandlerWith(logger, LoggingOptions{RespLevel: slog.LevelWarn})))
+	if err := Get(server.URL).Tracing(false).WithLogger(logger).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(defaultLogs.Records()); got != 0 {
+		t.Fatalf("expected no logs on the default logger,got:%v", defaultLogs.Records())
+	}
+	if got := len(logs.Find("got http resp")); got != 2 {
+		t.Fatalf("expected 2 client logs,got:%d", got)
+	}
+	served := logs.Find("serve http req")
+	if len(served) != 1 || served[0].Level != slog.LevelWarn {
+		t.Fatalf("expected 1 serve log at warn,got:%v", served)
+	}
+
+	logs = testkit.NewLogCapture()
+	client := &http.Client{Transport: WrapTransport(http.DefaultTransport, LoggingTransportWith(slog.New(logs), LoggingOptions{ReqLevel: slog.LevelDebug}))}
+	httpResp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	for message, level := range map[string]slog.Level{"send http req": slog.LevelDebug, "got http resp": slog.LevelInfo} {
+		if records := logs.Find(message); len(records) != 1 || records[0].Level != level {
+			t.Fatalf("expected %s at %s,got:%v", message, level, records)
+		}
+	}
+
+	// 低于handler级别的日志不输出
+	logs = testkit.NewLogCapture()
+	leveled := slog.New(leveledHandler{Handler: logs, level: slog.LevelInfo})
+	client = &http.Client{Transport: WrapTransport(http.DefaultTransport, LoggingTransportWith(leveled, LoggingOptions{ReqLevel: slog.LevelDebug}))}
+	if httpResp, err = client.Get(upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	if len(logs.Find("send http req")) != 0 || len(logs.Find("got http resp")) != 1 {
+		t.Fatalf("expected only the response log,got:%v", logs.Records())
+	}
+}
+
+type leveledHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h leveledHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
@@ -0,0 +1,154 @@
+package httpx
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// 日志字段的标准标识,输出时经过FieldMapper映射
+const (
+	FieldHTTPMethod      = "http_method"
+	FieldHTTPURL         = "http_url"
+	FieldHTTPHost        = "http_host"
+	FieldTraceID         = "traceID"
+	FieldSpanID          = "spanID"
+	FieldReqData         = "req_data"
+	FieldRespData        = "resp_data"
+	FieldReqHeader       = "req_header"
+	FieldRespHeader      = "resp_header"
+	FieldReqTruncated    = "req_truncated"
+	FieldRespTruncated   = "resp_truncated"
+	FieldReqTotalBytes   = "req_total_bytes"
+	FieldRespTotalBytes  = "resp_total_bytes"
+	FieldReqBodyOmitted  = "req_body_omitted"
+	FieldRespBodyOmitted = "resp_body_omitted"
+	FieldRespEvents      = "resp_events"
+	FieldDurationMs      = "duration_ms"
+	FieldRemoteAddr      = "remote_addr"
+	FieldConnReused      = "conn_reused"
+	FieldConnWasIdle     = "conn_was_idle"
+	FieldConnIdleMs      = "conn_idle_ms"
+	FieldDNSMs           = "dns_ms"
+	FieldConnectMs       = "connect_ms"
+	FieldTLSMs           = "tls_ms"
+	FieldStatusCode      = "http_status_code"
+	// FieldServerStatusCode LoggingHandler的状态码,保持原有的字段名
+	FieldServerStatusCode = "statusCode"
+	FieldOutcome          = "outcome"
+	FieldErr              = "err"
+	FieldPriority         = "priority"
+	FieldConcurrencyWait  = "concurrency_wait"
+	FieldRespTransformed  = "resp_transformed"
+	FieldDeprecation      = "deprecation"
+	FieldSunset           = "sunset"
+	FieldDeprecationLink  = "deprecation_link"
+	FieldHeader           = "header"
+	FieldPolicy           = "policy"
+	FieldTLSVersion       = "tls_version"
+	FieldTLSCipher        = "tls_cipher"
+)
+
+// FieldMapper 将标准字段标识映射为输出的字段名
+type FieldMapper func(field string) string
+
+func mapperFromTable(table map[string]string) FieldMapper {
+	return func(field string) string {
+		if name, exist := table[field]; exist {
+			return name
+		}
+		return field
+	}
+}
+
+var (
+	// DefaultFieldMapper 保持原有字段名
+	DefaultFieldMapper FieldMapper = func(field string) string {
+		return field
+	}
+	// ECSFieldMapper Elastic Common Schema
+	ECSFieldMapper = mapperFromTable(map[string]string{
+		FieldHTTPMethod:       "http.request.method",
+		FieldHTTPURL:          "url.full",
+		FieldTraceID:          "trace.id",
+		FieldSpanID:           "span.id",
+		FieldReqData:          "http.request.body.content",
+		FieldRespData:         "http.response.body.content",
+		FieldStatusCode:       "http.response.status_code",
+		FieldServerStatusCode: "http.response.status_code",
+		FieldRemoteAddr:       "client.address",
+		FieldErr:              "error.message",
+	})
+	// OTelFieldMapper OpenTelemetry语义约定
+	OTelFieldMapper = mapperFromTable(map[string]string{
+		FieldHTTPMethod:       "http.request.method",
+		FieldHTTPURL:          "url.full",
+		FieldTraceID:          "trace_id",
+		FieldSpanID:           "span_id",
+		FieldReqData:          "http.request.body",
+		FieldRespData:         "http.response.body",
+		FieldStatusCode:       "http.response.status_code",
+		FieldServerStatusCode: "http.response.status_code",
+		FieldRemoteAddr:       "client.address",
+		FieldErr:              "exception.message",
+	})
+)
+
+var fieldMapper atomic.Pointer[FieldMapper]
+
+// SetFieldMapper 设置没有指定LoggingOptions.FieldMapper的日志使用的FieldMapper,nil恢复默认
+func SetFieldMapper(mapper FieldMapper) {
+	if mapper == nil {
+		mapper = DefaultFieldMapper
+	}
+	fieldMapper.Store(&mapper)
+}
+
+func currentFieldMapper() FieldMapper {
+	if mapper := fieldMapper.Load(); mapper != nil {
+		return *mapper
+	}
+	return DefaultFieldMapper
+}
+
+// logInfo 所有http日志统一从这里输出,kvs中的key经过FieldMapper映射,并带上ctx中的日志属性
+func logInfo(ctx context.Context, msg string, kvs ...interface{}) {
+	logAt(ctx, nil, nil, slog.LevelInfo, msg, kvs...)
+}
+
+func logDebug(ctx context.Context, msg string, kvs ...interface{}) {
+	logAt(ctx, nil, nil, slog.LevelDebug, msg, kvs...)
+}
+
+func logWarn(ctx context.Context, msg string, kvs ...interface{}) {
+	logAt(ctx, nil, nil, slog.LevelWarn, msg, kvs...)
+}
+
+// logAt logger为nil时使用ctx中的logger,mapper为nil时使用SetFieldMapper设置的FieldMapper
+func logAt(ctx context.Context, logger *slog.Logger, mapper FieldMapper, level slog.Level, msg string, kvs ...interface{}) {
+	if logger == nil {
+		logger = LoggerFromContext(ctx)
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	logger.Log(ctx, level, msg, mapFields(ctx, mapper, kvs)...)
+}
+
+func mapFields(ctx context.Context, mapper FieldMapper, kvs []interface{}) []interface{} {
+	if mapper == nil {
+		mapper = currentFieldMapper()
+	}
+	attrs := LogAttrsFromContext(ctx)
+	mapped := make([]interface{}, len(kvs), len(kvs)+len(attrs))
+	copy(mapped, kvs)
+	for i := 0; i+1 < len(mapped); i += 2 {
+		if key, ok := mapped[i].(string); ok {
+			mapped[i] = mapper(key)
+		}
+	}
+	for _, attr := range attrs {
+		mapped = append(mapped, attr)
+	}
+	return mapped
+}
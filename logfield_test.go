@@ -0,0 +1,91 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestFieldMapper(t *testing.T) {
+	tests := []struct {
+		name   string
+		mapper FieldMapper
+		client string
+		server string
+	}{
+		{
+			name:   "default",
+			mapper: DefaultFieldMapper,
+			client: "duration_ms,http_method,http_status_code,http_url,req_data,resp_data,spanID,traceID",
+			server: "duration_ms,http_method,http_route,http_url,remote_addr,req_data,request_id,resp_data,spanID,statusCode,traceID",
+		},
+		{
+			name:   "ecs",
+			mapper: ECSFieldMapper,
+			client: "duration_ms,http.request.body.content,http.request.method,http.response.body.content,http.response.status_code,span.id,trace.id,url.full",
+			server: "client.address,duration_ms,http.request.body.content,http.request.method,http.response.body.content,http.response.status_code,http_route,request_id,span.id,trace.id,url.full",
+		},
+		{
+			name:   "otel",
+			mapper: OTelFieldMapper,
+			client: "duration_ms,http.request.body,http.request.method,http.response.body,http.response.status_code,span_id,trace_id,url.full",
+			server: "client.address,duration_ms,http.request.body,http.request.method,http.response.body,http.response.status_code,http_route,request_id,span_id,trace_id,url.full",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := LoggingOptions{ReqBody: true, RespBody: true, FieldMapper: tt.mapper}
+			server := testkit.NewServer(t, WrapHandler(testkit.Echo(), LoggingHandlerWithOptions(opts)))
+			client := &http.Client{Transport: LoggingTransportWithOptions(opts)(http.DefaultTransport)}
+			logs := testkit.CaptureLogs(t)
+			httpResp, err := client.Post(server.URL, ContentTypeJson, strings.NewReader(`{"data":"hello"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			httpResp.Body.Close()
+			assertLogFields(t, logs, "got http resp", tt.client)
+			assertLogFields(t, logs, "serve http req", tt.server)
+		})
+	}
+}
+
+// SetFieldMapper 作用于没有指定FieldMapper的日志
+func TestSetFieldMapper(t *testing.T) {
+	server := testkit.NewServer(t, WrapHandler(testkit.Echo(), LoggingHandler(false, true)))
+	SetFieldMapper(ECSFieldMapper)
+	t.Cleanup(func() {
+		SetFieldMapper(nil)
+	})
+	logs := testkit.CaptureLogs(t)
+	if err := Get(server.URL).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	logs.AssertField(t, "got http resp", "http.response.status_code", http.StatusOK)
+	logs.AssertField(t, "serve http req", "http.response.status_code", http.StatusOK)
+}
+
+func assertLogFields(t *testing.T, logs *testkit.LogCapture, message, expected string) {
+	t.Helper()
+	records := logs.Find(message)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 log %s,got:%d", message, len(records))
+	}
+	var keys []string
+	for key := range records[0].Attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if got := strings.Join(keys, ","); got != expected {
+		t.Fatalf("expected %s fields:%s,got:%s", message, expected, got)
+	}
+}
+
+func TestFieldMapperPassThrough(t *testing.T) {
+	if got := ECSFieldMapper("custom_field"); got != "custom_field" {
+		t.Fatalf("expected custom_field,got:%s", got)
+	}
+}
@@ -0,0 +1,62 @@
+package httpx
+
+import (
+	"io"
+	"mime/multipart"
+)
+
+const (
+	ContentTypeMultipart = "multipart/form-data"
+)
+
+// multipartPart multipart请求体中的一个字段或文件,按添加顺序写入
+type multipartPart struct {
+	name     string
+	fileName string
+	value    string
+	reader   io.Reader
+}
+
+// multipartBody 通过io.Pipe边编码边发送,第一次Read时才开始编码,返回的body只能读取一次
+func multipartBody(parts []multipartPart) (io.ReadCloser, string) {
+	// 先确定boundary,Content-Type不需要等待编码开始
+	boundaryWriter := multipart.NewWriter(io.Discard)
+	boundary := boundaryWriter.Boundary()
+	body := &lazyPipeReader{write: func(w io.Writer) error {
+		mw := multipart.NewWriter(w)
+		if err := mw.SetBoundary(boundary); err != nil {
+			return err
+		}
+		return writeMultipart(mw, parts)
+	}}
+	return body, boundaryWriter.FormDataContentType()
+}
+
+// oneShotMultipart 含有文件的multipart请求体只能发送一次
+func oneShotMultipart(parts []multipartPart) bool {
+	for _, part := range parts {
+		if part.reader != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func writeMultipart(mw *multipart.Writer, parts []multipartPart) error {
+	for _, part := range parts {
+		if part.reader == nil {
+			if err := mw.WriteField(part.name, part.value); err != nil {
+				return err
+			}
+			continue
+		}
+		w, err := mw.CreateFormFile(part.name, part.fileName)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, part.reader); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
@@ -0,0 +1,156 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+// ErrReqStreamConsumed WithReqStream的迭代器已经被之前的请求读取,不能再次发送
+var ErrReqStreamConsumed = errors.New("WithReqStream body can only be sent once")
+
+// ndjsonBody 逐行编码的请求体
+type ndjsonBody struct {
+	// newIter 返回一个新的迭代器,replayable为false时只能调用一次
+	newIter    func() func() (interface{}, bool)
+	replayable bool
+	codec      Codec
+	// consumed 不可重放时newIter已被使用
+	consumed int32
+}
+
+func newSliceNDJSONBody(items interface{}, lineCodec Codec) (*ndjsonBody, error) {
+	value := reflect.ValueOf(items)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected slice items,got:%T", items)
+	}
+	return &ndjsonBody{
+		newIter: func() func() (interface{}, bool) {
+			idx := 0
+			return func() (interface{}, bool) {
+				if idx >= value.Len() {
+					return nil, false
+				}
+				item := value.Index(idx).Interface()
+				idx++
+				return item, true
+			}
+		},
+		replayable: true,
+		codec:      lineCodec,
+	}, nil
+}
+
+func newStreamNDJSONBody(next func() (interface{}, bool), lineCodec Codec) *ndjsonBody {
+	return &ndjsonBody{
+		newIter: func() func() (interface{}, bool) {
+			return next
+		},
+		codec: lineCodec,
+	}
+}
+
+// reader 返回按需编码的body,第一次Read时才开始迭代;不可重放的body第二次调用时返回ErrReqStreamConsumed
+func (n *ndjsonBody) reader() (io.ReadCloser, error) {
+	if !n.replayable && !atomic.CompareAndSwapInt32(&n.consumed, 0, 1) {
+		return nil, ErrReqStreamConsumed
+	}
+	return &lazyPipeReader{
+		write: func(w io.Writer) error {
+			next := n.newIter()
+			for {
+				item, ok := next()
+				if !ok {
+					return nil
+				}
+				data, err := n.codec.Encode(item)
+				if err != nil {
+					return err
+				}
+				if bytes.IndexByte(data, '\n') >= 0 {
+					return errors.New("ndjson item must be encoded in a single line")
+				}
+				if _, err := w.Write(append(data, '\n')); err != nil {
+					return err
+				}
+			}
+		},
+	}, nil
+}
+
+// getBody 可重放时返回GetBody,否则返回nil,禁止重试
+func (n *ndjsonBody) getBody() func() (io.ReadCloser, error) {
+	if !n.replayable {
+		slog.Info("ndjson request body is not replayable, retries disabled")
+		return nil
+	}
+	return n.reader
+}
+
+type lazyPipeReader struct {
+	once  sync.Once
+	write func(io.Writer) error
+	pr    *io.PipeReader
+	pw    *io.PipeWriter
+}
+
+func (r *lazyPipeReader) start() {
+	r.once.Do(func() {
+		r.pr, r.pw = io.Pipe()
+		go func() {
+			r.pw.CloseWithError(r.write(r.pw))
+		}()
+	})
+}
+
+func (r *lazyPipeReader) Read(p []byte) (int, error) {
+	r.start()
+	return r.pr.Read(p)
+}
+
+func (r *lazyPipeReader) Close() error {
+	started := true
+	r.once.Do(func() {
+		started = false
+	})
+	if !started {
+		return nil
+	}
+	return r.pr.Close()
+}
+
+// decodeNDJSON 将每一行解码为obj(指向slice的指针)的一个元素
+func decodeNDJSON(codec Codec, r io.Reader, obj interface{}) error {
+	value := reflect.ValueOf(obj)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("expected pointer to slice,got:%T", obj)
+	}
+	slice := value.Elem()
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) != 0 {
+			elem := reflect.New(slice.Type().Elem())
+			if err := codec.Decode(bytes.NewReader(line), elem.Interface()); err != nil {
+				return err
+			}
+			slice.Set(reflect.Append(slice, elem.Elem()))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+type ndjsonEvent struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestWithReqStream(t *testing.T) {
+	const total = 50000
+	var produced int64
+	var producedAtFirstLine int64
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(ContentTypeKey); got != ContentTypeNDJSON {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		scanner := bufio.NewScanner(r.Body)
+		lines := 0
+		for scanner.Scan() {
+			if lines == 0 {
+				atomic.StoreInt64(&producedAtFirstLine, atomic.LoadInt64(&produced))
+			}
+			event := &ndjsonEvent{}
+			if err := json.Unmarshal(scanner.Bytes(), event); err != nil || event.ID != lines {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			lines++
+		}
+		json.NewEncoder(w).Encode(map[string]int{"lines": lines})
+	}))
+
+	next := func() (interface{}, bool) {
+		n := atomic.LoadInt64(&produced)
+		if n >= total {
+			return nil, false
+		}
+		atomic.AddInt64(&produced, 1)
+		return &ndjsonEvent{ID: int(n), Name: "event"}, true
+	}
+	gotResp := map[string]int{}
+	if err := Post(server.URL).
+		WithReqStream(next).
+		WithResp(&gotResp).
+		Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if gotResp["lines"] != total {
+		t.Fatalf("expected lines:%d,got:%d", total, gotResp["lines"])
+	}
+	if got := atomic.LoadInt64(&producedAtFirstLine); got >= total {
+		t.Fatalf("expected body to be streamed,produced %d items before first line arrived", got)
+	}
+}
+
+func TestWithReqStreamOnce(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(data))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	items := []interface{}{map[string]int{"a": 1}, map[string]int{"a": 2}}
+	next := func() (interface{}, bool) {
+		if len(items) == 0 {
+			return nil, false
+		}
+		item := items[0]
+		items = items[1:]
+		return item, true
+	}
+	b := Put(server.URL).WithReqStream(next).ResiliencePolicy(ResiliencePolicy{Retries: 2, RetryBackoff: PolicyDuration(time.Millisecond)})
+	if err := b.Do(context.Background()); err == nil {
+		t.Fatal("expected 503 not to be retried")
+	}
+	// 迭代器已经读完,再次发送会得到空的body
+	if err := b.Do(context.Background()); !errors.Is(err, ErrReqStreamConsumed) {
+		t.Fatalf("expected ErrReqStreamConsumed,got:%v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 || bodies[0] != "{\"a\":1}\n{\"a\":2}\n" {
+		t.Fatalf("expected single request with full body,got:%q", bodies)
+	}
+}
+
+func TestWithReqSlice(t *testing.T) {
+	items := []ndjsonEvent{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+	httpReq, err := WithReqSlice(items, nil).Post("http://localhost").BuildHTTPReq(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if httpReq.GetBody == nil {
+		t.Fatal("expected GetBody for slice body")
+	}
+	expected := "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"b\"}\n"
+	for i := 0; i < 2; i++ {
+		body, err := httpReq.GetBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Fatalf("expected body:%s,got:%s", expected, data)
+		}
+	}
+}
+
+func TestHandlerNDJSON(t *testing.T) {
+	server := testkit.NewServer(t, JsonHandler(func(ctx context.Context, events []ndjsonEvent) (int, error) {
+		return len(events), nil
+	}))
+	var got int
+	if err := Post(server.URL).
+		WithReqSlice([]ndjsonEvent{{ID: 1}, {ID: 2}, {ID: 3}}, nil).
+		WithResp(&got).
+		Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got != 3 {
+		t.Fatalf("expected 3 events,got:%d", got)
+	}
+}
@@ -0,0 +1,96 @@
+package httpx
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+)
+
+// Outcome 描述请求出错时是否已经发送到服务端
+type Outcome int
+
+const (
+	// OutcomeUnknown 无法判断
+	OutcomeUnknown Outcome = iota
+	// OutcomeNotSent 请求未发出(dns/dial/tls失败),可以安全重试
+	OutcomeNotSent
+	// OutcomeSentUnknown 请求已写出但未收到响应,服务端可能已经处理
+	OutcomeSentUnknown
+	// OutcomeReceived 已收到响应,但状态码或解码检查失败
+	OutcomeReceived
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeNotSent:
+		return "not_sent"
+	case OutcomeSentUnknown:
+		return "sent_unknown"
+	case OutcomeReceived:
+		return "received"
+	default:
+		return "unknown"
+	}
+}
+
+// OutcomeError 带有Outcome的错误
+type OutcomeError struct {
+	Outcome Outcome
+	Err     error
+}
+
+func (e *OutcomeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OutcomeError) Unwrap() error {
+	return e.Err
+}
+
+// OutcomeFromError 获取错误对应的Outcome
+func OutcomeFromError(err error) Outcome {
+	var outcomeErr *OutcomeError
+	if errors.As(err, &outcomeErr) {
+		return outcomeErr.Outcome
+	}
+	return OutcomeUnknown
+}
+
+func withOutcome(err error, outcome Outcome) error {
+	if err == nil {
+		return nil
+	}
+	var outcomeErr *OutcomeError
+	if errors.As(err, &outcomeErr) {
+		return err
+	}
+	return &OutcomeError{Outcome: outcome, Err: err}
+}
+
+type outcomeTracker struct {
+	wrote int32
+}
+
+// trackOutcome 通过httptrace记录请求是否已经写出
+func trackOutcome(httpReq *http.Request) (*http.Request, *outcomeTracker) {
+	tracker := &outcomeTracker{}
+	trace := &httptrace.ClientTrace{
+		WroteHeaders: func() {
+			atomic.StoreInt32(&tracker.wrote, 1)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			atomic.StoreInt32(&tracker.wrote, 1)
+		},
+	}
+	ctx := httptrace.WithClientTrace(httpReq.Context(), trace)
+	return httpReq.WithContext(ctx), tracker
+}
+
+// classify 为RoundTrip返回的错误标记Outcome
+func (t *outcomeTracker) classify(err error) error {
+	if atomic.LoadInt32(&t.wrote) == 1 {
+		return withOutcome(err, OutcomeSentUnknown)
+	}
+	return withOutcome(err, OutcomeNotSent)
+}
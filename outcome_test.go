@@ -0,0 +1,56 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestOutcomeFromError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedURL := "http://" + ln.Addr().String()
+	ln.Close()
+
+	hangup := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	failing := testkit.NewServer(t, testkit.StatusSequence(http.StatusInternalServerError))
+	garbage := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+
+	tests := []struct {
+		name     string
+		url      string
+		expected Outcome
+	}{
+		{name: "dial failure", url: closedURL, expected: OutcomeNotSent},
+		{name: "connection dropped", url: hangup.URL, expected: OutcomeSentUnknown},
+		{name: "unexpected status", url: failing.URL, expected: OutcomeReceived},
+		{name: "decode failure", url: garbage.URL, expected: OutcomeReceived},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Post(tt.url).
+				WithReq(map[string]string{"data": "hello"}).
+				WithResp(&map[string]string{}).
+				Do(context.Background())
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if got := OutcomeFromError(err); got != tt.expected {
+				t.Fatalf("expected outcome:%s,got:%s,err:%v", tt.expected, got, err)
+			}
+		})
+	}
+}
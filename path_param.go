@@ -0,0 +1,40 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	stdurl "net/url"
+	"strings"
+)
+
+// ErrMissingPathParam path中的{key}占位符没有通过WithPathParam设置
+var ErrMissingPathParam = errors.New("missing path param")
+
+// expandPathParams 将path中的{key}替换为PathEscape后的值,没有设置的占位符返回ErrMissingPathParam
+func expandPathParams(path string, params map[string]string) (string, error) {
+	if !strings.Contains(path, "{") {
+		return path, nil
+	}
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(path[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		key := path[start+1 : end]
+		value, exist := params[key]
+		if !exist {
+			return "", fmt.Errorf("%w:%s", ErrMissingPathParam, key)
+		}
+		sb.WriteString(path[:start])
+		sb.WriteString(stdurl.PathEscape(value))
+		path = path[end+1:]
+	}
+	sb.WriteString(path)
+	return sb.String(), nil
+}
@@ -0,0 +1,212 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	stdurl "net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyDuration 配置文件中以 "300ms" 形式书写的时长
+type PolicyDuration time.Duration
+
+func (d *PolicyDuration) UnmarshalYAML(value *yaml.Node) error {
+	duration, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return err
+	}
+	*d = PolicyDuration(duration)
+	return nil
+}
+
+// ResiliencePolicy 某个下游的重试与超时配置
+type ResiliencePolicy struct {
+	// Retries 失败后的重试次数,只重试未发出的请求,以及幂等请求的5xx、429和发出后未收到响应
+	Retries int `yaml:"retries"`
+	// RetryBackoff 两次尝试之间的等待时间
+	RetryBackoff PolicyDuration `yaml:"retry_backoff"`
+	// AttemptTimeout 单次尝试的超时,Builder设置了Timeout时以Builder为准
+	AttemptTimeout PolicyDuration `yaml:"attempt_timeout"`
+	// OverallTimeout 包括重试在内的整体超时
+	OverallTimeout PolicyDuration `yaml:"overall_timeout"`
+}
+
+func (p ResiliencePolicy) String() string {
+	return fmt.Sprintf("retries=%d,retry_backoff=%s,attempt_timeout=%s,overall_timeout=%s",
+		p.Retries, time.Duration(p.RetryBackoff), time.Duration(p.AttemptTimeout), time.Duration(p.OverallTimeout))
+}
+
+func (p ResiliencePolicy) validate() error {
+	switch {
+	case p.Retries < 0:
+		return errors.New("retries must not be negative")
+	case p.RetryBackoff < 0, p.AttemptTimeout < 0, p.OverallTimeout < 0:
+		return errors.New("durations must not be negative")
+	}
+	return nil
+}
+
+// HostPolicy host模式对应的策略
+// Host可以是精确的host(可带端口)、*.example.com形式的子域名通配,或者*匹配所有host
+type HostPolicy struct {
+	Host             string `yaml:"host"`
+	ResiliencePolicy `yaml:",inline"`
+}
+
+// PolicySet 按目标host选择ResiliencePolicy,精确匹配优先,其次是最长的通配,最后是*
+type PolicySet struct {
+	Policies []HostPolicy `yaml:"policies"`
+}
+
+// LoadPolicySet 从json或yaml文档加载PolicySet
+func LoadPolicySet(r io.Reader) (*PolicySet, error) {
+	var ps PolicySet
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&ps); err != nil && err != io.EOF {
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(ps.Policies))
+	for idx, policy := range ps.Policies {
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("policies[%d](%s):%w", idx, policy.Host, err)
+		}
+		host := strings.ToLower(policy.Host)
+		if _, exist := seen[host]; exist {
+			return nil, fmt.Errorf("policies[%d](%s):duplicate host", idx, policy.Host)
+		}
+		seen[host] = struct{}{}
+	}
+	return &ps, nil
+}
+
+func (p HostPolicy) validate() error {
+	host := p.Host
+	if host == "" {
+		return errors.New("host must not be empty")
+	}
+	if strings.Contains(strings.TrimPrefix(host, "*."), "*") && host != "*" {
+		return errors.New("only a leading *. wildcard is supported")
+	}
+	return p.ResiliencePolicy.validate()
+}
+
+// Match 返回addr(host或host:port)对应的策略
+func (ps *PolicySet) Match(addr string) (HostPolicy, bool) {
+	if ps == nil {
+		return HostPolicy{}, false
+	}
+	addr = strings.ToLower(addr)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	var best HostPolicy
+	bestRank := -1
+	for _, policy := range ps.Policies {
+		pattern := strings.ToLower(policy.Host)
+		rank := -1
+		switch {
+		case pattern == addr:
+			rank = 1 << 20
+		case pattern == host:
+			rank = 1 << 19
+		case strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]):
+			rank = len(pattern)
+		case pattern == "*":
+			rank = 0
+		}
+		if rank > bestRank {
+			best, bestRank = policy, rank
+		}
+	}
+	return best, bestRank >= 0
+}
+
+var defaultPolicySet atomic.Pointer[PolicySet]
+
+// SwapPolicySet 原子地替换默认的PolicySet,之后的请求使用新的策略,返回旧的PolicySet
+func SwapPolicySet(ps *PolicySet) *PolicySet {
+	return defaultPolicySet.Swap(ps)
+}
+
+// effectivePolicy Builder显式设置的策略优先,其次按host匹配默认的PolicySet
+func (b *builder) effectivePolicy() (string, ResiliencePolicy, bool) {
+	if b.policy != nil {
+		return "builder", *b.policy, true
+	}
+	urlObj, err := stdurl.Parse(b.baseURL + b.path)
+	if err != nil {
+		return "", ResiliencePolicy{}, false
+	}
+	policy, ok := defaultPolicySet.Load().Match(urlObj.Host)
+	return policy.Host, policy.ResiliencePolicy, ok
+}
+
+// doWithPolicy 按策略重试DoWithClient
+func (b *builder) doWithPolicy(ctx context.Context, source string, policy ResiliencePolicy) error {
+	logDebug(ctx, "resilience policy",
+		FieldHTTPMethod, b.method,
+		FieldHTTPURL, b.baseURL+b.path,
+		FieldPolicy, source+":"+policy.String(),
+	)
+	if policy.OverallTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(policy.OverallTimeout))
+		defer cancel()
+	}
+	attemptBuilder := b
+	if b.timeout == 0 && policy.AttemptTimeout > 0 {
+		attemptBuilder = b.cloneTransport()
+		attemptBuilder.timeout = time.Duration(policy.AttemptTimeout)
+		// 重试复用ctx中这次Do生成的Idempotency-Key
+		attemptBuilder.idempotencyKey = b.idempotencyKey
+	}
+	_, client := attemptBuilder.builtClient()
+	for attempt := 0; ; attempt++ {
+		err := attemptBuilder.doAttempt(ctx, client, attempt)
+		if err == nil || attempt >= policy.Retries || ctx.Err() != nil {
+			return err
+		}
+		if !b.retryable(err) {
+			if skippedByMethod(b.idempotent(), err) {
+				return &ErrRetrySkipped{Method: b.effectiveMethod(), Err: err}
+			}
+			return err
+		}
+		wait, waitErr := retryWait(ctx, statusErrHeader(err), time.Duration(policy.RetryBackoff), b.effectiveMaxRetryAfter(), err)
+		if waitErr != nil {
+			return waitErr
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// retryable 重试时重新构造请求,只能读取一次的请求体已经被消耗,不重试
+func (b *builder) retryable(err error) bool {
+	return !b.oneShotBody() && retryableOutcome(b.idempotent(), err)
+}
+
+// idempotent 带Idempotency-Key的请求由服务端去重,RetryNonIdempotent时由调用方保证
+func (b *builder) idempotent() bool {
+	return b.retryNonIdempotent || isIdempotent(b.effectiveMethod()) || b.idempotencyKey != nil
+}
+
+func (b *builder) effectiveMethod() string {
+	if b.method == "" {
+		return http.MethodGet
+	}
+	return b.method
+}
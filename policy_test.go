@@ -0,0 +1,99 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestLoadPolicySet(t *testing.T) {
+	ps, err := LoadPolicySet(strings.NewReader(`
+policies:
+  - host: "*"
+    retries: 1
+  - host: "*.example.com"
+    retries: 2
+  - host: "*.payments.example.com"
+    retries: 3
+    attempt_timeout: 300ms
+  - host: api.payments.example.com:8443
+    retries: 4
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr    string
+		host    string
+		retries int
+	}{
+		{addr: "api.payments.example.com:8443", host: "api.payments.example.com:8443", retries: 4},
+		{addr: "api.payments.example.com", host: "*.payments.example.com", retries: 3},
+		{addr: "API.Example.com", host: "*.example.com", retries: 2},
+		{addr: "example.org", host: "*", retries: 1},
+	}
+	for _, tt := range tests {
+		policy, ok := ps.Match(tt.addr)
+		if !ok || policy.Host != tt.host || policy.Retries != tt.retries {
+			t.Fatalf("expected %s to match %s,got:%+v", tt.addr, tt.host, policy)
+		}
+	}
+	if policy, _ := ps.Match("api.payments.example.com"); time.Duration(policy.AttemptTimeout) != time.Millisecond*300 {
+		t.Fatalf("expected attempt timeout 300ms,got:%s", time.Duration(policy.AttemptTimeout))
+	}
+
+	jsonPS, err := LoadPolicySet(strings.NewReader(`{"policies":[{"host":"a","retries":2,"overall_timeout":"1s"}]}`))
+	if err != nil || jsonPS.Policies[0].Retries != 2 || time.Duration(jsonPS.Policies[0].OverallTimeout) != time.Second {
+		t.Fatalf("unexpected json policy set:%+v,%v", jsonPS, err)
+	}
+
+	for doc, expected := range map[string]string{
+		`{"policies":[{"host":"a"},{"host":"b","retries":-1}]}`: "policies[1](b)",
+		`{"policies":[{"host":"a"},{"host":"A"}]}`:              "policies[1](A):duplicate host",
+		`{"policies":[{"host":"a.*.com"}]}`:                     "policies[0](a.*.com)",
+		`{"policies":[{"host":"a","retry":1}]}`:                 "retry",
+	} {
+		if _, err := LoadPolicySet(strings.NewReader(doc)); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected error containing %s,got:%v", expected, err)
+		}
+	}
+}
+
+func TestPolicySetSwap(t *testing.T) {
+	prev := SwapPolicySet(nil)
+	t.Cleanup(func() {
+		SwapPolicySet(prev)
+	})
+	server := testkit.NewServer(t, testkit.StatusSequence(
+		http.StatusServiceUnavailable,
+		http.StatusServiceUnavailable, http.StatusOK,
+		http.StatusServiceUnavailable,
+		http.StatusServiceUnavailable, http.StatusOK,
+	))
+	b := Get(server.URL).Tracing(false)
+
+	if err := b.Do(context.Background()); err == nil {
+		t.Fatal("expected 503 without policy")
+	}
+
+	SwapPolicySet(&PolicySet{Policies: []HostPolicy{{Host: "127.0.0.1", ResiliencePolicy: ResiliencePolicy{Retries: 1}}}})
+	if err := b.Do(context.Background()); err != nil {
+		t.Fatalf("expected retry after swap,got:%v", err)
+	}
+	if got := b.Describe(); !strings.Contains(got, "policy=127.0.0.1(retries=1") {
+		t.Fatalf("expected policy in describe,got:%s", got)
+	}
+
+	// Builder上的策略优先
+	if err := b.ResiliencePolicy(ResiliencePolicy{}).Do(context.Background()); err == nil {
+		t.Fatal("expected builder policy to disable retries")
+	}
+	// 非幂等请求收到5xx不重试
+	if err := Post(server.URL).Tracing(false).Do(context.Background()); err == nil {
+		t.Fatal("expected POST not to be retried")
+	}
+}
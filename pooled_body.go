@@ -0,0 +1,131 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// pooledBodyThreshold 长度已知且不超过该值的响应体读入池化的buffer
+	pooledBodyThreshold = 64 << 10
+)
+
+var bodyBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// pooledBody 读入池化buffer的响应体,Close后buffer归还,不能再使用Bytes
+type pooledBody struct {
+	*bytes.Reader
+	buf    *bytes.Buffer
+	closed int32
+}
+
+func (b *pooledBody) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+func (b *pooledBody) Close() error {
+	if atomic.CompareAndSwapInt32(&b.closed, 0, 1) {
+		buf := b.buf
+		b.Reader = bytes.NewReader(nil)
+		if buf.Cap() <= pooledBodyThreshold*2 {
+			buf.Reset()
+			bodyBufferPool.Put(buf)
+		}
+	}
+	return nil
+}
+
+func (b *pooledBody) isClosed() bool {
+	return atomic.LoadInt32(&b.closed) == 1
+}
+
+// poolable 只有长度已知的小响应体走池化路径
+func poolable(contentLength int64) bool {
+	return contentLength >= 0 && contentLength <= pooledBodyThreshold
+}
+
+// readPooledBody 将src读入池化buffer并关闭src
+func readPooledBody(src io.ReadCloser, contentLength int64) (*pooledBody, error) {
+	defer src.Close()
+	buf := bodyBufferPool.Get().(*bytes.Buffer)
+	buf.Grow(int(contentLength))
+	if _, err := buf.ReadFrom(src); err != nil {
+		buf.Reset()
+		bodyBufferPool.Put(buf)
+		return nil, err
+	}
+	return &pooledBody{Reader: bytes.NewReader(buf.Bytes()), buf: buf}, nil
+}
+
+// sharedRespBody 让LoggingTransport读入的响应体在解码时复用,避免重复读取
+type sharedRespBody struct {
+	sync.Mutex
+	body *pooledBody
+}
+
+type sharedRespBodyKey struct{}
+
+func withSharedRespBody(ctx context.Context) (context.Context, *sharedRespBody) {
+	shared := &sharedRespBody{}
+	return context.WithValue(ctx, sharedRespBodyKey{}, shared), shared
+}
+
+func sharedRespBodyFromContext(ctx context.Context) *sharedRespBody {
+	shared, _ := ctx.Value(sharedRespBodyKey{}).(*sharedRespBody)
+	return shared
+}
+
+func (s *sharedRespBody) set(body *pooledBody) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.body = body
+}
+
+// get 返回最后一个未关闭的响应体,重定向中间的响应体已经被关闭
+func (s *sharedRespBody) get() *pooledBody {
+	if s == nil {
+		return nil
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.body == nil || s.body.isClosed() {
+		return nil
+	}
+	return s.body
+}
+
+// decodeJSON 一次性解码,出错时退回json.Decoder以保持相同的行为(忽略结尾的多余数据、相同的错误)
+func decodeJSON(data []byte, obj interface{}) error {
+	if err := json.Unmarshal(data, obj); err != nil {
+		return json.NewDecoder(bytes.NewReader(data)).Decode(obj)
+	}
+	return nil
+}
+
+// decodeSmallJSON 小响应体直接从池化buffer中解码,大的或长度未知的响应体使用流式解码
+func decodeSmallJSON(httpResp *http.Response, shared *sharedRespBody, obj interface{}) error {
+	if body := shared.get(); body != nil {
+		return decodeJSON(body.Bytes(), obj)
+	}
+	if !poolable(httpResp.ContentLength) {
+		return defaultCodec.Decode(httpResp.Body, obj)
+	}
+	body, err := readPooledBody(io.NopCloser(httpResp.Body), httpResp.ContentLength)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return decodeJSON(body.Bytes(), obj)
+}
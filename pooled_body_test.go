@@ -0,0 +1,124 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+type pooledPayload struct {
+	ID   string `json:"id"`
+	Data string `json:"data"`
+}
+
+// payloadOfSize 返回序列化后恰好size字节的json
+func payloadOfSize(id string, size int) []byte {
+	empty, _ := json.Marshal(pooledPayload{ID: id})
+	data, _ := json.Marshal(pooledPayload{ID: id, Data: strings.Repeat("a", size-len(empty))})
+	return data
+}
+
+func TestPooledBodyThreshold(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		data := payloadOfSize(r.URL.Query().Get("id"), size)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	}))
+	for _, size := range []int{pooledBodyThreshold - 1, pooledBodyThreshold, pooledBodyThreshold + 1} {
+		for _, logging := range []bool{true, false} {
+			var resp pooledPayload
+			err := Get(server.URL).
+				WithQueryString("size", strconv.Itoa(size)).
+				WithQueryString("id", "x").
+				Logging(false, logging).
+				WithResp(&resp).
+				Do(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := json.Marshal(resp); len(data) != size || resp.ID != "x" {
+				t.Fatalf("expected %d bytes with id x,got:%d,%s", size, len(data), resp.ID)
+			}
+		}
+	}
+}
+
+func TestPooledBodyConcurrentReuse(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payloadOfSize(r.URL.Query().Get("id"), 1024))
+	}))
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := strconv.Itoa(i)
+			for j := 0; j < 10; j++ {
+				var resp pooledPayload
+				if err := Get(server.URL).WithQueryString("id", id).WithResp(&resp).Do(context.Background()); err != nil {
+					errs <- err
+					return
+				}
+				if resp.ID != id {
+					errs <- fmt.Errorf("expected id:%s,got:%s", id, resp.ID)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeJSONMatchesDecoder(t *testing.T) {
+	for _, body := range []string{`{"id":"a"} trailing`, ``, `{"id":1}`, `{"id":"a"}`} {
+		var fast, slow pooledPayload
+		fastErr := decodeJSON([]byte(body), &fast)
+		slowErr := json.NewDecoder(strings.NewReader(body)).Decode(&slow)
+		if fmt.Sprint(fastErr) != fmt.Sprint(slowErr) || fast != slow {
+			t.Fatalf("expected %v,%+v,got:%v,%+v", slowErr, slow, fastErr, fast)
+		}
+	}
+}
+
+func BenchmarkDecodeSmallJSON(b *testing.B) {
+	data := payloadOfSize("bench", 2048)
+	b.Run("decoder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var resp pooledPayload
+			body, _, _ := DrainBody(io.NopCloser(bytes.NewReader(data)))
+			if err := defaultCodec.Decode(bytes.NewReader(body), &resp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var resp pooledPayload
+			body, err := readPooledBody(io.NopCloser(bytes.NewReader(data)), int64(len(data)))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := decodeJSON(body.Bytes(), &resp); err != nil {
+				b.Fatal(err)
+			}
+			body.Close()
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package httpx
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContextWithTraceID 返回使用traceID作为远端span context的新context,没有span时PropagationTransport传播它,
+// 日志也会记录它;spanID随机生成
+func ContextWithTraceID(ctx context.Context, traceID trace.TraceID) context.Context {
+	var spanID trace.SpanID
+	rand.Read(spanID[:])
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	if !spanContext.IsValid() {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, spanContext)
+}
+
+// PropagationTransport 只把context中的span context注入请求头,不创建span;
+// propagator为nil时使用W3C traceparent
+func PropagationTransport(propagator propagation.TextMapPropagator) TransportWrapper {
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+	return NamedWrapper("propagation", "", func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			httpReq = httpReq.Clone(httpReq.Context())
+			propagator.Inject(httpReq.Context(), propagation.HeaderCarrier(httpReq.Header))
+			return next.RoundTrip(httpReq)
+		})
+	})
+}
+
+// requestSpanContext context中没有span时,从请求的traceparent头中解析
+func requestSpanContext(httpReq *http.Request) trace.SpanContext {
+	spanContext := trace.SpanContextFromContext(httpReq.Context())
+	if spanContext.IsValid() || httpReq.Header.Get("Traceparent") == "" {
+		return spanContext
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.HeaderCarrier(httpReq.Header))
+	return trace.SpanContextFromContext(ctx)
+}
@@ -0,0 +1,59 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPropagation(t *testing.T) {
+	exporter := testkit.InstallTracer(t)
+	var got string
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Traceparent")
+	}))
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+
+	logs := testkit.CaptureLogs(t)
+	ctx := ContextWithTraceID(context.Background(), traceID)
+	if err := Get(server.URL).Tracing(false).Propagation(true).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "00-"+traceID.String()+"-") {
+		t.Fatalf("expected traceparent with trace id %s,got:%s", traceID, got)
+	}
+	logs.AssertField(t, "send http req", FieldTraceID, traceID.String())
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "parent")
+	if err := Get(server.URL).Tracing(false).Propagation(true).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	span.End()
+	if !strings.HasPrefix(got, "00-"+span.SpanContext().TraceID().String()+"-"+span.SpanContext().SpanID().String()) {
+		t.Fatalf("expected traceparent of the parent span,got:%s", got)
+	}
+	if spans := exporter.GetSpans(); len(spans) != 1 {
+		t.Fatalf("expected only the parent span,got:%d", len(spans))
+	}
+
+	if err := Get(server.URL).Tracing(false).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got != "" {
+		t.Fatalf("expected no traceparent without propagation,got:%s", got)
+	}
+
+	// 没有span时日志从traceparent头中取trace id
+	logs = testkit.CaptureLogs(t)
+	header := "00-" + traceID.String() + "-00f067aa0ba902b7-01"
+	if err := Get(server.URL).Tracing(false).WithHeader("traceparent", header).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	logs.AssertField(t, "got http resp", FieldTraceID, traceID.String())
+	logs.AssertField(t, "got http resp", FieldSpanID, "00f067aa0ba902b7")
+}
@@ -0,0 +1,199 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	proxyBufferSize = 32 << 10
+)
+
+// hopByHopHeaders 只对单跳有效,代理时需要去掉
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ProxyOptions ProxyHandler的配置
+type ProxyOptions struct {
+	// RewriteHeader 发往上游前修改请求头
+	RewriteHeader func(header http.Header)
+	// RespHeaders 允许透传给客户端的响应头,为空时透传全部
+	RespHeaders []string
+	// Timeout 整个代理过程(包括转发响应body)的超时
+	Timeout time.Duration
+	// ConnectRetries 连接失败(请求未发出)时的重试次数,只对幂等请求生效
+	ConnectRetries int
+}
+
+// ProxyHandler 将请求通过target返回的Builder流式转发到上游,并流式返回响应
+func ProxyHandler(target func(*http.Request) Builder, opts ProxyOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+		b, ok := target(r).(*builder)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		httpResp, err := doProxy(ctx, b.proxyBuilder(r, opts), r.Method, opts.ConnectRetries)
+		if err != nil {
+			logInfo(ctx, "proxy http req failed",
+				FieldHTTPMethod, r.Method,
+				FieldHTTPURL, b.baseURL+b.path,
+				FieldErr, err,
+			)
+			if errors.Is(err, context.DeadlineExceeded) {
+				w.WriteHeader(http.StatusGatewayTimeout)
+				return
+			}
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer httpResp.Body.Close()
+
+		header := w.Header()
+		respHeader := httpResp.Header.Clone()
+		removeHopByHopHeaders(respHeader)
+		if len(opts.RespHeaders) == 0 {
+			for key, values := range respHeader {
+				header[key] = values
+			}
+		} else {
+			for _, key := range opts.RespHeaders {
+				key = textproto.CanonicalMIMEHeaderKey(key)
+				if values, exist := respHeader[key]; exist {
+					header[key] = values
+				}
+			}
+		}
+		w.WriteHeader(httpResp.StatusCode)
+		if err := copyFlush(w, httpResp.Body); err != nil {
+			// 上游或客户端中途断开,中断与客户端的连接
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// proxyBuilder 基于Builder与入站请求构造通过DoRaw发出的上游请求:body不做缓冲,透传所有状态码与重定向,
+// 入站请求的query追加在Builder的query之后
+func (b *builder) proxyBuilder(r *http.Request, opts ProxyOptions) Builder {
+	proxyBuilder := b.cloneTransport()
+	proxyBuilder.req = nil
+	if proxyBuilder.method == "" {
+		proxyBuilder.method = r.Method
+	}
+	proxyBuilder.proxied = true
+	proxyBuilder.anyStatus = true
+	proxyBuilder.loggingReq, proxyBuilder.loggingResp = false, false
+	return proxyBuilder.WithRequestEditor(func(httpReq *http.Request) error {
+		header := r.Header.Clone()
+		removeHopByHopHeaders(header)
+		for key, values := range httpReq.Header {
+			// Content-Type以入站请求为准,除非Builder显式设置
+			if key == ContentTypeKey && b.header.Get(ContentTypeKey) == "" {
+				continue
+			}
+			header[key] = values
+		}
+		if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			if prior := header.Get("X-Forwarded-For"); prior != "" {
+				clientIP = prior + ", " + clientIP
+			}
+			header.Set("X-Forwarded-For", clientIP)
+		}
+		if opts.RewriteHeader != nil {
+			opts.RewriteHeader(header)
+		}
+		httpReq.Header = header
+		if r.URL.RawQuery != "" {
+			if httpReq.URL.RawQuery != "" {
+				httpReq.URL.RawQuery += "&"
+			}
+			httpReq.URL.RawQuery += r.URL.RawQuery
+		}
+		if r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0 {
+			// 入站body由server负责关闭,避免transport出错时将其关闭导致无法重试
+			httpReq.Body = io.NopCloser(r.Body)
+			httpReq.ContentLength = r.ContentLength
+			httpReq.GetBody = nil
+		}
+		return nil
+	})
+}
+
+// doProxy 请求未发出时重试幂等请求
+func doProxy(ctx context.Context, b Builder, method string, retries int) (*http.Response, error) {
+	idempotent := isIdempotent(method)
+	for attempt := 0; ; attempt++ {
+		httpResp, err := b.DoRaw(ctx)
+		if err == nil {
+			return httpResp, nil
+		}
+		if !idempotent || attempt >= retries || OutcomeFromError(err) != OutcomeNotSent || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func removeHopByHopHeaders(header http.Header) {
+	for _, values := range header.Values("Connection") {
+		for _, key := range strings.Split(values, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				header.Del(key)
+			}
+		}
+	}
+	for _, key := range hopByHopHeaders {
+		header.Del(key)
+	}
+}
+
+// copyFlush 边读边写并及时flush,保证流式响应
+func copyFlush(w http.ResponseWriter, src io.Reader) error {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, proxyBufferSize)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
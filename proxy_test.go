@@ -0,0 +1,185 @@
+package httpx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestProxyHandlerStreaming(t *testing.T) {
+	next := make(chan struct{})
+	upstream := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("X-Upstream", r.URL.Path)
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "{\"n\":%d}\n", i)
+			w.(http.Flusher).Flush()
+			select {
+			case <-next:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	proxy := testkit.NewServer(t, ProxyHandler(func(r *http.Request) Builder {
+		return BaseURL(upstream.URL).Get("/upstream" + r.URL.Path)
+	}, ProxyOptions{RespHeaders: []string{"content-type", "x-upstream"}}))
+
+	httpResp, err := http.Get(proxy.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpResp.Body.Close()
+	if got := httpResp.Header.Get("X-Upstream"); got != "/upstream/events" {
+		t.Fatalf("expected x-upstream:/upstream/events,got:%s", got)
+	}
+	reader := bufio.NewReader(httpResp.Body)
+	for i := 0; i < 3; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf("{\"n\":%d}\n", i); line != expected {
+			t.Fatalf("expected line:%s,got:%s", expected, line)
+		}
+		// 上游在收到信号前不会继续写,能读到说明没有缓冲整个响应
+		next <- struct{}{}
+	}
+}
+
+func TestProxyHandlerUpload(t *testing.T) {
+	const size = 64 << 20
+	upstream := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := io.Copy(io.Discard, r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(strconv.FormatInt(n, 10)))
+	}))
+	proxy := testkit.NewServer(t, ProxyHandler(func(r *http.Request) Builder {
+		return Post(upstream.URL).Logging(false, false)
+	}, ProxyOptions{}))
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	httpResp, err := http.Post(proxy.URL, "application/octet-stream", io.LimitReader(zeroReader{}, size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	if got := string(data); got != strconv.Itoa(size) {
+		t.Fatalf("expected upstream to receive:%d,got:%s", size, got)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/4 {
+		t.Fatalf("expected streaming upload,allocated:%d", allocated)
+	}
+}
+
+func TestProxyHandlerErrorStatus(t *testing.T) {
+	upstream := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Keep-Alive") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Reason", "maintenance")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("down"))
+	}))
+	proxy := testkit.NewServer(t, ProxyHandler(func(r *http.Request) Builder {
+		return New().BaseURL(upstream.URL)
+	}, ProxyOptions{}))
+
+	httpReq, err := http.NewRequest(http.MethodDelete, proxy.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpReq.Header.Set("Keep-Alive", "timeout=5")
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpResp.Body.Close()
+	data, _ := io.ReadAll(httpResp.Body)
+	if httpResp.StatusCode != http.StatusServiceUnavailable || string(data) != "down" {
+		t.Fatalf("expected 503 down,got:%d %s", httpResp.StatusCode, data)
+	}
+	if got := httpResp.Header.Get("X-Reason"); got != "maintenance" {
+		t.Fatalf("expected x-reason:maintenance,got:%s", got)
+	}
+}
+
+func TestProxyHandlerBadGateway(t *testing.T) {
+	upstream := testkit.NewServer(t, testkit.Echo())
+	upstreamURL := upstream.URL
+	upstream.Close()
+	proxy := testkit.NewServer(t, ProxyHandler(func(r *http.Request) Builder {
+		return Get(upstreamURL)
+	}, ProxyOptions{ConnectRetries: 2}))
+
+	httpResp, err := http.Get(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected statuscode:%d,got:%d", http.StatusBadGateway, httpResp.StatusCode)
+	}
+}
+
+func TestProxyHandlerQueryAndRedirect(t *testing.T) {
+	upstream := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+			return
+		}
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	proxy := testkit.NewServer(t, ProxyHandler(func(r *http.Request) Builder {
+		return BaseURL(upstream.URL).Get(r.URL.Path).WithQueryString("tenant", "a")
+	}, ProxyOptions{}))
+
+	httpResp, err := http.Get(proxy.URL + "/search?q=go&page=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	if expected := "tenant=a&q=go&page=2"; string(data) != expected {
+		t.Fatalf("expected query:%s,got:%s", expected, data)
+	}
+
+	// 重定向交给下游客户端处理
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	httpResp, err = client.Get(proxy.URL + "/moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusFound || httpResp.Header.Get("Location") != "/elsewhere" {
+		t.Fatalf("expected 302 to /elsewhere,got:%d %s", httpResp.StatusCode, httpResp.Header.Get("Location"))
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
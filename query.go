@@ -0,0 +1,72 @@
+package httpx
+
+import (
+	"fmt"
+	"log/slog"
+	stdurl "net/url"
+	"sort"
+	"strings"
+)
+
+// DuplicatePolicy 同一个query key出现在多个来源时的处理方式
+//
+// 来源的优先级为: WithQueryString/WithURLValues > WithQueryStringObj > ReqAsQuery的WithReq > url中自带的query,
+// 合并后key按字典序排列,同一个key的值按来源优先级排列
+type DuplicatePolicy int
+
+const (
+	// DuplicateAccumulate 保留所有来源的值,并打印warning日志
+	DuplicateAccumulate DuplicatePolicy = iota
+	// DuplicateError 返回错误
+	DuplicateError
+	// DuplicateFirstWins 只保留优先级最高的来源的值
+	DuplicateFirstWins
+	// DuplicateLastWins 只保留优先级最低的来源的值
+	DuplicateLastWins
+)
+
+type querySource struct {
+	name   string
+	values stdurl.Values
+}
+
+// mergeQuery 按优先级合并各来源的query
+func mergeQuery(policy DuplicatePolicy, sources ...querySource) (stdurl.Values, error) {
+	merged := make(stdurl.Values)
+	owners := make(map[string][]string)
+	for _, source := range sources {
+		for key, values := range source.values {
+			if len(values) == 0 {
+				continue
+			}
+			owners[key] = append(owners[key], source.name)
+			if len(owners[key]) > 1 {
+				switch policy {
+				case DuplicateError, DuplicateFirstWins:
+					continue
+				case DuplicateLastWins:
+					merged[key] = nil
+				}
+			}
+			merged[key] = append(merged[key], values...)
+		}
+	}
+	keys := make([]string, 0, len(owners))
+	for key := range owners {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		names := owners[key]
+		if len(names) < 2 {
+			continue
+		}
+		switch policy {
+		case DuplicateError:
+			return nil, fmt.Errorf("duplicate query key:%s,from:%s", key, strings.Join(names, ","))
+		case DuplicateAccumulate:
+			slog.Warn("duplicate query key", "key", key, "sources", strings.Join(names, ","))
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,183 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	stdurl "net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrQueryBind query参数无法转换为Req字段的类型
+type ErrQueryBind struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *ErrQueryBind) Error() string {
+	return fmt.Sprintf("invalid query parameter %s:%q,%s", e.Field, e.Value, e.Err)
+}
+
+func (e *ErrQueryBind) Unwrap() error {
+	return e.Err
+}
+
+// bindQuery 按url或form tag将values写入obj指向的struct,tag的写法与go-querystring相同:
+// 没有tag时使用字段名,"-"跳过,slice支持重复的key以及comma、space、semicolon、brackets选项;
+// 匿名struct字段展开。obj不指向struct时不做任何事
+func bindQuery(values stdurl.Values, obj interface{}) error {
+	if !bindsQuery(obj) {
+		return nil
+	}
+	return bindQueryStruct(values, reflect.ValueOf(obj).Elem())
+}
+
+// bindsQuery obj指向struct,没有body时Req由query参数构造
+func bindsQuery(obj interface{}) bool {
+	value := reflect.ValueOf(obj)
+	return value.Kind() == reflect.Pointer && value.Elem().Kind() == reflect.Struct
+}
+
+func bindQueryStruct(values stdurl.Values, value reflect.Value) error {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get("url")
+		if tag == "" {
+			tag = field.Tag.Get("form")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldValue := value.Field(i)
+		if field.Anonymous && name == "" && indirectType(field.Type).Kind() == reflect.Struct {
+			if fieldValue.Kind() == reflect.Pointer {
+				if !field.IsExported() {
+					continue
+				}
+				if fieldValue.IsNil() {
+					fieldValue.Set(reflect.New(field.Type.Elem()))
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			if err := bindQueryStruct(values, fieldValue); err != nil {
+				return err
+			}
+			continue
+		}
+		if !fieldValue.CanSet() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		raw := queryFieldValues(values, name, opts)
+		if len(raw) == 0 {
+			continue
+		}
+		if err := setQueryField(fieldValue, raw); err != nil {
+			var numErr *strconv.NumError
+			if errors.As(err, &numErr) {
+				err = fmt.Errorf("%w for %s", numErr.Err, field.Type)
+			}
+			return &ErrQueryBind{Field: name, Value: strings.Join(raw, ","), Err: err}
+		}
+	}
+	return nil
+}
+
+// queryFieldValues 按go-querystring的slice选项拆分值
+func queryFieldValues(values stdurl.Values, name, opts string) []string {
+	raw := values[name]
+	var sep string
+	for _, opt := range strings.Split(opts, ",") {
+		switch opt {
+		case "comma":
+			sep = ","
+		case "space":
+			sep = " "
+		case "semicolon":
+			sep = ";"
+		case "brackets":
+			raw = append(raw, values[name+"[]"]...)
+		}
+	}
+	if sep == "" {
+		return raw
+	}
+	var split []string
+	for _, value := range raw {
+		split = append(split, strings.Split(value, sep)...)
+	}
+	return split
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Pointer {
+		return typ.Elem()
+	}
+	return typ
+}
+
+func setQueryField(value reflect.Value, raw []string) error {
+	if value.Kind() == reflect.Pointer {
+		elem := reflect.New(value.Type().Elem())
+		if err := setQueryField(elem.Elem(), raw); err != nil {
+			return err
+		}
+		value.Set(elem)
+		return nil
+	}
+	if value.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(value.Type(), len(raw), len(raw))
+		for i, item := range raw {
+			if err := setQueryScalar(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+		return nil
+	}
+	// 重复的key赋给非slice字段时使用第一个值
+	return setQueryScalar(value, raw[0])
+}
+
+func setQueryScalar(value reflect.Value, raw string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	QuotaRemainingKey = "X-Quota-Remaining"
+	QuotaResetKey     = "X-Quota-Reset"
+)
+
+// QuotaStore 按key统计流量配额
+type QuotaStore interface {
+	// Remaining 返回key剩余的字节数以及配额恢复的时间
+	Remaining(key string) (int64, time.Time)
+	// Consume 记录key消耗的字节数
+	Consume(key string, n int64)
+}
+
+type quotaEvent struct {
+	at time.Time
+	n  int64
+}
+
+type memoryQuotaStore struct {
+	sync.Mutex
+	limit  int64
+	window time.Duration
+	events map[string][]quotaEvent
+	now    func() time.Time
+}
+
+// NewMemoryQuotaStore 基于滑动窗口的内存配额
+func NewMemoryQuotaStore(limit int64, window time.Duration) QuotaStore {
+	return &memoryQuotaStore{
+		limit:  limit,
+		window: window,
+		events: make(map[string][]quotaEvent),
+		now:    time.Now,
+	}
+}
+
+func (s *memoryQuotaStore) Remaining(key string) (int64, time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	now := s.now()
+	events := s.evict(key, now)
+	var used int64
+	for _, event := range events {
+		used += event.n
+	}
+	reset := now
+	if len(events) != 0 {
+		reset = events[0].at.Add(s.window)
+	}
+	remaining := s.limit - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, reset
+}
+
+func (s *memoryQuotaStore) Consume(key string, n int64) {
+	if n <= 0 {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	now := s.now()
+	s.events[key] = append(s.evict(key, now), quotaEvent{at: now, n: n})
+}
+
+func (s *memoryQuotaStore) evict(key string, now time.Time) []quotaEvent {
+	events := s.events[key]
+	idx := 0
+	for idx < len(events) && !events[idx].at.Add(s.window).After(now) {
+		idx++
+	}
+	events = events[idx:]
+	if len(events) == 0 {
+		delete(s.events, key)
+		return nil
+	}
+	s.events[key] = events
+	return events
+}
+
+type principalKey struct{}
+
+// WithPrincipal 在context中记录调用方身份
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext 获取调用方身份
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// ErrMissingQuotaKey QuotaTransport无法确定请求的key,没有key的请求不会共享同一个配额
+var ErrMissingQuotaKey = errors.New("missing quota key")
+
+// defaultQuotaKey 优先使用Principal,其次使用客户端ip
+func defaultQuotaKey(httpReq *http.Request) string {
+	if principal := PrincipalFromContext(httpReq.Context()); principal != "" {
+		return principal
+	}
+	host, _, err := net.SplitHostPort(httpReq.RemoteAddr)
+	if err != nil {
+		return httpReq.RemoteAddr
+	}
+	return host
+}
+
+func setQuotaHeader(header http.Header, remaining int64, reset time.Time) {
+	header.Set(QuotaRemainingKey, strconv.FormatInt(remaining, 10))
+	header.Set(QuotaResetKey, strconv.FormatInt(reset.Unix(), 10))
+}
+
+// QuotaHandler 按key限制请求与响应的字节数,X-Quota-Remaining为扣除写出响应头时
+// 已读取的请求体与第一次写出的响应体之后的剩余量
+func QuotaHandler(q QuotaStore, keyFn func(*http.Request) string) HandlerWrapper {
+	if keyFn == nil {
+		keyFn = defaultQuotaKey
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+			key := keyFn(httpReq)
+			remaining, reset := q.Remaining(key)
+			if remaining <= 0 {
+				setQuotaHeader(w.Header(), remaining, reset)
+				writeTooManyRequests(w, RetryAdvice{Remaining: remaining, Reset: reset})
+				return
+			}
+			var transferred int64
+			defer func() {
+				q.Consume(key, atomic.LoadInt64(&transferred))
+			}()
+			if httpReq.Body != nil && httpReq.Body != http.NoBody {
+				httpReq.Body = &countingReadCloser{ReadCloser: httpReq.Body, n: &transferred}
+			}
+			next.ServeHTTP(&countingResponseWriter{
+				ResponseWriter: w,
+				n:              &transferred,
+				onHeader: func(pending int) {
+					setQuotaHeader(w.Header(), max(remaining-atomic.LoadInt64(&transferred)-int64(pending), 0), reset)
+				},
+			}, httpReq)
+		})
+	}
+}
+
+// QuotaTransport 按key限制发出请求与收到响应的字节数,keyFn为nil时使用PrincipalFromContext;
+// key为空的请求不发出,返回ErrMissingQuotaKey
+func QuotaTransport(q QuotaStore, keyFn func(*http.Request) string) TransportWrapper {
+	if keyFn == nil {
+		keyFn = func(httpReq *http.Request) string {
+			return PrincipalFromContext(httpReq.Context())
+		}
+	}
+	return NamedWrapper("quota", "", func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			key := keyFn(httpReq)
+			if key == "" {
+				return nil, withOutcome(ErrMissingQuotaKey, OutcomeNotSent)
+			}
+			if remaining, reset := q.Remaining(key); remaining <= 0 {
+				return nil, fmt.Errorf("quota exceeded for:%s,reset at:%s", key, reset.Format(time.RFC3339))
+			}
+			var transferred int64
+			if httpReq.Body != nil && httpReq.Body != http.NoBody {
+				httpReq.Body = &countingReadCloser{ReadCloser: httpReq.Body, n: &transferred}
+			}
+			httpResp, err := next.RoundTrip(httpReq)
+			if err != nil {
+				q.Consume(key, atomic.LoadInt64(&transferred))
+				return nil, err
+			}
+			httpResp.Body = &countingReadCloser{
+				ReadCloser: httpResp.Body,
+				n:          &transferred,
+				onClose: func() {
+					q.Consume(key, atomic.LoadInt64(&transferred))
+				},
+			}
+			return httpResp, nil
+		})
+	})
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	n       *int64
+	once    sync.Once
+	onClose func()
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
+
+func (c *countingReadCloser) Close() error {
+	err := c.ReadCloser.Close()
+	if c.onClose != nil {
+		c.once.Do(c.onClose)
+	}
+	return err
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	n *int64
+	// onHeader 写出响应头之前调用,pending为即将写出但还没有计入n的字节数
+	onHeader    func(pending int)
+	wroteHeader bool
+}
+
+func (w *countingResponseWriter) header(pending int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if w.onHeader != nil {
+		w.onHeader(pending)
+	}
+}
+
+func (w *countingResponseWriter) WriteHeader(statusCode int) {
+	if statusCode >= http.StatusOK {
+		w.header(0)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *countingResponseWriter) Write(data []byte) (int, error) {
+	w.header(len(data))
+	n, err := w.ResponseWriter.Write(data)
+	atomic.AddInt64(w.n, int64(n))
+	return n, err
+}
+
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *countingResponseWriter) Flush() {
+	w.header(0)
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,108 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestQuotaHandler(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryQuotaStore(10, time.Minute).(*memoryQuotaStore)
+	store.now = func() time.Time { return now }
+
+	handler := QuotaHandler(store, func(r *http.Request) string {
+		return r.Header.Get("X-Tenant")
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.Write(data)
+	}))
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		httpReq := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		httpReq.Header.Set("X-Tenant", "a")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httpReq)
+		return w
+	}
+
+	w := serve("abc")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected statuscode:%d,got:%d", http.StatusOK, w.Code)
+	}
+	// 扣除本次的请求体与响应体
+	if got := w.Header().Get(QuotaRemainingKey); got != "4" {
+		t.Fatalf("expected remaining:4,got:%s", got)
+	}
+
+	w = serve("abcd")
+	if got := w.Header().Get(QuotaRemainingKey); got != "0" {
+		t.Fatalf("expected remaining:0,got:%s", got)
+	}
+	expectedReset := strconv.FormatInt(now.Add(time.Minute).Unix(), 10)
+	if got := w.Header().Get(QuotaResetKey); got != expectedReset {
+		t.Fatalf("expected reset:%s,got:%s", expectedReset, got)
+	}
+
+	w = serve("a")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected statuscode:%d,got:%d", http.StatusTooManyRequests, w.Code)
+	}
+	if got := w.Header().Get(QuotaRemainingKey); got != "0" {
+		t.Fatalf("expected remaining:0,got:%s", got)
+	}
+
+	now = now.Add(time.Minute)
+	if w = serve("a"); w.Code != http.StatusOK {
+		t.Fatalf("expected statuscode:%d after reset,got:%d", http.StatusOK, w.Code)
+	}
+}
+
+func TestQuotaTransport(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	store := NewMemoryQuotaStore(10, time.Minute)
+	client := &http.Client{Transport: WrapTransport(http.DefaultTransport, QuotaTransport(store, nil))}
+	post := func(ctx context.Context, body string) error {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader(body))
+		if err != nil {
+			return err
+		}
+		httpResp, err := client.Do(httpReq)
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, httpResp.Body)
+		return httpResp.Body.Close()
+	}
+
+	// 没有principal的请求不共享同一个配额
+	if err := post(context.Background(), "abc"); !errors.Is(err, ErrMissingQuotaKey) {
+		t.Fatalf("expected ErrMissingQuotaKey,got:%v", err)
+	}
+	tenantA := WithPrincipal(context.Background(), "a")
+	if err := post(tenantA, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if remaining, _ := store.Remaining("a"); remaining != 4 {
+		t.Fatalf("expected remaining:4,got:%d", remaining)
+	}
+	if err := post(tenantA, "abcd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := post(tenantA, "a"); err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Fatalf("expected quota exceeded,got:%v", err)
+	}
+	if err := post(WithPrincipal(context.Background(), "b"), "a"); err != nil {
+		t.Fatalf("expected other principal to keep its quota,got:%v", err)
+	}
+}
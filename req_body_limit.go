@@ -0,0 +1,118 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxBodyBytes DefaultHandlerWrapper与MaxBodyBytesHandler(0)使用的请求体上限
+var DefaultMaxBodyBytes int64 = 10 << 20
+
+type bodyLimitKey struct{}
+
+// bodyLimitFromContext MaxBodyBytesHandler设置的请求体上限
+func bodyLimitFromContext(ctx context.Context) (int64, bool) {
+	limit, ok := ctx.Value(bodyLimitKey{}).(int64)
+	return limit, ok
+}
+
+func bodyTooLargeErr(limit int64) *ErrHTTPStatus {
+	return &ErrHTTPStatus{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    "body_too_large",
+		Message: fmt.Sprintf("request body exceeds %d bytes", limit),
+	}
+}
+
+// MaxBodyBytesHandler 限制请求体最多n字节,n为0时使用DefaultMaxBodyBytes,<0时不限制;
+// Content-Length超过n时直接响应413,读取时超过n且handler没有写响应时同样响应413,响应体为json的ErrHTTPStatus。
+// 应放在LoggingHandler外层,日志不会缓存超过n的请求体
+func MaxBodyBytesHandler(n int64) HandlerWrapper {
+	return func(next http.Handler) http.Handler {
+		limit := n
+		if limit == 0 {
+			limit = DefaultMaxBodyBytes
+		}
+		if limit < 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+			if httpReq.ContentLength > limit {
+				logWarn(httpReq.Context(), "request body too large",
+					FieldHTTPMethod, httpReq.Method,
+					FieldHTTPURL, httpReq.URL.String(),
+					FieldReqTotalBytes, httpReq.ContentLength,
+				)
+				writeHTTPStatusErr(w, defaultCodec, bodyTooLargeErr(limit))
+				return
+			}
+			lw := &bodyLimitWriter{ResponseWriter: w}
+			body := &limitedReqBody{ReadCloser: http.MaxBytesReader(lw, httpReq.Body, limit)}
+			httpReq.Body = body
+			httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), bodyLimitKey{}, limit))
+			next.ServeHTTP(lw, httpReq)
+			if body.exceeded && !lw.wroteHeader {
+				logWarn(httpReq.Context(), "request body too large",
+					FieldHTTPMethod, httpReq.Method,
+					FieldHTTPURL, httpReq.URL.String(),
+				)
+				writeHTTPStatusErr(lw, defaultCodec, bodyTooLargeErr(limit))
+			}
+		})
+	}
+}
+
+// limitedReqBody 记录读取时是否超过了上限
+type limitedReqBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedReqBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// bodyLimitWriter 记录handler是否已经写了响应
+type bodyLimitWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *bodyLimitWriter) WriteHeader(statusCode int) {
+	if statusCode >= http.StatusOK {
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *bodyLimitWriter) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyLimitWriter) Flush() {
+	w.wroteHeader = true
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *bodyLimitWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logBodyLimit 日志最多缓存的请求体字节数不超过MaxBodyBytesHandler的上限
+func logBodyLimit(ctx context.Context, max int64) int64 {
+	if limit, ok := bodyLimitFromContext(ctx); ok && (max < 0 || max > limit) {
+		return limit
+	}
+	return max
+}
@@ -0,0 +1,102 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestMaxBodyBytesHandler(t *testing.T) {
+	type req struct {
+		Name string `json:"name"`
+	}
+	echo := JsonHandler(func(ctx context.Context, req req) (req, error) {
+		return req, nil
+	})
+	var ignoreErr http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	})
+	large := `{"name":"` + strings.Repeat("a", 64) + `"}`
+
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		body       string
+		chunked    bool
+		statusCode int
+	}{
+		{name: "within limit", handler: echo, body: `{"name":"a"}`, statusCode: http.StatusOK},
+		{name: "content length", handler: echo, body: large, statusCode: http.StatusRequestEntityTooLarge},
+		{name: "chunked handler", handler: echo, body: large, chunked: true, statusCode: http.StatusRequestEntityTooLarge},
+		{name: "chunked plain handler", handler: ignoreErr, body: large, chunked: true, statusCode: http.StatusRequestEntityTooLarge},
+		{name: "chunked logging", handler: WrapHandler(echo, LoggingHandlerWithOptions(LoggingOptions{ReqBody: true, MaxBodyBytes: -1})), body: large, chunked: true, statusCode: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		handler := MaxBodyBytesHandler(32)(tt.handler)
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		if tt.chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.statusCode {
+			t.Fatalf("%s:expected status:%d,got:%d", tt.name, tt.statusCode, rec.Code)
+		}
+		if tt.statusCode != http.StatusRequestEntityTooLarge {
+			continue
+		}
+		statusErr := &ErrHTTPStatus{}
+		if err := json.Unmarshal(rec.Body.Bytes(), statusErr); err != nil {
+			t.Fatalf("%s:expected json body,got:%s", tt.name, rec.Body.String())
+		}
+		if statusErr.Code != "body_too_large" {
+			t.Fatalf("%s:expected code:body_too_large,got:%s", tt.name, statusErr.Code)
+		}
+	}
+}
+
+func TestMaxBodyBytesLogging(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	called := false
+	handler := WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), LoggingHandlerWithOptions(LoggingOptions{ReqBody: true, RespBody: true, MaxBodyBytes: -1}), MaxBodyBytesHandler(16))
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", 1024)))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status:%d,got:%d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+	if called {
+		t.Fatal("expected handler not to be called")
+	}
+	logs.AssertField(t, "serve http req", FieldServerStatusCode, http.StatusRequestEntityTooLarge)
+	for _, record := range logs.Find("serve http req") {
+		if data, ok := record.Attrs[FieldReqData]; ok {
+			t.Fatalf("expected no request body in log,got:%v", data)
+		}
+	}
+}
+
+func TestDefaultHandlerWrapperMaxBodyBytes(t *testing.T) {
+	prev := DefaultMaxBodyBytes
+	DefaultMaxBodyBytes = 8
+	t.Cleanup(func() {
+		DefaultMaxBodyBytes = prev
+	})
+	handler := DefaultHandlerWrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", 9)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status:%d,got:%d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
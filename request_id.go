@@ -0,0 +1,64 @@
+package httpx
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+type requestIDKey struct{}
+
+// withRequestID 保存request id并加入日志属性
+func withRequestID(ctx context.Context, requestID string) context.Context {
+	if RequestIDFromContext(ctx) == requestID {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	return AppendLogAttrs(ctx, slog.String(FieldRequestID, requestID))
+}
+
+// RequestIDFromContext 返回RequestIDHandler、RequestIDTransport或LoggingHandler保存的request id
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// RequestIDTransport 请求没有headerName头时设置request id,优先沿用ctx中的id(例如处理server请求时),
+// 否则随机生成;id同时保存到请求的ctx,之内的LoggingTransport会记录它。headerName为空时使用RequestIDKey
+func RequestIDTransport(headerName string) TransportWrapper {
+	if headerName == "" {
+		headerName = RequestIDKey
+	}
+	return NamedWrapper("request_id", headerName, func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			ctx := httpReq.Context()
+			requestID := httpReq.Header.Get(headerName)
+			if requestID == "" {
+				if requestID = RequestIDFromContext(ctx); requestID == "" {
+					requestID = newRequestID()
+				}
+				httpReq = httpReq.Clone(ctx)
+				httpReq.Header.Set(headerName, requestID)
+			}
+			return next.RoundTrip(httpReq.WithContext(withRequestID(ctx, requestID)))
+		})
+	})
+}
+
+// RequestIDHandler 读取headerName头作为request id,没有时随机生成,保存到ctx并写回响应头;
+// 放在LoggingHandler之外时日志使用同一个id。headerName为空时使用RequestIDKey
+func RequestIDHandler(headerName string) HandlerWrapper {
+	if headerName == "" {
+		headerName = RequestIDKey
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+			requestID := httpReq.Header.Get(headerName)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set(headerName, requestID)
+			next.ServeHTTP(w, httpReq.WithContext(withRequestID(httpReq.Context(), requestID)))
+		})
+	}
+}
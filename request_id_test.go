@@ -0,0 +1,52 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestRequestID(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	var upstreamID string
+	upstream := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamID = r.Header.Get(RequestIDKey)
+	}))
+	server := testkit.NewServer(t, WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if RequestIDFromContext(r.Context()) == "" {
+			t.Error("expected request id in handler ctx")
+		}
+		if err := Get(upstream.URL).Tracing(false).RequestID("").Do(r.Context()); err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}), LoggingHandler(false, false), RequestIDHandler("")))
+
+	// client生成的id经过server传递给上游
+	var respHeader http.Header
+	if err := Get(server.URL).Tracing(false).RequestID("").WithRespHeaders(&respHeader).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	requestID := respHeader.Get(RequestIDKey)
+	if requestID == "" || upstreamID != requestID {
+		t.Fatalf("expected request id to be echoed and propagated,got:%s,upstream:%s", requestID, upstreamID)
+	}
+	for _, message := range []string{"send http req", "got http resp", "serve http req"} {
+		logs.AssertField(t, message, FieldRequestID, requestID)
+	}
+
+	// 已有的头保持不变,server没有收到时生成
+	if err := Get(server.URL).Tracing(false).RequestID("").WithHeader(RequestIDKey, "req-1").WithRespHeaders(&respHeader).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := respHeader.Get(RequestIDKey); got != "req-1" || upstreamID != "req-1" {
+		t.Fatalf("expected request id:req-1,got:%s,upstream:%s", got, upstreamID)
+	}
+	if err := Get(server.URL).Tracing(false).WithRespHeaders(&respHeader).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := respHeader.Get(RequestIDKey); got == "" || got == "req-1" || upstreamID != got {
+		t.Fatalf("expected generated request id,got:%s,upstream:%s", got, upstreamID)
+	}
+}
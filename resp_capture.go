@@ -0,0 +1,65 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// respCapture 由最内层的transport填充最后一个响应的状态码与header,状态码不符合预期时也能拿到
+type respCapture struct {
+	sync.Mutex
+	statusCode int
+	header     http.Header
+}
+
+type respCaptureKey struct{}
+
+func withRespCapture(ctx context.Context) (context.Context, *respCapture) {
+	capture := &respCapture{}
+	return context.WithValue(ctx, respCaptureKey{}, capture), capture
+}
+
+func (c *respCapture) get() (int, http.Header) {
+	c.Lock()
+	defer c.Unlock()
+	return c.statusCode, c.header
+}
+
+// respCaptureTransport 在状态码检查之前记录响应
+func respCaptureTransport(next http.RoundTripper) http.RoundTripper {
+	return newNamedTransport(WrapperInfo{Name: "resp_capture"}, next, TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+		httpResp, err := next.RoundTrip(httpReq)
+		if err != nil {
+			return nil, err
+		}
+		if capture, ok := httpReq.Context().Value(respCaptureKey{}).(*respCapture); ok {
+			capture.Lock()
+			capture.statusCode = httpResp.StatusCode
+			capture.header = httpResp.Header.Clone()
+			capture.Unlock()
+		}
+		return httpResp, nil
+	}))
+}
+
+// setRespCapture 写入WithRespStatusCode与WithRespHeaders指定的目标
+func (b *builder) setRespCapture(statusCode int, header http.Header) {
+	if b.respStatusCode != nil {
+		*b.respStatusCode = statusCode
+	}
+	if b.respHeaders != nil {
+		*b.respHeaders = header
+	}
+}
+
+// finalRespHeaders 读完body后返回包含trailer的响应头
+func finalRespHeaders(httpResp *http.Response) http.Header {
+	io.Copy(io.Discard, httpResp.Body)
+	header := httpResp.Header.Clone()
+	for key, values := range httpResp.Trailer {
+		header[key] = append(header[key], values...)
+	}
+	return header
+}
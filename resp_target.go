@@ -0,0 +1,25 @@
+package httpx
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// ErrSharedRespTarget 多个请求同时解码到同一个WithResp目标
+var ErrSharedRespTarget = errors.New("httpx: concurrent requests share the same WithResp target, use DoInto instead")
+
+var respTargets sync.Map
+
+// acquireRespTarget 标记resp正在被使用,同一个目标被并发使用时返回ErrSharedRespTarget
+func acquireRespTarget(resp interface{}) (func(), error) {
+	if resp == nil || reflect.ValueOf(resp).Kind() != reflect.Pointer {
+		return func() {}, nil
+	}
+	if _, loaded := respTargets.LoadOrStore(resp, struct{}{}); loaded {
+		return nil, ErrSharedRespTarget
+	}
+	return func() {
+		respTargets.Delete(resp)
+	}, nil
+}
@@ -0,0 +1,131 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// RespTransformer 在解码前转换响应体,尽量以流式方式处理
+type RespTransformer func(contentType string, body io.Reader) (io.Reader, error)
+
+// ErrRespTransform 第Index个RespTransformer返回错误
+type ErrRespTransform struct {
+	Index int
+	Err   error
+}
+
+func (e *ErrRespTransform) Error() string {
+	return fmt.Sprintf("resp transformer %d failed:%s", e.Index, e.Err)
+}
+
+func (e *ErrRespTransform) Unwrap() error {
+	return e.Err
+}
+
+// xssiPrefix 防止json被当作脚本引用的前缀
+const xssiPrefix = ")]}'"
+
+// StripXSSIPrefix 去掉响应开头的 )]}' 及其后的逗号和换行,没有时原样返回
+func StripXSSIPrefix() RespTransformer {
+	return func(contentType string, body io.Reader) (io.Reader, error) {
+		br := bufio.NewReader(body)
+		head, err := br.Peek(len(xssiPrefix))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if string(head) != xssiPrefix {
+			return br, nil
+		}
+		br.Discard(len(xssiPrefix))
+		for {
+			next, err := br.Peek(1)
+			if err != nil || (next[0] != ',' && next[0] != '\r' && next[0] != '\n') {
+				break
+			}
+			br.Discard(1)
+		}
+		return br, nil
+	}
+}
+
+// TrimTransformer 去掉响应开头的prefix与结尾的suffix,不存在时保持不变
+func TrimTransformer(prefix, suffix string) RespTransformer {
+	return func(contentType string, body io.Reader) (io.Reader, error) {
+		br := bufio.NewReader(body)
+		if prefix != "" {
+			head, err := br.Peek(len(prefix))
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			if string(head) == prefix {
+				br.Discard(len(prefix))
+			}
+		}
+		if suffix == "" {
+			return br, nil
+		}
+		return &suffixTrimReader{src: br, suffix: []byte(suffix)}, nil
+	}
+}
+
+// suffixTrimReader 始终保留最后len(suffix)个字节,读到EOF时再决定是否丢弃
+type suffixTrimReader struct {
+	src    io.Reader
+	suffix []byte
+	held   []byte
+	eof    bool
+}
+
+func (r *suffixTrimReader) Read(p []byte) (int, error) {
+	for !r.eof && len(r.held) <= len(r.suffix) {
+		buf := make([]byte, len(p)+len(r.suffix))
+		n, err := r.src.Read(buf)
+		r.held = append(r.held, buf[:n]...)
+		if err == io.EOF {
+			r.eof = true
+			if bytes.HasSuffix(r.held, r.suffix) {
+				r.held = r.held[:len(r.held)-len(r.suffix)]
+			}
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	available := len(r.held)
+	if !r.eof {
+		available -= len(r.suffix)
+	}
+	n := copy(p, r.held[:available])
+	r.held = r.held[n:]
+	if r.eof && len(r.held) == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func applyRespTransformers(transformers []RespTransformer, contentType string, body io.Reader) (io.Reader, error) {
+	for idx, transformer := range transformers {
+		transformed, err := transformer(contentType, body)
+		if err != nil {
+			return nil, &ErrRespTransform{Index: idx, Err: err}
+		}
+		body = transformed
+	}
+	return body, nil
+}
+
+type respTransformedKey struct{}
+
+func withRespTransformed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, respTransformedKey{}, true)
+}
+
+// respTransformedFromContext 日志记录的是原始响应体,用于标记解码前经过了转换
+func respTransformedFromContext(ctx context.Context) bool {
+	transformed, _ := ctx.Value(respTransformedKey{}).(bool)
+	return transformed
+}
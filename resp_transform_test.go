@@ -0,0 +1,78 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestRespTransformerXSSI(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	const payload = ")]}',\n{\"name\":\"a\"}"
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(payload))
+	}))
+	var resp struct {
+		Name string `json:"name"`
+	}
+	err := Get(server.URL).
+		WithRespTransformer(StripXSSIPrefix()).
+		WithResp(&resp).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Name != "a" {
+		t.Fatalf("expected name:a,got:%s", resp.Name)
+	}
+	logs.AssertField(t, "got http resp", FieldRespData, payload)
+	logs.AssertField(t, "got http resp", FieldRespTransformed, true)
+}
+
+func TestRespTransformerError(t *testing.T) {
+	server := testkit.NewServer(t, testkit.Echo())
+	boom := errors.New("boom")
+	err := Post(server.URL).
+		WithReq(map[string]string{}).
+		WithRespTransformer(TrimTransformer("", "")).
+		WithRespTransformer(func(string, io.Reader) (io.Reader, error) {
+			return nil, boom
+		}).
+		WithResp(&map[string]string{}).
+		Do(context.Background())
+	var transformErr *ErrRespTransform
+	if !errors.As(err, &transformErr) || transformErr.Index != 1 || !errors.Is(err, boom) {
+		t.Fatalf("expected ErrRespTransform at index 1,got:%v", err)
+	}
+}
+
+func TestTrimTransformer(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{in: "callback({\"a\":1});", expected: "{\"a\":1}"},
+		{in: "{\"a\":1}", expected: "{\"a\":1}"},
+		{in: "callback(", expected: ""},
+		{in: ");", expected: ""},
+	}
+	for _, tt := range tests {
+		body, err := TrimTransformer("callback(", ");")("", iotest.OneByteReader(strings.NewReader(tt.in)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.expected {
+			t.Fatalf("expected:%s,got:%s", tt.expected, data)
+		}
+	}
+}
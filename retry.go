@@ -0,0 +1,225 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// BackoffPolicy 返回第attempt次重试(从0开始)之前的等待时间
+type BackoffPolicy func(attempt int) time.Duration
+
+// ConstantBackoff 每次重试前等待d
+func ConstantBackoff(d time.Duration) BackoffPolicy {
+	return func(attempt int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff 等待base*2^attempt,不超过max;jitter为true时在[d/2,d]之间随机
+func ExponentialBackoff(base, max time.Duration, jitter bool) BackoffPolicy {
+	return func(attempt int) time.Duration {
+		d := max
+		if attempt < 62 {
+			if shifted := base << uint(attempt); shifted > 0 && shifted < max {
+				d = shifted
+			}
+		}
+		if jitter && d > 1 {
+			d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+		}
+		return d
+	}
+}
+
+// ErrRetryFailed 重试之后仍然失败,Err为最后一次尝试的错误
+type ErrRetryFailed struct {
+	Attempts int
+	Err      error
+}
+
+func (e *ErrRetryFailed) Error() string {
+	return fmt.Sprintf("retry failed after %d attempts:%s", e.Attempts, e.Err)
+}
+
+func (e *ErrRetryFailed) Unwrap() error {
+	return e.Err
+}
+
+// retryableOutcome 未发出的请求总是可以重试,幂等请求还可以重试5xx、429以及发出后未收到响应
+func retryableOutcome(idempotent bool, err error) bool {
+	switch OutcomeFromError(err) {
+	case OutcomeNotSent:
+		return true
+	case OutcomeSentUnknown:
+		return idempotent
+	case OutcomeReceived:
+		var statusErr *ErrUnexpectedStatusCode
+		if !errors.As(err, &statusErr) || !idempotent {
+			return false
+		}
+		return retryableStatus(statusErr.Got)
+	}
+	return false
+}
+
+// ErrRetrySkipped 失败本可以重试,但请求方法不是幂等的且没有Idempotency-Key
+type ErrRetrySkipped struct {
+	Method string
+	Err    error
+}
+
+func (e *ErrRetrySkipped) Error() string {
+	return fmt.Sprintf("retries skipped for non-idempotent method %s:%s", e.Method, e.Err)
+}
+
+func (e *ErrRetrySkipped) Unwrap() error {
+	return e.Err
+}
+
+// skippedByMethod 仅因为请求不幂等而没有重试
+func skippedByMethod(idempotent bool, err error) bool {
+	return !idempotent && retryableOutcome(true, err) && !retryableOutcome(false, err)
+}
+
+func retryableStatus(statusCode int) bool {
+	return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+}
+
+// DefaultMaxRetryAfter 重试时最多等待服务端Retry-After要求的时长,超过时放弃重试;<=0时不限制
+var DefaultMaxRetryAfter = time.Second * 30
+
+// ErrRetryAfterTooLong 服务端要求的等待时间超过MaxRetryAfter或ctx剩余的时间
+type ErrRetryAfterTooLong struct {
+	Delay time.Duration
+	Limit time.Duration
+	Err   error
+}
+
+func (e *ErrRetryAfterTooLong) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("server requested retry after %s,exceeds %s", e.Delay, e.Limit)
+	}
+	return fmt.Sprintf("server requested retry after %s,exceeds %s:%s", e.Delay, e.Limit, e.Err)
+}
+
+func (e *ErrRetryAfterTooLong) Unwrap() error {
+	return e.Err
+}
+
+// retryWait 返回下一次重试前的等待时间,服务端给出Retry-After时代替backoff;
+// 超过maxRetryAfter或ctx剩余时间时返回ErrRetryAfterTooLong
+func retryWait(ctx context.Context, header http.Header, backoff, maxRetryAfter time.Duration, err error) (time.Duration, error) {
+	if header == nil || header.Get(RetryAfterKey) == "" {
+		return backoff, nil
+	}
+	advice, ok := RetryAdviceFromResponse(&http.Response{Header: header})
+	if !ok {
+		return backoff, nil
+	}
+	limit := maxRetryAfter
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); limit <= 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+	if limit > 0 && advice.Delay > limit {
+		return 0, &ErrRetryAfterTooLong{Delay: advice.Delay, Limit: limit, Err: err}
+	}
+	return advice.Delay, nil
+}
+
+// statusErrHeader err为ErrUnexpectedStatusCode时返回响应头
+func statusErrHeader(err error) http.Header {
+	var statusErr *ErrUnexpectedStatusCode
+	if errors.As(err, &statusErr) {
+		return statusErr.Header
+	}
+	return nil
+}
+
+// RetryTransport 失败后按backoff重试,包括第一次在内最多尝试maxAttempts次,重试范围与ResiliencePolicy一致;
+// 非幂等方法只在带Idempotency-Key时重试,因此放弃重试时返回ErrRetrySkipped;
+// 请求体通过GetBody重放,无法重放时不重试;服务端给出Retry-After时按其等待,受DefaultMaxRetryAfter限制。
+// 放在TimeoutTransport之内时,所有尝试共用一个超时
+func RetryTransport(maxAttempts int, backoff BackoffPolicy) TransportWrapper {
+	return retryTransport(maxAttempts, backoff, DefaultMaxRetryAfter, false)
+}
+
+// retryTransport nonIdempotent为true时所有方法都按幂等处理
+func retryTransport(maxAttempts int, backoff BackoffPolicy, maxRetryAfter time.Duration, nonIdempotent bool) TransportWrapper {
+	return NamedWrapper("retry", fmt.Sprintf("attempts=%d", maxAttempts), func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			ctx := httpReq.Context()
+			idempotent := nonIdempotent || isIdempotent(httpReq.Method) || httpReq.Header.Get(IdempotencyKeyHeader) != ""
+			hasBody := httpReq.Body != nil && httpReq.Body != http.NoBody
+			attemptReq := httpReq
+			for attempt := 1; ; attempt++ {
+				tracked, tracker := trackOutcome(attemptReq)
+				httpResp, err := next.RoundTrip(tracked)
+				var retry bool
+				var header http.Header
+				if err != nil {
+					err = tracker.classify(err)
+					retry = retryableOutcome(idempotent, err)
+					header = statusErrHeader(err)
+				} else if idempotent && retryableStatus(httpResp.StatusCode) {
+					retry = true
+					header = httpResp.Header
+				}
+				var delay time.Duration
+				if retry && attempt < maxAttempts && (!hasBody || httpReq.GetBody != nil) && ctx.Err() == nil {
+					var backoffDelay time.Duration
+					if backoff != nil {
+						backoffDelay = backoff(attempt - 1)
+					}
+					var waitErr error
+					if delay, waitErr = retryWait(ctx, header, backoffDelay, maxRetryAfter, err); waitErr != nil {
+						if httpResp != nil {
+							httpResp.Body.Close()
+							httpResp = nil
+						}
+						err, retry = withOutcome(waitErr, OutcomeReceived), false
+					}
+				} else {
+					retry = false
+				}
+				if !retry {
+					if err != nil && attempt < maxAttempts && skippedByMethod(idempotent, err) {
+						err = &ErrRetrySkipped{Method: httpReq.Method, Err: err}
+					}
+					if err != nil && attempt > 1 {
+						err = &ErrRetryFailed{Attempts: attempt, Err: err}
+					}
+					return httpResp, err
+				}
+				if httpResp != nil {
+					io.Copy(io.Discard, io.LimitReader(httpResp.Body, maxErrorBodyBytes))
+					httpResp.Body.Close()
+				}
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					if err == nil {
+						err = withOutcome(ctx.Err(), OutcomeNotSent)
+					}
+					return nil, &ErrRetryFailed{Attempts: attempt, Err: err}
+				}
+				attemptReq = httpReq.Clone(ctx)
+				if hasBody {
+					body, err := httpReq.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					attemptReq.Body = body
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,99 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	RetryAfterKey         = "Retry-After"
+	RateLimitLimitKey     = "RateLimit-Limit"
+	RateLimitRemainingKey = "RateLimit-Remaining"
+	RateLimitResetKey     = "RateLimit-Reset"
+)
+
+// RetryAdvice 服务端告诉客户端何时可以重试
+type RetryAdvice struct {
+	// Delay 建议的重试间隔
+	Delay time.Duration
+	// Limit 窗口内的配额,0表示未知
+	Limit int64
+	// Remaining 窗口内剩余的配额
+	Remaining int64
+	// Reset 配额恢复的时间
+	Reset time.Time
+}
+
+// WriteHeader 写入Retry-After与RateLimit-*头
+func (a RetryAdvice) WriteHeader(header http.Header) {
+	delay := a.Delay
+	if delay <= 0 && !a.Reset.IsZero() {
+		delay = time.Until(a.Reset)
+	}
+	if delay > 0 {
+		header.Set(RetryAfterKey, strconv.FormatInt(int64((delay+time.Second-1)/time.Second), 10))
+	}
+	if a.Limit > 0 {
+		header.Set(RateLimitLimitKey, strconv.FormatInt(a.Limit, 10))
+		header.Set(RateLimitRemainingKey, strconv.FormatInt(a.Remaining, 10))
+	}
+	if !a.Reset.IsZero() {
+		reset := time.Until(a.Reset)
+		if reset < 0 {
+			reset = 0
+		}
+		header.Set(RateLimitResetKey, strconv.FormatInt(int64((reset+time.Second-1)/time.Second), 10))
+	}
+}
+
+// RetryAdviceFromResponse 从响应头中解析RetryAdvice
+func RetryAdviceFromResponse(httpResp *http.Response) (RetryAdvice, bool) {
+	var advice RetryAdvice
+	var found bool
+	now := time.Now()
+	if value := httpResp.Header.Get(RetryAfterKey); value != "" {
+		if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+			advice.Delay = time.Duration(seconds) * time.Second
+			found = true
+		} else if at, err := http.ParseTime(value); err == nil {
+			advice.Delay = at.Sub(now)
+			if advice.Delay < 0 {
+				advice.Delay = 0
+			}
+			found = true
+		}
+	}
+	if limit, err := strconv.ParseInt(httpResp.Header.Get(RateLimitLimitKey), 10, 64); err == nil {
+		advice.Limit = limit
+		found = true
+	}
+	if remaining, err := strconv.ParseInt(httpResp.Header.Get(RateLimitRemainingKey), 10, 64); err == nil {
+		advice.Remaining = remaining
+		found = true
+	}
+	if reset, err := strconv.ParseInt(httpResp.Header.Get(RateLimitResetKey), 10, 64); err == nil {
+		advice.Reset = now.Add(time.Duration(reset) * time.Second)
+		if advice.Delay == 0 && advice.Remaining == 0 {
+			advice.Delay = time.Duration(reset) * time.Second
+		}
+		found = true
+	}
+	return advice, found
+}
+
+// ErrTooManyRequests handler返回该错误时响应429,并带上RetryAdvice
+type ErrTooManyRequests struct {
+	Advice RetryAdvice
+}
+
+func (e *ErrTooManyRequests) Error() string {
+	return fmt.Sprintf("too many requests, retry after:%s", e.Advice.Delay)
+}
+
+// writeTooManyRequests 统一输出429响应
+func writeTooManyRequests(w http.ResponseWriter, advice RetryAdvice) {
+	advice.WriteHeader(w.Header())
+	w.WriteHeader(http.StatusTooManyRequests)
+}
@@ -0,0 +1,69 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/httpxtest"
+)
+
+func TestErrTooManyRequests(t *testing.T) {
+	var calls int64
+	handler := JsonHandler(func(ctx context.Context, req map[string]string) (map[string]string, error) {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			return nil, &ErrTooManyRequests{Advice: RetryAdvice{
+				Delay:     time.Second,
+				Limit:     10,
+				Remaining: 0,
+				Reset:     time.Now().Add(time.Second * 5),
+			}}
+		}
+		return req, nil
+	})
+
+	recorder := httptest.NewRecorder()
+	httpxtest.Call[map[string]string](t, handler, httpxtest.Req{
+		Method:   http.MethodPost,
+		JSON:     map[string]string{},
+		Status:   http.StatusTooManyRequests,
+		Recorder: recorder,
+	})
+	advice, ok := RetryAdviceFromResponse(recorder.Result())
+	if !ok {
+		t.Fatal("expected retry advice")
+	}
+	if advice.Delay != time.Second || advice.Limit != 10 || advice.Remaining != 0 {
+		t.Fatalf("unexpected advice:%+v", advice)
+	}
+	if until := time.Until(advice.Reset); until <= 0 || until > time.Second*6 {
+		t.Fatalf("unexpected reset:%s", until)
+	}
+	if expected := `{"code":"too_many_requests","message":"too many requests, retry after:1s"}`; strings.TrimSpace(recorder.Body.String()) != expected {
+		t.Fatalf("expected body:%s,got:%s", expected, recorder.Body.String())
+	}
+
+	httpxtest.Call[map[string]string](t, handler, httpxtest.Req{
+		Method: http.MethodPost,
+		JSON:   map[string]string{"data": "hello"},
+		Expect: map[string]string{"data": "hello"},
+	})
+}
+
+func TestErrTooManyRequestsEnvelope(t *testing.T) {
+	handler := StatusJsonHandler(func(ctx context.Context, req struct{}) (struct{}, error) {
+		return struct{}{}, &ErrTooManyRequests{Advice: RetryAdvice{Delay: time.Second}}
+	})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}")))
+	if got := recorder.Header().Get(RetryAfterKey); got != "1" {
+		t.Fatalf("expected Retry-After:1,got:%s", got)
+	}
+	if !strings.Contains(recorder.Body.String(), `"code":"too_many_requests"`) {
+		t.Fatalf("expected envelope with too_many_requests,got:%s", recorder.Body.String())
+	}
+}
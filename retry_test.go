@@ -0,0 +1,197 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Millisecond*10, time.Millisecond*50, false)
+	for attempt, expected := range []time.Duration{10, 20, 40, 50, 50} {
+		if got := backoff(attempt); got != expected*time.Millisecond {
+			t.Fatalf("expected backoff %d:%s,got:%s", attempt, expected*time.Millisecond, got)
+		}
+	}
+	if got := backoff(100); got != time.Millisecond*50 {
+		t.Fatalf("expected max backoff on overflow,got:%s", got)
+	}
+	jittered := ExponentialBackoff(time.Millisecond*10, time.Millisecond*50, true)
+	for i := 0; i < 100; i++ {
+		if got := jittered(2); got < time.Millisecond*20 || got > time.Millisecond*40 {
+			t.Fatalf("expected jittered backoff in [20ms,40ms],got:%s", got)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost && string(body) != `{"data":"a"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		idx := int(atomic.AddInt32(&calls, 1)) - 1
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(statuses[idx%len(statuses)])
+	}))
+	backoff := ConstantBackoff(time.Millisecond)
+
+	if err := Get(server.URL).Retry(3, backoff).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls,got:%d", calls)
+	}
+
+	// 非幂等请求只在带Idempotency-Key时重试,请求体通过GetBody重放
+	atomic.StoreInt32(&calls, 0)
+	post := Post(server.URL).WithReq(map[string]string{"data": "a"}).Retry(3, backoff)
+	if err := post.Do(context.Background()); err == nil || calls != 1 {
+		t.Fatalf("expected post without key not to be retried,got:%v,calls:%d", err, calls)
+	}
+	atomic.StoreInt32(&calls, 0)
+	if err := post.WithIdempotencyKey("k").Do(context.Background()); err != nil || calls != 3 {
+		t.Fatalf("expected keyed post to be retried,got:%v,calls:%d", err, calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	err := Get(server.URL+"/down").Retry(3, backoff).Do(context.Background())
+	retryErr := &ErrRetryFailed{}
+	statusErr := &ErrUnexpectedStatusCode{}
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 3 || !errors.As(err, &statusErr) || statusErr.Got != http.StatusInternalServerError {
+		t.Fatalf("expected retry failed after 3 attempts,got:%v", err)
+	}
+	if calls != 3 || OutcomeFromError(err) != OutcomeReceived {
+		t.Fatalf("expected 3 calls and received outcome,got:%d,%s", calls, OutcomeFromError(err))
+	}
+
+	// Timeout覆盖所有尝试
+	start := time.Now()
+	err = Get(server.URL+"/down").Timeout(time.Millisecond*100).Retry(100, ConstantBackoff(time.Millisecond*20)).Do(context.Background())
+	if !errors.As(err, &retryErr) || retryErr.Attempts > 6 || time.Since(start) > time.Millisecond*500 {
+		t.Fatalf("expected retries to stop at timeout,got:%v after %s", err, time.Since(start))
+	}
+}
+
+func TestRetryConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	err := Get(url).Retry(3, ConstantBackoff(time.Millisecond)).Do(context.Background())
+	retryErr := &ErrRetryFailed{}
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 3 || OutcomeFromError(err) != OutcomeNotSent {
+		t.Fatalf("expected 3 attempts on dial errors,got:%v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var calls int32
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1)%2 == 1 {
+			retryAfter := "1"
+			if r.URL.Query().Get("form") == "date" {
+				retryAfter = time.Now().Add(time.Second * 3).UTC().Format(http.TimeFormat)
+			}
+			w.Header().Set(RetryAfterKey, retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	start := time.Now()
+	if err := Get(server.URL).Retry(2, ConstantBackoff(time.Millisecond)).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected to wait for Retry-After:1,waited:%s", elapsed)
+	}
+
+	// 超过上限时立即失败
+	start = time.Now()
+	err := Get(server.URL).WithQueryString("form", "date").MaxRetryAfter(time.Second).Retry(2, ConstantBackoff(time.Millisecond)).Do(context.Background())
+	tooLong := &ErrRetryAfterTooLong{}
+	if !errors.As(err, &tooLong) || tooLong.Delay <= time.Second || tooLong.Limit != time.Second || time.Since(start) > time.Millisecond*500 {
+		t.Fatalf("expected retry after too long,got:%v", err)
+	}
+	statusErr := &ErrUnexpectedStatusCode{}
+	if !errors.As(err, &statusErr) || statusErr.Got != http.StatusTooManyRequests {
+		t.Fatalf("expected wrapped 429,got:%v", err)
+	}
+
+	// 超过ctx剩余时间时同样立即失败,ResiliencePolicy也遵守Retry-After
+	atomic.StoreInt32(&calls, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+	err = Get(server.URL).ResiliencePolicy(ResiliencePolicy{Retries: 1}).Do(ctx)
+	if !errors.As(err, &tooLong) || tooLong.Delay != time.Second || tooLong.Limit > time.Millisecond*500 || ctx.Err() != nil {
+		t.Fatalf("expected retry after exceeding deadline,got:%v", err)
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	var calls int32
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	backoff := ConstantBackoff(time.Millisecond)
+	methods := map[string]bool{
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodPut:     true,
+		http.MethodDelete:  true,
+		http.MethodOptions: true,
+		http.MethodTrace:   true,
+		http.MethodPost:    false,
+		http.MethodPatch:   false,
+	}
+	for method, idempotent := range methods {
+		for _, nonIdempotent := range []bool{false, true} {
+			atomic.StoreInt32(&calls, 0)
+			err := Method(method, server.URL).Retry(2, backoff).RetryNonIdempotent(nonIdempotent).Do(context.Background())
+			retried := idempotent || nonIdempotent
+			if expected := map[bool]int32{true: 2, false: 1}[retried]; calls != expected {
+				t.Fatalf("expected %s(nonIdempotent=%t) calls:%d,got:%d", method, nonIdempotent, expected, calls)
+			}
+			skipped := &ErrRetrySkipped{}
+			if errors.As(err, &skipped) == retried {
+				t.Fatalf("expected %s(nonIdempotent=%t) skipped:%t,got:%v", method, nonIdempotent, !retried, err)
+			}
+			if !retried && (skipped.Method != method || !strings.Contains(err.Error(), "non-idempotent method "+method)) {
+				t.Fatalf("expected skipped method %s,got:%v", method, err)
+			}
+			statusErr := &ErrUnexpectedStatusCode{}
+			if !errors.As(err, &statusErr) || statusErr.Got != http.StatusServiceUnavailable {
+				t.Fatalf("expected wrapped 503,got:%v", err)
+			}
+		}
+	}
+
+	// ResiliencePolicy做同样的判断
+	atomic.StoreInt32(&calls, 0)
+	err := Post(server.URL).ResiliencePolicy(ResiliencePolicy{Retries: 1}).Do(context.Background())
+	skipped := &ErrRetrySkipped{}
+	if !errors.As(err, &skipped) || calls != 1 {
+		t.Fatalf("expected policy retries skipped,got:%v,calls:%d", err, calls)
+	}
+	atomic.StoreInt32(&calls, 0)
+	err = Post(server.URL).ResiliencePolicy(ResiliencePolicy{Retries: 1}).RetryNonIdempotent(true).Do(context.Background())
+	if errors.As(err, &skipped) || calls != 2 {
+		t.Fatalf("expected policy to retry post,got:%v,calls:%d", err, calls)
+	}
+}
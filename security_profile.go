@@ -0,0 +1,101 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync"
+)
+
+// SecurityProfile 预置的TLS安全基线
+type SecurityProfile int
+
+const (
+	// SecurityProfileNone 不修改Go默认的TLS配置
+	SecurityProfileNone SecurityProfile = iota
+	// SecurityProfileModern 只允许TLS 1.3
+	SecurityProfileModern
+	// SecurityProfileIntermediate 最低TLS 1.2,1.2只允许ECDHE+AEAD套件
+	SecurityProfileIntermediate
+	// SecurityProfileLegacy 兼容TLS 1.0的老旧服务端
+	SecurityProfileLegacy
+)
+
+// DefaultSecurityProfile Builder未指定SecurityProfile时使用的profile
+var DefaultSecurityProfile = SecurityProfileNone
+
+// ErrSecurityProfileWithTransport SecurityProfile无法作用于WithTransport指定的transport
+var ErrSecurityProfileWithTransport = errors.New("SecurityProfile can not be combined with WithTransport")
+
+func (p SecurityProfile) String() string {
+	switch p {
+	case SecurityProfileModern:
+		return "modern"
+	case SecurityProfileIntermediate:
+		return "intermediate"
+	case SecurityProfileLegacy:
+		return "legacy"
+	default:
+		return "none"
+	}
+}
+
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// apply 将profile写入config,SecurityProfileNone时不修改
+func (p SecurityProfile) apply(config *tls.Config) {
+	switch p {
+	case SecurityProfileModern:
+		config.MinVersion = tls.VersionTLS13
+		config.CurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256}
+		config.Renegotiation = tls.RenegotiateNever
+	case SecurityProfileIntermediate:
+		config.MinVersion = tls.VersionTLS12
+		config.CipherSuites = intermediateCipherSuites
+		config.CurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384}
+		config.Renegotiation = tls.RenegotiateNever
+	case SecurityProfileLegacy:
+		// 只放宽版本与套件,不允许重协商
+		config.MinVersion = tls.VersionTLS10
+		config.Renegotiation = tls.RenegotiateNever
+	}
+}
+
+// securityProfile 生效的profile,未指定时使用DefaultSecurityProfile;WithTLSConfig的配置不受默认profile影响
+func (b *builder) securityProfile() SecurityProfile {
+	if b.profileSet {
+		return b.profile
+	}
+	if b.tlsConfig != nil {
+		return SecurityProfileNone
+	}
+	return DefaultSecurityProfile
+}
+
+// securityProfileErr 显式指定的profile不能与自定义transport同时使用
+func (b *builder) securityProfileErr() error {
+	if b.profileSet && b.profile != SecurityProfileNone && b.transport != nil {
+		return ErrSecurityProfileWithTransport
+	}
+	return nil
+}
+
+var legacyTLSWarned sync.Map
+
+// observeNegotiatedTLS 统计低于TLS 1.2的握手,每种版本与套件的组合提示一次
+func observeNegotiatedTLS(state tls.ConnectionState) {
+	if state.Version >= tls.VersionTLS12 {
+		return
+	}
+	negotiated := tls.VersionName(state.Version) + "/" + tls.CipherSuiteName(state.CipherSuite)
+	if _, warned := legacyTLSWarned.LoadOrStore(negotiated, struct{}{}); !warned {
+		logWarn(context.Background(), "legacy tls negotiated", FieldTLSVersion, tls.VersionName(state.Version), FieldTLSCipher, tls.CipherSuiteName(state.CipherSuite))
+	}
+}
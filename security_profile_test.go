@@ -0,0 +1,121 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func newVersionedTLSServer(t *testing.T, config *tls.Config) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(testkit.Echo())
+	server.TLS = config
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSecurityProfileHandshake(t *testing.T) {
+	servers := map[string]*tls.Config{
+		"tls13":     {MinVersion: tls.VersionTLS13},
+		"tls12":     {MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS12},
+		"tls12-cbc": {MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA, tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}},
+		"tls11":     {MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11},
+	}
+	tests := []struct {
+		profile  SecurityProfile
+		accepted map[string]bool
+	}{
+		{profile: SecurityProfileModern, accepted: map[string]bool{"tls13": true}},
+		{profile: SecurityProfileIntermediate, accepted: map[string]bool{"tls13": true, "tls12": true}},
+		{profile: SecurityProfileLegacy, accepted: map[string]bool{"tls13": true, "tls12": true, "tls12-cbc": true, "tls11": true}},
+	}
+	urls := make(map[string]string)
+	for name, config := range servers {
+		urls[name] = newVersionedTLSServer(t, config).URL
+	}
+	for _, tt := range tests {
+		for name := range servers {
+			t.Run(tt.profile.String()+"/"+name, func(t *testing.T) {
+				err := Get(urls[name]).Insecure(true).SecurityProfile(tt.profile).Do(context.Background())
+				if tt.accepted[name] && err != nil {
+					t.Fatalf("expected handshake accepted,got:%s", err)
+				}
+				if !tt.accepted[name] && err == nil {
+					t.Fatal("expected handshake rejected")
+				}
+			})
+		}
+	}
+}
+
+func TestSecurityProfileRenegotiation(t *testing.T) {
+	for _, profile := range []SecurityProfile{SecurityProfileModern, SecurityProfileIntermediate, SecurityProfileLegacy} {
+		config := &tls.Config{Renegotiation: tls.RenegotiateFreelyAsClient}
+		profile.apply(config)
+		if config.Renegotiation != tls.RenegotiateNever {
+			t.Fatalf("%s:expected renegotiation disabled,got:%d", profile, config.Renegotiation)
+		}
+	}
+}
+
+func TestSecurityProfileDefault(t *testing.T) {
+	server := newVersionedTLSServer(t, &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11})
+	DefaultSecurityProfile = SecurityProfileIntermediate
+	defer func() { DefaultSecurityProfile = SecurityProfileNone }()
+
+	b := Get(server.URL).Insecure(true)
+	if desc := b.Describe(); !strings.HasSuffix(desc, " tls=intermediate") {
+		t.Fatalf("expected profile in describe,got:%s", desc)
+	}
+	if err := b.Do(context.Background()); err == nil {
+		t.Fatal("expected default profile to reject tls 1.1")
+	}
+	if err := b.SecurityProfile(SecurityProfileLegacy).Do(context.Background()); err != nil {
+		t.Fatalf("expected builder profile to override default,got:%s", err)
+	}
+}
+
+func TestSecurityProfileWithTransport(t *testing.T) {
+	server := testkit.NewTLSServer(t, testkit.Echo())
+	err := Get(server.URL).WithTransport(server.Client().Transport).SecurityProfile(SecurityProfileModern).Do(context.Background())
+	if !errors.Is(err, ErrSecurityProfileWithTransport) {
+		t.Fatalf("expected:%s,got:%v", ErrSecurityProfileWithTransport, err)
+	}
+
+	// 包级别的默认profile不作用于自定义transport
+	DefaultSecurityProfile = SecurityProfileModern
+	defer func() { DefaultSecurityProfile = SecurityProfileNone }()
+	if err := Get(server.URL).WithTransport(server.Client().Transport).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSecurityProfileConnHooks(t *testing.T) {
+	server := newVersionedTLSServer(t, &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11})
+	hooks, events := recordingHooks()
+	if err := Get(server.URL).Insecure(true).SecurityProfile(SecurityProfileLegacy).ConnEventHooks(hooks).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	nextConnEvent(t, events)
+	event := nextConnEvent(t, events)
+	if event.kind != "tls" || event.state.Version != tls.VersionTLS11 || event.state.CipherSuite == 0 {
+		t.Fatalf("unexpected tls event:%+v", event)
+	}
+
+	before := GetConnEventStats().LegacyTLSHandshakes
+	DefaultConnEventHooks.OnTLSHandshake(event.state, nil, 0)
+	stats := GetConnEventStats()
+	if stats.LegacyTLSHandshakes != before+1 {
+		t.Fatalf("expected legacy handshakes:%d,got:%d", before+1, stats.LegacyTLSHandshakes)
+	}
+	negotiated := "TLS 1.1/" + tls.CipherSuiteName(event.state.CipherSuite)
+	if stats.NegotiatedTLS[negotiated] == 0 {
+		t.Fatalf("expected negotiated tls:%s,got:%v", negotiated, stats.NegotiatedTLS)
+	}
+}
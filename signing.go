@@ -0,0 +1,143 @@
+package httpx
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SignatureKey = "X-Signature"
+
+// Signer 对最终发出的请求签名,body为请求体的副本,没有请求体时为nil
+type Signer interface {
+	Sign(httpReq *http.Request, body []byte) error
+}
+
+// ErrUnsignableBody 请求体无法通过GetBody读取,签名会消耗请求体
+type ErrUnsignableBody struct {
+	Method string
+	URL    string
+}
+
+func (e *ErrUnsignableBody) Error() string {
+	return fmt.Sprintf("cannot sign %s %s:body is not replayable", e.Method, e.URL)
+}
+
+// SigningTransport 使用signer对请求签名,请求体通过GetBody读取,不会消耗发送的请求体;
+// 签名需要覆盖最终的请求,应当放在所有修改header的wrapper之内
+func SigningTransport(signer Signer) TransportWrapper {
+	return NamedWrapper("signing", "", func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			var body []byte
+			if httpReq.Body != nil && httpReq.Body != http.NoBody {
+				if httpReq.GetBody == nil {
+					httpReq.Body.Close()
+					return nil, withOutcome(&ErrUnsignableBody{Method: httpReq.Method, URL: httpReq.URL.Redacted()}, OutcomeNotSent)
+				}
+				bodyCopy, err := httpReq.GetBody()
+				if err != nil {
+					httpReq.Body.Close()
+					return nil, withOutcome(err, OutcomeNotSent)
+				}
+				body, err = io.ReadAll(bodyCopy)
+				bodyCopy.Close()
+				if err != nil {
+					httpReq.Body.Close()
+					return nil, withOutcome(err, OutcomeNotSent)
+				}
+			}
+			httpReq = httpReq.Clone(httpReq.Context())
+			if err := signer.Sign(httpReq, body); err != nil {
+				if httpReq.Body != nil {
+					httpReq.Body.Close()
+				}
+				return nil, withOutcome(err, OutcomeNotSent)
+			}
+			return next.RoundTrip(httpReq)
+		})
+	})
+}
+
+type hmacSigner struct {
+	keyID      string
+	secret     []byte
+	headerName string
+}
+
+// HMACSigner 使用HMAC-SHA256对method、path(含query)、时间戳与body的sha256签名,
+// 写入headerName头,格式为 keyId=<keyID>,ts=<unix秒>,sig=<hex>;headerName为空时使用SignatureKey
+func HMACSigner(keyID, secret string, headerName string) Signer {
+	if headerName == "" {
+		headerName = SignatureKey
+	}
+	return &hmacSigner{keyID: keyID, secret: []byte(secret), headerName: headerName}
+}
+
+func (s *hmacSigner) Sign(httpReq *http.Request, body []byte) error {
+	ts := time.Now().Unix()
+	sig := hmacSignature(s.secret, httpReq.Method, httpReq.URL.RequestURI(), ts, body)
+	httpReq.Header.Set(s.headerName, fmt.Sprintf("keyId=%s,ts=%d,sig=%s", s.keyID, ts, sig))
+	return nil
+}
+
+func hmacSignature(secret []byte, method, path string, ts int64, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%s", method, path, ts, hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ErrSignatureVerification 请求的签名缺失、格式错误、过期或不匹配
+type ErrSignatureVerification struct {
+	KeyID  string
+	Reason string
+}
+
+func (e *ErrSignatureVerification) Error() string {
+	return fmt.Sprintf("signature verification failed,keyId:%q,%s", e.KeyID, e.Reason)
+}
+
+// VerifyHMACRequest 在server端校验HMACSigner的签名,secret按keyId查找密钥,
+// 时间戳与当前时间相差超过maxSkew时失败(<=0时不检查);请求体读取后会被还原
+func VerifyHMACRequest(httpReq *http.Request, secret func(keyID string) (string, bool), headerName string, maxSkew time.Duration) error {
+	if headerName == "" {
+		headerName = SignatureKey
+	}
+	fields := make(map[string]string, 3)
+	for _, part := range strings.Split(httpReq.Header.Get(headerName), ",") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			fields[key] = value
+		}
+	}
+	keyID := fields["keyId"]
+	ts, err := strconv.ParseInt(fields["ts"], 10, 64)
+	if keyID == "" || err != nil || fields["sig"] == "" {
+		return &ErrSignatureVerification{KeyID: keyID, Reason: "malformed signature header"}
+	}
+	if skew := time.Since(time.Unix(ts, 0)); maxSkew > 0 && (skew > maxSkew || skew < -maxSkew) {
+		return &ErrSignatureVerification{KeyID: keyID, Reason: fmt.Sprintf("timestamp skew %s exceeds %s", skew, maxSkew)}
+	}
+	key, ok := secret(keyID)
+	if !ok {
+		return &ErrSignatureVerification{KeyID: keyID, Reason: "unknown key"}
+	}
+	var body []byte
+	if httpReq.Body != nil && httpReq.Body != http.NoBody {
+		if body, err = io.ReadAll(httpReq.Body); err != nil {
+			return err
+		}
+		httpReq.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	expected := hmacSignature([]byte(key), httpReq.Method, httpReq.URL.RequestURI(), ts, body)
+	if !hmac.Equal([]byte(expected), []byte(fields["sig"])) {
+		return &ErrSignatureVerification{KeyID: keyID, Reason: "signature mismatch"}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestSigningTransport(t *testing.T) {
+	secrets := func(keyID string) (string, bool) {
+		return "secret", keyID == "k1"
+	}
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifyHMACRequest(r, secrets, "", time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		io.Copy(w, r.Body)
+	}))
+	signed := Post(server.URL+"/orders?id=1").
+		WithSigner(HMACSigner("k1", "secret", "")).
+		WithHeader("X-Tenant", "t1").
+		RequestID("")
+
+	resp := map[string]string{}
+	if err := signed.WithReq(map[string]string{"data": "a"}).WithResp(&resp).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if resp["data"] != "a" {
+		t.Fatalf("expected body to be sent after signing,got:%v", resp)
+	}
+	// 签名覆盖压缩后的请求体
+	if err := signed.WithReq(map[string]string{"data": "a"}).CompressRequest(ContentEncodingGzip).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := Post(server.URL).WithSigner(HMACSigner("k2", "secret", "")).Do(context.Background()); err == nil {
+		t.Fatal("expected unknown key to be rejected")
+	}
+
+	err := signed.WithBodyReader(io.MultiReader(strings.NewReader(`{"data":"a"}`))).Do(context.Background())
+	unsignable := &ErrUnsignableBody{}
+	if !errors.As(err, &unsignable) || OutcomeFromError(err) != OutcomeNotSent {
+		t.Fatalf("expected unsignable stream body,got:%v", err)
+	}
+}
+
+func TestVerifyHMACRequest(t *testing.T) {
+	secrets := func(keyID string) (string, bool) {
+		return "secret", true
+	}
+	newReq := func(body string) *http.Request {
+		httpReq := httptest.NewRequest(http.MethodPost, "/orders?id=1", strings.NewReader(body))
+		if err := HMACSigner("k1", "secret", "").Sign(httpReq, []byte(`{"data":"a"}`)); err != nil {
+			t.Fatal(err)
+		}
+		return httpReq
+	}
+	httpReq := newReq(`{"data":"a"}`)
+	if err := VerifyHMACRequest(httpReq, secrets, "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(httpReq.Body); string(body) != `{"data":"a"}` {
+		t.Fatalf("expected body to be restored,got:%s", body)
+	}
+
+	verifyErr := &ErrSignatureVerification{}
+	if err := VerifyHMACRequest(newReq(`{"data":"b"}`), secrets, "", time.Minute); !errors.As(err, &verifyErr) || verifyErr.Reason != "signature mismatch" {
+		t.Fatalf("expected signature mismatch,got:%v", err)
+	}
+	httpReq = newReq(`{"data":"a"}`)
+	httpReq.URL.RawQuery = "id=2"
+	if err := VerifyHMACRequest(httpReq, secrets, "", time.Minute); !errors.As(err, &verifyErr) {
+		t.Fatalf("expected query to be signed,got:%v", err)
+	}
+	httpReq = newReq(`{"data":"a"}`)
+	httpReq.Header.Set(SignatureKey, "keyId=k1,ts=1,sig=00")
+	if err := VerifyHMACRequest(httpReq, secrets, "", time.Minute); !errors.As(err, &verifyErr) || !strings.Contains(verifyErr.Reason, "skew") {
+		t.Fatalf("expected timestamp skew,got:%v", err)
+	}
+}
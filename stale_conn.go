@@ -0,0 +1,93 @@
+package httpx
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+var staleConnRetries uint64
+
+// GetStaleConnRetryCount 返回因连接池中的失效连接而重试的次数
+func GetStaleConnRetryCount() uint64 {
+	return atomic.LoadUint64(&staleConnRetries)
+}
+
+type staleConnTracker struct {
+	reused    int32
+	wrote     int32
+	firstByte int32
+}
+
+// StaleConnRetryTransport 复用的空闲连接已被服务端关闭、且没有收到任何响应时,在新连接上重试一次;
+// 请求已经写出时服务端可能已经处理,只重试幂等方法或带Idempotency-Key的请求,body需要可以重放(nil或有GetBody)
+func StaleConnRetryTransport(next http.RoundTripper) http.RoundTripper {
+	return newNamedTransport(WrapperInfo{Name: "stale_conn_retry"}, next, TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+		tracker := &staleConnTracker{}
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				if info.Reused {
+					atomic.StoreInt32(&tracker.reused, 1)
+				}
+			},
+			WroteHeaders: func() {
+				atomic.StoreInt32(&tracker.wrote, 1)
+			},
+			GotFirstResponseByte: func() {
+				atomic.StoreInt32(&tracker.firstByte, 1)
+			},
+		}
+		tracedReq := httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), trace))
+		httpResp, err := next.RoundTrip(tracedReq)
+		if err == nil || !tracker.stale(err) || !tracker.replayable(httpReq) || httpReq.Context().Err() != nil {
+			return httpResp, err
+		}
+		retryReq := httpReq.Clone(httpReq.Context())
+		if httpReq.Body != nil && httpReq.Body != http.NoBody {
+			if httpReq.GetBody == nil {
+				return httpResp, err
+			}
+			body, bodyErr := httpReq.GetBody()
+			if bodyErr != nil {
+				return httpResp, err
+			}
+			retryReq.Body = body
+		}
+		atomic.AddUint64(&staleConnRetries, 1)
+		logDebug(httpReq.Context(), "retry on stale pooled connection",
+			FieldHTTPMethod, httpReq.Method,
+			FieldHTTPURL, httpReq.URL.String(),
+			FieldErr, err,
+		)
+		return next.RoundTrip(retryReq)
+	}))
+}
+
+// stale 连接是复用的、没有收到响应的任何字节,并且错误是连接被对端关闭
+func (t *staleConnTracker) stale(err error) bool {
+	if atomic.LoadInt32(&t.reused) == 0 || atomic.LoadInt32(&t.firstByte) == 1 {
+		return false
+	}
+	return isStaleConnErr(err)
+}
+
+// replayable 请求没有写出,或者重复发送不会被服务端重复处理
+func (t *staleConnTracker) replayable(httpReq *http.Request) bool {
+	if atomic.LoadInt32(&t.wrote) == 0 {
+		return true
+	}
+	return isIdempotent(httpReq.Method) || httpReq.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+func isStaleConnErr(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	// http.Transport的errServerClosedIdle未导出
+	return strings.Contains(err.Error(), "server closed idle connection")
+}
@@ -0,0 +1,72 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+// 同一连接上的第二个请求到达时直接关闭连接,模拟服务端关闭空闲连接的竞争
+func newStaleConnServer(t *testing.T) (string, func(string) int) {
+	var mu sync.Mutex
+	served := make(map[string]int)
+	handled := make(map[string]int)
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		served[r.RemoteAddr]++
+		count := served[r.RemoteAddr]
+		handled[r.URL.Path]++
+		mu.Unlock()
+		if count == 2 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	return server.URL, func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled[path]
+	}
+}
+
+func TestStaleConnRetry(t *testing.T) {
+	url, handled := newStaleConnServer(t)
+	b := Post(url + "/key").WithReq(map[string]string{"a": "b"}).WithIdempotencyKey("key")
+	if err := b.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Do(context.Background()); err != nil {
+		t.Fatalf("expected stale connection to be retried,got:%v", err)
+	}
+	if got := handled("/key"); got != 3 {
+		t.Fatalf("expected 3 requests,got:%d", got)
+	}
+}
+
+func TestStaleConnNoRetryWrittenPost(t *testing.T) {
+	url, handled := newStaleConnServer(t)
+	before := GetStaleConnRetryCount()
+	b := Post(url + "/post").WithReq(map[string]string{"a": "b"})
+	if err := b.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Do(context.Background()); err == nil {
+		t.Fatal("expected written POST not to be retried")
+	}
+	// 第一次请求与被关闭连接的请求,各处理一次
+	if got := handled("/post"); got != 2 {
+		t.Fatalf("expected server to see the POST exactly once,got:%d requests", got)
+	}
+	if got := GetStaleConnRetryCount() - before; got != 0 {
+		t.Fatalf("expected 0 retry,got:%d", got)
+	}
+}
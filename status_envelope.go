@@ -0,0 +1,124 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// StatusEnvelopeOptions NewStatusJsonCodec解码与WithStatusEnvelope输出的响应包装格式
+type StatusEnvelopeOptions struct {
+	// CodeKey 业务码的字段名,默认code
+	CodeKey string
+	// MsgKey 错误信息的字段名,默认msg
+	MsgKey string
+	// DataKey 数据的字段名,默认data
+	DataKey string
+	// SuccessCodes 表示成功的业务码,数字与字符串都按字面值比较,如"0"、"200"、"OK";默认"0",服务端使用第一个
+	SuccessCodes []string
+	// MissingCodeOK 没有业务码字段或为null时视为成功
+	MissingCodeOK bool
+}
+
+// ErrEnvelope 响应的业务码不表示成功,Code为业务码的字面值,缺失时为空
+type ErrEnvelope struct {
+	Code string
+	Msg  string
+}
+
+func (e *ErrEnvelope) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("missing code,msg:%s", e.Msg)
+	}
+	return fmt.Sprintf("unexpected code:%s,msg:%s", e.Code, e.Msg)
+}
+
+type statusEnvelope struct {
+	opts         StatusEnvelopeOptions
+	successCodes map[string]struct{}
+}
+
+// NewStatusJsonCodec 按opts解码带业务码的json响应,成功时把数据字段解码到resp,否则返回ErrEnvelope;
+// 编码与JsonCodec相同
+func NewStatusJsonCodec(opts StatusEnvelopeOptions) Codec {
+	return newStatusEnvelope(opts)
+}
+
+func newStatusEnvelope(opts StatusEnvelopeOptions) *statusEnvelope {
+	if opts.CodeKey == "" {
+		opts.CodeKey = "code"
+	}
+	if opts.MsgKey == "" {
+		opts.MsgKey = "msg"
+	}
+	if opts.DataKey == "" {
+		opts.DataKey = "data"
+	}
+	if len(opts.SuccessCodes) == 0 {
+		opts.SuccessCodes = []string{"0"}
+	}
+	successCodes := make(map[string]struct{}, len(opts.SuccessCodes))
+	for _, code := range opts.SuccessCodes {
+		successCodes[code] = struct{}{}
+	}
+	return &statusEnvelope{opts: opts, successCodes: successCodes}
+}
+
+func (c *statusEnvelope) Decode(r io.Reader, obj interface{}) error {
+	var envelope map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&envelope); err != nil {
+		return err
+	}
+	code, exist := rawLiteral(envelope[c.opts.CodeKey])
+	if !exist && !c.opts.MissingCodeOK {
+		msg, _ := rawLiteral(envelope[c.opts.MsgKey])
+		return &ErrEnvelope{Msg: msg}
+	}
+	if _, success := c.successCodes[code]; exist && !success {
+		msg, _ := rawLiteral(envelope[c.opts.MsgKey])
+		return &ErrEnvelope{Code: code, Msg: msg}
+	}
+	data, exist := envelope[c.opts.DataKey]
+	if obj == nil || !exist {
+		return nil
+	}
+	return json.Unmarshal(data, obj)
+}
+
+func (c *statusEnvelope) Encode(obj interface{}) ([]byte, error) {
+	return json.Marshal(obj)
+}
+
+// rawLiteral 字符串去掉引号,其他值使用json字面值;字段缺失或为null时exist为false
+func rawLiteral(raw json.RawMessage) (string, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", false
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, true
+	}
+	return string(raw), true
+}
+
+// wrap 服务端构造响应包装,数字形式的code不加引号,data为nil时省略
+func (c *statusEnvelope) wrap(code, msg string, data interface{}) ([]byte, error) {
+	envelope := map[string]interface{}{
+		c.opts.CodeKey: codeLiteral(code),
+		c.opts.MsgKey:  msg,
+	}
+	if data != nil {
+		envelope[c.opts.DataKey] = data
+	}
+	return json.Marshal(envelope)
+}
+
+func codeLiteral(code string) interface{} {
+	if _, err := strconv.ParseFloat(code, 64); err == nil && json.Valid([]byte(code)) {
+		return json.RawMessage(code)
+	}
+	return code
+}
@@ -0,0 +1,64 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DoStream 从响应体中逐个解码json对象(如ndjson)并回调fn,直到响应结束、fn返回错误或ctx取消,
+// 响应体总会被关闭。响应体不记录日志,结束时记录解码出的对象数;
+// 不使用DefaultDeadline,持续时间由ctx或Timeout控制。Codec为*JsonCodec时使用它的解码选项
+func DoStream[T any](ctx context.Context, b Builder, fn func(T) error) error {
+	raw, ok := b.(*builder)
+	if !ok {
+		return fmt.Errorf("DoStream requires a Builder created by httpx,got:%T", b)
+	}
+	if raw.err != nil {
+		return raw.err
+	}
+	newBuilder := raw.cloneTransportIf(raw.respStream == nil)
+	newBuilder.noDefaultDeadline = true
+	newBuilder.respStream = func(httpResp *http.Response) error {
+		ctx := httpResp.Request.Context()
+		codec, ok := newBuilder.codec.(*JsonCodec)
+		if !ok {
+			codec = defaultCodec
+		}
+		decoder := codec.newDecoder(httpResp.Body)
+		events := 0
+		err := func() error {
+			for {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				var item T
+				if err := decoder.Decode(&item); err != nil {
+					if errors.Is(err, io.EOF) {
+						return nil
+					}
+					return unknownFieldErr(err, &item)
+				}
+				events++
+				if err := fn(item); err != nil {
+					return err
+				}
+			}
+		}()
+		if newBuilder.loggingResp {
+			kvs := []interface{}{
+				FieldHTTPMethod, httpResp.Request.Method,
+				FieldHTTPURL, httpResp.Request.URL.Redacted(),
+				FieldRespEvents, events,
+			}
+			if err != nil {
+				kvs = append(kvs, FieldErr, err)
+			}
+			logInfo(ctx, "got http stream", kvs...)
+		}
+		return err
+	}
+	return newBuilder.Do(ctx)
+}
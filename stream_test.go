@@ -0,0 +1,146 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+type streamEvent struct {
+	Seq int `json:"seq"`
+}
+
+// streamHandler 每隔delay写入并flush一个事件,events<0时一直写到客户端断开
+func streamHandler(events int, delay time.Duration, done chan<- struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if done != nil {
+			defer close(done)
+		}
+		w.Header().Set(ContentTypeKey, ContentTypeNDJSON)
+		for i := 1; events < 0 || i <= events; i++ {
+			if _, err := fmt.Fprintf(w, "{\"seq\":%d}\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+func TestDoStream(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	server := testkit.NewServer(t, streamHandler(3, 50*time.Millisecond, nil))
+	start := time.Now()
+	var got []int
+	var firstAt time.Duration
+	err := DoStream(context.Background(), Get(server.URL), func(event streamEvent) error {
+		if len(got) == 0 {
+			firstAt = time.Since(start)
+		}
+		got = append(got, event.Seq)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[1 2 3]" {
+		t.Fatalf("expected events:[1 2 3],got:%v", got)
+	}
+	if total := time.Since(start); firstAt >= total-50*time.Millisecond {
+		t.Fatalf("expected first event before the stream ended,first:%s,total:%s", firstAt, total)
+	}
+	logs.AssertField(t, "got http stream", FieldRespEvents, 3)
+	for _, record := range logs.Find("got http resp") {
+		if _, exist := record.Attrs[FieldRespData]; exist {
+			t.Fatalf("expected stream body not to be logged,got:%v", record.Attrs)
+		}
+	}
+}
+
+func TestDoStreamStop(t *testing.T) {
+	errStop := errors.New("stop")
+	tests := []struct {
+		name     string
+		run      func(ctx context.Context, cancel context.CancelFunc, url string) error
+		expected error
+	}{
+		{
+			name: "callback error",
+			run: func(ctx context.Context, cancel context.CancelFunc, url string) error {
+				return DoStream(ctx, Get(url), func(event streamEvent) error {
+					return errStop
+				})
+			},
+			expected: errStop,
+		},
+		{
+			name: "context canceled",
+			run: func(ctx context.Context, cancel context.CancelFunc, url string) error {
+				return DoStream(ctx, Get(url), func(event streamEvent) error {
+					cancel()
+					return nil
+				})
+			},
+			expected: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan struct{})
+			server := testkit.NewServer(t, streamHandler(-1, 10*time.Millisecond, done))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if err := tt.run(ctx, cancel, server.URL); !errors.Is(err, tt.expected) {
+				t.Fatalf("expected err:%v,got:%v", tt.expected, err)
+			}
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("expected the body to be closed and the server to stop streaming")
+			}
+		})
+	}
+}
+
+// 没有设置Timeout时,流式与写入io.Writer的响应不受默认超时与DefaultDeadline限制
+func TestStreamNoDefaultTimeout(t *testing.T) {
+	prevTimeout, prevDeadline := defaultTransprtTimeout, DefaultDeadline
+	defaultTransprtTimeout, DefaultDeadline = 100*time.Millisecond, 100*time.Millisecond
+	t.Cleanup(func() {
+		defaultTransprtTimeout, DefaultDeadline = prevTimeout, prevDeadline
+	})
+	server := testkit.NewServer(t, streamHandler(6, 50*time.Millisecond, nil))
+
+	events := 0
+	if err := DoStream(context.Background(), Get(server.URL), func(event streamEvent) error {
+		events++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if events != 6 {
+		t.Fatalf("expected 6 events,got:%d", events)
+	}
+
+	var written int64
+	if err := Get(server.URL).WithRespWriter(io.Discard).WithRespWritten(&written).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if written != int64(len("{\"seq\":1}\n")*6) {
+		t.Fatalf("expected whole body written,got:%d", written)
+	}
+
+	if err := Get(server.URL).WithRespWriter(io.Discard).Timeout(100 * time.Millisecond).Do(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected explicit Timeout to cover the body,got:%v", err)
+	}
+}
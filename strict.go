@@ -0,0 +1,116 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// DefaultStrictOptions New()创建的Builder是否默认开启StrictOptions,可以在CI中打开
+var DefaultStrictOptions = false
+
+var optionConflictsWarned sync.Map
+
+// ErrOptionConflict 两个option同时设置时,其中一个会被忽略
+type ErrOptionConflict struct {
+	Option  string
+	Other   string
+	Winner  string
+	Details string
+}
+
+func (e *ErrOptionConflict) Error() string {
+	return fmt.Sprintf("option conflict %s and %s:%s wins,%s", e.Option, e.Other, e.Winner, e.Details)
+}
+
+// optionConflicts 检查会被静默忽略的option组合,ctx为nil时跳过与调用方context相关的检查
+func (b *builder) optionConflicts(ctx context.Context) []error {
+	var conflicts []error
+	if b.transport != nil {
+		if b.insecure {
+			conflicts = append(conflicts, &ErrOptionConflict{Option: "WithTransport", Other: "Insecure", Winner: "WithTransport", Details: "the custom transport decides tls verification"})
+		}
+		if len(b.insecureHosts) != 0 {
+			conflicts = append(conflicts, &ErrOptionConflict{Option: "WithTransport", Other: "InsecureForHosts", Winner: "WithTransport", Details: "the custom transport decides tls verification"})
+		}
+		if b.connHooks != nil {
+			conflicts = append(conflicts, &ErrOptionConflict{Option: "WithTransport", Other: "ConnEventHooks", Winner: "WithTransport", Details: "hooks are only installed on builder managed transports"})
+		}
+	}
+	if b.retryAttempts > 1 && b.policy != nil && b.policy.Retries > 0 {
+		conflicts = append(conflicts, &ErrOptionConflict{Option: "Retry", Other: "ResiliencePolicy", Winner: "both", Details: "each policy attempt is retried again by the transport"})
+	}
+	if b.respWriter != nil && (b.resp != nil || b.respValidator != nil) {
+		conflicts = append(conflicts, &ErrOptionConflict{Option: "WithRespWriter", Other: "WithResp", Winner: "WithRespWriter", Details: "the body is copied to the writer and not decoded"})
+	}
+	if isJsonCodec(b.codec) && !isJsonContentType(b.contentType) {
+		conflicts = append(conflicts, &ErrOptionConflict{Option: "WithCodec", Other: "ContentType", Winner: "WithCodec", Details: fmt.Sprintf("the body is json but Content-Type is %s", b.contentType)})
+	}
+	// 只检查显式调用的Tracing(true),默认开启的tracing在没有provider时本来就是空操作
+	if b.tracingSet && b.tracing && !tracerProviderConfigured() {
+		conflicts = append(conflicts, &ErrOptionConflict{Option: "Tracing", Other: "otel.SetTracerProvider", Winner: "otel.SetTracerProvider", Details: "no tracer provider is configured so spans are dropped"})
+	}
+	if ctx != nil && b.timeout > 0 {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < b.timeout {
+			conflicts = append(conflicts, &ErrOptionConflict{Option: "Timeout", Other: "context deadline", Winner: "context deadline", Details: fmt.Sprintf("the context expires before timeout %s", b.timeout)})
+		}
+	}
+	return conflicts
+}
+
+// checkOptions 返回不能组合的option错误,strict时还返回冲突,否则打印Warn日志
+func (b *builder) checkOptions(ctx context.Context) error {
+	if err := b.transportOptionsErr(); err != nil {
+		return err
+	}
+	conflicts := b.optionConflicts(ctx)
+	if len(conflicts) == 0 {
+		return nil
+	}
+	if b.strict {
+		return errors.Join(conflicts...)
+	}
+	logCtx := ctx
+	if logCtx == nil {
+		logCtx = context.Background()
+	}
+	for _, conflict := range conflicts {
+		// 相同的冲突只提示一次
+		if _, warned := optionConflictsWarned.LoadOrStore(conflict.Error(), struct{}{}); !warned {
+			logWarn(logCtx, "option conflict", FieldErr, conflict)
+		}
+	}
+	return nil
+}
+
+func isJsonCodec(codec Codec) bool {
+	switch codec.(type) {
+	case *JsonCodec, *StatusJsonCodec, *statusEnvelope, *transformJsonCodec:
+		return true
+	}
+	return false
+}
+
+func isJsonContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == ContentTypeJson || mediaType == ContentTypeNDJSON
+}
+
+// initialTracerProvider 包初始化时otel的全局provider,在调用SetTracerProvider之前不会产生span
+var initialTracerProvider = otel.GetTracerProvider()
+
+// tracerProviderConfigured 全局provider已经不是初始化时的provider
+func tracerProviderConfigured() bool {
+	return otel.GetTracerProvider() != initialTracerProvider
+}
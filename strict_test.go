@@ -0,0 +1,92 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestStrictOptions(t *testing.T) {
+	server := testkit.NewServer(t, testkit.Echo())
+	base := Get(server.URL).Tracing(false)
+	tests := []struct {
+		name    string
+		builder Builder
+		ctx     func() (context.Context, context.CancelFunc)
+		option  string
+		other   string
+		winner  string
+	}{
+		{name: "transport insecure", builder: base.WithTransport(http.DefaultTransport).Insecure(true), option: "WithTransport", other: "Insecure", winner: "WithTransport"},
+		{name: "transport insecure hosts", builder: base.WithTransport(http.DefaultTransport).InsecureForHosts("a"), option: "WithTransport", other: "InsecureForHosts", winner: "WithTransport"},
+		{name: "transport conn hooks", builder: base.WithTransport(http.DefaultTransport).ConnEventHooks(&ConnEventHooks{}), option: "WithTransport", other: "ConnEventHooks", winner: "WithTransport"},
+		{name: "codec content type", builder: base.ContentType("application/xml"), option: "WithCodec", other: "ContentType", winner: "WithCodec"},
+		{name: "tracing without provider", builder: base.Tracing(true), option: "Tracing", other: "otel.SetTracerProvider", winner: "otel.SetTracerProvider"},
+		{name: "timeout longer than context", builder: base.Timeout(time.Minute), ctx: func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), time.Second*5)
+		}, option: "Timeout", other: "context deadline", winner: "context deadline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if tt.ctx != nil {
+				ctx, cancel = tt.ctx()
+			}
+			defer cancel()
+
+			err := tt.builder.StrictOptions(true).Do(ctx)
+			var conflict *ErrOptionConflict
+			if !errors.As(err, &conflict) {
+				t.Fatalf("expected ErrOptionConflict,got:%v", err)
+			}
+			if conflict.Option != tt.option || conflict.Other != tt.other || conflict.Winner != tt.winner {
+				t.Fatalf("unexpected conflict:%+v", conflict)
+			}
+			if !strings.Contains(err.Error(), tt.option) || !strings.Contains(err.Error(), tt.other) {
+				t.Fatalf("expected error to name both options,got:%s", err)
+			}
+			if tt.ctx == nil {
+				if err := tt.builder.StrictOptions(true).Validate(); !errors.As(err, &conflict) {
+					t.Fatalf("expected Validate to report conflict,got:%v", err)
+				}
+			}
+
+			optionConflictsWarned = sync.Map{}
+			logs := testkit.CaptureLogs(t)
+			tt.builder.Do(ctx)
+			tt.builder.Do(ctx)
+			if got := len(logs.Find("option conflict")); got != 1 {
+				t.Fatalf("expected 1 warning,got:%d", got)
+			}
+		})
+	}
+}
+
+func TestStrictOptionsClean(t *testing.T) {
+	server := testkit.NewServer(t, testkit.Echo())
+	if err := Get(server.URL).StrictOptions(true).Timeout(time.Second).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTracerProviderConfigured(t *testing.T) {
+	if tracerProviderConfigured() {
+		t.Fatal("expected no tracer provider before SetTracerProvider")
+	}
+	t.Run("installed", func(t *testing.T) {
+		testkit.InstallTracer(t)
+		if !tracerProviderConfigured() {
+			t.Fatal("expected tracer provider after SetTracerProvider")
+		}
+	})
+	// 恢复为初始的provider后重新视为未配置
+	if tracerProviderConfigured() {
+		t.Fatal("expected no tracer provider after restore")
+	}
+}
@@ -0,0 +1,75 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timings 一次请求各阶段的耗时,没有发生的阶段为0;
+// 重试时连接相关的字段取最后一次尝试,TTFB与Total从第一次尝试开始计算
+type Timings struct {
+	// DNS 域名解析耗时
+	DNS time.Duration
+	// Connect 建立TCP连接的耗时
+	Connect time.Duration
+	// TLSHandshake TLS握手的耗时
+	TLSHandshake time.Duration
+	// TTFB 从开始请求到收到响应的第一个字节
+	TTFB time.Duration
+	// Total 从开始请求到结束,Do中包括读取响应体
+	Total time.Duration
+	// ConnReused 连接是否复用
+	ConnReused bool
+	// ConnWasIdle 复用的连接是否来自空闲连接池
+	ConnWasIdle bool
+	// ConnIdleTime 复用的连接空闲了多久
+	ConnIdleTime time.Duration
+}
+
+// TimingsTransport 每个请求结束时回调cb,成功时在响应体关闭后回调,失败时立即回调
+func TimingsTransport(cb func(Timings)) TransportWrapper {
+	return NamedWrapper("timings", "", func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			httpReq, timings := traceConnTimings(httpReq)
+			httpResp, err := next.RoundTrip(httpReq)
+			if err != nil {
+				cb(timings.snapshot())
+				return nil, err
+			}
+			httpResp.Body = &timingsBody{ReadCloser: httpResp.Body, done: func() {
+				cb(timings.snapshot())
+			}}
+			return httpResp, nil
+		})
+	})
+}
+
+type timingsBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *timingsBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// withTimings 设置了WithTimings时在ctx上追加httptrace回调
+func (b *builder) withTimings(ctx context.Context) (context.Context, *connTimings) {
+	if b.timings == nil {
+		return ctx, nil
+	}
+	return withConnTimings(ctx)
+}
+
+// setTimings 写入WithTimings指定的目标
+func (b *builder) setTimings(timings *connTimings) {
+	if timings != nil {
+		*b.timings = timings.snapshot()
+	}
+}
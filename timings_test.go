@@ -0,0 +1,72 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestWithTimings(t *testing.T) {
+	server := testkit.NewTLSServer(t, testkit.Echo())
+	var timings Timings
+	b := Get(server.URL).Insecure(true).WithTimings(&timings)
+	if err := b.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if timings.ConnReused || timings.Connect <= 0 || timings.TLSHandshake <= 0 {
+		t.Fatalf("expected a new tls connection,got:%+v", timings)
+	}
+	if timings.TTFB <= 0 || timings.Total < timings.TTFB {
+		t.Fatalf("expected 0<ttfb<=total,got:%+v", timings)
+	}
+
+	if err := b.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !timings.ConnReused || timings.Connect != 0 || timings.TLSHandshake != 0 {
+		t.Fatalf("expected a reused connection,got:%+v", timings)
+	}
+
+	slow := testkit.NewServer(t, testkit.Delay(time.Second, testkit.Echo()))
+	timings = Timings{}
+	err := Get(slow.URL).Timeout(50 * time.Millisecond).WithTimings(&timings).Do(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded,got:%v", err)
+	}
+	if timings.Connect <= 0 || timings.TTFB != 0 || timings.Total < 50*time.Millisecond {
+		t.Fatalf("expected completed phases only,got:%+v", timings)
+	}
+}
+
+func TestTimingsTransport(t *testing.T) {
+	server := testkit.NewServer(t, testkit.Echo())
+	var got []Timings
+	client := BuildClient(TimingsTransport(func(timings Timings) {
+		got = append(got, timings)
+	}))
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	if len(got) != 0 {
+		t.Fatalf("expected callback after close,got:%d", len(got))
+	}
+	resp.Body.Close()
+	resp.Body.Close()
+	if len(got) != 1 || got[0].TTFB <= 0 {
+		t.Fatalf("expected one callback with ttfb,got:%+v", got)
+	}
+
+	server.Close()
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("expected error from closed server")
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected callback on error,got:%d", len(got))
+	}
+}
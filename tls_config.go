@@ -0,0 +1,94 @@
+package httpx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTLSConfigWithInsecure Insecure会覆盖WithTLSConfig的证书校验
+	ErrTLSConfigWithInsecure = errors.New("WithTLSConfig can not be combined with Insecure")
+	// ErrTLSConfigWithSecurityProfile SecurityProfile会覆盖WithTLSConfig的版本与套件
+	ErrTLSConfigWithSecurityProfile = errors.New("WithTLSConfig can not be combined with SecurityProfile")
+	// ErrTLSConfigWithTransport WithTLSConfig无法作用于WithTransport指定的transport
+	ErrTLSConfigWithTransport = errors.New("WithTLSConfig can not be combined with WithTransport")
+)
+
+// WithTLSConfig 使用config的副本构造专用的transport,派生的Builder共享该transport
+func (b *builder) WithTLSConfig(config *tls.Config) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	newBuilder.tlsConfig = config.Clone()
+	return newBuilder
+}
+
+// WithClientCert 添加pem格式的客户端证书用于mTLS,与WithTLSConfig、WithRootCAs叠加
+func (b *builder) WithClientCert(certPEM, keyPEM []byte) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		newBuilder.err = fmt.Errorf("invalid client cert:%w", err)
+		return newBuilder
+	}
+	config := newBuilder.derivedTLSConfig()
+	config.Certificates = append(config.Certificates, cert)
+	newBuilder.tlsConfig = config
+	return newBuilder
+}
+
+// WithRootCAs 使用pool校验服务端证书,与WithTLSConfig、WithClientCert叠加
+func (b *builder) WithRootCAs(pool *x509.CertPool) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	config := newBuilder.derivedTLSConfig()
+	config.RootCAs = pool
+	newBuilder.tlsConfig = config
+	return newBuilder
+}
+
+// derivedTLSConfig 复制当前的config再修改,不影响派生出它的Builder
+func (b *builder) derivedTLSConfig() *tls.Config {
+	if b.tlsConfig == nil {
+		return &tls.Config{}
+	}
+	return b.tlsConfig.Clone()
+}
+
+// tlsConfigErr 自定义的TLS配置不能被其他TLS option覆盖
+func (b *builder) tlsConfigErr() error {
+	if b.tlsConfig == nil {
+		return nil
+	}
+	switch {
+	case b.transport != nil:
+		return ErrTLSConfigWithTransport
+	case b.insecure:
+		return ErrTLSConfigWithInsecure
+	case b.profileSet && b.profile != SecurityProfileNone:
+		return ErrTLSConfigWithSecurityProfile
+	}
+	return nil
+}
+
+// transportOptionsErr 作用于共享transport的option之间不能组合的错误
+func (b *builder) transportOptionsErr() error {
+	if err := b.securityProfileErr(); err != nil {
+		return err
+	}
+	if err := b.proxyErr(); err != nil {
+		return err
+	}
+	if b.transport != nil && b.transportOptions != (TransportOptions{}) {
+		return ErrTransportOptionsWithTransport
+	}
+	return b.tlsConfigErr()
+}
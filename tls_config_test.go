@@ -0,0 +1,159 @@
+package httpx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newClientCert 生成自签名的客户端证书,返回pem以及信任它的pool
+func newClientCert(t *testing.T) ([]byte, []byte, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "httpx-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		pool
+}
+
+func TestMutualTLS(t *testing.T) {
+	certPEM, keyPEM, clientCAs := newClientCert(t)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	serverCAs := x509.NewCertPool()
+	serverCAs.AddCert(server.Certificate())
+
+	trusted := Get(server.URL).WithRootCAs(serverCAs)
+	if err := trusted.Do(context.Background()); err == nil {
+		t.Fatal("expected handshake without client cert to fail")
+	}
+	if err := Get(server.URL).WithClientCert(certPEM, keyPEM).Do(context.Background()); err == nil {
+		t.Fatal("expected unknown server certificate to be rejected")
+	}
+	for name, builder := range map[string]Builder{
+		"root cas":   trusted.WithClientCert(certPEM, keyPEM),
+		"tls config": WithTLSConfig(&tls.Config{RootCAs: serverCAs}).Get(server.URL).WithClientCert(certPEM, keyPEM),
+	} {
+		resp := map[string]string{}
+		if err := builder.WithResp(&resp).Do(context.Background()); err != nil {
+			t.Fatalf("%s:expected mtls handshake to succeed,got:%s", name, err)
+		}
+		if resp["data"] != "httpx-client" {
+			t.Fatalf("%s:expected client cert to be presented,got:%v", name, resp)
+		}
+	}
+	// 派生的Builder不影响原来的配置
+	if err := trusted.Do(context.Background()); err == nil {
+		t.Fatal("expected original builder to stay without client cert")
+	}
+	if err := Get(server.URL).WithClientCert([]byte("bad"), keyPEM).Do(context.Background()); err == nil {
+		t.Fatal("expected invalid client cert error")
+	}
+}
+
+func TestTLSConfigConflicts(t *testing.T) {
+	config := &tls.Config{}
+	tests := []struct {
+		name     string
+		builder  Builder
+		expected error
+	}{
+		{name: "insecure", builder: WithTLSConfig(config).Insecure(true), expected: ErrTLSConfigWithInsecure},
+		{name: "security profile", builder: WithTLSConfig(config).SecurityProfile(SecurityProfileModern), expected: ErrTLSConfigWithSecurityProfile},
+		{name: "transport", builder: WithTLSConfig(config).WithTransport(Transport()), expected: ErrTLSConfigWithTransport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.builder.BuildTransport(context.Background()); !errors.Is(err, tt.expected) {
+				t.Fatalf("expected:%s,got:%v", tt.expected, err)
+			}
+			if err := tt.builder.Get("https://upstream.invalid").Do(context.Background()); !errors.Is(err, tt.expected) {
+				t.Fatalf("expected:%s,got:%v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestTLSConfigTransportShared(t *testing.T) {
+	prev := defaultTransportCache
+	defaultTransportCache = newTransportCache(defaultTransportCacheSize)
+	t.Cleanup(func() {
+		defaultTransportCache = prev
+	})
+	certPEM, keyPEM, _ := newClientCert(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	// 每次调用都会复制出新的config,内容相同时共享transport
+	for i := 0; i < 3; i++ {
+		if err := WithRootCAs(pool).WithClientCert(certPEM, keyPEM).Get(server.URL).Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := GetTransportCacheStats(); stats.Size != 1 || stats.Misses != 1 {
+		t.Fatalf("expected one shared transport,got:%+v", stats)
+	}
+
+	fingerprint := func(b Builder) string {
+		return b.(*builder).transportConfig().fingerprint()
+	}
+	base := fingerprint(WithRootCAs(pool))
+	for name, b := range map[string]Builder{
+		"other pool":  WithRootCAs(x509.NewCertPool()),
+		"client cert": WithRootCAs(pool).WithClientCert(certPEM, keyPEM),
+		"server name": WithTLSConfig(&tls.Config{RootCAs: pool, ServerName: "example.com"}),
+		"callback": WithTLSConfig(&tls.Config{RootCAs: pool, VerifyConnection: func(tls.ConnectionState) error {
+			return nil
+		}}),
+	} {
+		if fingerprint(b) == base {
+			t.Fatalf("%s:expected a different transport", name)
+		}
+	}
+	if got := fingerprint(WithTLSConfig(&tls.Config{RootCAs: pool})); got != base {
+		t.Fatal("expected equal tls configs to share a transport")
+	}
+}
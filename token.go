@@ -0,0 +1,144 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const AuthorizationKey = "Authorization"
+
+// TokenProvider 返回bearer token,例如包装oauth2.TokenSource
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// ExpiringTokenProvider 同时返回token的过期时间,过期前会被缓存;零值表示不过期
+type ExpiringTokenProvider interface {
+	TokenProvider
+	TokenWithExpiry(ctx context.Context) (string, time.Time, error)
+}
+
+type TokenProviderFunc func(ctx context.Context) (string, error)
+
+func (f TokenProviderFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// tokenExpiryDelta 提前刷新,避免token在请求途中过期
+const tokenExpiryDelta = time.Second * 10
+
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// tokenCache 缓存token,并发的刷新合并为一次
+type tokenCache struct {
+	provider TokenProvider
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time
+	call     *tokenCall
+}
+
+func (c *tokenCache) get(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	if c.token != "" && (c.expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(c.expiry)) {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+	call := c.call
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		c.call = call
+		// 刷新不受单个请求取消的影响,其他等待者仍然需要结果
+		go c.refresh(context.WithoutCancel(ctx), call)
+	}
+	c.mu.Unlock()
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (c *tokenCache) refresh(ctx context.Context, call *tokenCall) {
+	var expiry time.Time
+	if provider, ok := c.provider.(ExpiringTokenProvider); ok {
+		call.token, expiry, call.err = provider.TokenWithExpiry(ctx)
+	} else {
+		call.token, call.err = c.provider.Token(ctx)
+	}
+	c.mu.Lock()
+	if call.err == nil {
+		c.token, c.expiry = call.token, expiry
+	}
+	c.call = nil
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// invalidate 只丢弃被拒绝的token,已经刷新过的不受影响
+func (c *tokenCache) invalidate(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token, c.expiry = "", time.Time{}
+	}
+}
+
+// TokenSourceTransport 为每个请求设置Authorization: Bearer,token缓存到过期为止;
+// 响应401时丢弃缓存并用新的token重试一次,body无法通过GetBody重放时不重试
+func TokenSourceTransport(provider TokenProvider) TransportWrapper {
+	cache := &tokenCache{provider: provider}
+	return NamedWrapper("token", "", func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			ctx := httpReq.Context()
+			hasBody := httpReq.Body != nil && httpReq.Body != http.NoBody
+			for attempt := 0; ; attempt++ {
+				token, err := cache.get(ctx)
+				if err != nil {
+					if hasBody {
+						httpReq.Body.Close()
+					}
+					return nil, withOutcome(err, OutcomeNotSent)
+				}
+				attemptReq := httpReq.Clone(ctx)
+				if attempt > 0 && hasBody {
+					if attemptReq.Body, err = httpReq.GetBody(); err != nil {
+						return nil, err
+					}
+				}
+				attemptReq.Header.Set(AuthorizationKey, "Bearer "+token)
+				httpResp, err := next.RoundTrip(attemptReq)
+				if !unauthorized(httpResp, err) {
+					return httpResp, err
+				}
+				cache.invalidate(token)
+				if attempt > 0 || (hasBody && httpReq.GetBody == nil) {
+					return httpResp, err
+				}
+				if httpResp != nil {
+					io.Copy(io.Discard, io.LimitReader(httpResp.Body, maxErrorBodyBytes))
+					httpResp.Body.Close()
+				}
+			}
+		})
+	})
+}
+
+// unauthorized 响应401,或者内层的状态码检查返回了401
+func unauthorized(httpResp *http.Response, err error) bool {
+	if err != nil {
+		var statusErr *ErrUnexpectedStatusCode
+		return errors.As(err, &statusErr) && statusErr.Got == http.StatusUnauthorized
+	}
+	return httpResp.StatusCode == http.StatusUnauthorized
+}
@@ -0,0 +1,97 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+type expiringTokens struct {
+	calls int32
+}
+
+func (p *expiringTokens) Token(ctx context.Context) (string, error) {
+	token, _, err := p.TokenWithExpiry(ctx)
+	return token, err
+}
+
+func (p *expiringTokens) TokenWithExpiry(ctx context.Context) (string, time.Time, error) {
+	atomic.AddInt32(&p.calls, 1)
+	// 在tokenExpiryDelta之内过期,每次都需要刷新
+	return "t1", time.Now().Add(tokenExpiryDelta / 2), nil
+}
+
+func TestTokenSourceTransport(t *testing.T) {
+	var valid atomic.Value
+	valid.Store("t1")
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(AuthorizationKey) != "Bearer "+valid.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.Copy(w, r.Body)
+	}))
+	var calls int32
+	provider := TokenProviderFunc(func(ctx context.Context) (string, error) {
+		time.Sleep(time.Millisecond * 20)
+		return fmt.Sprintf("t%d", atomic.AddInt32(&calls, 1)), nil
+	})
+	builder := Post(server.URL).Logging(false, false).WithTokenProvider(provider)
+
+	// 并发请求只刷新一次token
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- builder.Do(context.Background())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected 1 token fetch,got:%d", got)
+	}
+
+	// token失效后刷新并重放body
+	valid.Store("t2")
+	resp := map[string]string{}
+	if err := builder.WithReq(map[string]string{"data": "a"}).WithResp(&resp).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if resp["data"] != "a" || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("expected refreshed token and replayed body,got:%v,calls:%d", resp, calls)
+	}
+
+	// 新的token仍然被拒绝时只重试一次
+	valid.Store("never")
+	err := builder.Do(context.Background())
+	if got := atomic.LoadInt32(&calls); err == nil || got != 3 {
+		t.Fatalf("expected 401 after one refresh,got:%v,calls:%d", err, got)
+	}
+
+	valid.Store("t1")
+	expiring := &expiringTokens{}
+	expiringBuilder := Get(server.URL).Logging(false, false).WithTokenProvider(expiring)
+	for i := 0; i < 2; i++ {
+		if err := expiringBuilder.Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expiring.calls != 2 {
+		t.Fatalf("expected expiring token to be refreshed,got calls:%d", expiring.calls)
+	}
+}
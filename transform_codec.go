@@ -0,0 +1,303 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// NamingStrategy 将go字段名转换为json key
+type NamingStrategy func(string) string
+
+var (
+	// SnakeCase UserID -> user_id
+	SnakeCase NamingStrategy = snakeCase
+	// ScreamingSnakeCase UserID -> USER_ID
+	ScreamingSnakeCase NamingStrategy = func(name string) string {
+		return strings.ToUpper(snakeCase(name))
+	}
+	// LowerCamelCase UserID -> userID
+	LowerCamelCase NamingStrategy = lowerCamelCase
+)
+
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				prev := runes[i-1]
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+					sb.WriteByte('_')
+				}
+			}
+			sb.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func lowerCamelCase(name string) string {
+	runes := []rune(name)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+type transformJsonCodec struct {
+	naming NamingStrategy
+	fields sync.Map
+}
+
+// TransformJsonCodec 按naming转换未打json tag的字段名,json tag优先
+func TransformJsonCodec(naming NamingStrategy) Codec {
+	return &transformJsonCodec{
+		naming: naming,
+	}
+}
+
+func (c *transformJsonCodec) Encode(obj interface{}) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := c.rewrite(dec, &buf, reflect.TypeOf(obj), reflect.ValueOf(obj), true); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *transformJsonCodec) Decode(r io.Reader, obj interface{}) error {
+	var buf bytes.Buffer
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := c.rewrite(dec, &buf, reflect.TypeOf(obj), reflect.ValueOf(obj), false); err != nil {
+		return err
+	}
+	return json.Unmarshal(buf.Bytes(), obj)
+}
+
+// rewrite 逐个token地复制一个json值,并按typ转换其中的对象key;
+// val为typ对应的值,静态类型为interface时按val的动态类型转换
+func (c *transformJsonCodec) rewrite(dec *json.Decoder, buf *bytes.Buffer, typ reflect.Type, val reflect.Value, encode bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	typ, val = c.resolve(typ, val, encode)
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		data, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		return nil
+	}
+	switch delim {
+	case '{':
+		buf.WriteByte('{')
+		for first := true; dec.More(); first = false {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, ok := keyTok.(string)
+			if !ok {
+				return fmt.Errorf("unexpected object key:%v", keyTok)
+			}
+			key, valueType, value := c.mapKey(typ, val, key, encode)
+			if !first {
+				buf.WriteByte(',')
+			}
+			keyData, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			buf.Write(keyData)
+			buf.WriteByte(':')
+			if err := c.rewrite(dec, buf, valueType, value, encode); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case '[':
+		var elemType reflect.Type
+		if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+			elemType = typ.Elem()
+		}
+		buf.WriteByte('[')
+		for idx := 0; dec.More(); idx++ {
+			if idx > 0 {
+				buf.WriteByte(',')
+			}
+			var elem reflect.Value
+			if elemType != nil && val.IsValid() && idx < val.Len() {
+				elem = val.Index(idx)
+			}
+			if err := c.rewrite(dec, buf, elemType, elem, encode); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	}
+	// 读取结束的 } 或 ]
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	return nil
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// resolve 去掉val的指针与interface,有值时使用动态类型;
+// decode时与encoding/json相同,只有interface中是非nil的指针才解码到其中
+func (c *transformJsonCodec) resolve(typ reflect.Type, val reflect.Value, encode bool) (reflect.Type, reflect.Value) {
+	for val.IsValid() && (val.Kind() == reflect.Interface || val.Kind() == reflect.Pointer) {
+		if val.IsNil() || (!encode && val.Kind() == reflect.Interface && val.Elem().Kind() != reflect.Pointer) {
+			val = reflect.Value{}
+			break
+		}
+		val = val.Elem()
+	}
+	if val.IsValid() {
+		typ = val.Type()
+	}
+	return c.underlying(typ), val
+}
+
+// underlying 去掉指针,自定义序列化的类型不做转换
+func (c *transformJsonCodec) underlying(typ reflect.Type) reflect.Type {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() == reflect.Interface {
+		return nil
+	}
+	ptr := reflect.PointerTo(typ)
+	if ptr.Implements(jsonMarshalerType) || ptr.Implements(jsonUnmarshalerType) {
+		return nil
+	}
+	return typ
+}
+
+// mapKey 返回转换后的key以及对应的值的类型与值
+func (c *transformJsonCodec) mapKey(typ reflect.Type, val reflect.Value, key string, encode bool) (string, reflect.Type, reflect.Value) {
+	if typ == nil {
+		return key, nil, reflect.Value{}
+	}
+	switch typ.Kind() {
+	case reflect.Map:
+		var value reflect.Value
+		if val.IsValid() && typ.Key().Kind() == reflect.String {
+			value = val.MapIndex(reflect.ValueOf(key).Convert(typ.Key()))
+		}
+		return key, typ.Elem(), value
+	case reflect.Struct:
+		fields := c.structFields(typ)
+		keys := fields.decodeKeys
+		if encode {
+			keys = fields.encodeKeys
+		}
+		field, ok := keys[key]
+		if !ok {
+			return key, nil, reflect.Value{}
+		}
+		var value reflect.Value
+		if val.IsValid() {
+			// 嵌入的nil指针没有对应的值
+			value, _ = val.FieldByIndexErr(field.index)
+		}
+		return field.key, field.typ, value
+	}
+	return key, nil, reflect.Value{}
+}
+
+type transformField struct {
+	key   string
+	typ   reflect.Type
+	index []int
+}
+
+type transformFields struct {
+	// go输出的key -> 转换后的key
+	encodeKeys map[string]transformField
+	// 收到的key -> go能识别的key
+	decodeKeys map[string]transformField
+}
+
+func (c *transformJsonCodec) structFields(typ reflect.Type) *transformFields {
+	if cached, ok := c.fields.Load(typ); ok {
+		return cached.(*transformFields)
+	}
+	fields := &transformFields{
+		encodeKeys: make(map[string]transformField),
+		decodeKeys: make(map[string]transformField),
+	}
+	for _, field := range reflect.VisibleFields(typ) {
+		if !field.IsExported() || !flattened(typ, field.Index) {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			// 匿名结构体的字段会被提升到外层
+			if fieldType.Kind() == reflect.Struct {
+				continue
+			}
+		}
+		if name != "" {
+			fields.encodeKeys[name] = transformField{key: name, typ: field.Type, index: field.Index}
+			fields.decodeKeys[name] = transformField{key: name, typ: field.Type, index: field.Index}
+			continue
+		}
+		transformed := c.naming(field.Name)
+		fields.encodeKeys[field.Name] = transformField{key: transformed, typ: field.Type, index: field.Index}
+		fields.decodeKeys[transformed] = transformField{key: field.Name, typ: field.Type, index: field.Index}
+	}
+	c.fields.Store(typ, fields)
+	return fields
+}
+
+// flattened 判断字段是否会被encoding/json提升到typ这一层
+func flattened(typ reflect.Type, index []int) bool {
+	for i := 1; i < len(index); i++ {
+		parent := typ.FieldByIndex(index[:i])
+		if !parent.Anonymous {
+			return false
+		}
+		if name, _, _ := strings.Cut(parent.Tag.Get("json"), ","); name != "" {
+			return false
+		}
+	}
+	return true
+}
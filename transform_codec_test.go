@@ -0,0 +1,146 @@
+package httpx
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type transformBase struct {
+	CreatedAt string
+}
+
+type transformItem struct {
+	ItemID   int
+	Tags     []string
+	RawValue string `json:"raw"`
+}
+
+type transformOrder struct {
+	transformBase
+	OrderID     string
+	HTTPStatus  int
+	Items       []transformItem
+	Owner       *transformItem
+	Meta        map[string]transformItem
+	ignored     string
+	SkippedFlag bool `json:"-"`
+}
+
+func TestTransformJsonCodec(t *testing.T) {
+	given := &transformOrder{
+		transformBase: transformBase{CreatedAt: "now"},
+		OrderID:       "o1",
+		HTTPStatus:    200,
+		Items: []transformItem{
+			{ItemID: 1, Tags: []string{"a"}, RawValue: "x"},
+			{ItemID: 2},
+		},
+		Owner: &transformItem{ItemID: 3},
+		Meta:  map[string]transformItem{"KeepMe": {ItemID: 4}},
+	}
+	tests := []struct {
+		name     string
+		naming   NamingStrategy
+		expected []string
+	}{
+		{
+			name:     "snake",
+			naming:   SnakeCase,
+			expected: []string{`"created_at":"now"`, `"order_id":"o1"`, `"http_status":200`, `"item_id":1`, `"raw":"x"`, `"KeepMe":{`},
+		},
+		{
+			name:     "lower camel",
+			naming:   LowerCamelCase,
+			expected: []string{`"createdAt":"now"`, `"orderID":"o1"`, `"httpStatus":200`, `"itemID":1`, `"raw":"x"`},
+		},
+		{
+			name:     "screaming snake",
+			naming:   ScreamingSnakeCase,
+			expected: []string{`"CREATED_AT":"now"`, `"ORDER_ID":"o1"`, `"HTTP_STATUS":200`, `"ITEM_ID":1`, `"raw":"x"`},
+		},
+		{
+			name:     "custom",
+			naming:   func(name string) string { return "x_" + name },
+			expected: []string{`"x_OrderID":"o1"`, `"x_ItemID":1`, `"raw":"x"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec := TransformJsonCodec(tt.naming)
+			data, err := codec.Encode(given)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(string(data), expected) {
+					t.Fatalf("expected %s in %s", expected, data)
+				}
+			}
+			got := &transformOrder{}
+			if err := codec.Decode(bytes.NewReader(data), got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(given, got) {
+				t.Fatalf("expected:%#v,got:%#v", given, got)
+			}
+		})
+	}
+}
+
+func BenchmarkTransformJsonCodec(b *testing.B) {
+	given := &transformOrder{OrderID: "o1", Items: make([]transformItem, 100)}
+	for _, bench := range []struct {
+		name  string
+		codec Codec
+	}{
+		{name: "json", codec: &JsonCodec{}},
+		{name: "snake", codec: TransformJsonCodec(SnakeCase)},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				data, err := bench.codec.Encode(given)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := bench.codec.Decode(bytes.NewReader(data), &transformOrder{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestTransformJsonCodecInterfaceValues(t *testing.T) {
+	codec := TransformJsonCodec(SnakeCase)
+	tests := []struct {
+		name     string
+		given    interface{}
+		expected string
+	}{
+		{name: "interface field", given: struct{ Inner interface{} }{transformItem{ItemID: 1}}, expected: `{"inner":{"item_id":1,"tags":null,"raw":""}}`},
+		{name: "interface pointer", given: struct{ Inner interface{} }{&transformItem{ItemID: 1}}, expected: `{"inner":{"item_id":1,"tags":null,"raw":""}}`},
+		{name: "map of interface", given: map[string]interface{}{"KeepMe": transformItem{ItemID: 2}}, expected: `{"KeepMe":{"item_id":2,"tags":null,"raw":""}}`},
+		{name: "slice of interface", given: []interface{}{transformItem{ItemID: 3}, "x"}, expected: `[{"item_id":3,"tags":null,"raw":""},"x"]`},
+		{name: "top level interface", given: interface{}(&transformItem{ItemID: 4}), expected: `{"item_id":4,"tags":null,"raw":""}`},
+	}
+	for _, tt := range tests {
+		data, err := codec.Encode(tt.given)
+		if err != nil {
+			t.Fatalf("%s:%v", tt.name, err)
+		}
+		if string(data) != tt.expected {
+			t.Fatalf("%s:expected:%s,got:%s", tt.name, tt.expected, data)
+		}
+	}
+
+	// interface中是指针时解码到其中
+	got := struct{ Inner interface{} }{&transformItem{}}
+	if err := codec.Decode(strings.NewReader(`{"inner":{"item_id":5}}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if item := got.Inner.(*transformItem); item.ItemID != 5 {
+		t.Fatalf("expected item_id:5,got:%+v", item)
+	}
+}
@@ -5,172 +5,141 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
+	"net/url"
 	"sync"
-	"time"
 )
 
+// BuildTransport 每次都创建新的transport,tws只作用于这个transport
 func BuildTransport(tws ...TransportWrapper) http.RoundTripper {
-	dialer := &net.Dialer{
-		Timeout:   5 * time.Second,
-		KeepAlive: 30 * time.Second,
-		DualStack: true,
-	}
-	transport := &http.Transport{
-		IdleConnTimeout:     30 * time.Second,
-		MaxIdleConnsPerHost: 10,
-		MaxConnsPerHost:     10000,
-		MaxIdleConns:        10000,
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			conn, err := dialer.DialContext(ctx, network, addr)
-			if err != nil {
-				return nil, err
-			}
-			return conn, nil
-		},
-		DisableCompression:     false,
-		DisableKeepAlives:      false,
-		ResponseHeaderTimeout:  360 * time.Second,
-		ExpectContinueTimeout:  360 * time.Second,
-		MaxResponseHeaderBytes: 1 << 20,
-		WriteBufferSize:        1 << 12,
-		ReadBufferSize:         1 << 12,
-		ForceAttemptHTTP2:      false,
-	}
-	return WrapTransport(transport, tws...)
+	return BuildTransportWithOptions(TransportOptions{}, tws...)
 }
 
+// BuildWrappedTransport 创建带默认TransportWrapper的transport
 func BuildWrappedTransport() http.RoundTripper {
-	dialer := &net.Dialer{
-		Timeout:   5 * time.Second,
-		KeepAlive: 30 * time.Second,
-		DualStack: true,
-	}
-	transport := &http.Transport{
-		IdleConnTimeout:     30 * time.Second,
-		MaxIdleConnsPerHost: 10,
-		MaxConnsPerHost:     1000,
-		MaxIdleConns:        1000,
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			conn, err := dialer.DialContext(ctx, network, addr)
-			if err != nil {
-				return nil, err
-			}
-			return conn, nil
-		},
-		DisableCompression:     false,
-		DisableKeepAlives:      false,
-		ResponseHeaderTimeout:  360 * time.Second,
-		ExpectContinueTimeout:  360 * time.Second,
-		MaxResponseHeaderBytes: 1 << 10,
-		WriteBufferSize:        1 << 12,
-		ReadBufferSize:         1 << 12,
-		ForceAttemptHTTP2:      false,
-	}
-	return DefaultTransportWrapper(transport)
+	return BuildTransport(DefaultTransportChain)
 }
 
+// BuildInsecureTransport 每次都创建新的不校验证书的transport,tws只作用于这个transport
 func BuildInsecureTransport(tws ...TransportWrapper) http.RoundTripper {
+	return WrapTransportChain(newTransport(transportConfig{insecure: true}), tws...)
+}
+
+// BuildWrappedInsecureTransport 创建带默认TransportWrapper且不校验证书的transport
+func BuildWrappedInsecureTransport() http.RoundTripper {
+	return BuildInsecureTransport(DefaultTransportChain)
+}
+
+// BuildInsecureForHostsTransport 只对hosts跳过证书校验
+func BuildInsecureForHostsTransport(hosts []string, tws ...TransportWrapper) http.RoundTripper {
+	return WrapTransportChain(newTransport(transportConfig{insecureHosts: hosts}), tws...)
+}
+
+// BuildConnEventTransport 构造的transport在连接建立、TLS握手以及关闭时回调hooks
+func BuildConnEventTransport(hooks *ConnEventHooks, tws ...TransportWrapper) http.RoundTripper {
+	return WrapTransportChain(connReuseTransport(newTransport(transportConfig{connHooks: hooks})), tws...)
+}
+
+// BuildSecurityProfileTransport 构造的transport按profile限制TLS版本与套件
+func BuildSecurityProfileTransport(profile SecurityProfile, tws ...TransportWrapper) http.RoundTripper {
+	return WrapTransportChain(newTransport(transportConfig{profile: profile}), tws...)
+}
+
+// newTransport 按config构造transport
+func newTransport(config transportConfig) *http.Transport {
+	opts := config.options.withDefaults()
 	dialer := &net.Dialer{
-		Timeout:   5 * time.Second,
-		KeepAlive: 30 * time.Second,
-		DualStack: true,
+		Timeout:   opts.DialTimeout,
+		KeepAlive: opts.KeepAlive,
 	}
+	dial := config.connHooks.wrapDial(dialer.DialContext)
 	transport := &http.Transport{
-		IdleConnTimeout:     30 * time.Second,
-		MaxIdleConnsPerHost: 10,
-		MaxConnsPerHost:     1000,
-		MaxIdleConns:        1000,
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			conn, err := dialer.DialContext(ctx, network, addr)
-			if err != nil {
-				return nil, err
-			}
-			return conn, nil
-		},
-		DisableCompression:     false,
-		DisableKeepAlives:      false,
-		ResponseHeaderTimeout:  360 * time.Second,
-		ExpectContinueTimeout:  360 * time.Second,
-		MaxResponseHeaderBytes: 1 << 10,
-		WriteBufferSize:        1 << 12,
-		ReadBufferSize:         1 << 12,
-		ForceAttemptHTTP2:      false,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
+		IdleConnTimeout:        opts.IdleConnTimeout,
+		MaxIdleConnsPerHost:    opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:        opts.MaxConnsPerHost,
+		MaxIdleConns:           opts.MaxIdleConns,
+		DialContext:            dial,
+		DisableCompression:     opts.DisableCompression,
+		DisableKeepAlives:      opts.DisableKeepAlives,
+		ResponseHeaderTimeout:  opts.ResponseHeaderTimeout,
+		ExpectContinueTimeout:  opts.ExpectContinueTimeout,
+		MaxResponseHeaderBytes: opts.MaxResponseHeaderBytes,
+		WriteBufferSize:        opts.WriteBufferSize,
+		ReadBufferSize:         opts.ReadBufferSize,
+		ForceAttemptHTTP2:      opts.ForceHTTP2,
 	}
-	return WrapTransport(transport, tws...)
-
+	transport.TLSClientConfig = config.clientTLSConfig()
+	if config.proxy != "" {
+		// 已经在WithProxy/ProxyTransport中校验过
+		proxyURL, _ := url.Parse(config.proxy)
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if len(config.insecureHosts) != 0 || config.connHooks != nil {
+		transport.DialTLSContext = dialTLS(dial, transport.TLSClientConfig, config.insecureHosts, config.connHooks)
+	}
+	return transport
 }
 
-func BuildWrappedInsecureTransport() http.RoundTripper {
-	dialer := &net.Dialer{
-		Timeout:   5 * time.Second,
-		KeepAlive: 30 * time.Second,
-		DualStack: true,
+// clientTLSConfig 按config生成transport的TLS配置,没有任何TLS设置时为nil
+func (config transportConfig) clientTLSConfig() *tls.Config {
+	var tlsConfig *tls.Config
+	if config.options.TLSConfig != nil {
+		tlsConfig = config.options.TLSConfig.Clone()
 	}
-	transport := &http.Transport{
-		IdleConnTimeout:     30 * time.Second,
-		MaxIdleConnsPerHost: 10,
-		MaxConnsPerHost:     1000,
-		MaxIdleConns:        1000,
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			conn, err := dialer.DialContext(ctx, network, addr)
-			if err != nil {
-				return nil, err
-			}
-			return conn, nil
-		},
-		DisableCompression:     false,
-		DisableKeepAlives:      false,
-		ResponseHeaderTimeout:  360 * time.Second,
-		ExpectContinueTimeout:  360 * time.Second,
-		MaxResponseHeaderBytes: 1 << 10,
-		WriteBufferSize:        1 << 12,
-		ReadBufferSize:         1 << 12,
-		ForceAttemptHTTP2:      false,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
+	if config.insecure {
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		tlsConfig.InsecureSkipVerify = true
 	}
-	return DefaultTransportWrapper(transport)
+	if config.tlsConfig != nil {
+		tlsConfig = config.tlsConfig.Clone()
+	}
+	if config.profile != SecurityProfileNone {
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		config.profile.apply(tlsConfig)
+	}
+	return tlsConfig
 }
 
+// 共享的transport在第一次调用对应的访问函数时创建,之后一直复用;
+// 各个单例互相独立,创建顺序与调用顺序一致。需要自定义TransportWrapper时使用BuildTransport等构造函数
 var (
-	transportOnce                sync.Once
-	transport                    http.RoundTripper
-	insecureTransportOnce        sync.Once
-	insecureTransport            http.RoundTripper
-	wrappedTransportOnce         sync.Once
-	wrappedTransport             http.RoundTripper
-	wrappedInsecureTransportOnce sync.Once
-	wrappedInsecureTransport     http.RoundTripper
+	sharedTransport                = sharedOnce("transport", func() http.RoundTripper { return BuildTransport() })
+	sharedInsecureTransport        = sharedOnce("insecure_transport", func() http.RoundTripper { return BuildInsecureTransport() })
+	sharedWrappedTransport         = sharedOnce("wrapped_transport", BuildWrappedTransport)
+	sharedWrappedInsecureTransport = sharedOnce("wrapped_insecure_transport", BuildWrappedInsecureTransport)
 )
 
-func Transport(tws ...TransportWrapper) http.RoundTripper {
-	transportOnce.Do(func() {
-		transport = BuildTransport(tws...)
+// sharedOnce 创建时注册closer,Close时关闭空闲连接,transport本身仍然可用
+func sharedOnce(name string, build func() http.RoundTripper) func() http.RoundTripper {
+	return sync.OnceValue(func() http.RoundTripper {
+		transport := build()
+		defaultLifecycle.register(name, closeStageTransports, func(ctx context.Context) error {
+			closeIdleConnections(transport)
+			return nil
+		})
+		return transport
 	})
-	return transport
 }
 
-func InsecureTransport(tws ...TransportWrapper) http.RoundTripper {
-	insecureTransportOnce.Do(func() {
-		insecureTransport = BuildInsecureTransport(tws...)
-	})
-	return insecureTransport
+// Transport 共享的transport
+func Transport() http.RoundTripper {
+	return sharedTransport()
 }
 
+// InsecureTransport 共享的不校验证书的transport
+func InsecureTransport() http.RoundTripper {
+	return sharedInsecureTransport()
+}
+
+// WrappedTransport 共享的带默认TransportWrapper的transport
 func WrappedTransport() http.RoundTripper {
-	wrappedTransportOnce.Do(func() {
-		wrappedTransport = BuildWrappedTransport()
-	})
-	return wrappedTransport
+	return sharedWrappedTransport()
 }
 
+// WrappedInsecureTransport 共享的带默认TransportWrapper且不校验证书的transport
 func WrappedInsecureTransport() http.RoundTripper {
-	wrappedInsecureTransportOnce.Do(func() {
-		wrappedInsecureTransport = BuildWrappedInsecureTransport()
-	})
-	return wrappedInsecureTransport
+	return sharedWrappedInsecureTransport()
 }
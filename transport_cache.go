@@ -0,0 +1,178 @@
+package httpx
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultTransportCacheSize = 64
+)
+
+// transportConfig 影响transport的builder配置,相同配置共享同一个transport
+type transportConfig struct {
+	insecure      bool
+	insecureHosts []string
+	connHooks     *ConnEventHooks
+	profile       SecurityProfile
+	proxy         string
+	tlsConfig     *tls.Config
+	options       TransportOptions
+}
+
+// fingerprint 规范化后的配置摘要
+func (c transportConfig) fingerprint() string {
+	insecureHosts := append([]string(nil), c.insecureHosts...)
+	sort.Strings(insecureHosts)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "insecure=%t;", c.insecure)
+	fmt.Fprintf(&sb, "insecure_hosts=%s;", strings.Join(insecureHosts, ","))
+	fmt.Fprintf(&sb, "conn_hooks=%p;", c.connHooks)
+	fmt.Fprintf(&sb, "security_profile=%s;", c.profile)
+	fmt.Fprintf(&sb, "proxy=%s;", c.proxy)
+	fmt.Fprintf(&sb, "tls_config=%s;", tlsConfigFingerprint(c.tlsConfig))
+	// 零值字段与默认值相同的配置共享transport,TLSConfig按内容区分
+	options := c.options.withDefaults()
+	fmt.Fprintf(&sb, "options_tls_config=%s;", tlsConfigFingerprint(options.TLSConfig))
+	options.TLSConfig = nil
+	fmt.Fprintf(&sb, "transport_options=%+v;", options)
+	sum := sha256.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// tlsConfigFingerprint 按内容描述config,每次调用WithRootCAs等option复制出的config可以共享transport;
+// RootCAs与会话缓存等对象按指针区分,设置了回调时无法比较内容,按config的指针区分
+func tlsConfigFingerprint(config *tls.Config) string {
+	if config == nil {
+		return ""
+	}
+	if config.GetClientCertificate != nil || config.VerifyPeerCertificate != nil || config.VerifyConnection != nil ||
+		config.GetCertificate != nil || config.GetConfigForClient != nil || config.Rand != nil || config.Time != nil ||
+		config.KeyLogWriter != nil {
+		return fmt.Sprintf("%p", config)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "root_cas=%p,", config.RootCAs)
+	for _, cert := range config.Certificates {
+		sum := sha256.New()
+		for _, der := range cert.Certificate {
+			sum.Write(der)
+		}
+		fmt.Fprintf(&sb, "cert=%x,", sum.Sum(nil))
+	}
+	fmt.Fprintf(&sb, "server_name=%s,insecure=%t,", config.ServerName, config.InsecureSkipVerify)
+	fmt.Fprintf(&sb, "versions=%d-%d,", config.MinVersion, config.MaxVersion)
+	fmt.Fprintf(&sb, "cipher_suites=%v,curves=%v,", config.CipherSuites, config.CurvePreferences)
+	fmt.Fprintf(&sb, "next_protos=%v,renegotiation=%d,", config.NextProtos, config.Renegotiation)
+	fmt.Fprintf(&sb, "session_cache=%p,session_tickets_disabled=%t", config.ClientSessionCache, config.SessionTicketsDisabled)
+	return sb.String()
+}
+
+// TransportCacheStats transport缓存的统计信息
+type TransportCacheStats struct {
+	Size   int
+	Hits   int64
+	Misses int64
+}
+
+type transportCacheEntry struct {
+	key       string
+	transport *http.Transport
+}
+
+type transportCache struct {
+	sync.Mutex
+	max     int
+	entries map[string]*list.Element
+	lru     *list.List
+	hits    int64
+	misses  int64
+	// gen 淘汰或关闭transport时递增,builder缓存的wrapper链据此失效
+	gen atomic.Uint64
+}
+
+func newTransportCache(max int) *transportCache {
+	if max <= 0 {
+		max = defaultTransportCacheSize
+	}
+	return &transportCache{
+		max:     max,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+var defaultTransportCache = newDefaultTransportCache()
+
+func newDefaultTransportCache() *transportCache {
+	cache := newTransportCache(defaultTransportCacheSize)
+	defaultLifecycle.register("transport_cache", closeStageTransports, func(ctx context.Context) error {
+		cache.closeAll()
+		return nil
+	})
+	return cache
+}
+
+// get 返回config对应的transport,不存在时创建,超出容量时淘汰最久未使用的并关闭其空闲连接
+func (c *transportCache) get(config transportConfig) *http.Transport {
+	key := config.fingerprint()
+	c.Lock()
+	defer c.Unlock()
+	if elem, exist := c.entries[key]; exist {
+		c.hits++
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*transportCacheEntry).transport
+	}
+	c.misses++
+	transport := newTransport(config)
+	c.entries[key] = c.lru.PushFront(&transportCacheEntry{key: key, transport: transport})
+	for c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		entry := oldest.Value.(*transportCacheEntry)
+		c.lru.Remove(oldest)
+		delete(c.entries, entry.key)
+		entry.transport.CloseIdleConnections()
+		c.gen.Add(1)
+	}
+	return transport
+}
+
+// closeAll 关闭所有transport的空闲连接并清空缓存,之后的请求使用新的transport
+func (c *transportCache) closeAll() {
+	c.Lock()
+	defer c.Unlock()
+	for _, elem := range c.entries {
+		elem.Value.(*transportCacheEntry).transport.CloseIdleConnections()
+	}
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.gen.Add(1)
+}
+
+func (c *transportCache) generation() uint64 {
+	return c.gen.Load()
+}
+
+func (c *transportCache) stats() TransportCacheStats {
+	c.Lock()
+	defer c.Unlock()
+	return TransportCacheStats{
+		Size:   c.lru.Len(),
+		Hits:   c.hits,
+		Misses: c.misses,
+	}
+}
+
+// GetTransportCacheStats 返回builder共享transport缓存的统计信息
+func GetTransportCacheStats() TransportCacheStats {
+	return defaultTransportCache.stats()
+}
@@ -0,0 +1,69 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestTransportCache(t *testing.T) {
+	prev := defaultTransportCache
+	defaultTransportCache = newTransportCache(defaultTransportCacheSize)
+	t.Cleanup(func() {
+		defaultTransportCache = prev
+	})
+
+	configs := []func(Builder) Builder{
+		func(b Builder) Builder { return b },
+		func(b Builder) Builder { return b.Insecure(true) },
+		func(b Builder) Builder { return b.InsecureForHosts("a.example.com", "b.example.com") },
+		func(b Builder) Builder { return b.InsecureForHosts("b.example.com", "a.example.com") },
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	live := make(map[*http.Transport]struct{})
+	for i := 0; i < 2000; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b := configs[i%len(configs)](Get("http://example.com").WithHeader("X-Idx", "1")).(*builder)
+			if _, err := b.BuildTransport(context.Background()); err != nil {
+				t.Error(err)
+				return
+			}
+			transport := defaultTransportCache.get(b.transportConfig())
+			mu.Lock()
+			live[transport] = struct{}{}
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	if len(live) != 3 {
+		t.Fatalf("expected 3 live transports,got:%d", len(live))
+	}
+	stats := GetTransportCacheStats()
+	if stats.Size != 3 || stats.Misses != 3 {
+		t.Fatalf("unexpected stats:%+v", stats)
+	}
+
+	if _, err := WithTransport(http.DefaultTransport).BuildTransport(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetTransportCacheStats().Size; got != 3 {
+		t.Fatalf("expected WithTransport to bypass cache,size:%d", got)
+	}
+}
+
+func TestTransportCacheEviction(t *testing.T) {
+	cache := newTransportCache(2)
+	first := cache.get(transportConfig{insecureHosts: []string{"a"}})
+	cache.get(transportConfig{insecureHosts: []string{"b"}})
+	cache.get(transportConfig{insecureHosts: []string{"c"}})
+	if got := cache.stats().Size; got != 2 {
+		t.Fatalf("expected size:2,got:%d", got)
+	}
+	if cache.get(transportConfig{insecureHosts: []string{"a"}}) == first {
+		t.Fatal("expected evicted transport to be rebuilt")
+	}
+}
@@ -0,0 +1,61 @@
+package httpx
+
+import (
+	"net/http"
+	"sync"
+)
+
+// builtTransport 缓存builder构建好的wrapper链与client,clone时共享,
+// 修改影响wrapper链的字段时通过cloneTransport丢弃;共享transport被淘汰或关闭后重新构建
+type builtTransport struct {
+	sync.Mutex
+	cache     *transportCache
+	gen       uint64
+	transport http.RoundTripper
+	client    *http.Client
+}
+
+// builtClient 返回缓存的transport与client,没有缓存或已失效时重新构建
+func (b *builder) builtClient() (http.RoundTripper, *http.Client) {
+	memo := b.built
+	if memo == nil {
+		transport := b.buildTransport(false)
+		return transport, b.newClient(transport)
+	}
+	cache := defaultTransportCache
+	gen := cache.generation()
+	memo.Lock()
+	defer memo.Unlock()
+	if memo.client == nil || memo.cache != cache || memo.gen != gen {
+		memo.transport = b.buildTransport(false)
+		memo.client = b.newClient(memo.transport)
+		memo.cache, memo.gen = cache, gen
+	}
+	return memo.transport, memo.client
+}
+
+// newClient 代理的请求不跟随重定向,交给下游客户端处理
+func (b *builder) newClient(transport http.RoundTripper) *http.Client {
+	client := &http.Client{Transport: transport, Jar: b.jar}
+	if b.proxied {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return client
+}
+
+// cloneTransport clone并丢弃缓存的transport,修改影响wrapper链或client的字段时使用
+func (b *builder) cloneTransport() *builder {
+	newBuilder := b.clone()
+	newBuilder.built = &builtTransport{}
+	return newBuilder
+}
+
+// cloneTransportIf 只有是否设置会影响wrapper链的字段,changed为false时沿用缓存
+func (b *builder) cloneTransportIf(changed bool) *builder {
+	if changed {
+		return b.cloneTransport()
+	}
+	return b.clone()
+}
@@ -0,0 +1,116 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func okTransport() http.RoundTripper {
+	return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{ContentTypeKey: []string{ContentTypeJson}},
+			Body:       io.NopCloser(strings.NewReader(`{}`)),
+			Request:    httpReq,
+		}, nil
+	})
+}
+
+func TestBuiltTransportMemo(t *testing.T) {
+	prev := defaultTransportCache
+	defaultTransportCache = newTransportCache(defaultTransportCacheSize)
+	t.Cleanup(func() {
+		defaultTransportCache = prev
+	})
+
+	b := Get("http://example.com")
+	first, err := b.BuildTransport(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := b.BuildTransport(context.Background())
+	if first != second {
+		t.Fatal("expected transport to be reused by the same builder")
+	}
+	// 不影响wrapper链的修改沿用缓存
+	if derived, _ := b.WithHeader("X-Idx", "1").WithPathParam("id", "1").BuildTransport(context.Background()); derived != first {
+		t.Fatal("expected transport to be reused by a derived builder")
+	}
+	if changed, _ := b.Timeout(time.Second).BuildTransport(context.Background()); changed == first {
+		t.Fatal("expected a new transport after changing the timeout")
+	}
+	if changed, _ := b.WithRespHeaders(&http.Header{}).BuildTransport(context.Background()); changed == first {
+		t.Fatal("expected a new transport after capturing response headers")
+	}
+	defaultTransportCache.closeAll()
+	if rebuilt, _ := b.BuildTransport(context.Background()); rebuilt == first {
+		t.Fatal("expected transport to be rebuilt after the shared transport was closed")
+	}
+}
+
+func TestBuiltTransportDoInto(t *testing.T) {
+	var calls int
+	b := Get("http://example.com").Tracing(false).WithTransport(TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+		calls++
+		return okTransport().RoundTrip(httpReq)
+	}))
+	for i := 0; i < 3; i++ {
+		var resp map[string]interface{}
+		if err := b.DoInto(context.Background(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("expected calls:3,got:%d", calls)
+	}
+	if b.(*builder).built.client == nil {
+		t.Fatal("expected DoInto to share the builder's client")
+	}
+}
+
+func BenchmarkDoSequential(b *testing.B) {
+	const calls = 10000
+	newBuilder := func() Builder {
+		return Get("http://example.com").WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))).Tracing(false).WithTransport(okTransport())
+	}
+	b.Run("memoized", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			builder := newBuilder()
+			for j := 0; j < calls; j++ {
+				if err := builder.Do(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	// 每次调用从模板builder派生
+	b.Run("cloned", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			builder := newBuilder()
+			for j := 0; j < calls; j++ {
+				if err := builder.WithHeader("X-Idx", "1").Do(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("rebuilt", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			builder := newBuilder().(*builder)
+			for j := 0; j < calls; j++ {
+				builder.built = &builtTransport{}
+				if err := builder.Do(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
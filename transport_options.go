@@ -0,0 +1,108 @@
+package httpx
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrTransportOptionsWithTransport WithTransportOptions无法作用于WithTransport指定的transport
+var ErrTransportOptionsWithTransport = errors.New("WithTransportOptions can not be combined with WithTransport")
+
+// TransportOptions 构造transport的连接池与超时配置,零值字段使用DefaultTransportOptions中的值,
+// 整数字段<0时表示不限制
+type TransportOptions struct {
+	// DialTimeout 建连超时
+	DialTimeout time.Duration
+	// KeepAlive TCP keep-alive间隔
+	KeepAlive time.Duration
+	// MaxIdleConns 所有host的空闲连接总数
+	MaxIdleConns int
+	// MaxIdleConnsPerHost 每个host的空闲连接数
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost 每个host的连接数
+	MaxConnsPerHost int
+	// IdleConnTimeout 空闲连接的保留时间
+	IdleConnTimeout time.Duration
+	// ResponseHeaderTimeout 发送完请求后等待响应头的时间
+	ResponseHeaderTimeout time.Duration
+	// ExpectContinueTimeout 带Expect: 100-continue时等待服务端响应的时间
+	ExpectContinueTimeout time.Duration
+	// MaxResponseHeaderBytes 响应头的最大字节数
+	MaxResponseHeaderBytes int64
+	// WriteBufferSize 写缓冲大小
+	WriteBufferSize int
+	// ReadBufferSize 读缓冲大小
+	ReadBufferSize int
+	// TLSConfig 为nil时使用默认的TLS配置;builder的WithTLSConfig、Insecure与SecurityProfile在它之上生效
+	TLSConfig *tls.Config
+	// ForceHTTP2 设置了自定义的dial或TLS配置时仍然尝试HTTP/2
+	ForceHTTP2 bool
+	// DisableCompression 不自动请求gzip压缩的响应
+	DisableCompression bool
+	// DisableKeepAlives 每个请求使用新的连接
+	DisableKeepAlives bool
+}
+
+// DefaultTransportOptions 默认的transport配置
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		DialTimeout:            5 * time.Second,
+		KeepAlive:              30 * time.Second,
+		MaxIdleConns:           10000,
+		MaxIdleConnsPerHost:    10,
+		MaxConnsPerHost:        10000,
+		IdleConnTimeout:        30 * time.Second,
+		ResponseHeaderTimeout:  360 * time.Second,
+		ExpectContinueTimeout:  360 * time.Second,
+		MaxResponseHeaderBytes: 1 << 20,
+		WriteBufferSize:        1 << 12,
+		ReadBufferSize:         1 << 12,
+	}
+}
+
+// withDefaults 零值字段使用默认值,<0的字段换成http.Transport中表示不限制的0
+func (o TransportOptions) withDefaults() TransportOptions {
+	defaults := DefaultTransportOptions()
+	o.DialTimeout = orDefault(o.DialTimeout, defaults.DialTimeout)
+	o.KeepAlive = orDefault(o.KeepAlive, defaults.KeepAlive)
+	o.MaxIdleConns = orDefault(o.MaxIdleConns, defaults.MaxIdleConns)
+	o.MaxIdleConnsPerHost = orDefault(o.MaxIdleConnsPerHost, defaults.MaxIdleConnsPerHost)
+	o.MaxConnsPerHost = orDefault(o.MaxConnsPerHost, defaults.MaxConnsPerHost)
+	o.IdleConnTimeout = orDefault(o.IdleConnTimeout, defaults.IdleConnTimeout)
+	o.ResponseHeaderTimeout = orDefault(o.ResponseHeaderTimeout, defaults.ResponseHeaderTimeout)
+	o.ExpectContinueTimeout = orDefault(o.ExpectContinueTimeout, defaults.ExpectContinueTimeout)
+	o.MaxResponseHeaderBytes = orDefault(o.MaxResponseHeaderBytes, defaults.MaxResponseHeaderBytes)
+	o.WriteBufferSize = orDefault(o.WriteBufferSize, defaults.WriteBufferSize)
+	o.ReadBufferSize = orDefault(o.ReadBufferSize, defaults.ReadBufferSize)
+	return o
+}
+
+func orDefault[T int | int64 | time.Duration](value, fallback T) T {
+	switch {
+	case value == 0:
+		return fallback
+	case value < 0:
+		return 0
+	}
+	return value
+}
+
+// BuildTransportWithOptions 按opts创建新的transport,tws只作用于这个transport
+func BuildTransportWithOptions(opts TransportOptions, tws ...TransportWrapper) http.RoundTripper {
+	return WrapTransportChain(newTransport(transportConfig{options: opts}), tws...)
+}
+
+// WithTransportOptions 使用opts构造专用的transport,派生的Builder共享该transport
+func (b *builder) WithTransportOptions(opts TransportOptions) Builder {
+	newBuilder := b.cloneTransport()
+	if newBuilder.err != nil {
+		return newBuilder
+	}
+	if opts.TLSConfig != nil {
+		opts.TLSConfig = opts.TLSConfig.Clone()
+	}
+	newBuilder.transportOptions = opts
+	return newBuilder
+}
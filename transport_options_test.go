@@ -0,0 +1,94 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTransportOptions(t *testing.T) {
+	defaults := DefaultTransportOptions()
+	tests := []struct {
+		name      string
+		transport http.RoundTripper
+		check     func(*http.Transport) bool
+	}{
+		{
+			name:      "defaults",
+			transport: BuildTransport(),
+			check: func(transport *http.Transport) bool {
+				return transport.MaxIdleConnsPerHost == defaults.MaxIdleConnsPerHost &&
+					transport.ResponseHeaderTimeout == defaults.ResponseHeaderTimeout &&
+					transport.MaxResponseHeaderBytes == defaults.MaxResponseHeaderBytes &&
+					!transport.ForceAttemptHTTP2
+			},
+		},
+		{
+			name:      "insecure matches secure pool",
+			transport: BuildInsecureTransport(),
+			check: func(transport *http.Transport) bool {
+				return transport.MaxConnsPerHost == defaults.MaxConnsPerHost &&
+					transport.MaxIdleConns == defaults.MaxIdleConns &&
+					transport.TLSClientConfig.InsecureSkipVerify
+			},
+		},
+		{
+			name: "overrides",
+			transport: BuildTransportWithOptions(TransportOptions{
+				MaxIdleConnsPerHost:   3,
+				MaxConnsPerHost:       -1,
+				ResponseHeaderTimeout: time.Second,
+				ForceHTTP2:            true,
+			}),
+			check: func(transport *http.Transport) bool {
+				return transport.MaxIdleConnsPerHost == 3 &&
+					transport.MaxConnsPerHost == 0 &&
+					transport.ResponseHeaderTimeout == time.Second &&
+					transport.IdleConnTimeout == defaults.IdleConnTimeout &&
+					transport.ForceAttemptHTTP2
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, ok := tt.transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("expected *http.Transport,got:%T", tt.transport)
+			}
+			if !tt.check(transport) {
+				t.Fatalf("unexpected transport:%+v", transport)
+			}
+		})
+	}
+}
+
+func TestWithTransportOptions(t *testing.T) {
+	prev := defaultTransportCache
+	defaultTransportCache = newTransportCache(defaultTransportCacheSize)
+	t.Cleanup(func() {
+		defaultTransportCache = prev
+	})
+
+	b := Get("http://example.com").WithTransportOptions(TransportOptions{MaxIdleConnsPerHost: 50}).(*builder)
+	if _, err := b.BuildTransport(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	transport := defaultTransportCache.get(b.transportConfig())
+	if transport.MaxIdleConnsPerHost != 50 {
+		t.Fatalf("expected MaxIdleConnsPerHost:50,got:%d", transport.MaxIdleConnsPerHost)
+	}
+	shared := defaultTransportCache.get(Get("http://example.com").(*builder).transportConfig())
+	if shared == transport {
+		t.Fatal("expected default builders to use a different transport")
+	}
+	if explicit := defaultTransportCache.get(Get("http://example.com").WithTransportOptions(DefaultTransportOptions()).(*builder).transportConfig()); explicit != shared {
+		t.Fatal("expected explicit default options to share the default transport")
+	}
+
+	_, err := b.WithTransport(http.DefaultTransport).BuildTransport(context.Background())
+	if !errors.Is(err, ErrTransportOptionsWithTransport) {
+		t.Fatalf("expected err:%v,got:%v", ErrTransportOptionsWithTransport, err)
+	}
+}
@@ -0,0 +1,261 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestSharedSingletons(t *testing.T) {
+	const goroutines = 200
+	var wg sync.WaitGroup
+	transports := make([][4]http.RoundTripper, goroutines)
+	clients := make([][4]*http.Client, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			transports[i] = [4]http.RoundTripper{Transport(), InsecureTransport(), WrappedTransport(), WrappedInsecureTransport()}
+			clients[i] = [4]*http.Client{Client(), InsecureClient(), WrappedClient(), WrappedInsecureClient()}
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < goroutines; i++ {
+		for j := range transports[i] {
+			if transports[i][j] == nil || clients[i][j] == nil {
+				t.Fatalf("expected non-nil singleton %d", j)
+			}
+			if clients[i][j] != clients[0][j] {
+				t.Fatalf("expected the same client %d across goroutines", j)
+			}
+		}
+	}
+}
+
+func TestBuildWithWrappers(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Caller")))
+	}))
+	tests := []struct {
+		name   string
+		client func(tws ...TransportWrapper) *http.Client
+	}{
+		{name: "client", client: BuildClient},
+		{name: "insecure client", client: BuildInsecureClient},
+		{name: "transport", client: func(tws ...TransportWrapper) *http.Client {
+			return &http.Client{Transport: BuildTransport(tws...)}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, caller := range []string{"a", "b", ""} {
+				var tws []TransportWrapper
+				if caller != "" {
+					tws = append(tws, HeaderTransport("X-Caller", caller))
+				}
+				resp, err := tt.client(tws...).Get(server.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(data) != caller {
+					t.Fatalf("expected caller:%s,got:%s", caller, data)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeoutPropagation(t *testing.T) {
+	server := testkit.NewServer(t, testkit.Delay(time.Second, testkit.Echo()))
+	start := time.Now()
+	err := Get(server.URL).Timeout(time.Millisecond * 50).Do(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded,got:%v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Millisecond*500 {
+		t.Fatalf("expected timeout to abort the request,took:%s", elapsed)
+	}
+}
+
+func TestCancellationPropagation(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	err := Get(server.URL).Do(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled,got:%v", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected cancellation to reach the server")
+	}
+}
+
+func TestUserAgentTransport(t *testing.T) {
+	var got string
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+	}))
+	client := &http.Client{Transport: WrapTransport(http.DefaultTransport, UserAgentTransport("svc/1.0"))}
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{header: "", expected: "svc/1.0"},
+		{header: "explicit", expected: "explicit"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		if tt.header != "" {
+			req.Header.Set("User-Agent", tt.header)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got != tt.expected {
+			t.Fatalf("expected User-Agent:%s,got:%s", tt.expected, got)
+		}
+	}
+}
+
+func TestErrUnexpectedStatusCode(t *testing.T) {
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(strings.Repeat("x", 1024)))
+	}))
+	tests := []struct {
+		name    string
+		wrapper TransportWrapper
+	}{
+		{name: "single", wrapper: StatusCodeTransport(http.StatusOK)},
+		{name: "multiple", wrapper: StatusCodesTransport(http.StatusOK, http.StatusAccepted)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: WrapTransport(http.DefaultTransport, tt.wrapper)}
+			_, err := client.Post(server.URL+"/users?token=secret", ContentTypeJson, nil)
+			statusErr := &ErrUnexpectedStatusCode{}
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("expected statuscode error,got:%v", err)
+			}
+			if statusErr.Got != http.StatusNotFound || statusErr.Method != http.MethodPost || statusErr.URL != server.URL+"/users?token=secret" ||
+				statusErr.Header.Get("X-Request-Id") != "req" || len(statusErr.Body) != 1024 {
+				t.Fatalf("unexpected statuscode error:%+v", statusErr)
+			}
+			if msg := statusErr.Error(); len(msg) > errorBodySnippetBytes+128 || !strings.Contains(msg, "got:404,content-type:text/plain; charset=utf-8,body:\"xxx") {
+				t.Fatalf("expected truncated body in error,got:%s", msg)
+			}
+		})
+	}
+}
+
+type trackingBody struct {
+	io.Reader
+	read   int
+	closed bool
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.read += n
+	return n, err
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestStatusCodesTransportWithLimit(t *testing.T) {
+	for _, limit := range []int64{0, 8} {
+		body := &trackingBody{Reader: strings.NewReader(`{"error":"database unavailable"}` + strings.Repeat(" ", 64))}
+		rt := StatusCodesTransportWithLimit(limit, http.StatusOK)(TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			header := http.Header{ContentTypeKey: []string{ContentTypeJson}}
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: header, Body: body}, nil
+		}))
+		httpReq, _ := http.NewRequest(http.MethodGet, "http://upstream.test", nil)
+		_, err := rt.RoundTrip(httpReq)
+		statusErr := &ErrUnexpectedStatusCode{}
+		if !errors.As(err, &statusErr) || !body.closed {
+			t.Fatalf("expected closed body and statuscode error,got:%v,closed:%t", err, body.closed)
+		}
+		if int64(len(statusErr.Body)) != limit || int64(body.read) > 2*limit || statusErr.ContentType != ContentTypeJson {
+			t.Fatalf("expected body limited to %d,got:%q,read:%d", limit, statusErr.Body, body.read)
+		}
+		expected := "expected statuscodes:[200],got:503"
+		if limit > 0 {
+			expected += `,content-type:application/json,body:"{\"error\""`
+		}
+		if err.Error() != expected {
+			t.Fatalf("expected:%s,got:%s", expected, err)
+		}
+	}
+}
+
+func TestLoggingTransportHeaders(t *testing.T) {
+	logs := testkit.CaptureLogs(t)
+	server := testkit.NewServer(t, testkit.Echo())
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	client := &http.Client{Transport: WrapTransport(http.DefaultTransport, LoggingTransportWithOptions(LoggingOptions{LogHeaders: true}))}
+	httpReq, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	httpReq.Header.Set(AuthorizationKey, "Bearer secret")
+	httpReq.Header.Set("X-Trace", "t1")
+	httpResp, err := client.Do(httpReq)
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	if printed, _ := io.ReadAll(r); len(printed) != 0 {
+		t.Fatalf("expected nothing on stdout,got:%s", printed)
+	}
+	records := logs.Find("send http req")
+	if len(records) != 1 {
+		t.Fatalf("expected 1 request log,got:%d", len(records))
+	}
+	reqHeader, _ := records[0].Attrs[FieldReqHeader].(http.Header)
+	if reqHeader.Get(AuthorizationKey) != redactedValue || reqHeader.Get("X-Trace") != "t1" {
+		t.Fatalf("unexpected logged header:%v", reqHeader)
+	}
+	if _, exist := logs.Find("got http resp")[0].Attrs[FieldRespHeader]; !exist {
+		t.Fatal("expected response header to be logged")
+	}
+
+	failing := &http.Client{Transport: WrapTransport(TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+		return nil, errors.New("dial failed")
+	}), LoggingTransport(true, true))}
+	if _, err := failing.Get(server.URL); err == nil {
+		t.Fatal("expected error")
+	}
+	logs.AssertField(t, "got http resp", FieldErr, "dial failed")
+	logs.AssertField(t, "got http resp", FieldOutcome, OutcomeNotSent.String())
+}
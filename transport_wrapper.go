@@ -3,20 +3,30 @@ package httpx
 import (
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"log/slog"
-
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	defaultTransprtTimeout = time.Second * 10
+	// maxErrorBodyBytes 状态码不符合预期时保留的响应体长度
+	maxErrorBodyBytes = 64 << 10
 )
 
+// defaultTransprtTimeout TimeoutTransport(0)使用的超时
+var defaultTransprtTimeout = time.Second * 10
+
+// DefaultDeadline 调用方ctx没有deadline且Builder未设置Timeout时的整体超时
+var DefaultDeadline = time.Second * 30
+
 type TransportFunc func(*http.Request) (*http.Response, error)
 
 func (t TransportFunc) RoundTrip(r *http.Request) (*http.Response, error) {
@@ -25,50 +35,86 @@ func (t TransportFunc) RoundTrip(r *http.Request) (*http.Response, error) {
 
 type TransportWrapper func(http.RoundTripper) http.RoundTripper
 
-// TimeoutTransport 添加timeout
+// TimeoutTransport 添加timeout;timeout<=0时使用默认超时,请求已经有Builder的DefaultDeadline时不再添加
 func TimeoutTransport(timeout time.Duration) TransportWrapper {
-	if timeout <= 0 {
+	useDefault := timeout <= 0
+	if useDefault {
 		timeout = defaultTransprtTimeout
 	}
-	return func(next http.RoundTripper) http.RoundTripper {
+	return NamedWrapper("timeout", timeout.String(), func(next http.RoundTripper) http.RoundTripper {
 		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			// 默认超时短于DefaultDeadline,否则总是先于DefaultDeadline触发
+			if useDefault && hasDefaultDeadline(httpReq.Context()) {
+				return next.RoundTrip(httpReq)
+			}
 			ctx, cancel := context.WithTimeout(httpReq.Context(), timeout)
-			defer cancel()
-
 			httpReq = httpReq.WithContext(ctx)
-			return next.RoundTrip(httpReq)
+			httpResp, err := next.RoundTrip(httpReq)
+			if err != nil || httpResp.StatusCode == http.StatusSwitchingProtocols {
+				cancel()
+				return httpResp, err
+			}
+			// timeout同样覆盖读取body,body关闭时才释放
+			httpResp.Body = &cancelOnClose{ReadCloser: httpResp.Body, cancel: cancel}
+			return httpResp, nil
 		})
-	}
+	})
+}
+
+// cancelOnClose body关闭时cancel对应的ctx
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
 }
 
 // HeaderTransport 添加header kv
 func HeaderTransport(key, value string) TransportWrapper {
-	return func(next http.RoundTripper) http.RoundTripper {
+	return NamedWrapper("header", key, func(next http.RoundTripper) http.RoundTripper {
 		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
 			httpReq.Header.Add(key, value)
 			return next.RoundTrip(httpReq)
 		})
-	}
+	})
 }
 
 const (
 	ContentTypeKey  = "Content-Type"
 	ContentTypeJson = "application/json"
+	ContentTypeForm = "application/x-www-form-urlencoded"
+	UserAgentKey    = "User-Agent"
 )
 
+// UserAgentTransport 请求未设置User-Agent时添加ua
+func UserAgentTransport(ua string) TransportWrapper {
+	return NamedWrapper("user_agent", ua, func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			if httpReq.Header.Get(UserAgentKey) == "" {
+				httpReq.Header.Set(UserAgentKey, ua)
+			}
+			return next.RoundTrip(httpReq)
+		})
+	})
+}
+
 // JsonTransport 添加json header
 func JsonTransport(next http.RoundTripper) http.RoundTripper {
-	return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+	return newNamedTransport(WrapperInfo{Name: "json"}, next, TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
 		if httpReq.Header.Get(ContentTypeKey) == "" {
 			httpReq.Header.Add(ContentTypeKey, ContentTypeJson)
 		}
 		return next.RoundTrip(httpReq)
-	})
+	}))
 }
 
 // HeadersTransport 添加header
 func HeadersTransport(header http.Header) TransportWrapper {
-	return func(next http.RoundTripper) http.RoundTripper {
+	return NamedWrapper("headers", headerKeys(header), func(next http.RoundTripper) http.RoundTripper {
 		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
 			for key, values := range header {
 				for _, value := range values {
@@ -77,91 +123,304 @@ func HeadersTransport(header http.Header) TransportWrapper {
 			}
 			return next.RoundTrip(httpReq)
 		})
-	}
+	})
 }
 
 // LoggingTransport 添加日志
 func LoggingTransport(loggingReqBody, loggingRespBody bool) TransportWrapper {
-	return func(next http.RoundTripper) http.RoundTripper {
-		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
-			fmt.Println("=======", httpReq.Header)
-			spanContext := trace.SpanFromContext(httpReq.Context()).SpanContext()
+	return LoggingTransportWithOptions(LoggingOptions{ReqBody: loggingReqBody, RespBody: loggingRespBody})
+}
+
+// LoggingTransportWith 使用logger输出日志,opts只使用第一个
+func LoggingTransportWith(logger *slog.Logger, opts ...LoggingOptions) TransportWrapper {
+	var opt LoggingOptions
+	if len(opts) != 0 {
+		opt = opts[0]
+	}
+	opt.Logger = logger
+	return LoggingTransportWithOptions(opt)
+}
+
+// LoggingTransportWithOptions 添加日志,header与json body中的敏感字段按opts隐藏
+func LoggingTransportWithOptions(opts LoggingOptions) TransportWrapper {
+	loggingReqBody, loggingRespBody := opts.ReqBody, opts.RespBody
+	redactor := newLogRedactor(opts)
+	maxBodyBytes := opts.maxBodyBytes()
+	return NamedWrapper("logging", fmt.Sprintf("req=%t,resp=%t", loggingReqBody, loggingRespBody), func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(func(httpReq *http.Request) (httpResp *http.Response, err error) {
+			spanContext := requestSpanContext(httpReq)
 
 			traceID := spanContext.TraceID().String()
 			spanID := spanContext.SpanID().String()
 			kvs := []interface{}{
-				"http_method", httpReq.Method,
-				"http_url", httpReq.URL.String(),
-				"traceID", traceID,
-				"spanID", spanID,
+				FieldHTTPMethod, httpReq.Method,
+				FieldHTTPURL, httpReq.URL.String(),
+				FieldTraceID, traceID,
+				FieldSpanID, spanID,
+			}
+			if hostOverridden(httpReq) {
+				kvs = append(kvs, FieldHTTPHost, httpReq.Host)
 			}
+			if opts.LogHeaders {
+				kvs = append(kvs, FieldReqHeader, redactor.header(httpReq.Header))
+			}
+			// 任何返回错误的路径都带上outcome与err
 			defer func() {
-				slog.Info("got http resp", kvs...)
+				if err != nil {
+					kvs = append(kvs, FieldOutcome, OutcomeFromError(err).String(), FieldErr, err)
+				}
+				logAt(httpReq.Context(), opts.Logger, opts.FieldMapper, opts.RespLevel, "got http resp", kvs...)
 			}()
 
 			isUpgrade := httpReq.Header.Get("Connection") == "Upgrade"
-			if !isUpgrade && loggingReqBody && httpReq.Body != nil {
-				reqData, reqBody, err := DrainBody(httpReq.Body)
-				if err != nil {
-					return nil, err
+			// 长度未知的body是流式的,记录日志会把整个body读入内存
+			isStream := httpReq.Body != nil && httpReq.Body != http.NoBody && httpReq.ContentLength <= 0
+			if isStream && loggingReqBody {
+				kvs = append(kvs, FieldReqData, "<stream>")
+			}
+			if !isUpgrade && !isStream && loggingReqBody && httpReq.Body != nil {
+				if binaryBody(httpReq.Header) {
+					kvs = append(kvs, FieldReqBodyOmitted, bodyOmittedBinary)
+				} else {
+					reqData, truncated, reqBody, err := peekBody(httpReq.Body, maxBodyBytes)
+					if err != nil {
+						return nil, withOutcome(err, OutcomeNotSent)
+					}
+					kvs = redactor.appendBody(kvs, reqLogBodyFields, reqData, truncated, httpReq.ContentLength)
+					httpReq.Body = reqBody
 				}
-				kvs = append(kvs, "req_data", string(reqData))
-				httpReq.Body = reqBody
 			}
-			slog.Info("send http req", kvs...)
-			httpResp, err := next.RoundTrip(httpReq)
+			logAt(httpReq.Context(), opts.Logger, opts.FieldMapper, opts.ReqLevel, "send http req", kvs...)
+			httpReq, tracker := trackOutcome(httpReq)
+			var timings *connTimings
+			if opts.LogTimings {
+				httpReq, timings = traceConnTimings(httpReq)
+			}
+			start := time.Now()
+			httpResp, err = next.RoundTrip(httpReq)
+			kvs = append(kvs, FieldDurationMs, durationMs(time.Since(start)))
+			if timings != nil {
+				kvs = append(kvs, timings.kvs()...)
+			}
 			if err != nil {
-				return nil, err
+				return nil, tracker.classify(err)
+			}
+			kvs = append(kvs, FieldStatusCode, httpResp.StatusCode)
+			if opts.LogHeaders {
+				kvs = append(kvs, FieldRespHeader, redactor.header(httpResp.Header))
 			}
-			kvs = append(kvs, "http_status_code", httpResp.StatusCode)
 			if !isUpgrade && loggingRespBody {
-				respData, respBody, err := DrainBody(httpResp.Body)
-				if err != nil {
-					return nil, err
+				switch {
+				case binaryBody(httpResp.Header):
+					kvs = append(kvs, FieldRespBodyOmitted, bodyOmittedBinary)
+				case poolable(httpResp.ContentLength) && (maxBodyBytes < 0 || httpResp.ContentLength <= maxBodyBytes):
+					// 小响应体读入池化buffer,并与解码共用
+					respBody, err := readPooledBody(httpResp.Body, httpResp.ContentLength)
+					if err != nil {
+						return nil, withOutcome(err, OutcomeReceived)
+					}
+					sharedRespBodyFromContext(httpReq.Context()).set(respBody)
+					httpResp.Body = respBody
+					kvs = append(kvs, FieldRespData, redactor.body(respBody.Bytes()))
+				default:
+					respData, truncated, respBody, err := peekBody(httpResp.Body, maxBodyBytes)
+					if err != nil {
+						return nil, withOutcome(err, OutcomeReceived)
+					}
+					httpResp.Body = respBody
+					kvs = redactor.appendBody(kvs, respLogBodyFields, respData, truncated, httpResp.ContentLength)
+				}
+				if respTransformedFromContext(httpReq.Context()) {
+					kvs = append(kvs, FieldRespTransformed, true)
 				}
-				kvs = append(kvs, "resp_data", string(respData))
-				httpResp.Body = respBody
 			}
 			return httpResp, nil
 		})
+	})
+}
+
+type spanNameKey struct{}
+
+// ContextWithSpanName 返回带有span名称的新context,TracingTransport使用它命名client span
+func ContextWithSpanName(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, spanNameKey{}, name)
+}
+
+type spanAttrsKey struct{}
+
+// ContextWithSpanAttributes 返回追加了attrs的新context,TracingTransport将它们设置到client span上
+func ContextWithSpanAttributes(ctx context.Context, attrs ...attribute.KeyValue) context.Context {
+	if len(attrs) == 0 {
+		return ctx
+	}
+	prev, _ := ctx.Value(spanAttrsKey{}).([]attribute.KeyValue)
+	merged := make([]attribute.KeyValue, 0, len(prev)+len(attrs))
+	merged = append(merged, prev...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, spanAttrsKey{}, merged)
+}
+
+// spanNameFormatter context中没有span名称时与otelhttp默认的名称相同
+func spanNameFormatter(_ string, httpReq *http.Request) string {
+	if name, ok := httpReq.Context().Value(spanNameKey{}).(string); ok {
+		return name
 	}
+	return "HTTP " + httpReq.Method
 }
 
 // TracingTransport 添加traceid
 func TracingTransport(serviceName string) TransportWrapper {
+	return TracingTransportWithOptions(serviceName)
+}
+
+// TracingTransportWithOptions 添加traceid,opts在默认的配置之后应用,可以覆盖span名称等
+func TracingTransportWithOptions(serviceName string, opts ...otelhttp.Option) TransportWrapper {
 	if serviceName == "" {
 		serviceName = os.Args[0]
 	}
-	return func(next http.RoundTripper) http.RoundTripper {
-		transport := otelhttp.NewTransport(next, otelhttp.WithServerName(serviceName))
+	return NamedWrapper("tracing", serviceName, func(next http.RoundTripper) http.RoundTripper {
+		// 在otelhttp创建的span上记录覆盖的Host与context中的属性
+		inner := TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
+			span := trace.SpanFromContext(httpReq.Context())
+			if hostOverridden(httpReq) {
+				span.SetAttributes(attribute.String("http.host", httpReq.Host))
+			}
+			if attrs, ok := httpReq.Context().Value(spanAttrsKey{}).([]attribute.KeyValue); ok {
+				span.SetAttributes(attrs...)
+			}
+			return next.RoundTrip(httpReq)
+		})
+		transport := otelhttp.NewTransport(inner, append([]otelhttp.Option{
+			otelhttp.WithServerName(serviceName),
+			otelhttp.WithSpanNameFormatter(spanNameFormatter),
+		}, opts...)...)
 		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
 			return transport.RoundTrip(httpReq)
 		})
-	}
+	})
+}
+
+// hostOverridden Host头与实际连接的地址不同
+func hostOverridden(httpReq *http.Request) bool {
+	return httpReq.Host != "" && httpReq.Host != httpReq.URL.Host
 }
 
 // StatusCodeTransport 添加statuscode检查
 func StatusCodeTransport(expectedStatusCode int) TransportWrapper {
-	return func(next http.RoundTripper) http.RoundTripper {
+	return NamedWrapper("status", fmt.Sprint(expectedStatusCode), func(next http.RoundTripper) http.RoundTripper {
 		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
 			httpResp, err := next.RoundTrip(httpReq)
 			if err != nil {
 				return nil, err
 			}
-			if gotStatusCode := httpResp.StatusCode; gotStatusCode != expectedStatusCode {
-				return nil, fmt.Errorf("expected statuscode:%d,got:%d", expectedStatusCode, gotStatusCode)
+			if httpResp.StatusCode != expectedStatusCode {
+				return nil, withOutcome(newErrUnexpectedStatusCode(httpReq, httpResp, []int{expectedStatusCode}, maxErrorBodyBytes), OutcomeReceived)
 			}
 			return httpResp, nil
 		})
+	})
+}
+
+// ErrUnexpectedStatusCode 响应的状态码不在预期中,Body为响应体的前maxErrorBodyBytes字节
+type ErrUnexpectedStatusCode struct {
+	Expected       []int
+	ExpectedRanges []StatusCodeRange
+	Got            int
+	Body           []byte
+	ContentType    string
+	Header         http.Header
+	Method         string
+	URL            string
+}
+
+// newErrUnexpectedStatusCode 最多保留limit字节的响应体,再丢弃至多limit字节后关闭,较短的body的连接可以被复用
+func newErrUnexpectedStatusCode(httpReq *http.Request, httpResp *http.Response, expected []int, limit int64) *ErrUnexpectedStatusCode {
+	var body []byte
+	if limit > 0 {
+		body, _ = io.ReadAll(io.LimitReader(httpResp.Body, limit))
+		io.Copy(io.Discard, io.LimitReader(httpResp.Body, limit))
+	}
+	httpResp.Body.Close()
+	return &ErrUnexpectedStatusCode{
+		Expected:    expected,
+		Got:         httpResp.StatusCode,
+		Body:        body,
+		ContentType: httpResp.Header.Get(ContentTypeKey),
+		Header:      httpResp.Header,
+		Method:      httpReq.Method,
+		URL:         httpReq.URL.Redacted(),
+	}
+}
+
+func (e *ErrUnexpectedStatusCode) Error() string {
+	expected := e.expected()
+	if len(e.Body) == 0 {
+		return fmt.Sprintf("expected statuscodes:%s,got:%d", expected, e.Got)
+	}
+	if e.ContentType == "" {
+		return fmt.Sprintf("expected statuscodes:%s,got:%d,body:%q", expected, e.Got, bodySnippet(e.Body))
 	}
+	return fmt.Sprintf("expected statuscodes:%s,got:%d,content-type:%s,body:%q", expected, e.Got, e.ContentType, bodySnippet(e.Body))
+}
+
+// expected 状态码与范围放在同一个列表中,例如[200 300-399]
+func (e *ErrUnexpectedStatusCode) expected() string {
+	if len(e.ExpectedRanges) == 0 {
+		return fmt.Sprintf("%d", e.Expected)
+	}
+	items := make([]string, 0, len(e.Expected)+len(e.ExpectedRanges))
+	for _, code := range e.Expected {
+		items = append(items, strconv.Itoa(code))
+	}
+	for _, statusRange := range e.ExpectedRanges {
+		items = append(items, statusRange.String())
+	}
+	return "[" + strings.Join(items, " ") + "]"
+}
+
+// StatusCodeRange Start到End之间(包含两端)的状态码
+type StatusCodeRange struct {
+	Start int
+	End   int
 }
 
+func (r StatusCodeRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+func (r StatusCodeRange) contains(statusCode int) bool {
+	return statusCode >= r.Start && statusCode <= r.End
+}
+
+// StatusCodesTransport 状态码不在expectedStatusCodes中时返回ErrUnexpectedStatusCode,保留前64KB的响应体
 func StatusCodesTransport(expectedStatusCodes ...int) TransportWrapper {
+	return StatusCodesTransportWithLimit(maxErrorBodyBytes, expectedStatusCodes...)
+}
+
+// StatusCodesTransportWithLimit 与StatusCodesTransport相同,错误中最多保留limit字节的响应体,<=0时不保留
+func StatusCodesTransportWithLimit(limit int64, expectedStatusCodes ...int) TransportWrapper {
+	return statusCheckTransport(limit, expectedStatusCodes, nil)
+}
+
+// StatusCodeRangeTransport 状态码不在[start,end]中时返回ErrUnexpectedStatusCode,保留前64KB的响应体
+func StatusCodeRangeTransport(start, end int) TransportWrapper {
+	return statusCheckTransport(maxErrorBodyBytes, nil, []StatusCodeRange{{Start: start, End: end}})
+}
+
+// statusCheckTransport 状态码在expectedStatusCodes中或者落在任一range中即通过
+func statusCheckTransport(limit int64, expectedStatusCodes []int, ranges []StatusCodeRange) TransportWrapper {
 	expectedStatusCodesMap := make(map[int]struct{})
 	for _, exexpectedStatusCode := range expectedStatusCodes {
 		expectedStatusCodesMap[exexpectedStatusCode] = struct{}{}
 	}
-	return func(next http.RoundTripper) http.RoundTripper {
+	detail := fmt.Sprint(expectedStatusCodes)
+	if len(ranges) != 0 {
+		detail = (&ErrUnexpectedStatusCode{Expected: expectedStatusCodes, ExpectedRanges: ranges}).expected()
+	}
+	return NamedWrapper("status", detail, func(next http.RoundTripper) http.RoundTripper {
 		return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
 
 			httpResp, err := next.RoundTrip(httpReq)
@@ -169,44 +428,46 @@ func StatusCodesTransport(expectedStatusCodes ...int) TransportWrapper {
 				return nil, err
 			}
 			gotStatusCode := httpResp.StatusCode
-			if _, exist := expectedStatusCodesMap[gotStatusCode]; !exist {
-				return nil, fmt.Errorf("expected statuscodes:%d,got:%d", expectedStatusCodes, gotStatusCode)
+			if _, exist := expectedStatusCodesMap[gotStatusCode]; exist {
+				return httpResp, nil
 			}
-			return httpResp, nil
+			for _, statusRange := range ranges {
+				if statusRange.contains(gotStatusCode) {
+					return httpResp, nil
+				}
+			}
+			statusErr := newErrUnexpectedStatusCode(httpReq, httpResp, expectedStatusCodes, limit)
+			statusErr.ExpectedRanges = ranges
+			return nil, withOutcome(statusErr, OutcomeReceived)
 		})
-	}
+	})
 }
 
+// DefaultTransportWrapper 默认的wrapper组合
 func DefaultTransportWrapper(next http.RoundTripper) TransportFunc {
-	for _, wrapper := range []TransportWrapper{
+	return TransportFunc(DefaultTransportChain(next).RoundTrip)
+}
+
+// DefaultTransportChain 与DefaultTransportWrapper相同,返回的wrapper链可以被ChainOf识别,可以作为TransportWrapper使用
+func DefaultTransportChain(next http.RoundTripper) http.RoundTripper {
+	return WrapTransportChain(next,
 		StatusCodeTransport(http.StatusOK),
 		JsonTransport,
 		LoggingTransport(true, true),
 		TracingTransport(""),
 		TimeoutTransport(defaultHandlerTimeout),
-	} {
-		next = wrapper(next)
-	}
-	return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
-		resp, err := next.RoundTrip(httpReq)
-		if err != nil {
-			return nil, err
-		}
-		return resp, nil
-
-	})
+	)
 }
 
+// WrapTransport 按顺序包装next,第一个wrapper在最内层
 func WrapTransport(next http.RoundTripper, wrappers ...TransportWrapper) TransportFunc {
+	return TransportFunc(WrapTransportChain(next, wrappers...).RoundTrip)
+}
+
+// WrapTransportChain 与WrapTransport相同,直接返回最外层的wrapper,可以被ChainOf识别
+func WrapTransportChain(next http.RoundTripper, wrappers ...TransportWrapper) http.RoundTripper {
 	for _, wrapper := range wrappers {
 		next = wrapper(next)
 	}
-	return TransportFunc(func(httpReq *http.Request) (*http.Response, error) {
-		resp, err := next.RoundTrip(httpReq)
-		if err != nil {
-			return nil, err
-		}
-		return resp, nil
-
-	})
+	return next
 }
@@ -0,0 +1,97 @@
+package httpx
+
+import (
+	"net/http"
+	stdurl "net/url"
+	"path"
+	"strings"
+)
+
+const (
+	defaultMaxPathLength  = 2048
+	defaultMaxQueryLength = 4096
+
+	// ErrorCodeKey 拒绝请求时返回的错误码header
+	ErrorCodeKey = "X-Error-Code"
+	// ErrorCodeURLInvalidChar url中含有NUL或控制字符
+	ErrorCodeURLInvalidChar = "url_invalid_char"
+	// ErrorCodeURLTooLong url的path或query超长
+	ErrorCodeURLTooLong = "url_too_long"
+)
+
+// URLNormalizeOptions URLNormalizeHandler的配置
+type URLNormalizeOptions struct {
+	// MaxPathLength path的最大长度,默认2048
+	MaxPathLength int
+	// MaxQueryLength query的最大长度,默认4096
+	MaxQueryLength int
+	// Redirect 为true时308重定向到规范的url,否则直接改写后继续处理
+	Redirect bool
+}
+
+// URLNormalizeHandler 清理path(合并重复的/、处理.与..),拒绝含控制字符以及超长的url
+// 需要放在最外层,保证路由、监控以及日志看到的都是规范后的path
+func URLNormalizeHandler(opts URLNormalizeOptions) HandlerWrapper {
+	if opts.MaxPathLength <= 0 {
+		opts.MaxPathLength = defaultMaxPathLength
+	}
+	if opts.MaxQueryLength <= 0 {
+		opts.MaxQueryLength = defaultMaxQueryLength
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+			urlObj := httpReq.URL
+			if len(urlObj.EscapedPath()) > opts.MaxPathLength || len(urlObj.RawQuery) > opts.MaxQueryLength {
+				rejectURL(w, http.StatusRequestURITooLong, ErrorCodeURLTooLong)
+				return
+			}
+			query, err := stdurl.QueryUnescape(urlObj.RawQuery)
+			if err != nil || hasControlChar(urlObj.Path) || hasControlChar(query) {
+				rejectURL(w, http.StatusBadRequest, ErrorCodeURLInvalidChar)
+				return
+			}
+			cleaned := cleanPath(urlObj.Path)
+			if cleaned == urlObj.Path {
+				next.ServeHTTP(w, httpReq)
+				return
+			}
+			normalized := *urlObj
+			normalized.Path = cleaned
+			normalized.RawPath = ""
+			if opts.Redirect {
+				http.Redirect(w, httpReq, normalized.RequestURI(), http.StatusPermanentRedirect)
+				return
+			}
+			httpReq = httpReq.Clone(httpReq.Context())
+			httpReq.URL = &normalized
+			httpReq.RequestURI = normalized.RequestURI()
+			next.ServeHTTP(w, httpReq)
+		})
+	}
+}
+
+// cleanPath 与path.Clean相同,但保留结尾的/
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+func hasControlChar(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+func rejectURL(w http.ResponseWriter, statusCode int, code string) {
+	w.Header().Set(ErrorCodeKey, code)
+	w.WriteHeader(statusCode)
+}
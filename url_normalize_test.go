@@ -0,0 +1,63 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestURLNormalizeHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		redirect   bool
+		statusCode int
+		errorCode  string
+		location   string
+		seen       string
+	}{
+		{name: "benign", target: "/api/v1/users?id=1&name=a%20b", statusCode: http.StatusOK, seen: "/api/v1/users?id=1&name=a%20b"},
+		{name: "benign encoded", target: "/files/a%2Fb", statusCode: http.StatusOK, seen: "/files/a%2Fb"},
+		{name: "trailing slash", target: "/api/users/", statusCode: http.StatusOK, seen: "/api/users/"},
+		{name: "duplicate slash", target: "//admin//users", statusCode: http.StatusOK, seen: "/admin/users"},
+		{name: "traversal", target: "//admin/../secret", statusCode: http.StatusOK, seen: "/secret"},
+		{name: "encoded traversal", target: "/static/%2e%2e/%2e%2e/etc/passwd?x=1", statusCode: http.StatusOK, seen: "/etc/passwd?x=1"},
+		{name: "redirect", target: "/a/./b//c", redirect: true, statusCode: http.StatusPermanentRedirect, location: "/a/b/c"},
+		{name: "encoded nul in path", target: "/a%00b", statusCode: http.StatusBadRequest, errorCode: ErrorCodeURLInvalidChar},
+		{name: "encoded nul in query", target: "/a?x=%00", statusCode: http.StatusBadRequest, errorCode: ErrorCodeURLInvalidChar},
+		{name: "control char", target: "/a%0d%0aSet-Cookie:x", statusCode: http.StatusBadRequest, errorCode: ErrorCodeURLInvalidChar},
+		{name: "bad query escape", target: "/a?x=%zz", statusCode: http.StatusBadRequest, errorCode: ErrorCodeURLInvalidChar},
+		{name: "overlong path", target: "/" + strings.Repeat("a", 64), statusCode: http.StatusRequestURITooLong, errorCode: ErrorCodeURLTooLong},
+		{name: "overlong query", target: "/a?q=" + strings.Repeat("a", 128), statusCode: http.StatusRequestURITooLong, errorCode: ErrorCodeURLTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := URLNormalizeHandler(URLNormalizeOptions{
+				MaxPathLength:  32,
+				MaxQueryLength: 64,
+				Redirect:       tt.redirect,
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.RequestURI != r.URL.RequestURI() {
+					t.Fatalf("expected RequestURI:%s,got:%s", r.URL.RequestURI(), r.RequestURI)
+				}
+				seen = r.RequestURI
+			}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.statusCode {
+				t.Fatalf("expected status:%d,got:%d", tt.statusCode, rec.Code)
+			}
+			if got := rec.Header().Get(ErrorCodeKey); got != tt.errorCode {
+				t.Fatalf("expected error code:%s,got:%s", tt.errorCode, got)
+			}
+			if got := rec.Header().Get("Location"); got != tt.location {
+				t.Fatalf("expected location:%s,got:%s", tt.location, got)
+			}
+			if seen != tt.seen {
+				t.Fatalf("expected seen:%s,got:%s", tt.seen, seen)
+			}
+		})
+	}
+}
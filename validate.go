@@ -0,0 +1,238 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	stdurl "net/url"
+	"strings"
+	"sync"
+)
+
+// Severity 校验问题的严重程度
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// MarshalText 以字符串形式序列化
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ValidationCheck 可选的主动检查
+type ValidationCheck int
+
+const (
+	// CheckDNS 解析host
+	CheckDNS ValidationCheck = iota
+	// CheckTLS 对https的host做TLS握手
+	CheckTLS
+	// CheckOptionsProbe 发送OPTIONS请求
+	CheckOptionsProbe
+)
+
+// ValidationIssue 一个已注册Builder的配置问题
+type ValidationIssue struct {
+	Name     string   `json:"name"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Hint     string   `json:"hint"`
+}
+
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("%s[%s]:%s,hint:%s", i.Name, i.Severity, i.Message, i.Hint)
+}
+
+type validationEntry struct {
+	name    string
+	builder Builder
+}
+
+type validationRegistry struct {
+	sync.Mutex
+	entries []validationEntry
+}
+
+var defaultValidationRegistry = &validationRegistry{}
+
+// RegisterForValidation 注册Builder,一般在init中调用,由ValidateAll统一检查
+func RegisterForValidation(name string, b Builder) {
+	defaultValidationRegistry.Lock()
+	defer defaultValidationRegistry.Unlock()
+	defaultValidationRegistry.entries = append(defaultValidationRegistry.entries, validationEntry{name: name, builder: b})
+}
+
+// ValidateAll 检查所有已注册的Builder,checks指定额外的主动检查
+func ValidateAll(ctx context.Context, checks ...ValidationCheck) []ValidationIssue {
+	defaultValidationRegistry.Lock()
+	entries := append([]validationEntry(nil), defaultValidationRegistry.entries...)
+	defaultValidationRegistry.Unlock()
+
+	var issues []ValidationIssue
+	for _, entry := range entries {
+		issues = append(issues, validateEntry(ctx, entry, checks)...)
+	}
+	return issues
+}
+
+func validateEntry(ctx context.Context, entry validationEntry, checks []ValidationCheck) []ValidationIssue {
+	newIssue := func(severity Severity, err error, hint string) ValidationIssue {
+		return ValidationIssue{Name: entry.name, Severity: severity, Message: err.Error(), Hint: hint}
+	}
+	if err := entry.builder.Validate(); err != nil {
+		return []ValidationIssue{newIssue(SeverityError, err, validateHint(err))}
+	}
+	b, ok := entry.builder.(*builder)
+	if !ok {
+		return nil
+	}
+	urlObj, _ := stdurl.Parse(b.baseURL + b.path)
+	var issues []ValidationIssue
+	for _, check := range checks {
+		switch check {
+		case CheckDNS:
+			if _, err := net.DefaultResolver.LookupHost(ctx, urlObj.Hostname()); err != nil {
+				issues = append(issues, newIssue(SeverityError, err, "check the host name and the resolver configuration"))
+			}
+		case CheckTLS:
+			if urlObj.Scheme != "https" {
+				continue
+			}
+			if err := b.checkTLS(ctx, urlObj); err != nil {
+				issues = append(issues, newIssue(SeverityError, err, "check the server certificate or configure Insecure/InsecureForHosts"))
+			}
+		case CheckOptionsProbe:
+			if err := b.probeOptions(ctx, urlObj); err != nil {
+				issues = append(issues, newIssue(SeverityWarning, err, "check that the dependency is reachable"))
+			}
+		}
+	}
+	return issues
+}
+
+var (
+	errValidateScheme    = errors.New("url scheme must be http or https")
+	errValidateHost      = errors.New("url has no host")
+	errValidatePathParam = errors.New("url has unresolved path params")
+)
+
+func validateHint(err error) string {
+	switch {
+	case errors.Is(err, errValidateScheme), errors.Is(err, errValidateHost):
+		return "set an absolute BaseURL such as https://example.com"
+	case errors.Is(err, errValidatePathParam):
+		return "replace {param} placeholders before registering"
+	}
+	return "fix the builder configuration"
+}
+
+// Validate 不发请求,检查Builder的配置
+func (b *builder) Validate() error {
+	if b.err != nil {
+		return b.err
+	}
+	path, err := expandPathParams(b.path, b.pathParams)
+	if err != nil {
+		return err
+	}
+	url := b.baseURL + path
+	urlObj, err := stdurl.Parse(url)
+	if err != nil {
+		return err
+	}
+	if urlObj.Scheme != "http" && urlObj.Scheme != "https" {
+		return fmt.Errorf("%w:%s", errValidateScheme, url)
+	}
+	if urlObj.Host == "" {
+		return fmt.Errorf("%w:%s", errValidateHost, url)
+	}
+	if strings.ContainsAny(urlObj.Path, "{}") {
+		return fmt.Errorf("%w:%s", errValidatePathParam, urlObj.Path)
+	}
+	if b.strict {
+		return errors.Join(b.optionConflicts(nil)...)
+	}
+	return nil
+}
+
+func (b *builder) checkTLS(ctx context.Context, urlObj *stdurl.URL) error {
+	addr := urlObj.Host
+	if urlObj.Port() == "" {
+		addr = net.JoinHostPort(urlObj.Hostname(), "443")
+	}
+	// 与发出请求的transport使用相同的TLS配置
+	var config *tls.Config
+	if transport, ok := b.transport.(*http.Transport); ok {
+		config = transport.TLSClientConfig
+	} else if b.transport == nil {
+		config = b.transportConfig().clientTLSConfig()
+	}
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config = config.Clone()
+	if config.RootCAs == nil && insecureHostMatcher(b.insecureHosts).match(addr) {
+		config.InsecureSkipVerify = true
+	}
+	dialer := &tls.Dialer{Config: config}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (b *builder) probeOptions(ctx context.Context, urlObj *stdurl.URL) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodOptions, urlObj.String(), nil)
+	if err != nil {
+		return err
+	}
+	httpResp, err := b.buildTransport(true).RoundTrip(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("options probe got status:%d", httpResp.StatusCode)
+	}
+	return nil
+}
+
+// ReadinessHandler 有SeverityError级别的问题时返回503,响应体为问题列表
+func ReadinessHandler(checks ...ValidationCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issues := ValidateAll(r.Context(), checks...)
+		statusCode := http.StatusOK
+		for _, issue := range issues {
+			if issue.Severity == SeverityError {
+				statusCode = http.StatusServiceUnavailable
+				break
+			}
+		}
+		if issues == nil {
+			issues = []ValidationIssue{}
+		}
+		data, err := json.Marshal(issues)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(ContentTypeKey, ContentTypeJson)
+		w.WriteHeader(statusCode)
+		w.Write(data)
+	})
+}
@@ -0,0 +1,84 @@
+package httpx
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func resetValidationRegistry(t *testing.T) {
+	prev := defaultValidationRegistry
+	defaultValidationRegistry = &validationRegistry{}
+	t.Cleanup(func() {
+		defaultValidationRegistry = prev
+	})
+}
+
+func TestValidateAll(t *testing.T) {
+	resetValidationRegistry(t)
+	server := testkit.NewServer(t, testkit.Echo())
+	RegisterForValidation("good", BaseURL(server.URL).Get("/users"))
+	RegisterForValidation("relative", Get("/users"))
+	RegisterForValidation("path-param", BaseURL(server.URL).Get("/users/{id}"))
+	RegisterForValidation("bad-url", Get("http://[::1"))
+
+	issues := ValidateAll(context.Background(), CheckDNS, CheckOptionsProbe)
+	expected := []string{"relative", "path-param", "bad-url"}
+	if len(issues) != len(expected) {
+		t.Fatalf("expected issues:%v,got:%v", expected, issues)
+	}
+	for idx, issue := range issues {
+		if issue.Name != expected[idx] || issue.Severity != SeverityError || issue.Hint == "" {
+			t.Fatalf("unexpected issue:%s", issue)
+		}
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	resetValidationRegistry(t)
+	server := testkit.NewServer(t, testkit.Echo())
+	RegisterForValidation("good", BaseURL(server.URL).Get("/users"))
+
+	rec := httptest.NewRecorder()
+	ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status:%d,got:%d", http.StatusOK, rec.Code)
+	}
+
+	RegisterForValidation("relative", Get("/users"))
+	rec = httptest.NewRecorder()
+	ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status:%d,got:%d", http.StatusServiceUnavailable, rec.Code)
+	}
+	var issues []map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &issues); err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0]["name"] != "relative" || issues[0]["severity"] != "error" {
+		t.Fatalf("unexpected issues:%v", issues)
+	}
+}
+
+func TestValidateTLSPrivateCA(t *testing.T) {
+	resetValidationRegistry(t)
+	server := httptest.NewTLSServer(testkit.Echo())
+	t.Cleanup(server.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	RegisterForValidation("private-ca", BaseURL(server.URL).WithRootCAs(pool).Get("/users"))
+	if issues := ValidateAll(context.Background(), CheckTLS); len(issues) != 0 {
+		t.Fatalf("expected probe to use the builder tls config,got:%v", issues)
+	}
+
+	RegisterForValidation("untrusted", BaseURL(server.URL).Get("/users"))
+	issues := ValidateAll(context.Background(), CheckTLS)
+	if len(issues) != 1 || issues[0].Name != "untrusted" {
+		t.Fatalf("expected untrusted certificate issue,got:%v", issues)
+	}
+}
@@ -0,0 +1,81 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// RespValidator 在解码后校验响应,ctx为请求的context,decoded为WithResp的目标,raw为原始响应体
+type RespValidator func(ctx context.Context, decoded interface{}, raw []byte) error
+
+// ErrResponseValidation 响应校验失败
+type ErrResponseValidation struct {
+	Raw []byte
+	Err error
+}
+
+func (e *ErrResponseValidation) Error() string {
+	return fmt.Sprintf("response validation failed:%s", e.Err)
+}
+
+func (e *ErrResponseValidation) Unwrap() error {
+	return e.Err
+}
+
+// ValidateResp 只关心解码结果的RespValidator
+func ValidateResp(fn func(resp interface{}) error) RespValidator {
+	return func(ctx context.Context, decoded interface{}, raw []byte) error {
+		return fn(decoded)
+	}
+}
+
+// chainRespValidators 按顺序执行,返回第一个错误
+func chainRespValidators(first, next RespValidator) RespValidator {
+	if first == nil {
+		return next
+	}
+	if next == nil {
+		return first
+	}
+	return func(ctx context.Context, decoded interface{}, raw []byte) error {
+		if err := first(ctx, decoded, raw); err != nil {
+			return err
+		}
+		return next(ctx, decoded, raw)
+	}
+}
+
+// StrictFieldsValidator 响应中出现目标结构体没有的字段时报错
+func StrictFieldsValidator() RespValidator {
+	return func(ctx context.Context, decoded interface{}, raw []byte) error {
+		typ := reflect.TypeOf(decoded)
+		if typ == nil || typ.Kind() != reflect.Pointer {
+			return nil
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		return dec.Decode(reflect.New(typ.Elem()).Interface())
+	}
+}
+
+// ReportOnly 校验失败时只使用请求的logger打印warning日志,不让请求失败
+func ReportOnly(validator RespValidator) RespValidator {
+	return func(ctx context.Context, decoded interface{}, raw []byte) error {
+		if err := validator(ctx, decoded, raw); err != nil {
+			logWarn(ctx, "response validation failed", FieldErr, err)
+		}
+		return nil
+	}
+}
+
+// respContext 响应对应的请求的context,带有请求的logger与trace
+func respContext(httpResp *http.Response) context.Context {
+	if httpResp.Request == nil {
+		return context.Background()
+	}
+	return httpResp.Request.Context()
+}
@@ -0,0 +1,112 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/internal/testkit"
+)
+
+func TestRespValidator(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/added":
+			w.Write([]byte(`{"id":1,"name":"a","email":"a@b.c"}`))
+		case "/removed":
+			w.Write([]byte(`{"id":1}`))
+		}
+	}))
+
+	logs := testkit.CaptureLogs(t)
+	got := &user{}
+	ctx := AppendLogAttrs(context.Background(), slog.String("tenant", "t1"))
+	if err := Get(server.URL + "/added").
+		WithResp(got).
+		WithRespValidator(ReportOnly(StrictFieldsValidator())).
+		Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "a" {
+		t.Fatalf("expected name:a,got:%s", got.Name)
+	}
+	records := logs.Find("response validation failed")
+	if len(records) != 1 || !strings.Contains(records[0].Attrs[FieldErr].(error).Error(), "email") {
+		t.Fatalf("expected warning about unknown field email,got:%v", records)
+	}
+	// 使用请求的context打印日志,带上调用方的日志属性
+	if records[0].Attrs["tenant"] != "t1" {
+		t.Fatalf("expected request log attrs in warning,got:%v", records[0].Attrs)
+	}
+
+	requireName := func(ctx context.Context, decoded interface{}, raw []byte) error {
+		if !strings.Contains(string(raw), `"name"`) {
+			return errors.New("missing required field name")
+		}
+		return nil
+	}
+	err := Get(server.URL + "/removed").
+		WithResp(&user{}).
+		WithRespValidator(requireName).
+		Do(context.Background())
+	var validationErr *ErrResponseValidation
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ErrResponseValidation,got:%v", err)
+	}
+	if string(validationErr.Raw) != `{"id":1}` {
+		t.Fatalf("expected raw body,got:%s", validationErr.Raw)
+	}
+	if OutcomeFromError(err) != OutcomeReceived {
+		t.Fatalf("expected outcome received,got:%s", OutcomeFromError(err))
+	}
+}
+
+func TestRespValidatorChain(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	var calls int32
+	server := testkit.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"id":1,"name":"a"}`))
+	}))
+	var order []string
+	validator := func(name string, err error) RespValidator {
+		return ValidateResp(func(resp interface{}) error {
+			if resp.(*user).Name != "a" {
+				t.Fatalf("expected decoded resp,got:%+v", resp)
+			}
+			order = append(order, name)
+			return err
+		})
+	}
+	versionErr := errors.New("version mismatch")
+	err := Get(server.URL).
+		WithResp(&user{}).
+		ResiliencePolicy(ResiliencePolicy{Retries: 2, RetryBackoff: PolicyDuration(time.Millisecond)}).
+		WithRespValidator(validator("first", nil)).
+		WithRespValidator(validator("second", versionErr)).
+		WithRespValidator(validator("third", nil)).
+		Do(context.Background())
+	var validationErr *ErrResponseValidation
+	if !errors.As(err, &validationErr) || !errors.Is(err, versionErr) {
+		t.Fatalf("expected ErrResponseValidation,got:%v", err)
+	}
+	if strings.Join(order, ",") != "first,second" {
+		t.Fatalf("expected first,second,got:%v", order)
+	}
+	// 校验失败不重试
+	if calls != 1 {
+		t.Fatalf("expected 1 call,got:%d", calls)
+	}
+}
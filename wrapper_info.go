@@ -0,0 +1,67 @@
+package httpx
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// WrapperInfo TransportWrapper的名称与配置摘要
+type WrapperInfo struct {
+	Name   string
+	Config string
+}
+
+// namedTransport 记录wrapper的身份以及被包装的下一层,用于ChainOf
+type namedTransport struct {
+	info WrapperInfo
+	next http.RoundTripper
+	rt   http.RoundTripper
+}
+
+func newNamedTransport(info WrapperInfo, next, rt http.RoundTripper) http.RoundTripper {
+	return &namedTransport{info: info, next: next, rt: rt}
+}
+
+func (t *namedTransport) RoundTrip(httpReq *http.Request) (*http.Response, error) {
+	return t.rt.RoundTrip(httpReq)
+}
+
+// WrapperName 返回wrapper的名称
+func (t *namedTransport) WrapperName() string {
+	return t.info.Name
+}
+
+// Unwrap 返回被包装的下一层
+func (t *namedTransport) Unwrap() http.RoundTripper {
+	return t.next
+}
+
+// NamedWrapper 为自定义的wrapper附加名称,使其可以被ChainOf识别
+func NamedWrapper(name, config string, wrapper TransportWrapper) TransportWrapper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return newNamedTransport(WrapperInfo{Name: name, Config: config}, next, wrapper(next))
+	}
+}
+
+// ChainOf 从外到内返回rt上的wrapper,遇到未命名的wrapper或底层transport时停止
+func ChainOf(rt http.RoundTripper) []WrapperInfo {
+	var chain []WrapperInfo
+	for {
+		named, ok := rt.(*namedTransport)
+		if !ok {
+			return chain
+		}
+		chain = append(chain, named.info)
+		rt = named.next
+	}
+}
+
+func headerKeys(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
@@ -0,0 +1,57 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/httpx/httpxtest"
+)
+
+func TestChainOfDefault(t *testing.T) {
+	rt, err := Get("http://example.com").BuildTransport(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpxtest.AssertChain(t, rt, "timeout", "tracing", "logging", "json", "status", "deprecation_watch", "stale_conn_retry")
+	chain := ChainOf(rt)
+	if chain[0].Config != defaultTransprtTimeout.String() || chain[4].Config != "[200]" {
+		t.Fatalf("unexpected chain config:%+v", chain)
+	}
+}
+
+func TestChainOfCustomized(t *testing.T) {
+	rt, err := Get("http://example.com").
+		Tracing(false).
+		ContentType("application/xml").
+		Timeout(time.Second).
+		ExpectedStatusCodes(http.StatusOK, http.StatusCreated).
+		BuildTransport(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpxtest.AssertChain(t, rt, "timeout", "logging", "status", "deprecation_watch", "stale_conn_retry")
+	chain := ChainOf(rt)
+	if chain[0].Config != "1s" || chain[2].Config != "[200 201]" {
+		t.Fatalf("unexpected chain config:%+v", chain)
+	}
+
+	custom := NamedWrapper("custom", "v1", func(next http.RoundTripper) http.RoundTripper {
+		return next
+	})
+	anonymous := func(next http.RoundTripper) http.RoundTripper {
+		return TransportFunc(next.RoundTrip)
+	}
+	rt = BuildTransport(HeaderTransport("X-A", "a"), anonymous, custom, DefaultTransportChain)
+	httpxtest.AssertChain(t, rt, "timeout", "tracing", "logging", "json", "status", "custom")
+}
+
+func TestWrapTransportCompat(t *testing.T) {
+	var wrap func(http.RoundTripper) TransportFunc = DefaultTransportWrapper
+	if chain := ChainOf(wrap(http.DefaultTransport)); len(chain) != 0 {
+		t.Fatalf("expected opaque TransportFunc,got:%+v", chain)
+	}
+	rt := WrapTransportChain(http.DefaultTransport, HeaderTransport("X-A", "a"), TimeoutTransport(time.Second))
+	httpxtest.AssertChain(t, rt, "timeout", "header")
+}